@@ -0,0 +1,217 @@
+package spannerdef
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// executableHash identifies the running spannerdef build. It is mixed into
+// cache keys so that a cached Schema is never reused by a build that may
+// parse the same input differently.
+var executableHash = sync.OnceValue(func() string {
+	path, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+})
+
+// schemaCacheKey returns the cache file name for the given DDLs
+func schemaCacheKey(ddls string) string {
+	h := sha256.New()
+	h.Write([]byte(executableHash()))
+	h.Write([]byte{0})
+	h.Write([]byte(ddls))
+	return hex.EncodeToString(h.Sum(nil)) + ".gob"
+}
+
+// parseDDLsCached is ParseDDLs backed by an on-disk cache in dir, keyed by
+// the content hash of ddls. Caching is skipped when dir is empty. A missing
+// or unreadable cache entry is never an error; the DDLs are parsed again.
+func parseDDLsCached(ddls string, dir string) (*Schema, error) {
+	if dir == "" {
+		return ParseDDLs(ddls)
+	}
+
+	path := filepath.Join(dir, schemaCacheKey(ddls))
+	if buf, err := os.ReadFile(path); err == nil {
+		var schema Schema
+		if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&schema); err == nil {
+			restoreEmptyMaps(&schema)
+			return &schema, nil
+		}
+	}
+
+	schema, err := ParseDDLs(ddls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failing to write the cache only costs a re-parse next time
+	_ = writeSchemaCache(path, schema)
+	return schema, nil
+}
+
+// restoreEmptyMaps re-creates the maps gob drops when they are empty, so a
+// cached Schema is indistinguishable from a freshly parsed one.
+func restoreEmptyMaps(schema *Schema) {
+	if schema.Tables == nil {
+		schema.Tables = make(map[string]*Table)
+	}
+	if schema.Indexes == nil {
+		schema.Indexes = make(map[string]*Index)
+	}
+	if schema.VectorIndexes == nil {
+		schema.VectorIndexes = make(map[string]*VectorIndex)
+	}
+	for _, index := range schema.VectorIndexes {
+		if index.Options == nil {
+			index.Options = make(map[string]string)
+		}
+	}
+	if schema.Views == nil {
+		schema.Views = make(map[string]*View)
+	}
+	if schema.ChangeStreams == nil {
+		schema.ChangeStreams = make(map[string]*ChangeStream)
+	}
+	if schema.Roles == nil {
+		schema.Roles = make(map[string]*Role)
+	}
+	for _, role := range schema.Roles {
+		if role.Privileges == nil {
+			role.Privileges = make(map[string]bool)
+		}
+	}
+	if schema.NamedSchemas == nil {
+		schema.NamedSchemas = make(map[string]*NamedSchema)
+	}
+	if schema.Sequences == nil {
+		schema.Sequences = make(map[string]*Sequence)
+	}
+	for _, sequence := range schema.Sequences {
+		if sequence.Options == nil {
+			sequence.Options = make(map[string]string)
+		}
+	}
+	for _, stream := range schema.ChangeStreams {
+		if stream.Tables == nil {
+			stream.Tables = make(map[string]string)
+		}
+		if stream.Options == nil {
+			stream.Options = make(map[string]string)
+		}
+	}
+	for _, table := range schema.Tables {
+		if table.Columns == nil {
+			table.Columns = make(map[string]*Column)
+		}
+		if table.Constraints == nil {
+			table.Constraints = make(map[string]*Constraint)
+		}
+	}
+}
+
+// writeSchemaCache writes schema to path atomically so that concurrent runs
+// never observe a partially written entry.
+func writeSchemaCache(path string, schema *Schema) error {
+	return writeCache(path, schema)
+}
+
+// cachedPlan is the outcome of planning, as stored by planDDLsCached
+type cachedPlan struct {
+	DDLs     []string
+	Warnings []Warning
+}
+
+// planCacheKey returns the cache file name of the plan from currentDDLs to
+// desiredDDLs for database, which ALTER DATABASE statements name. Every
+// config field but CacheDir and Freeze, which don't change the plan, is
+// part of the key, so that options such as recreate_primary_keys, which
+// decide whether planning fails, can't be bypassed by a plan cached with
+// other options, and fields added later are part of it too.
+func planCacheKey(desiredDDLs, currentDDLs, database string, config GeneratorConfig) string {
+	h := sha256.New()
+	h.Write([]byte(executableHash()))
+	for _, s := range []string{desiredDDLs, currentDDLs, database} {
+		h.Write([]byte{0})
+		h.Write([]byte(s))
+	}
+	h.Write([]byte{0})
+	config.CacheDir, config.Freeze = "", ""
+	fmt.Fprintf(h, "%#v", config)
+	return hex.EncodeToString(h.Sum(nil)) + ".plan.gob"
+}
+
+// planDDLsCached is planDDLs backed by the cache in config.CacheDir, keyed
+// by the content hash of both schemas and the config, so a CI job
+// re-checking an unchanged pull request against an unchanged database
+// skips diffing. Only plans that passed the checks of planDDLs are cached,
+// and the checks depend on nothing but the key. Caching is skipped when the
+// directory is empty.
+func planDDLsCached(desiredDDLs, currentDDLs string, current, desired *Schema, config GeneratorConfig) ([]string, []Warning, error) {
+	if config.CacheDir == "" {
+		return planDDLs(desiredDDLs, current, desired, config)
+	}
+
+	path := filepath.Join(config.CacheDir, planCacheKey(desiredDDLs, currentDDLs, current.DatabaseName, config))
+	if buf, err := os.ReadFile(path); err == nil {
+		var plan cachedPlan
+		if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&plan); err == nil {
+			return plan.DDLs, plan.Warnings, nil
+		}
+	}
+
+	ddls, warnings, err := planDDLs(desiredDDLs, current, desired, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Failing to write the cache only costs a re-plan next time
+	_ = writeCache(path, &cachedPlan{DDLs: ddls, Warnings: warnings})
+	return ddls, warnings, nil
+}
+
+// writeCache writes v to path atomically so that concurrent runs never
+// observe a partially written entry.
+func writeCache(path string, v any) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
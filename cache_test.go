@@ -0,0 +1,92 @@
+package spannerdef
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDDLsCached(t *testing.T) {
+	dir := t.TempDir()
+	ddl := `
+		CREATE TABLE users (
+			id INT64 NOT NULL,
+			name STRING(100)
+		) PRIMARY KEY (id);
+
+		CREATE INDEX idx_name ON users (name);
+	`
+
+	parsed, err := parseDDLsCached(ddl, dir)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, schemaCacheKey(ddl))
+	_, err = os.Stat(path)
+	require.NoError(t, err, "cache entry should be written")
+
+	cached, err := parseDDLsCached(ddl, dir)
+	require.NoError(t, err)
+	assert.Equal(t, parsed, cached)
+	assert.NotNil(t, cached.Tables["users"].Constraints)
+}
+
+func TestParseDDLsCached_CorruptEntry(t *testing.T) {
+	dir := t.TempDir()
+	ddl := "CREATE TABLE users (id INT64 NOT NULL) PRIMARY KEY (id)"
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, schemaCacheKey(ddl)), []byte("garbage"), 0o644))
+
+	schema, err := parseDDLsCached(ddl, dir)
+	require.NoError(t, err)
+	assert.Contains(t, schema.Tables, "users")
+}
+
+func TestParseDDLsCached_ParseError(t *testing.T) {
+	_, err := parseDDLsCached("CREATE TABLE", t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestPlanDDLsCached(t *testing.T) {
+	config := GeneratorConfig{CacheDir: t.TempDir()}
+	current := "CREATE TABLE users (id INT64 NOT NULL) PRIMARY KEY (id)"
+	desired := "CREATE TABLE users (id INT64 NOT NULL, name STRING(100)) PRIMARY KEY (id)"
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALTER TABLE users ADD COLUMN name STRING(100)"}, ddls)
+	assert.Empty(t, warnings)
+
+	path := filepath.Join(config.CacheDir, planCacheKey(desired, current, "", config))
+	_, err = os.Stat(path)
+	require.NoError(t, err, "cache entry should be written")
+
+	// A hit returns the cached plan without diffing again
+	require.NoError(t, writeCache(path, &cachedPlan{DDLs: []string{"cached"}}))
+	ddls, _, err = GenerateIdempotentDDLs(desired, current, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cached"}, ddls)
+
+	// Options changing the plan don't share its entry
+	config.IfNotExists = true
+	ddls, _, err = GenerateIdempotentDDLs(desired, current, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALTER TABLE users ADD COLUMN IF NOT EXISTS name STRING(100)"}, ddls)
+}
+
+func TestPlanDDLsCached_RecreatePrimaryKeys(t *testing.T) {
+	config := GeneratorConfig{CacheDir: t.TempDir(), RecreatePrimaryKeys: true}
+	current := "CREATE TABLE users (id INT64 NOT NULL, name STRING(100) NOT NULL) PRIMARY KEY (id)"
+	desired := "CREATE TABLE users (id INT64 NOT NULL, name STRING(100) NOT NULL) PRIMARY KEY (name)"
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, config)
+	require.NoError(t, err)
+	assert.Contains(t, ddls, "DROP TABLE users")
+
+	// The plan cached with the option doesn't bypass the check without it
+	config.RecreatePrimaryKeys = false
+	_, _, err = GenerateIdempotentDDLs(desired, current, config)
+	assert.ErrorContains(t, err, "primary key of users differs")
+}
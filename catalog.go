@@ -0,0 +1,106 @@
+package spannerdef
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Catalog formats supported by WriteCatalog
+const (
+	CatalogCSV   = "csv"
+	CatalogJSONL = "jsonl"
+)
+
+// CatalogColumn is the column-level metadata exported for data catalogs
+type CatalogColumn struct {
+	Table      string   `json:"table"`
+	Column     string   `json:"column"`
+	Type       string   `json:"type"`
+	Nullable   bool     `json:"nullable"`
+	Default    string   `json:"default,omitempty"`
+	PrimaryKey bool     `json:"primary_key"`
+	Indexes    []string `json:"indexes,omitempty"`
+	Owner      string   `json:"owner,omitempty"` // owner of the table
+}
+
+// catalogCSVHeader is the header row of the CSV format
+var catalogCSVHeader = []string{"table", "column", "type", "nullable", "default", "primary_key", "indexes", "owner"}
+
+// CatalogColumns flattens schema into one entry per column, ordered by table
+// name and column position.
+func CatalogColumns(schema *Schema) []CatalogColumn {
+	indexes := make(map[string]map[string][]string) // table -> column -> index names
+	for _, name := range sortedKeys(schema.Indexes) {
+		index := schema.Indexes[name]
+		if indexes[index.TableName] == nil {
+			indexes[index.TableName] = make(map[string][]string)
+		}
+		for _, col := range index.Columns {
+			indexes[index.TableName][col] = append(indexes[index.TableName][col], name)
+		}
+	}
+
+	var columns []CatalogColumn
+	for _, tableName := range sortedKeys(schema.Tables) {
+		table := schema.Tables[tableName]
+		for _, col := range sortedColumns(table) {
+			columns = append(columns, CatalogColumn{
+				Table:      table.Name,
+				Column:     col.Name,
+				Type:       col.Type,
+				Nullable:   !col.NotNull,
+				Default:    col.Default,
+				PrimaryKey: slices.Contains(table.PrimaryKey, col.Name),
+				Indexes:    indexes[tableName][col.Name],
+				Owner:      table.Owner,
+			})
+		}
+	}
+	return columns
+}
+
+// WriteCatalog writes the column-level metadata of schema to w as CSV or
+// JSON Lines
+func WriteCatalog(w io.Writer, schema *Schema, format string) error {
+	columns := CatalogColumns(schema)
+
+	switch format {
+	case CatalogCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(catalogCSVHeader); err != nil {
+			return err
+		}
+		for _, col := range columns {
+			record := []string{
+				col.Table,
+				col.Column,
+				col.Type,
+				strconv.FormatBool(col.Nullable),
+				col.Default,
+				strconv.FormatBool(col.PrimaryKey),
+				strings.Join(col.Indexes, ";"),
+				col.Owner,
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case CatalogJSONL:
+		enc := json.NewEncoder(w)
+		for _, col := range columns {
+			if err := enc.Encode(col); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown catalog format: %s", format)
+	}
+}
@@ -0,0 +1,51 @@
+package spannerdef
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const catalogTestDDLs = `
+-- owner: team-accounts
+CREATE TABLE Users (
+	Id INT64 NOT NULL,
+	Email STRING(255) NOT NULL,
+	Status STRING(20) DEFAULT ("active"),
+) PRIMARY KEY (Id);
+
+CREATE UNIQUE INDEX IdxUsersEmail ON Users (Email);
+CREATE INDEX IdxUsersStatusEmail ON Users (Status, Email);
+`
+
+func TestWriteCatalog_CSV(t *testing.T) {
+	schema, err := ParseDDLs(catalogTestDDLs)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCatalog(&buf, schema, CatalogCSV))
+	assert.Equal(t, `table,column,type,nullable,default,primary_key,indexes,owner
+Users,Id,INT64,false,,true,,team-accounts
+Users,Email,STRING(255),false,,false,IdxUsersEmail;IdxUsersStatusEmail,team-accounts
+Users,Status,STRING(20),true,"(""active"")",false,IdxUsersStatusEmail,team-accounts
+`, buf.String())
+}
+
+func TestWriteCatalog_JSONL(t *testing.T) {
+	schema, err := ParseDDLs(catalogTestDDLs)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCatalog(&buf, schema, CatalogJSONL))
+	assert.Equal(t, `{"table":"Users","column":"Id","type":"INT64","nullable":false,"primary_key":true,"owner":"team-accounts"}
+{"table":"Users","column":"Email","type":"STRING(255)","nullable":false,"primary_key":false,"indexes":["IdxUsersEmail","IdxUsersStatusEmail"],"owner":"team-accounts"}
+{"table":"Users","column":"Status","type":"STRING(20)","nullable":true,"default":"(\"active\")","primary_key":false,"indexes":["IdxUsersStatusEmail"],"owner":"team-accounts"}
+`, buf.String())
+}
+
+func TestWriteCatalog_UnknownFormat(t *testing.T) {
+	err := WriteCatalog(&bytes.Buffer{}, &Schema{}, "xml")
+	assert.EqualError(t, err, "unknown catalog format: xml")
+}
@@ -0,0 +1,291 @@
+package spannerdef
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// ChangeStream represents a Spanner change stream
+type ChangeStream struct {
+	Name string
+	All  bool // FOR ALL
+	// Tables are the watched tables, each with its watched columns as a
+	// list such as "(Name, Email)", "()" for the key columns only, or ""
+	// for all columns. Empty, with All unset, for a stream watching nothing.
+	Tables map[string]string
+	// Options are the values of the options set on the stream by name,
+	// e.g. "'7d'" for retention_period
+	Options map[string]string
+	Pos     int    // byte offset of the definition in the parsed DDLs
+	Owner   string // team from an "-- owner:" annotation, if any
+}
+
+// processCreateChangeStream processes CREATE CHANGE STREAM statement
+func processCreateChangeStream(schema *Schema, stmt *ast.CreateChangeStream) {
+	stream := &ChangeStream{
+		Name:    stmt.Name.Name,
+		Tables:  make(map[string]string),
+		Options: make(map[string]string),
+		Pos:     int(stmt.Pos()),
+	}
+	switch f := stmt.For.(type) {
+	case *ast.ChangeStreamForAll:
+		stream.All = true
+	case *ast.ChangeStreamForTables:
+		for _, table := range f.Tables {
+			stream.Tables[table.TableName.Name] = watchedColumns(table)
+		}
+	}
+	if stmt.Options != nil {
+		for _, option := range stmt.Options.Records {
+			stream.Options[option.Name.Name] = option.Value.SQL()
+		}
+	}
+	schema.ChangeStreams[stream.Name] = stream
+}
+
+// watchedColumns formats the columns of table watched by a change stream
+// as stored in ChangeStream.Tables
+func watchedColumns(table *ast.ChangeStreamForTable) string {
+	if table.Rparen.Invalid() {
+		return ""
+	}
+	columns := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		columns[i] = column.Name
+	}
+	sort.Strings(columns)
+	return "(" + strings.Join(columns, ", ") + ")"
+}
+
+// generateCreateChangeStream generates CREATE CHANGE STREAM DDL
+func generateCreateChangeStream(stream *ChangeStream) string {
+	ddl := "CREATE CHANGE STREAM " + stream.Name
+	if clause := forClause(stream); clause != "" {
+		ddl += " " + clause
+	}
+	if len(stream.Options) > 0 {
+		options := make([]string, 0, len(stream.Options))
+		for _, name := range sortedKeys(stream.Options) {
+			options = append(options, name+" = "+stream.Options[name])
+		}
+		ddl += " OPTIONS (" + strings.Join(options, ", ") + ")"
+	}
+	return ddl
+}
+
+// forClause returns the FOR clause of stream, or "" if it watches nothing
+func forClause(stream *ChangeStream) string {
+	if stream.All {
+		return "FOR ALL"
+	}
+	if len(stream.Tables) == 0 {
+		return ""
+	}
+	tables := make([]string, 0, len(stream.Tables))
+	for _, name := range sortedKeys(stream.Tables) {
+		tables = append(tables, name+stream.Tables[name])
+	}
+	return "FOR " + strings.Join(tables, ", ")
+}
+
+// changeStreamsEqual compares the definitions of two change streams
+func changeStreamsEqual(current, desired *ChangeStream) bool {
+	return generateCreateChangeStream(current) == generateCreateChangeStream(desired)
+}
+
+// generateAlterChangeStreamDDLs generates DDLs to change what existing
+// change streams watch and their options. Spanner requires the watched
+// tables and columns to exist, and those that are dropped not to be
+// watched, so the statements are split: early ones run before tables and
+// columns are dropped, late ones after they are added. A stream that
+// stops watching a dropped table and starts watching a new one is first
+// set to watch what exists on both sides.
+func generateAlterChangeStreamDDLs(current, desired *Schema) (early, late []string) {
+	for _, name := range sortedKeys(desired.ChangeStreams) {
+		currentStream, exists := current.ChangeStreams[name]
+		if !exists {
+			continue
+		}
+		desiredStream := desired.ChangeStreams[name]
+
+		if options := changedOptions(currentStream.Options, desiredStream.Options); options != "" {
+			early = append(early, fmt.Sprintf("ALTER CHANGE STREAM %s SET OPTIONS (%s)", name, options))
+		}
+
+		if forClause(currentStream) == forClause(desiredStream) {
+			continue
+		}
+		existing := restrictWatch(desiredStream, current)
+		if forClause(existing) == forClause(desiredStream) {
+			early = append(early, generateSetFor(desiredStream))
+			continue
+		}
+		if watchesDropped(currentStream, desired) && forClause(existing) != forClause(currentStream) {
+			early = append(early, generateSetFor(existing))
+		}
+		late = append(late, generateSetFor(desiredStream))
+	}
+	return early, late
+}
+
+// generateSetFor generates the ALTER CHANGE STREAM DDL making the stream
+// watch what stream does
+func generateSetFor(stream *ChangeStream) string {
+	if clause := forClause(stream); clause != "" {
+		return fmt.Sprintf("ALTER CHANGE STREAM %s SET %s", stream.Name, clause)
+	}
+	return fmt.Sprintf("ALTER CHANGE STREAM %s DROP FOR ALL", stream.Name)
+}
+
+// changedOptions returns the assignments turning the current options into
+// the desired ones, with removed options set to null, or "" if they are
+// the same
+func changedOptions(current, desired map[string]string) string {
+	var assignments []string
+	for _, name := range unionKeys(current, desired) {
+		value, ok := desired[name]
+		if !ok {
+			value = "null"
+		}
+		if current[name] != desired[name] {
+			assignments = append(assignments, name+" = "+value)
+		}
+	}
+	return strings.Join(assignments, ", ")
+}
+
+// restrictWatch returns a copy of stream watching only the tables and
+// columns that exist in schema. A table none of whose listed columns exist
+// is watched for its key columns only.
+func restrictWatch(stream *ChangeStream, schema *Schema) *ChangeStream {
+	restricted := *stream
+	restricted.Tables = make(map[string]string)
+	for name, columns := range stream.Tables {
+		table, ok := schema.Tables[name]
+		if !ok {
+			continue
+		}
+		var kept []string
+		for _, column := range splitWatchedColumns(columns) {
+			if _, ok := table.Columns[column]; ok {
+				kept = append(kept, column)
+			}
+		}
+		if columns != "" {
+			columns = "(" + strings.Join(kept, ", ") + ")"
+		}
+		restricted.Tables[name] = columns
+	}
+	return &restricted
+}
+
+// watchesDropped reports whether stream explicitly watches a table or a
+// column that isn't in schema
+func watchesDropped(stream *ChangeStream, schema *Schema) bool {
+	for name, columns := range stream.Tables {
+		table, ok := schema.Tables[name]
+		if !ok {
+			return true
+		}
+		for _, column := range splitWatchedColumns(columns) {
+			if _, ok := table.Columns[column]; !ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitWatchedColumns returns the columns of a ChangeStream.Tables value
+func splitWatchedColumns(columns string) []string {
+	columns = strings.Trim(columns, "()")
+	if columns == "" {
+		return nil
+	}
+	return strings.Split(columns, ", ")
+}
+
+// replayAlterChangeStream applies an ALTER CHANGE STREAM statement to
+// schema
+func replayAlterChangeStream(schema *Schema, stmt *ast.AlterChangeStream) error {
+	stream, ok := schema.ChangeStreams[stmt.Name.Name]
+	if !ok {
+		return fmt.Errorf("ALTER CHANGE STREAM on unknown change stream %s", stmt.Name.Name)
+	}
+
+	switch a := stmt.ChangeStreamAlteration.(type) {
+	case *ast.ChangeStreamSetFor:
+		stream.All = false
+		stream.Tables = make(map[string]string)
+		switch f := a.For.(type) {
+		case *ast.ChangeStreamForAll:
+			stream.All = true
+		case *ast.ChangeStreamForTables:
+			for _, table := range f.Tables {
+				stream.Tables[table.TableName.Name] = watchedColumns(table)
+			}
+		}
+	case *ast.ChangeStreamDropForAll:
+		stream.All = false
+		stream.Tables = make(map[string]string)
+	case *ast.ChangeStreamSetOptions:
+		for _, option := range a.Options.Records {
+			if _, isNull := option.Value.(*ast.NullLiteral); isNull {
+				delete(stream.Options, option.Name.Name)
+			} else {
+				stream.Options[option.Name.Name] = option.Value.SQL()
+			}
+		}
+	}
+	return nil
+}
+
+// generateDropChangeStreamDDLs generates DDLs to drop the change streams
+// that no longer exist
+func generateDropChangeStreamDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(current.ChangeStreams) {
+		if _, exists := desired.ChangeStreams[name]; !exists {
+			ddls = append(ddls, fmt.Sprintf("DROP CHANGE STREAM %s", name))
+		}
+	}
+	return ddls
+}
+
+// generateCreateChangeStreamDDLs generates DDLs to create new change
+// streams
+func generateCreateChangeStreamDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(desired.ChangeStreams) {
+		if _, exists := current.ChangeStreams[name]; !exists {
+			ddls = append(ddls, generateCreateChangeStream(desired.ChangeStreams[name]))
+		}
+	}
+	return ddls
+}
+
+// statementEnd returns the end offset of stmt in ddls, which it was parsed
+// from. memefish ends FOR ALL at the token following it rather than at ALL,
+// which is corrected here, as it would overlap the next statement.
+func statementEnd(ddls string, stmt ast.DDL) int {
+	var forClause ast.ChangeStreamFor
+	switch s := stmt.(type) {
+	case *ast.CreateChangeStream:
+		if s.Options == nil {
+			forClause = s.For
+		}
+	case *ast.AlterChangeStream:
+		if setFor, ok := s.ChangeStreamAlteration.(*ast.ChangeStreamSetFor); ok {
+			forClause = setFor.For
+		}
+	}
+	all, ok := forClause.(*ast.ChangeStreamForAll)
+	if !ok {
+		return int(stmt.End())
+	}
+	return len(strings.TrimRight(ddls[:min(int(all.All), len(ddls))], " \t\r\n"))
+}
@@ -0,0 +1,78 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDDLs_ChangeStreams(t *testing.T) {
+	schema, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100), Email STRING(100)) PRIMARY KEY (Id);
+CREATE CHANGE STREAM UserChanges FOR Users(Name, Email) OPTIONS (retention_period = '7d');
+CREATE CHANGE STREAM Everything FOR ALL;
+-- owner: data
+CREATE CHANGE STREAM Nothing`)
+	require.NoError(t, err)
+	assert.Empty(t, schema.Unsupported)
+
+	// Watched columns are sorted, and literals formatted by memefish
+	assert.Equal(t, `CREATE CHANGE STREAM UserChanges FOR Users(Email, Name) OPTIONS (retention_period = "7d")`,
+		generateCreateChangeStream(schema.ChangeStreams["UserChanges"]))
+	assert.Equal(t, "CREATE CHANGE STREAM Everything FOR ALL", generateCreateChangeStream(schema.ChangeStreams["Everything"]))
+	assert.Equal(t, "CREATE CHANGE STREAM Nothing", generateCreateChangeStream(schema.ChangeStreams["Nothing"]))
+	// The end of FOR ALL doesn't run into the next statement
+	assert.Equal(t, "data", schema.ChangeStreams["Nothing"].Owner)
+}
+
+func TestGenerateIdempotentDDLs_ChangeStreams(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE CHANGE STREAM LogChanges FOR Logs;
+CREATE CHANGE STREAM UserChanges FOR Users`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(100)) PRIMARY KEY (Id);
+CREATE CHANGE STREAM UserChanges FOR Users;
+CREATE CHANGE STREAM EmailChanges FOR Users(Email)`
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		// Streams watching a table must be dropped before it
+		"DROP CHANGE STREAM LogChanges",
+		"DROP TABLE Logs",
+		"ALTER TABLE Users ADD COLUMN Email STRING(100)",
+		"CREATE CHANGE STREAM EmailChanges FOR Users(Email)",
+	}, ddls)
+
+	// Dropping a change stream is destructive
+	assert.True(t, isDestructive("DROP CHANGE STREAM LogChanges", nil))
+}
+
+func TestGenerateIdempotentDDLs_AlterChangeStream(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE CHANGE STREAM AllChanges FOR ALL OPTIONS (retention_period = '7d', value_capture_type = 'NEW_ROW');
+CREATE CHANGE STREAM UserChanges FOR Users;
+CREATE CHANGE STREAM LogChanges FOR Logs, Users(Name)`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE TABLE Orders (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE CHANGE STREAM AllChanges OPTIONS (retention_period = '36h');
+CREATE CHANGE STREAM UserChanges FOR Users(Name);
+CREATE CHANGE STREAM LogChanges FOR Orders, Users(Name)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`ALTER CHANGE STREAM AllChanges SET OPTIONS (retention_period = "36h", value_capture_type = null)`,
+		"ALTER CHANGE STREAM AllChanges DROP FOR ALL",
+		// Logs is unwatched before it is dropped, and Orders watched once
+		// it is created
+		"ALTER CHANGE STREAM LogChanges SET FOR Users(Name)",
+		"ALTER CHANGE STREAM UserChanges SET FOR Users(Name)",
+		"DROP TABLE Logs",
+		"CREATE TABLE Orders (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"ALTER CHANGE STREAM LogChanges SET FOR Orders, Users(Name)",
+	}, ddls)
+	// Replaying the statements brings the streams in line
+	assert.Empty(t, warnings)
+}
@@ -0,0 +1,86 @@
+package spannerdef
+
+import (
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// canonicalSQL returns the expression expr, e.g. of a CHECK constraint or
+// a DEFAULT, in a form that compares equal for expressions that differ only
+// in whitespace, keyword or function name case, string literal quoting or
+// redundant parentheses. Parentheses written in expr are dropped and every
+// operand that isn't a name, literal or function call is parenthesized
+// instead, so that the form follows the parsed tree rather than the way
+// expr was written.
+func canonicalSQL(expr string) (string, error) {
+	parsed, err := memefish.ParseExpr("", expr)
+	if err != nil {
+		return "", err
+	}
+	canonical := canonicalExpr(parsed)
+	ast.Inspect(canonical, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpr); ok {
+			for _, ident := range call.Func.Idents {
+				ident.Name = strings.ToUpper(ident.Name)
+			}
+		}
+		return true
+	})
+	return canonical.SQL(), nil
+}
+
+// canonicalExpr drops the parentheses of expr and of the operands of its
+// logical, comparison and arithmetic operators, and parenthesizes those
+// operands that aren't atomic
+func canonicalExpr(expr ast.Expr) ast.Expr {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		expr = paren.Expr
+	}
+	switch e := expr.(type) {
+	case *ast.BinaryExpr:
+		e.Left = groupOperand(canonicalExpr(e.Left))
+		e.Right = groupOperand(canonicalExpr(e.Right))
+	case *ast.UnaryExpr:
+		e.Expr = groupOperand(canonicalExpr(e.Expr))
+	}
+	return expr
+}
+
+// groupOperand parenthesizes an operand unless it is atomic
+func groupOperand(expr ast.Expr) ast.Expr {
+	switch expr.(type) {
+	case *ast.Ident, *ast.Path, *ast.CallExpr,
+		*ast.IntLiteral, *ast.FloatLiteral, *ast.StringLiteral,
+		*ast.BytesLiteral, *ast.BoolLiteral, *ast.NullLiteral:
+		return expr
+	}
+	return &ast.ParenExpr{Expr: expr}
+}
+
+// expressionsEqual compares two expressions by their canonical SQL, falling
+// back to comparing them as written when either can't be parsed
+func expressionsEqual(current, desired string) bool {
+	if current == desired {
+		return true
+	}
+	canonicalCurrent, err := canonicalSQL(current)
+	if err != nil {
+		return false
+	}
+	canonicalDesired, err := canonicalSQL(desired)
+	if err != nil {
+		return false
+	}
+	return canonicalCurrent == canonicalDesired
+}
+
+// checksEqual compares the expressions of two CHECK constraints
+func checksEqual(current, desired string) bool {
+	return expressionsEqual(current, desired)
+}
@@ -0,0 +1,39 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksEqual(t *testing.T) {
+	for _, tc := range []struct {
+		current, desired string
+		equal            bool
+	}{
+		{"(Age >= 0)", "(Age>=0)", true},
+		{"(Status IN ('a', 'b'))", `(Status IN ("a", "b"))`, true},
+		{"(((Age >= 0)))", "(Age >= 0)", true},
+		{"((Age >= 0) AND (Age < 200))", "(Age >= 0 AND Age < 200)", true},
+		{"(NOT (Deleted))", "(NOT Deleted)", true},
+		{"((A OR B) AND C)", "(A OR B AND C)", false},
+		{"(Age >= 0)", "(Age > 0)", false},
+	} {
+		assert.Equal(t, tc.equal, checksEqual(tc.current, tc.desired), "%s vs %s", tc.current, tc.desired)
+	}
+}
+
+func TestGenerateIdempotentDDLs_CheckAsDumped(t *testing.T) {
+	current := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Age INT64,
+  CONSTRAINT CK_Age CHECK((Age >= 0) AND (Age < 200)),
+) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Age INT64, CONSTRAINT CK_Age CHECK (Age>=0 AND Age<200)) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	assert.Empty(t, warnings)
+}
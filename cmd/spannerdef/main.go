@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/hokaccha/spannerdef"
 	"github.com/jessevdk/go-flags"
@@ -17,21 +21,49 @@ var (
 // parseOptions parses command line options
 func parseOptions(args []string) (spannerdef.Config, *spannerdef.Options) {
 	var opts struct {
-		ProjectID  string   `short:"p" long:"project" description:"Google Cloud Project ID (or set SPANNER_PROJECT_ID)" value-name:"project_id"`
-		InstanceID string   `short:"i" long:"instance" description:"Spanner Instance ID (or set SPANNER_INSTANCE_ID)" value-name:"instance_id"`
-		DatabaseID string   `short:"d" long:"database" description:"Spanner Database ID (or set SPANNER_DATABASE_ID)" value-name:"database_id"`
-		File       []string `long:"file" description:"Read desired SQL from the file, rather than stdin" value-name:"sql_file" default:"-"`
-		DryRun     bool     `long:"dry-run" description:"Don't run DDLs but just show them"`
-		Export     bool     `long:"export" description:"Just dump the current schema to stdout"`
-		EnableDrop bool     `long:"enable-drop" description:"Enable destructive changes such as DROP TABLE, DROP INDEX"`
-		Config     string   `long:"config" description:"YAML file to specify: target_tables, skip_tables"`
-		Help       bool     `long:"help" description:"Show this help"`
-		Version    bool     `long:"version" description:"Show this version"`
+		ProjectID         string   `short:"p" long:"project" description:"Google Cloud Project ID (or set SPANNER_PROJECT_ID)" value-name:"project_id"`
+		InstanceID        string   `short:"i" long:"instance" description:"Spanner Instance ID (or set SPANNER_INSTANCE_ID)" value-name:"instance_id"`
+		DatabaseID        string   `short:"d" long:"database" description:"Spanner Database ID (or set SPANNER_DATABASE_ID)" value-name:"database_id"`
+		File              []string `long:"file" description:"Read desired SQL from the file, rather than stdin" value-name:"sql_file" default:"-"`
+		Overlay           []string `long:"overlay" description:"Apply the statements of the file on top of the desired schema, e.g. environment-specific indexes (can be repeated)" value-name:"sql_file"`
+		DryRun            bool     `long:"dry-run" description:"Don't run DDLs but just show them"`
+		Export            bool     `long:"export" description:"Just dump the current schema to stdout"`
+		VerifyExport      bool     `long:"verify-export" description:"With --export, fail if the schema doesn't generate back the same through spannerdef, e.g. losing OPTIONS or interleave clauses"`
+		ExportDir         string   `long:"export-dir" description:"With --export, write one file per table to the directory instead of stdout" value-name:"dir"`
+		EnableDrop        bool     `long:"enable-drop" description:"Enable destructive changes such as DROP TABLE, DROP INDEX"`
+		EnableDropTable   bool     `long:"enable-drop-table" description:"Alias of --enable-drop, for sqldef compatibility"`
+		SkipDrop          bool     `long:"skip-drop" description:"Skip destructive changes (the default), for sqldef compatibility"`
+		FailOnDestructive bool     `long:"fail-on-destructive" description:"Exit non-zero without applying anything if the plan contains destructive changes"`
+		Strict            bool     `long:"strict" description:"Fail instead of warning on unsupported statements and changes, for full fidelity between files and database"`
+		CheckNotNull      bool     `long:"check-not-null" description:"Count NULL values before making columns NOT NULL, and abort with the counts if there are any"`
+		SqldefCompat      bool     `long:"sqldef-compat" description:"Format output like mysqldef/psqldef"`
+		TUI               bool     `long:"tui" description:"Review the plan on the terminal and apply only the approved statements"`
+		ReportTemplate    string   `long:"report-template" description:"Render the plan with the Go template in the file instead of printing the statements" value-name:"tmpl_file"`
+		Stat              bool     `long:"stat" description:"Print a one-line summary of the changes, e.g. for commit messages, instead of the statements"`
+		Annotate          bool     `long:"annotate" description:"Explain why each statement is generated in a comment above it"`
+		Catalog           string   `long:"catalog" description:"Just dump column metadata of the current schema to stdout" choice:"csv" choice:"jsonl" value-name:"format"`
+		JSON              bool     `long:"json" description:"Print the plan, with the estimated duration of each statement, the drift matrix or the size report as JSON"`
+		OptionDrift       string   `long:"option-drift" description:"Severity of drifted database options such as default_leader in the drift matrix; error exits non-zero" choice:"ignore" choice:"warning" choice:"error" default:"warning" value-name:"severity"`
+		Config            string   `long:"config" description:"YAML file to specify: target_tables, skip_tables, target_indexes, skip_indexes, freeze, if_not_exists, qualified_names, equivalent_defaults, recreate_generated_columns, recreate_primary_keys, lenient, retry, timeouts, create_database"`
+		OverrideFreeze    bool     `long:"override-freeze" description:"Apply changes even though the config or the metadata table freezes the schema"`
+		CacheDir          string   `long:"cache-dir" description:"Cache parsed schemas and plans in the directory, keyed by content hash" value-name:"dir"`
+		Schema            string   `long:"schema" description:"Only export, plan and apply the objects of the named schema, e.g. accounting for accounting.Invoices" value-name:"name"`
+		From              string   `long:"from" description:"Database to clone the schema from" value-name:"database_id"`
+		To                string   `long:"to" description:"Database to clone the schema to, created if needed" value-name:"database_id"`
+		CreateDatabase    bool     `long:"create-database-if-not-exists" description:"Create the database before applying if it doesn't exist"`
+		DatabaseDialect   string   `long:"database-dialect" description:"Dialect of the created database" choice:"GOOGLE_STANDARD_SQL" choice:"POSTGRESQL" value-name:"dialect"`
+		KMSKeyName        string   `long:"kms-key-name" description:"Cloud KMS key to encrypt the created database with (CMEK)" value-name:"key_name"`
+		OperationFile     string   `long:"operation-file" description:"Record in-flight DDL operations in the file so a later run or 'wait' can resume them" value-name:"path"`
+		RequestTag        []string `long:"request-tag" description:"Attach key=value to admin/DDL requests for attribution (can be repeated)" value-name:"key=value"`
+		DatabaseRole      string   `long:"database-role" description:"Fine-grained access control role to read tables as" value-name:"role"`
+		ProtoDescriptors  string   `long:"proto-descriptors-file" description:"FileDescriptorSet of the proto types to create or alter the proto bundle with, e.g. from protoc --descriptor_set_out" value-name:"path"`
+		Help              bool     `long:"help" description:"Show this help"`
+		Version           bool     `long:"version" description:"Show this version"`
 	}
 
 	parser := flags.NewParser(&opts, flags.None)
-	parser.Usage = "[OPTIONS] < desired.sql"
-	_, err := parser.ParseArgs(args)
+	parser.Usage = "[OPTIONS] [wait|doctor|clone|sizes|drift [name=]database...] < desired.sql"
+	rest, err := parser.ParseArgs(args)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -46,6 +78,34 @@ func parseOptions(args []string) (spannerdef.Config, *spannerdef.Options) {
 		os.Exit(0)
 	}
 
+	wait, doctor, clone, sizes := false, false, false, false
+	var drift []string
+	switch {
+	case len(rest) == 0:
+	case len(rest) == 1 && rest[0] == "wait":
+		wait = true
+	case len(rest) == 1 && rest[0] == "doctor":
+		doctor = true
+	case len(rest) > 1 && rest[0] == "drift":
+		drift = rest[1:]
+	case len(rest) == 1 && rest[0] == "clone":
+		clone = true
+	case len(rest) == 1 && rest[0] == "sizes":
+		sizes = true
+	default:
+		log.Fatalf("Unknown command: %v", rest)
+	}
+	if wait && opts.OperationFile == "" {
+		log.Fatal("wait requires --operation-file.")
+	}
+
+	if opts.EnableDropTable {
+		opts.EnableDrop = true
+	}
+	if opts.SkipDrop && opts.EnableDrop {
+		log.Fatal("--skip-drop cannot be combined with --enable-drop.")
+	}
+
 	// Use environment variables as defaults if CLI args are not provided
 	if opts.ProjectID == "" {
 		opts.ProjectID = os.Getenv("SPANNER_PROJECT_ID")
@@ -64,40 +124,532 @@ func parseOptions(args []string) (spannerdef.Config, *spannerdef.Options) {
 	if opts.InstanceID == "" {
 		log.Fatal("Instance ID is required. Use --instance or set SPANNER_INSTANCE_ID environment variable.")
 	}
-	if opts.DatabaseID == "" {
-		log.Fatal("Database ID is required. Use --database or set SPANNER_DATABASE_ID environment variable.")
+
+	for _, tag := range opts.RequestTag {
+		key, _, found := strings.Cut(tag, "=")
+		if !found || key == "" {
+			log.Fatalf("Invalid request tag '%s': expected key=value", tag)
+		}
 	}
 
 	desiredFiles := spannerdef.ParseFiles(opts.File)
 
-	var desiredDDLs string
-	if !opts.Export {
-		desiredDDLs, err = spannerdef.ReadFiles(desiredFiles)
+	var desiredSources []spannerdef.Source
+	if clone {
+		if opts.From == "" || opts.To == "" {
+			log.Fatal("clone requires --from and --to.")
+		}
+		opts.DatabaseID = opts.To
+	}
+
+	// The catalog takes owners from the desired files, but doesn't wait
+	// for stdin
+	catalogFiles := opts.Catalog != "" && !slices.Equal(desiredFiles, []string{"-"})
+	if !opts.Export && (opts.Catalog == "" || catalogFiles) && !wait && !doctor && !clone {
+		desiredSources, err = spannerdef.ReadSources(desiredFiles)
 		if err != nil {
 			log.Fatalf("Failed to read '%v': %s", desiredFiles, err)
 		}
 	}
 
+	// The database may be left to `-- database: <id>` directives in the
+	// desired files
+	if opts.DatabaseID == "" && len(drift) == 0 && !spannerdef.HasDatabaseDirectives(desiredSources) {
+		log.Fatal("Database ID is required. Use --database or set SPANNER_DATABASE_ID environment variable.")
+	}
+	if opts.OperationFile != "" && spannerdef.HasDatabaseDirectives(desiredSources) {
+		log.Fatal("--operation-file cannot be combined with '-- database:' directives.")
+	}
+	if sizes && spannerdef.HasDatabaseDirectives(desiredSources) {
+		log.Fatal("sizes cannot be combined with '-- database:' directives.")
+	}
+	if opts.Stat && opts.ReportTemplate != "" {
+		log.Fatal("--stat cannot be combined with --report-template.")
+	}
+	if opts.JSON && len(drift) == 0 && (opts.Stat || opts.ReportTemplate != "") {
+		log.Fatal("--json cannot be combined with --stat or --report-template.")
+	}
+	if len(opts.Overlay) > 0 && spannerdef.HasDatabaseDirectives(desiredSources) {
+		log.Fatal("--overlay cannot be combined with '-- database:' directives.")
+	}
+
+	var protoDescriptors []byte
+	if opts.ProtoDescriptors != "" {
+		protoDescriptors, err = os.ReadFile(opts.ProtoDescriptors)
+		if err != nil {
+			log.Fatalf("Failed to read '%s': %s", opts.ProtoDescriptors, err)
+		}
+	}
+
+	generatorConfig := spannerdef.ParseGeneratorConfig(opts.Config)
+	generatorConfig.CacheDir = opts.CacheDir
+	generatorConfig.Schema = opts.Schema
+
 	options := spannerdef.Options{
-		DesiredDDLs: desiredDDLs,
-		DryRun:      opts.DryRun,
-		Export:      opts.Export,
-		EnableDrop:  opts.EnableDrop,
-		Config:      spannerdef.ParseGeneratorConfig(opts.Config),
+		DesiredDDLs:       spannerdef.JoinSources(desiredSources),
+		DesiredSources:    desiredSources,
+		DryRun:            opts.DryRun,
+		Export:            opts.Export,
+		VerifyExport:      opts.VerifyExport,
+		ExportDir:         opts.ExportDir,
+		EnableDrop:        opts.EnableDrop,
+		Wait:              wait,
+		Doctor:            doctor,
+		Sizes:             sizes,
+		Drift:             drift,
+		CloneFrom:         opts.From,
+		JSON:              opts.JSON,
+		OptionDrift:       opts.OptionDrift,
+		SqldefCompat:      opts.SqldefCompat,
+		Catalog:           opts.Catalog,
+		Annotate:          opts.Annotate,
+		FailOnDestructive: opts.FailOnDestructive,
+		Strict:            opts.Strict,
+		CheckNotNull:      opts.CheckNotNull,
+		Stat:              opts.Stat,
+		ReportTemplate:    opts.ReportTemplate,
+		TUI:               opts.TUI,
+		OverrideFreeze:    opts.OverrideFreeze,
+		CreateDatabase:    opts.CreateDatabase,
+		Config:            generatorConfig,
+	}
+
+	if len(opts.Overlay) > 0 && len(desiredSources) > 0 {
+		overlays, err := spannerdef.ReadSources(spannerdef.ParseFiles(opts.Overlay))
+		if err != nil {
+			log.Fatalf("Failed to read '%v': %s", opts.Overlay, err)
+		}
+		options.DesiredDDLs, err = spannerdef.MergeOverlays(options.DesiredDDLs, overlays)
+		if err != nil {
+			log.Fatalf("Failed to merge overlays: %s", err)
+		}
+		// Definitions in the merged schema don't map back to the files
+		options.DesiredSources = nil
 	}
 
 	config := spannerdef.Config{
-		ProjectID:  opts.ProjectID,
-		InstanceID: opts.InstanceID,
-		DatabaseID: opts.DatabaseID,
+		ProjectID:        opts.ProjectID,
+		InstanceID:       opts.InstanceID,
+		DatabaseID:       opts.DatabaseID,
+		UserAgent:        fmt.Sprintf("spannerdef/%s", version),
+		RequestTags:      opts.RequestTag,
+		OperationFile:    opts.OperationFile,
+		DatabaseRole:     opts.DatabaseRole,
+		DatabaseDialect:  opts.DatabaseDialect,
+		KMSKeyName:       opts.KMSKeyName,
+		ProtoDescriptors: protoDescriptors,
+		Retry:            spannerdef.ParseRetryPolicy(opts.Config),
+		Timeouts:         spannerdef.ParseTimeouts(opts.Config),
+	}
+
+	// Flags override the create_database section of the config file
+	dialect, kmsKeyName := spannerdef.ParseCreateDatabase(opts.Config)
+	if config.DatabaseDialect == "" {
+		config.DatabaseDialect = dialect
+	}
+	if config.KMSKeyName == "" {
+		config.KMSKeyName = kmsKeyName
 	}
 
 	return config, &options
 }
 
+// runImport implements `spannerdef import <dir>`, which doesn't need a
+// database connection
+func runImport(args []string) {
+	if len(args) != 1 {
+		log.Fatal("Usage: spannerdef import <migrations_dir>")
+	}
+
+	ddls, err := spannerdef.ImportMigrations(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(ddls)
+}
+
+// runRender implements `spannerdef render`, which prints the desired
+// schema in spannerdef's canonical format without connecting to a
+// database. With --bootstrap, it prints the script creating the schema in
+// an empty database instead, e.g. to seed ephemeral environments.
+func runRender(args []string) {
+	var opts struct {
+		File      []string `long:"file" description:"Read desired SQL from the file, rather than stdin" value-name:"sql_file" default:"-"`
+		Bootstrap bool     `long:"bootstrap" description:"Print the statements creating the schema in an empty database, in dependency order"`
+	}
+	parser := flags.NewParser(&opts, flags.None)
+	rest, err := parser.ParseArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(rest) != 0 {
+		log.Fatal("Usage: spannerdef render [--bootstrap] [--file=<desired.sql>...]")
+	}
+
+	files := spannerdef.ParseFiles(opts.File)
+	sources, err := spannerdef.ReadSources(files)
+	if err != nil {
+		log.Fatalf("Failed to read '%v': %s", files, err)
+	}
+	schema, err := spannerdef.ParseDDLs(spannerdef.JoinSources(sources))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !opts.Bootstrap {
+		fmt.Print(spannerdef.Render(schema, spannerdef.FormatOptions{Annotations: true, Unsupported: true}))
+		return
+	}
+	for i, ddl := range spannerdef.BootstrapDDLs(schema) {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s;\n", ddl)
+	}
+}
+
+// runDiff implements `spannerdef diff <from.sql> <to.sql>`, which prints the
+// statements turning one schema file into another without connecting to a
+// database. Without --from, --to is compared with its own normalized form,
+// so that `spannerdef --export | spannerdef diff --to -` shows what
+// re-applying a dump would change. With --since, the schema is diffed from
+// the one a migrations directory had as of a version, to the one as of
+// --until, --to, the live schema of --database if given, or else the
+// latest one.
+func runDiff(args []string) {
+	var opts struct {
+		From       string `long:"from" description:"Schema file to diff from, - for stdin" value-name:"sql_file"`
+		To         string `long:"to" description:"Schema file to diff to, - for stdin" value-name:"sql_file"`
+		Schema     string `long:"schema" description:"Only diff the objects of the named schema" value-name:"name"`
+		Migrations string `long:"migrations" description:"Migrations directory to take the history of the schema from" value-name:"dir"`
+		Since      string `long:"since" description:"Diff from the schema as of this version of --migrations" value-name:"version"`
+		Until      string `long:"until" description:"Diff to the schema as of this version of --migrations instead of the latest" value-name:"version"`
+		ProjectID  string `short:"p" long:"project" description:"With --since, Google Cloud Project ID of --database (or set SPANNER_PROJECT_ID)" value-name:"project_id"`
+		InstanceID string `short:"i" long:"instance" description:"With --since, Spanner Instance ID of --database (or set SPANNER_INSTANCE_ID)" value-name:"instance_id"`
+		DatabaseID string `short:"d" long:"database" description:"With --since, diff to the live schema of the database instead of the latest (or set SPANNER_DATABASE_ID)" value-name:"database_id"`
+	}
+	parser := flags.NewParser(&opts, flags.None)
+	rest, err := parser.ParseArgs(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch {
+	case opts.Since != "" || opts.Until != "":
+		if opts.Migrations == "" || opts.Since == "" || opts.From != "" || len(rest) != 0 || (opts.Until != "" && opts.To != "") {
+			log.Fatal("Usage: spannerdef diff --migrations=<dir> --since=<version> [--until=<version> | --to=<to.sql> | --database=<database_id>]")
+		}
+	case len(rest) == 2 && opts.From == "" && opts.To == "":
+		opts.From, opts.To = rest[0], rest[1]
+	case len(rest) == 0 && opts.To != "":
+	default:
+		log.Fatal("Usage: spannerdef diff <from.sql> <to.sql>, or spannerdef diff [--from=<from.sql>] --to=<to.sql>")
+	}
+	if opts.From == "-" && opts.To == "-" {
+		log.Fatal("Only one of --from and --to can be stdin.")
+	}
+	if opts.ProjectID == "" {
+		opts.ProjectID = os.Getenv("SPANNER_PROJECT_ID")
+	}
+	if opts.InstanceID == "" {
+		opts.InstanceID = os.Getenv("SPANNER_INSTANCE_ID")
+	}
+	if opts.DatabaseID == "" {
+		opts.DatabaseID = os.Getenv("SPANNER_DATABASE_ID")
+	}
+
+	var from, to string
+	switch {
+	case opts.To != "":
+		to, err = spannerdef.ReadFile(opts.To)
+		if err != nil {
+			log.Fatalf("Failed to read '%s': %s", opts.To, err)
+		}
+	case opts.Until != "":
+		to, err = spannerdef.ImportMigrationsAt(opts.Migrations, opts.Until)
+	case opts.Since != "" && opts.DatabaseID != "":
+		to, err = dumpLiveSchema(spannerdef.Config{
+			ProjectID:  opts.ProjectID,
+			InstanceID: opts.InstanceID,
+			DatabaseID: opts.DatabaseID,
+			UserAgent:  fmt.Sprintf("spannerdef/%s", version),
+		})
+	default:
+		to, err = spannerdef.ImportMigrations(opts.Migrations)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.Since != "" {
+		from, err = spannerdef.ImportMigrationsAt(opts.Migrations, opts.Since)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else if opts.From == "" {
+		from, err = spannerdef.NormalizeDDLs(to)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		from, err = spannerdef.ReadFile(opts.From)
+		if err != nil {
+			log.Fatalf("Failed to read '%s': %s", opts.From, err)
+		}
+	}
+
+	ddls, warnings, err := spannerdef.GenerateIdempotentDDLs(to, from, spannerdef.GeneratorConfig{Schema: opts.Schema})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", warning)
+	}
+	if len(ddls) == 0 {
+		fmt.Println("-- Nothing is modified --")
+		return
+	}
+	for _, ddl := range ddls {
+		fmt.Printf("%s;\n", ddl)
+	}
+}
+
+// dumpLiveSchema returns the current schema of the database of config
+func dumpLiveSchema(config spannerdef.Config) (string, error) {
+	if config.ProjectID == "" || config.InstanceID == "" {
+		return "", fmt.Errorf("--database needs --project and --instance, or SPANNER_PROJECT_ID and SPANNER_INSTANCE_ID")
+	}
+	db, err := spannerdef.NewDatabase(config)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	return db.DumpDDLs()
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "render":
+			runRender(os.Args[2:])
+			return
+		}
+	}
+
 	config, options := parseOptions(os.Args[1:])
 
+	if options.Doctor {
+		runDoctor(config)
+		return
+	}
+	if options.Sizes {
+		runSizes(config, options)
+		return
+	}
+	if options.CloneFrom != "" {
+		runClone(config, options)
+		return
+	}
+	if len(options.Drift) > 0 {
+		runDrift(config, options)
+		return
+	}
+
+	if !spannerdef.HasDatabaseDirectives(options.DesiredSources) {
+		run(config, options)
+		return
+	}
+
+	routes, err := spannerdef.RouteSources(options.DesiredSources, config.DatabaseID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	databases := make([]string, 0, len(routes))
+	for database := range routes {
+		databases = append(databases, database)
+	}
+	sort.Strings(databases)
+
+	for _, database := range databases {
+		fmt.Printf("-- Database: %s --\n", database)
+
+		databaseConfig := config
+		databaseConfig.DatabaseID = database
+		databaseOptions := *options
+		databaseOptions.DesiredSources = routes[database]
+		databaseOptions.DesiredDDLs = spannerdef.JoinSources(routes[database])
+		run(databaseConfig, &databaseOptions)
+	}
+}
+
+// runDoctor prints the result of every diagnostic check and exits non-zero
+// if any of them failed
+func runDoctor(config spannerdef.Config) {
+	failed := false
+	for _, d := range spannerdef.Diagnose(config) {
+		if d.Err != nil {
+			failed = true
+			fmt.Printf("FAIL %s: %s\n", d.Check, d.Err)
+		} else {
+			fmt.Printf("ok   %s: %s\n", d.Check, d.Detail)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runSizes prints the storage of the tables and indexes of the database,
+// marking those the desired schema drops
+func runSizes(config spannerdef.Config, options *spannerdef.Options) {
+	db, err := spannerdef.NewDatabase(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := spannerdef.BuildSizeReport(db, options.DesiredDDLs, options.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if options.JSON {
+		err = spannerdef.WriteSizeReportJSON(os.Stdout, rows)
+	} else {
+		err = spannerdef.WriteSizeReport(os.Stdout, rows)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runClone applies the schema of the --from database to the --to one,
+// creating it if needed. Table filters and --dry-run apply as usual; a dry
+// run doesn't create the database but plans against an empty schema.
+func runClone(config spannerdef.Config, options *spannerdef.Options) {
+	fromConfig := config
+	fromConfig.DatabaseID = options.CloneFrom
+	source, err := spannerdef.NewDatabase(fromConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ddls, err := source.DumpDDLs()
+	source.Close()
+	if err != nil {
+		log.Fatalf("Error on DumpDDLs of %s: %s", options.CloneFrom, err)
+	}
+
+	options.DesiredDDLs = ddls
+	options.CreateDatabase = true
+	run(config, options)
+}
+
+// runDrift prints which objects of the desired schema differ in each of
+// the environments given to the drift command. An environment is a database
+// ID in --project/--instance or a full database path, optionally prefixed
+// with "name=".
+func runDrift(config spannerdef.Config, options *spannerdef.Options) {
+	names := make([]string, 0, len(options.Drift))
+	dbs := make([]spannerdef.Database, 0, len(options.Drift))
+	for _, env := range options.Drift {
+		name, database, found := strings.Cut(env, "=")
+		if !found {
+			database = env
+		}
+
+		envConfig := config
+		if strings.HasPrefix(database, "projects/") {
+			parts := strings.Split(database, "/")
+			if len(parts) != 6 || parts[2] != "instances" || parts[4] != "databases" {
+				log.Fatalf("Invalid database path '%s': expected projects/<project>/instances/<instance>/databases/<database>", database)
+			}
+			envConfig.ProjectID, envConfig.InstanceID, envConfig.DatabaseID = parts[1], parts[3], parts[5]
+		} else {
+			envConfig.DatabaseID = database
+		}
+
+		db, err := spannerdef.NewDatabase(envConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		names = append(names, name)
+		dbs = append(dbs, db)
+	}
+
+	matrix, err := spannerdef.BuildDriftMatrix(options.DesiredDDLs, names, dbs, options.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	matrix.SetOptionSeverity(options.OptionDrift)
+	if options.JSON {
+		err = matrix.WriteJSON(os.Stdout)
+	} else {
+		err = matrix.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if matrix.HasErrors() {
+		os.Exit(1)
+	}
+}
+
+// createDatabaseIfNotExists creates the database of config, with its
+// dialect and encryption key, unless it already exists
+func createDatabaseIfNotExists(config spannerdef.Config) {
+	adminDB, err := spannerdef.NewAdminDatabase(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer adminDB.Close()
+
+	created, err := adminDB.CreateDatabaseIfNotExists(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if created {
+		fmt.Printf("-- Created database: %s --\n", config.DatabaseID)
+	}
+}
+
+// missingDatabase returns a stand-in for the database of config if it
+// doesn't exist, so that a dry run plans against an empty schema instead of
+// creating it, or nil if it exists
+func missingDatabase(config spannerdef.Config) spannerdef.Database {
+	adminDB, err := spannerdef.NewAdminDatabase(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer adminDB.Close()
+
+	exists, err := adminDB.DatabaseExists(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if exists {
+		return nil
+	}
+	fmt.Printf("-- Database %s doesn't exist and would be created --\n", config.DatabaseID)
+	return &spannerdef.MissingDatabase{Path: adminDB.DatabasePath()}
+}
+
+// run applies options to the database of config
+func run(config spannerdef.Config, options *spannerdef.Options) {
+	if options.CreateDatabase && options.DryRun {
+		if db := missingDatabase(config); db != nil {
+			spannerdef.Run(db, options)
+			return
+		}
+	} else if options.CreateDatabase {
+		createDatabaseIfNotExists(config)
+	}
+
 	db, err := spannerdef.NewDatabase(config)
 	if err != nil {
 		log.Fatal(err)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -55,7 +56,12 @@ func TestParseOptions_AllFlags(t *testing.T) {
 		"--database", "test-database",
 		"--dry-run",
 		"--export",
+		"--verify-export",
 		"--enable-drop",
+		"--fail-on-destructive",
+		"--strict",
+		"--check-not-null",
+		"--stat",
 		"--file", tempFile.Name(),
 	}
 
@@ -66,7 +72,12 @@ func TestParseOptions_AllFlags(t *testing.T) {
 	assert.Equal(t, "test-database", config.DatabaseID)
 	assert.True(t, options.DryRun)
 	assert.True(t, options.Export)
+	assert.True(t, options.VerifyExport)
 	assert.True(t, options.EnableDrop)
+	assert.True(t, options.FailOnDestructive)
+	assert.True(t, options.Strict)
+	assert.True(t, options.CheckNotNull)
+	assert.True(t, options.Stat)
 }
 
 func TestParseOptions_EnvironmentVariables(t *testing.T) {
@@ -211,6 +222,70 @@ func TestParseOptions_MultipleFiles(t *testing.T) {
 	assert.Contains(t, options.DesiredDDLs, "CREATE TABLE Posts")
 }
 
+func TestParseOptions_Overlay(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "schema.sql")
+	prod := filepath.Join(dir, "prod.sql")
+	require.NoError(t, os.WriteFile(base, []byte("CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);"), 0o644))
+	require.NoError(t, os.WriteFile(prod, []byte("CREATE INDEX IdxUsersName ON Users (Name);"), 0o644))
+
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--file", base,
+		"--overlay", prod,
+	}
+
+	_, options := parseOptions(args)
+
+	assert.Equal(t, `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Name STRING(100)
+) PRIMARY KEY (Id);
+
+CREATE INDEX IdxUsersName ON Users (Name);
+`, options.DesiredDDLs)
+	assert.Empty(t, options.DesiredSources)
+}
+
+func TestParseOptions_ReportTemplate(t *testing.T) {
+	schema := filepath.Join(t.TempDir(), "schema.sql")
+	require.NoError(t, os.WriteFile(schema, []byte("CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);"), 0o644))
+
+	_, options := parseOptions([]string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--file", schema,
+		"--report-template", "ticket.tmpl",
+	})
+	assert.Equal(t, "ticket.tmpl", options.ReportTemplate)
+}
+
+func TestParseOptions_DatabaseDirectives(t *testing.T) {
+	file, err := os.CreateTemp("", "schema-*.sql")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString("-- database: orders\nCREATE TABLE Orders (Id INT64) PRIMARY KEY (Id);")
+	require.NoError(t, err)
+	file.Close()
+
+	t.Setenv("SPANNER_DATABASE_ID", "")
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--file", file.Name(),
+	}
+
+	// --database is not required when the files route their statements
+	config, options := parseOptions(args)
+
+	assert.Empty(t, config.DatabaseID)
+	assert.Equal(t, file.Name(), options.DesiredSources[0].Path)
+}
+
 func TestParseOptions_ExportMode(t *testing.T) {
 	args := []string{
 		"--project", "test-project",
@@ -229,6 +304,215 @@ func TestParseOptions_ExportMode(t *testing.T) {
 	assert.Empty(t, options.DesiredDDLs)
 }
 
+func TestParseOptions_CatalogMode(t *testing.T) {
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--catalog", "jsonl",
+	}
+
+	_, options := parseOptions(args)
+
+	assert.Equal(t, "jsonl", options.Catalog)
+	// catalog export doesn't read the desired schema
+	assert.Empty(t, options.DesiredDDLs)
+}
+
+func TestParseOptions_CreateDatabase(t *testing.T) {
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--create-database-if-not-exists",
+		"--database-dialect", "POSTGRESQL",
+		"--kms-key-name", "projects/p/locations/us/keyRings/r/cryptoKeys/k",
+		"--export",
+	}
+
+	config, options := parseOptions(args)
+
+	assert.True(t, options.CreateDatabase)
+	assert.Equal(t, "POSTGRESQL", config.DatabaseDialect)
+	assert.Equal(t, "projects/p/locations/us/keyRings/r/cryptoKeys/k", config.KMSKeyName)
+}
+
+func TestParseOptions_CreateDatabaseConfigFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`create_database:
+  dialect: POSTGRESQL
+  kms_key_name: projects/p/locations/us/keyRings/r/cryptoKeys/k
+`), 0o644))
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--config", configFile,
+		"--create-database-if-not-exists",
+		"--kms-key-name", "projects/p/locations/us/keyRings/r/cryptoKeys/flag",
+		"--export",
+	}
+
+	config, _ := parseOptions(args)
+
+	// Flags override the config file
+	assert.Equal(t, "POSTGRESQL", config.DatabaseDialect)
+	assert.Equal(t, "projects/p/locations/us/keyRings/r/cryptoKeys/flag", config.KMSKeyName)
+}
+
+func TestParseOptions_CloneCommand(t *testing.T) {
+	t.Setenv("SPANNER_DATABASE_ID", "")
+	args := []string{
+		"clone",
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--from", "main",
+		"--to", "pr-123",
+	}
+
+	config, options := parseOptions(args)
+
+	assert.Equal(t, "main", options.CloneFrom)
+	assert.Equal(t, "pr-123", config.DatabaseID)
+	// clone takes the desired schema from --from, not from stdin
+	assert.Empty(t, options.DesiredDDLs)
+}
+
+func TestParseOptions_RequestTags(t *testing.T) {
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--request-tag", "deploy=release-42",
+		"--request-tag", "team=platform",
+		"--export",
+	}
+
+	config, _ := parseOptions(args)
+
+	assert.Equal(t, []string{"deploy=release-42", "team=platform"}, config.RequestTags)
+	assert.Equal(t, "spannerdef/dev", config.UserAgent)
+}
+
+func TestParseOptions_DatabaseRole(t *testing.T) {
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--database-role", "spannerdef_reader",
+		"--export",
+	}
+
+	config, _ := parseOptions(args)
+
+	assert.Equal(t, "spannerdef_reader", config.DatabaseRole)
+}
+
+func TestParseOptions_ProtoDescriptors(t *testing.T) {
+	descriptors := filepath.Join(t.TempDir(), "descriptors.pb")
+	require.NoError(t, os.WriteFile(descriptors, []byte{0x0a, 0x01}, 0o644))
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--proto-descriptors-file", descriptors,
+		"--export",
+	}
+
+	config, _ := parseOptions(args)
+
+	assert.Equal(t, []byte{0x0a, 0x01}, config.ProtoDescriptors)
+}
+
+func TestParseOptions_WaitCommand(t *testing.T) {
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--operation-file", "/tmp/spannerdef.operation",
+		"wait",
+	}
+
+	config, options := parseOptions(args)
+
+	assert.Equal(t, "/tmp/spannerdef.operation", config.OperationFile)
+	assert.True(t, options.Wait)
+	// wait doesn't read the desired schema
+	assert.Empty(t, options.DesiredDDLs)
+}
+
+func TestParseOptions_SizesCommand(t *testing.T) {
+	schemaFile := filepath.Join(t.TempDir(), "schema.sql")
+	require.NoError(t, os.WriteFile(schemaFile, []byte("-- test schema"), 0o644))
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--file", schemaFile,
+		"sizes",
+	}
+
+	_, options := parseOptions(args)
+
+	assert.True(t, options.Sizes)
+	assert.NotEmpty(t, options.DesiredDDLs)
+}
+
+func TestParseOptions_DoctorCommand(t *testing.T) {
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"doctor",
+	}
+
+	_, options := parseOptions(args)
+
+	assert.True(t, options.Doctor)
+	// doctor doesn't read the desired schema
+	assert.Empty(t, options.DesiredDDLs)
+}
+
+func TestParseOptions_DriftCommand(t *testing.T) {
+	file, err := os.CreateTemp("", "schema-*.sql")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.Close()
+
+	t.Setenv("SPANNER_DATABASE_ID", "")
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--file", file.Name(),
+		"--json",
+		"--option-drift", "error",
+		"drift", "staging=app-staging", "prod=projects/prod-project/instances/main/databases/app",
+	}
+
+	// drift doesn't need --database
+	_, options := parseOptions(args)
+
+	assert.Equal(t, []string{"staging=app-staging", "prod=projects/prod-project/instances/main/databases/app"}, options.Drift)
+	assert.True(t, options.JSON)
+	assert.Equal(t, "error", options.OptionDrift)
+}
+
+func TestParseOptions_SqldefCompatibility(t *testing.T) {
+	args := []string{
+		"--project", "test-project",
+		"--instance", "test-instance",
+		"--database", "test-database",
+		"--enable-drop-table",
+		"--sqldef-compat",
+		"--export",
+	}
+
+	_, options := parseOptions(args)
+
+	assert.True(t, options.EnableDrop)
+	assert.True(t, options.SqldefCompat)
+}
+
 // Test helper function to set up environment for testing
 func setupTestEnv(t *testing.T) func() {
 	// Save original environment
@@ -0,0 +1,37 @@
+package spannerdef
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// fixedType reports whether a column of type typ can't change type at all:
+// FLOAT32 and FLOAT64 can't be converted into one another, and the length
+// of vectors, set with vector_length, can't change
+func fixedType(typ string) bool {
+	return strings.Contains(typ, "FLOAT32") || strings.Contains(typ, "vector_length")
+}
+
+// checkColumnTypes returns an error listing the columns of current whose
+// type ddls change in a way Spanner doesn't allow, see fixedType. The
+// statements would otherwise fail when applied, possibly after others have
+// been.
+func checkColumnTypes(ddls []string, current *Schema) error {
+	var errs []error
+	for _, ddl := range ddls {
+		op := classifyDDL(ddl)
+		if op.kind != opAlterColumnType {
+			continue
+		}
+		table, ok := current.Tables[op.table]
+		if !ok || table.Columns[op.name] == nil {
+			continue
+		}
+
+		if typ := table.Columns[op.name].Type; typ != op.typ && (fixedType(typ) || fixedType(op.typ)) {
+			errs = append(errs, fmt.Errorf("column %s.%s can't change from %s to %s, which Spanner doesn't allow; add a new column instead", op.table, op.name, typ, op.typ))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,54 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_Float32(t *testing.T) {
+	// As Spanner dumps it
+	desired := `CREATE TABLE Documents (
+  Id INT64 NOT NULL,
+  Score float32,
+  Embedding ARRAY<FLOAT32>(vector_length=>768) NOT NULL,
+) PRIMARY KEY (Id);
+CREATE VECTOR INDEX DocumentsByEmbedding ON Documents(Embedding) OPTIONS (distance_type = 'COSINE')`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, "", GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE Documents (\n  Id INT64 NOT NULL,\n  Score FLOAT32,\n  Embedding ARRAY<FLOAT32>(vector_length => 768) NOT NULL\n) PRIMARY KEY (Id)",
+		"CREATE VECTOR INDEX DocumentsByEmbedding ON Documents (Embedding) OPTIONS (distance_type = \"COSINE\")",
+	}, ddls)
+	assert.Empty(t, warnings)
+
+	// Generated DDLs round-trip
+	ddls, _, err = GenerateIdempotentDDLs(desired, ddls[0]+";\n"+ddls[1], GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
+
+func TestGenerateIdempotentDDLs_ForbiddenColumnTypes(t *testing.T) {
+	current := `CREATE TABLE Documents (
+  Id INT64 NOT NULL,
+  Score FLOAT32,
+  Rank FLOAT64,
+  Embedding ARRAY<FLOAT32>(vector_length=>768),
+  Title STRING(100),
+) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Documents (
+  Id INT64 NOT NULL,
+  Score FLOAT64,
+  Rank FLOAT32,
+  Embedding ARRAY<FLOAT32>(vector_length=>1024),
+  Title STRING(200),
+) PRIMARY KEY (Id)`
+
+	// FLOAT32 is told apart from FLOAT64, and other types still change
+	_, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	assert.EqualError(t, err, "column Documents.Score can't change from FLOAT32 to FLOAT64, which Spanner doesn't allow; add a new column instead\n"+
+		"column Documents.Rank can't change from FLOAT64 to FLOAT32, which Spanner doesn't allow; add a new column instead\n"+
+		"column Documents.Embedding can't change from ARRAY<FLOAT32>(vector_length => 768) to ARRAY<FLOAT32>(vector_length => 1024), which Spanner doesn't allow; add a new column instead")
+}
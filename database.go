@@ -2,10 +2,19 @@ package spannerdef
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,12 +22,75 @@ type Config struct {
 	ProjectID  string
 	InstanceID string
 	DatabaseID string
+	// UserAgent identifies spannerdef to Spanner. Defaults to "spannerdef".
+	UserAgent string
+	// RequestTags are "key=value" pairs attached to admin/DDL calls so
+	// schema changes can be attributed to a specific deploy.
+	RequestTags []string
+	// OperationFile records the name of an in-flight UpdateDatabaseDdl
+	// operation so a later run can reattach to it. Disabled when empty.
+	OperationFile string
+	// DatabaseDialect is the dialect of databases created by
+	// SpannerAdminDatabase, GOOGLE_STANDARD_SQL (the default) or POSTGRESQL
+	DatabaseDialect string
+	// KMSKeyName is the customer-managed encryption key of databases
+	// created by SpannerAdminDatabase. Google-managed when empty.
+	KMSKeyName string
+	// Retry overrides how failed admin and data calls are retried; the
+	// client defaults are used when nil
+	Retry *RetryPolicy
+	// Timeouts bound how long a batch of DDLs may take, see ParseTimeouts
+	Timeouts map[string]time.Duration
+	// DatabaseRole is the fine-grained access control role the data
+	// client reads tables as, e.g. for NULL counts. The database's default
+	// role is used when empty.
+	DatabaseRole string
+	// ProtoDescriptors is a serialized FileDescriptorSet of the proto types
+	// in the proto bundle, sent with the DDLs that create or alter it
+	ProtoDescriptors []byte
 	// Future: CredentialsFile string
 }
 
 type GeneratorConfig struct {
+	// Schema limits everything to the objects of a named schema, e.g.
+	// "accounting" for accounting.Invoices. All schemas when empty.
+	Schema       string
 	TargetTables []string
 	SkipTables   []string
+	// TargetIndexes and SkipIndexes filter indexes independently of their
+	// tables. They are patterns as in path.Match, e.g. "IdxPerf_*".
+	TargetIndexes []string
+	SkipIndexes   []string
+	CacheDir      string // Directory to cache parsed schemas in; disabled when empty
+	// Freeze is the reason schema changes are frozen, e.g. "Black Friday";
+	// applies are refused while it is set unless overridden
+	Freeze string
+	// IfNotExists adds IF NOT EXISTS to the generated CREATE TABLE, CREATE
+	// INDEX, CREATE SEQUENCE and ADD COLUMN statements, so a plan that was
+	// partially applied can be run again as is
+	IfNotExists bool
+	// QualifiedNames backquotes every identifier of the generated DDLs,
+	// including each part of schema-qualified names, so they can't be
+	// taken for keywords or for objects of another named schema
+	QualifiedNames bool
+	// EquivalentDefaults are groups of DEFAULT expressions that are taken
+	// to be the same, e.g. CURRENT_TIMESTAMP() and CURRENT_TIMESTAMP, so
+	// that a column's default isn't reported as changed from one to another
+	EquivalentDefaults [][]string
+	// RecreateGeneratedColumns drops and adds back the generated columns
+	// whose expression changes, which Spanner can't alter in place, with
+	// the indexes on them and the generated columns reading them.
+	// Otherwise the change is an error.
+	RecreateGeneratedColumns bool
+	// RecreatePrimaryKeys drops and creates again the tables whose primary
+	// key changes, which Spanner can't alter, with the tables interleaved
+	// in them, the indexes on them and the foreign keys referencing them.
+	// Otherwise the change is an error.
+	RecreatePrimaryKeys bool
+	// Lenient skips the statements of the current and desired schemas that
+	// can't be parsed, such as those of Spanner features newer than
+	// spannerdef, with a warning, instead of failing
+	Lenient bool
 }
 
 // Database interface for Spanner
@@ -29,34 +101,400 @@ type Database interface {
 	Close() error
 }
 
+// MissingDatabase stands for a database that doesn't exist yet, whose
+// schema is empty, so that a dry run of --create-database-if-not-exists
+// plans the statements it would apply without creating the database
+type MissingDatabase struct {
+	Path string
+}
+
+func (db *MissingDatabase) DumpDDLs() (string, error) {
+	return "", nil
+}
+
+func (db *MissingDatabase) ExecDDL(ddl string) error {
+	return db.ExecDDLs([]string{ddl})
+}
+
+func (db *MissingDatabase) ExecDDLs(ddls []string) error {
+	return fmt.Errorf("database %s doesn't exist", db.Path)
+}
+
+func (db *MissingDatabase) Close() error {
+	return nil
+}
+
+func (db *MissingDatabase) DatabasePath() string {
+	return db.Path
+}
+
+// StatementError is a failed DDL batch attributed to the statement that
+// failed: Spanner applies the statements of a batch in order, and stops at
+// the first one that fails
+type StatementError struct {
+	Statement string
+	Index     int // of Statement in the batch
+	Total     int // number of statements in the batch
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	applied := "no statement of the batch was applied"
+	if e.Index > 0 {
+		applied = fmt.Sprintf("the %d statement(s) before it were applied", e.Index)
+	}
+	return fmt.Sprintf("%v\nfailed statement %d of %d (%s):\n  %s", e.Err, e.Index+1, e.Total, applied, e.Statement)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// DDLExporter is implemented by databases that can write their schema
+// straight to a writer instead of building the whole dump in memory.
+type DDLExporter interface {
+	ExportDDLs(w io.Writer) (int, error)
+}
+
+// OperationResumer is implemented by databases that record in-flight DDL
+// operations and can wait for them to finish in a later process.
+type OperationResumer interface {
+	ResumeOperation() (string, error)
+}
+
 func RunDDLs(d Database, ddls []string, enableDrop bool, quiet bool) error {
+	return runDDLs(d, ddls, nil, nil, enableDrop, quiet)
+}
+
+// runDDLs is RunDDLs printing reasons[i], if any, as a comment before ddls[i].
+// current, if known, is used to tell whether a statement is destructive.
+func runDDLs(d Database, ddls []string, reasons []string, current *Schema, enableDrop bool, quiet bool) error {
 	if !quiet {
 		fmt.Println("-- Apply --")
 	}
 
 	// Filter out destructive DDLs if enableDrop is false
+	skipped := skippedDDLs(ddls, current, enableDrop)
+	if err := checkSkippedRecreates(ddls, skipped); err != nil {
+		return err
+	}
+	if !quiet {
+		printPlan(ddls, reasons, skipped, ";")
+	}
 	validDDLs := make([]string, 0, len(ddls))
-	for _, ddl := range ddls {
-		if !enableDrop && (strings.Contains(ddl, "DROP TABLE") ||
-			strings.Contains(ddl, "DROP INDEX") ||
-			strings.Contains(ddl, "DROP COLUMN")) {
-			if !quiet {
-				fmt.Printf("-- Skipped: %s;\n", ddl)
+	for i, ddl := range ddls {
+		if !skipped[i] {
+			validDDLs = append(validDDLs, ddl)
+		}
+	}
+
+	if len(validDDLs) == 0 {
+		return nil
+	}
+
+	// Execute DDLs in batches so index backfills don't hold up schema changes
+	for _, batch := range splitBatches(validDDLs) {
+		if err := d.ExecDDLs(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isDestructive reports whether ddl drops a table, an index, a vector
+// index, a column, a constraint, a view, a change stream, a sequence, a
+// role or the proto bundle, revokes a privilege, deletes proto types,
+// narrows the type of a column, which fails or truncates values on
+// existing rows, sets the counter of a sequence, which may make it return
+// values already used, or sets a row deletion policy keeping rows for
+// less time. current tells whether an ALTER COLUMN narrows the type or a
+// policy shortens the retention; without it, any change to a sized STRING
+// or BYTES, and any policy, is assumed to.
+func isDestructive(ddl string, current *Schema) bool {
+	op := classifyDDL(ddl)
+	switch op.kind {
+	case opDropTable, opDropIndex, opDropVectorIndex, opDropColumn, opDropConstraint, opDropView, opDropChangeStream, opDropSequence,
+		opDropRole, opRevoke, opDropProtoBundle, opDropSchema:
+		return true
+	case opAlterSequence:
+		return op.restart
+	case opAlterProtoBundle:
+		return op.deletesTypes
+	case opAlterTable:
+		return op.retentionDays > 0 && shortensRetention(current, op)
+	case opAlterColumnType:
+		currentType := ""
+		if current != nil {
+			if table, ok := current.Tables[op.table]; ok {
+				if column, ok := table.Columns[op.name]; ok {
+					currentType = column.Type
+				}
 			}
+		}
+		return narrowsType(currentType, op.typ)
+	}
+	return false
+}
+
+// sizedTypeRe matches STRING and BYTES types and arrays of them
+var sizedTypeRe = regexp.MustCompile(`^(?:ARRAY<)?(?:STRING|BYTES)\((\d+|MAX)\)>?$`)
+
+// narrowsType reports whether changing a column from current to desired may
+// not fit existing values. An empty current is an unknown type.
+func narrowsType(current, desired string) bool {
+	d := sizedTypeRe.FindStringSubmatch(desired)
+	if d == nil || d[1] == "MAX" {
+		return false
+	}
+	c := sizedTypeRe.FindStringSubmatch(current)
+	if c == nil {
+		return true
+	}
+	if c[1] == "MAX" {
+		return true
+	}
+	currentLength, _ := strconv.Atoi(c[1])
+	desiredLength, _ := strconv.Atoi(d[1])
+	return desiredLength < currentLength
+}
+
+// skippedDDLs returns the indexes of ddls that aren't applied unless
+// enableDrop is set: the destructive statements other than the drops
+// authorized by current.Dropped (see tombstoned), and the ADD CONSTRAINT
+// recreating a changed constraint whose DROP CONSTRAINT is skipped, which
+// would otherwise fail as the constraint still exists.
+func skippedDDLs(ddls []string, current *Schema, enableDrop bool) map[int]bool {
+	skipped := make(map[int]bool)
+	if enableDrop {
+		return skipped
+	}
+
+	keptConstraints := make(map[string]bool)
+	for i, ddl := range ddls {
+		if !isDestructive(ddl, current) || tombstoned(ddl, current) {
 			continue
 		}
-		if !quiet {
-			fmt.Printf("%s;\n", ddl)
+		skipped[i] = true
+		if op := classifyDDL(ddl); op.kind == opDropConstraint {
+			keptConstraints[op.table+"."+op.name] = true
+		}
+	}
+	for i, ddl := range ddls {
+		if op := classifyDDL(ddl); op.kind == opAddConstraint && keptConstraints[op.table+"."+op.name] {
+			skipped[i] = true
 		}
-		validDDLs = append(validDDLs, ddl)
 	}
+	return skipped
+}
 
-	if len(validDDLs) == 0 {
-		return nil
+// appliedDDLs returns the statements of ddls that aren't skipped
+func appliedDDLs(ddls []string, current *Schema, enableDrop bool) []string {
+	skipped := skippedDDLs(ddls, current, enableDrop)
+	applied := make([]string, 0, len(ddls))
+	for i, ddl := range ddls {
+		if !skipped[i] {
+			applied = append(applied, ddl)
+		}
+	}
+	return applied
+}
+
+// checkSkippedRecreates returns an error if a skipped DROP INDEX, DROP
+// TABLE or DROP COLUMN is for an index, table or column created again by
+// the statements of ddls that aren't skipped, which is how changed
+// indexes, primary keys and generated expressions are made: the CREATE or
+// ADD COLUMN would fail with the index, table or column in place
+func checkSkippedRecreates(ddls []string, skipped map[int]bool) error {
+	createdIndexes, createdTables := make(map[string]bool), make(map[string]bool)
+	addedColumns := make(map[string]bool) // "table.column"
+	for i, ddl := range ddls {
+		if skipped[i] {
+			continue
+		}
+		switch op := classifyDDL(ddl); op.kind {
+		case opCreateIndex:
+			createdIndexes[op.name] = true
+		case opCreateTable:
+			createdTables[op.table] = true
+		case opAddColumn:
+			addedColumns[op.table+"."+op.name] = true
+		}
+	}
+	for i, ddl := range ddls {
+		op := classifyDDL(ddl)
+		if !skipped[i] {
+			continue
+		}
+		if op.kind == opDropIndex && createdIndexes[op.name] {
+			return fmt.Errorf("index %s must be dropped and recreated to change its definition or the type of its columns; use --enable-drop to allow it", op.name)
+		}
+		if op.kind == opDropTable && createdTables[op.table] {
+			return fmt.Errorf("table %s must be dropped and recreated to change its primary key; use --enable-drop to allow it", op.table)
+		}
+		if op.kind == opDropColumn && addedColumns[op.table+"."+op.name] {
+			return fmt.Errorf("column %s.%s must be dropped and added back to change its generated expression; use --enable-drop to allow it", op.table, op.name)
+		}
+	}
+	return nil
+}
+
+// checkSkippedDependencies returns an error if a skipped DROP INDEX or DROP
+// CONSTRAINT is for an index or foreign key of current on a column ddls
+// drop without skipping, which Spanner refuses to drop while an index or
+// foreign key is on it, or if a skipped DROP CONSTRAINT is for a
+// constraint ddls add back without skipping, which would fail as the
+// constraint still exists
+func checkSkippedDependencies(ddls []string, skipped map[int]bool, current *Schema) error {
+	droppedColumns := make(map[string]bool)   // "table.column"
+	addedConstraints := make(map[string]bool) // "table.constraint"
+	for i, ddl := range ddls {
+		if skipped[i] {
+			continue
+		}
+		switch op := classifyDDL(ddl); op.kind {
+		case opDropColumn:
+			droppedColumns[op.table+"."+op.name] = true
+		case opAddConstraint:
+			addedConstraints[op.table+"."+op.name] = true
+		}
 	}
+	for i, ddl := range ddls {
+		op := classifyDDL(ddl)
+		if !skipped[i] {
+			continue
+		}
+		if op.kind == opDropConstraint && addedConstraints[op.table+"."+op.name] {
+			return fmt.Errorf("constraint %s.%s must be dropped to be added back with its new definition; drop it too or leave out adding it back", op.table, op.name)
+		}
+		if index, ok := current.Indexes[op.name]; ok && op.kind == opDropIndex {
+			for _, column := range append(slices.Clone(index.Columns), index.Storing...) {
+				if droppedColumns[index.TableName+"."+column] {
+					return fmt.Errorf("column %s.%s can't be dropped while index %s is kept on it; drop the index too or keep the column", index.TableName, column, op.name)
+				}
+			}
+		}
+		if table, ok := current.Tables[op.table]; ok && op.kind == opDropConstraint {
+			constraint, ok := table.Constraints[op.name]
+			if !ok || constraint.Type != "FOREIGN KEY" {
+				continue
+			}
+			for _, column := range constraint.Columns {
+				if droppedColumns[table.Name+"."+column] {
+					return fmt.Errorf("column %s.%s can't be dropped while foreign key %s is kept on it; drop the foreign key too or keep the column", table.Name, column, op.name)
+				}
+			}
+			for _, column := range constraint.ReferenceColumns {
+				if droppedColumns[constraint.ReferenceTable+"."+column] {
+					return fmt.Errorf("column %s.%s can't be dropped while foreign key %s is kept on it; drop the foreign key too or keep the column", constraint.ReferenceTable, column, op.name)
+				}
+			}
+		}
+	}
+	return nil
+}
 
-	// Execute all DDLs in batch
-	return d.ExecDDLs(validDDLs)
+// printPlan prints ddls terminated by terminator, each preceded by its
+// reason, if any. Skipped statements are commented out. Apply and dry-run
+// share it so that a dry-run shows exactly what an apply would do.
+func printPlan(ddls, reasons []string, skipped map[int]bool, terminator string) {
+	for i, ddl := range ddls {
+		printReason(reasons, i)
+		if skipped[i] {
+			fmt.Printf("-- Skipped: %s%s\n", ddl, terminator)
+		} else {
+			fmt.Printf("%s%s\n", ddl, terminator)
+		}
+	}
+}
+
+// printReason prints reasons[i] as a SQL comment unless it is empty
+func printReason(reasons []string, i int) {
+	if i < len(reasons) && reasons[i] != "" {
+		fmt.Printf("-- %s\n", reasons[i])
+	}
+}
+
+// splitBatches groups DDLs into batches to be submitted one after another.
+// Spanner applies a batch as a single long-running operation, so a
+// metadata-only change such as ADD COLUMN would otherwise not complete until
+// every index in the same batch has finished backfilling. Indexes on tables
+// that already exist are therefore moved into a follow-up batch, unless a
+// later statement refers to them, such as a view hinting FORCE_INDEX, which
+// would then run before the index exists. Indexes on tables created in the
+// same run stay in the first batch: there is nothing to backfill and they
+// must be created alongside their table.
+func splitBatches(ddls []string) [][]string {
+	newTables := make(map[string]bool)
+	for _, ddl := range ddls {
+		if stmt, err := memefish.ParseDDL("", ddl); err == nil {
+			if ct, ok := stmt.(*ast.CreateTable); ok {
+				newTables[getPathName(ct.Name)] = true
+			}
+		}
+	}
+
+	var schemaChanges, backfills []string
+	for i, ddl := range ddls {
+		stmt, err := memefish.ParseDDL("", ddl)
+		if err == nil {
+			if ci, ok := stmt.(*ast.CreateIndex); ok && !newTables[getPathName(ci.TableName)] && !refersTo(ddls[i+1:], getPathName(ci.Name)) {
+				backfills = append(backfills, ddl)
+				continue
+			}
+		}
+		schemaChanges = append(schemaChanges, ddl)
+	}
+
+	var batches [][]string
+	if len(schemaChanges) > 0 {
+		batches = append(batches, schemaChanges)
+	}
+	if len(backfills) > 0 {
+		batches = append(batches, backfills)
+	}
+	return batches
+}
+
+// refersTo reports whether any of ddls mentions name as an identifier
+func refersTo(ddls []string, name string) bool {
+	nameRe := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`)
+	return slices.ContainsFunc(ddls, nameRe.MatchString)
+}
+
+// DumpResult is the outcome of dumping one database in DumpAll
+type DumpResult struct {
+	DDLs string
+	Err  error
+}
+
+// DefaultDumpConcurrency bounds the number of concurrent GetDatabaseDdl
+// calls made by DumpAll when no explicit limit is given.
+const DefaultDumpConcurrency = 8
+
+// DumpAll dumps the schemas of dbs concurrently with at most concurrency
+// calls in flight, and returns the results in the same order as dbs. A
+// failure on one database doesn't stop the others.
+func DumpAll(dbs []Database, concurrency int) []DumpResult {
+	if concurrency <= 0 {
+		concurrency = DefaultDumpConcurrency
+	}
+
+	results := make([]DumpResult, len(dbs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, db := range dbs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ddls, err := db.DumpDDLs()
+			results[i] = DumpResult{DDLs: ddls, Err: err}
+		}()
+	}
+	wg.Wait()
+
+	return results
 }
 
 func ParseGeneratorConfig(configFile string) GeneratorConfig {
@@ -70,8 +508,17 @@ func ParseGeneratorConfig(configFile string) GeneratorConfig {
 	}
 
 	var config struct {
-		TargetTables string `yaml:"target_tables"`
-		SkipTables   string `yaml:"skip_tables"`
+		TargetTables             string     `yaml:"target_tables"`
+		SkipTables               string     `yaml:"skip_tables"`
+		TargetIndexes            string     `yaml:"target_indexes"`
+		SkipIndexes              string     `yaml:"skip_indexes"`
+		Freeze                   string     `yaml:"freeze"`
+		IfNotExists              bool       `yaml:"if_not_exists"`
+		QualifiedNames           bool       `yaml:"qualified_names"`
+		EquivalentDefaults       [][]string `yaml:"equivalent_defaults"`
+		RecreateGeneratedColumns bool       `yaml:"recreate_generated_columns"`
+		RecreatePrimaryKeys      bool       `yaml:"recreate_primary_keys"`
+		Lenient                  bool       `yaml:"lenient"`
 	}
 
 	err = yaml.Unmarshal(buf, &config)
@@ -89,8 +536,189 @@ func ParseGeneratorConfig(configFile string) GeneratorConfig {
 		skipTables = strings.Split(strings.Trim(config.SkipTables, "\n"), "\n")
 	}
 
+	var targetIndexes []string
+	if config.TargetIndexes != "" {
+		targetIndexes = strings.Split(strings.Trim(config.TargetIndexes, "\n"), "\n")
+	}
+
+	var skipIndexes []string
+	if config.SkipIndexes != "" {
+		skipIndexes = strings.Split(strings.Trim(config.SkipIndexes, "\n"), "\n")
+	}
+
+	for _, pattern := range append(slices.Clone(targetIndexes), skipIndexes...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Fatalf("invalid index pattern %q: %v", pattern, err)
+		}
+	}
+	for _, exprs := range config.EquivalentDefaults {
+		for _, expr := range exprs {
+			if _, err := normalizeDefault(expr); err != nil {
+				log.Fatalf("invalid default expression %q: %v", expr, err)
+			}
+		}
+	}
+
 	return GeneratorConfig{
-		TargetTables: targetTables,
-		SkipTables:   skipTables,
+		TargetTables:             targetTables,
+		SkipTables:               skipTables,
+		TargetIndexes:            targetIndexes,
+		SkipIndexes:              skipIndexes,
+		Freeze:                   strings.TrimSpace(config.Freeze),
+		IfNotExists:              config.IfNotExists,
+		QualifiedNames:           config.QualifiedNames,
+		EquivalentDefaults:       config.EquivalentDefaults,
+		RecreateGeneratedColumns: config.RecreateGeneratedColumns,
+		RecreatePrimaryKeys:      config.RecreatePrimaryKeys,
+		Lenient:                  config.Lenient,
+	}
+}
+
+// ParseRetryPolicy reads the retry section of the config file, e.g.
+//
+//	retry:
+//	  max_attempts: 10
+//	  initial_backoff: 200ms
+//	  max_backoff: 30s
+//	  retryable_codes: [UNAVAILABLE, DEADLINE_EXCEEDED]
+//
+// It returns nil if the file has no retry section.
+func ParseRetryPolicy(configFile string) *RetryPolicy {
+	if configFile == "" {
+		return nil
+	}
+
+	buf, err := os.ReadFile(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var config struct {
+		Retry *struct {
+			MaxAttempts    int           `yaml:"max_attempts"`
+			InitialBackoff time.Duration `yaml:"initial_backoff"`
+			MaxBackoff     time.Duration `yaml:"max_backoff"`
+			RetryableCodes []string      `yaml:"retryable_codes"`
+		} `yaml:"retry"`
+	}
+
+	err = yaml.Unmarshal(buf, &config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.Retry == nil {
+		return nil
+	}
+
+	retryableCodes, err := ParseRetryCodes(config.Retry.RetryableCodes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &RetryPolicy{
+		MaxAttempts:    config.Retry.MaxAttempts,
+		InitialBackoff: config.Retry.InitialBackoff,
+		MaxBackoff:     config.Retry.MaxBackoff,
+		RetryableCodes: retryableCodes,
+	}
+}
+
+// ParseTimeouts reads the timeouts section of the config file, e.g.
+//
+//	timeouts:
+//	  default: 2m
+//	  create_index: 6h
+//
+// Keys are operation kinds (create_table, drop_table, create_index,
+// drop_index, add_column, drop_column, alter_column, add_constraint,
+// drop_constraint, alter_table) or default, for the kinds not listed. It
+// returns nil if the file has no timeouts section.
+func ParseTimeouts(configFile string) map[string]time.Duration {
+	if configFile == "" {
+		return nil
+	}
+
+	buf, err := os.ReadFile(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var config struct {
+		Timeouts map[string]time.Duration `yaml:"timeouts"`
+	}
+	err = yaml.Unmarshal(buf, &config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for kind := range config.Timeouts {
+		if kind != "default" && !slices.Contains(timeoutKinds(), kind) {
+			log.Fatalf("unknown timeout kind %q", kind)
+		}
+	}
+	return config.Timeouts
+}
+
+// ParseCreateDatabase reads the dialect and customer-managed encryption
+// key of databases created by --create-database-if-not-exists from the
+// create_database section of the config file, e.g.
+//
+//	create_database:
+//	  dialect: GOOGLE_STANDARD_SQL
+//	  kms_key_name: projects/p/locations/us/keyRings/r/cryptoKeys/k
+func ParseCreateDatabase(configFile string) (dialect, kmsKeyName string) {
+	if configFile == "" {
+		return "", ""
+	}
+
+	buf, err := os.ReadFile(configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var config struct {
+		CreateDatabase struct {
+			Dialect    string `yaml:"dialect"`
+			KMSKeyName string `yaml:"kms_key_name"`
+		} `yaml:"create_database"`
+	}
+	err = yaml.Unmarshal(buf, &config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return config.CreateDatabase.Dialect, config.CreateDatabase.KMSKeyName
+}
+
+// timeoutKinds returns the operation kinds timeouts can be set for
+func timeoutKinds() []string {
+	var kinds []string
+	for kind := range reportKinds {
+		if key := timeoutKind(kind); !slices.Contains(kinds, key) {
+			kinds = append(kinds, key)
+		}
+	}
+	return kinds
+}
+
+// timeoutKind returns the key of kind in the timeouts section, e.g.
+// create_index
+func timeoutKind(kind operationKind) string {
+	return strings.ToLower(strings.ReplaceAll(reportKinds[kind], " ", "_"))
+}
+
+// batchTimeout returns how long ddls may take as a batch: the longest
+// timeout of the kinds of its statements, falling back to the default
+// timeout for kinds without one. Zero means no timeout.
+func batchTimeout(timeouts map[string]time.Duration, ddls []string) time.Duration {
+	var timeout time.Duration
+	for _, ddl := range ddls {
+		t, ok := timeouts[timeoutKind(classifyDDL(ddl).kind)]
+		if !ok {
+			t = timeouts["default"]
+		}
+		if t == 0 {
+			return 0
+		}
+		timeout = max(timeout, t)
 	}
+	return timeout
 }
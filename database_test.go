@@ -0,0 +1,345 @@
+package spannerdef
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSplitBatches(t *testing.T) {
+	ddls := []string{
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"CREATE TABLE Posts (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"CREATE INDEX IdxUsersEmail ON Users (Email)",
+		"CREATE INDEX IdxPostsId ON Posts (Id)",
+	}
+
+	batches := splitBatches(ddls)
+	require.Len(t, batches, 2)
+	assert.Equal(t, []string{
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"CREATE TABLE Posts (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"CREATE INDEX IdxPostsId ON Posts (Id)",
+	}, batches[0])
+	assert.Equal(t, []string{"CREATE INDEX IdxUsersEmail ON Users (Email)"}, batches[1])
+}
+
+func TestSplitBatches_IndexReferredLater(t *testing.T) {
+	ddls := []string{
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"CREATE INDEX IdxUsersEmail ON Users (Email)",
+		"CREATE INDEX IdxUsersName ON Users (Name)",
+		"CREATE VIEW UsersByEmail SQL SECURITY INVOKER AS SELECT Users.Id FROM Users@{FORCE_INDEX=IdxUsersEmail}",
+	}
+
+	// The view needs IdxUsersEmail, which stays in place
+	batches := splitBatches(ddls)
+	require.Len(t, batches, 2)
+	assert.Equal(t, []string{ddls[0], ddls[1], ddls[3]}, batches[0])
+	assert.Equal(t, []string{ddls[2]}, batches[1])
+}
+
+func TestSplitBatches_OnlyIndexes(t *testing.T) {
+	batches := splitBatches([]string{"CREATE INDEX IdxUsersEmail ON Users (Email)"})
+	require.Len(t, batches, 1)
+	assert.Equal(t, []string{"CREATE INDEX IdxUsersEmail ON Users (Email)"}, batches[0])
+}
+
+func TestDestructiveDDLs(t *testing.T) {
+	ddls := []string{
+		"DROP INDEX IdxUsersName",
+		"DROP TABLE Logs",
+		"ALTER TABLE Users DROP COLUMN Legacy",
+		"ALTER TABLE Users DROP CONSTRAINT ChkName",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+	}
+
+	assert.Equal(t, []string{
+		"DROP INDEX IdxUsersName",
+		"DROP TABLE Logs",
+		"ALTER TABLE Users DROP COLUMN Legacy",
+		"ALTER TABLE Users DROP CONSTRAINT ChkName",
+	}, destructiveDDLs(ddls, nil))
+	assert.Empty(t, destructiveDDLs(ddls[4:], nil))
+}
+
+func TestIsDestructive_AlterColumnType(t *testing.T) {
+	current, err := ParseDDLs(`CREATE TABLE Users (
+		Id INT64 NOT NULL,
+		Name STRING(100),
+		Bio STRING(MAX),
+		Tags ARRAY<STRING(20)>,
+	) PRIMARY KEY (Id)`)
+	require.NoError(t, err)
+
+	assert.True(t, isDestructive("ALTER TABLE Users ALTER COLUMN Name STRING(50)", current))
+	assert.True(t, isDestructive("ALTER TABLE Users ALTER COLUMN Bio STRING(1000)", current))
+	assert.True(t, isDestructive("ALTER TABLE Users ALTER COLUMN Tags ARRAY<STRING(10)>", current))
+	assert.True(t, isDestructive("ALTER TABLE Users ALTER COLUMN Name BYTES(50)", current))
+	assert.False(t, isDestructive("ALTER TABLE Users ALTER COLUMN Name STRING(200)", current))
+	assert.False(t, isDestructive("ALTER TABLE Users ALTER COLUMN Name STRING(MAX)", current))
+	assert.False(t, isDestructive("ALTER TABLE Users ALTER COLUMN Name BYTES(100)", current))
+	assert.False(t, isDestructive("ALTER TABLE Users ALTER COLUMN Name SET OPTIONS (allow_commit_timestamp = true)", current))
+
+	// Without the current schema, any sized type may narrow
+	assert.True(t, isDestructive("ALTER TABLE Users ALTER COLUMN Name STRING(200)", nil))
+	assert.False(t, isDestructive("ALTER TABLE Users ALTER COLUMN Name STRING(MAX)", nil))
+}
+
+func TestSkippedDDLs(t *testing.T) {
+	ddls := []string{
+		"ALTER TABLE Users DROP CONSTRAINT ChkName",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"ALTER TABLE Users ADD CONSTRAINT ChkName CHECK (LENGTH(Name) > 1)",
+		"ALTER TABLE Users ADD CONSTRAINT ChkEmail CHECK (Email != '')",
+	}
+
+	// The changed constraint can't be recreated while the old one is kept
+	assert.Equal(t, map[int]bool{0: true, 2: true}, skippedDDLs(ddls, nil, false))
+	assert.Empty(t, skippedDDLs(ddls, nil, true))
+}
+
+func TestRunDDLs_SkipsDestructive(t *testing.T) {
+	db := &fakeDatabase{}
+	err := RunDDLs(db, []string{
+		"ALTER TABLE Users DROP CONSTRAINT ChkName",
+		"ALTER TABLE Users ALTER COLUMN Name STRING(50)",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+	}, false, true)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"ALTER TABLE Users ADD COLUMN Email STRING(255)"}}, db.batches)
+}
+
+func TestRunDDLs_RecreatedIndexNeedsEnableDrop(t *testing.T) {
+	ddls := []string{
+		"DROP INDEX IdxUsersEmail",
+		"ALTER TABLE Users ALTER COLUMN Email STRING(255)",
+		"CREATE INDEX IdxUsersEmail ON Users (Email)",
+	}
+
+	db := &fakeDatabase{}
+	err := RunDDLs(db, ddls, false, true)
+	assert.EqualError(t, err, "index IdxUsersEmail must be dropped and recreated to change its definition or the type of its columns; use --enable-drop to allow it")
+	assert.Empty(t, db.batches)
+
+	require.NoError(t, RunDDLs(db, ddls, true, true))
+	assert.Equal(t, [][]string{ddls[:2], ddls[2:]}, db.batches)
+}
+
+func TestCheckSkippedDependencies(t *testing.T) {
+	current, err := ParseDDLs(`
+		CREATE TABLE Users (Id INT64 NOT NULL, Code STRING(10)) PRIMARY KEY (Id);
+		CREATE TABLE Posts (Id INT64 NOT NULL, UserCode STRING(10),
+			CONSTRAINT FkPostsUsers FOREIGN KEY (UserCode) REFERENCES Users (Code)) PRIMARY KEY (Id);
+	`)
+	require.NoError(t, err)
+	ddls := []string{
+		"ALTER TABLE Posts DROP CONSTRAINT FkPostsUsers",
+		"ALTER TABLE Users DROP COLUMN Code",
+	}
+
+	assert.EqualError(t, checkSkippedDependencies(ddls, map[int]bool{0: true}, current),
+		"column Users.Code can't be dropped while foreign key FkPostsUsers is kept on it; drop the foreign key too or keep the column")
+	assert.NoError(t, checkSkippedDependencies(ddls, map[int]bool{1: true}, current))
+	assert.NoError(t, checkSkippedDependencies(ddls, map[int]bool{0: true, 1: true}, current))
+	assert.NoError(t, checkSkippedDependencies(ddls, nil, current))
+
+	// A changed constraint can't be added back without being dropped
+	ddls = []string{
+		"ALTER TABLE Posts DROP CONSTRAINT FkPostsUsers",
+		"ALTER TABLE Posts ADD CONSTRAINT FkPostsUsers FOREIGN KEY (UserCode) REFERENCES Users (Id)",
+	}
+	assert.EqualError(t, checkSkippedDependencies(ddls, map[int]bool{0: true}, current),
+		"constraint Posts.FkPostsUsers must be dropped to be added back with its new definition; drop it too or leave out adding it back")
+	assert.NoError(t, checkSkippedDependencies(ddls, map[int]bool{0: true, 1: true}, current))
+	assert.NoError(t, checkSkippedDependencies(ddls, nil, current))
+}
+
+func TestRunDDLs_ExecutesBatchesInOrder(t *testing.T) {
+	db := &fakeDatabase{}
+	err := RunDDLs(db, []string{
+		"CREATE INDEX IdxUsersEmail ON Users (Email)",
+		"ALTER TABLE Users ADD COLUMN Name STRING(100)",
+	}, false, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"ALTER TABLE Users ADD COLUMN Name STRING(100)"},
+		{"CREATE INDEX IdxUsersEmail ON Users (Email)"},
+	}, db.batches)
+}
+
+// slowDatabase is a fakeDatabase whose DumpDDLs takes a while and tracks the
+// maximum number of concurrent calls across all instances sharing inFlight.
+type slowDatabase struct {
+	fakeDatabase
+	err         error
+	inFlight    *int32
+	maxInFlight *int32
+}
+
+func (d *slowDatabase) DumpDDLs() (string, error) {
+	n := atomic.AddInt32(d.inFlight, 1)
+	defer atomic.AddInt32(d.inFlight, -1)
+	for {
+		peak := atomic.LoadInt32(d.maxInFlight)
+		if n <= peak || atomic.CompareAndSwapInt32(d.maxInFlight, peak, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return d.ddls, d.err
+}
+
+func TestDumpAll(t *testing.T) {
+	var inFlight, maxInFlight int32
+	var dbs []Database
+	for i := 0; i < 10; i++ {
+		db := &slowDatabase{inFlight: &inFlight, maxInFlight: &maxInFlight}
+		db.ddls = fmt.Sprintf("CREATE TABLE t%d (id INT64) PRIMARY KEY (id);", i)
+		if i == 3 {
+			db.err = errors.New("permission denied")
+		}
+		dbs = append(dbs, db)
+	}
+
+	results := DumpAll(dbs, 3)
+	require.Len(t, results, 10)
+	for i, result := range results {
+		if i == 3 {
+			assert.EqualError(t, result.Err, "permission denied")
+			continue
+		}
+		require.NoError(t, result.Err)
+		assert.Equal(t, fmt.Sprintf("CREATE TABLE t%d (id INT64) PRIMARY KEY (id);", i), result.DDLs)
+	}
+	assert.LessOrEqual(t, maxInFlight, int32(3))
+	assert.Greater(t, maxInFlight, int32(1))
+}
+
+func TestParseGeneratorConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`target_tables: |
+  Users
+  Posts
+skip_indexes: |
+  IdxPerf_*
+  IdxUsersLegacy
+freeze: Black Friday until 2026-11-30
+if_not_exists: true
+qualified_names: true
+equivalent_defaults:
+  - ["CURRENT_TIMESTAMP()", "PENDING_COMMIT_TIMESTAMP()"]
+recreate_generated_columns: true
+`), 0o644))
+
+	config := ParseGeneratorConfig(configFile)
+	assert.Equal(t, []string{"Users", "Posts"}, config.TargetTables)
+	assert.Empty(t, config.SkipTables)
+	assert.Empty(t, config.TargetIndexes)
+	assert.Equal(t, []string{"IdxPerf_*", "IdxUsersLegacy"}, config.SkipIndexes)
+	assert.Equal(t, "Black Friday until 2026-11-30", config.Freeze)
+	assert.True(t, config.IfNotExists)
+	assert.True(t, config.QualifiedNames)
+	assert.Equal(t, [][]string{{"CURRENT_TIMESTAMP()", "PENDING_COMMIT_TIMESTAMP()"}}, config.EquivalentDefaults)
+	assert.True(t, config.RecreateGeneratedColumns)
+}
+
+func TestParseRetryPolicy(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`retry:
+  max_attempts: 10
+  initial_backoff: 200ms
+  max_backoff: 30s
+  retryable_codes: [UNAVAILABLE, RESOURCE_EXHAUSTED]
+`), 0o644))
+
+	assert.Equal(t, &RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		RetryableCodes: []codes.Code{codes.Unavailable, codes.ResourceExhausted},
+	}, ParseRetryPolicy(configFile))
+
+	require.NoError(t, os.WriteFile(configFile, []byte("skip_tables: Logs\n"), 0o644))
+	assert.Nil(t, ParseRetryPolicy(configFile))
+}
+
+func TestParseTimeouts(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`timeouts:
+  default: 2m
+  create_index: 6h
+`), 0o644))
+
+	assert.Equal(t, map[string]time.Duration{
+		"default":      2 * time.Minute,
+		"create_index": 6 * time.Hour,
+	}, ParseTimeouts(configFile))
+
+	require.NoError(t, os.WriteFile(configFile, []byte("skip_tables: Logs\n"), 0o644))
+	assert.Nil(t, ParseTimeouts(configFile))
+}
+
+func TestParseCreateDatabase(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`create_database:
+  dialect: POSTGRESQL
+  kms_key_name: projects/p/locations/us/keyRings/r/cryptoKeys/k
+`), 0o644))
+
+	dialect, kmsKeyName := ParseCreateDatabase(configFile)
+	assert.Equal(t, "POSTGRESQL", dialect)
+	assert.Equal(t, "projects/p/locations/us/keyRings/r/cryptoKeys/k", kmsKeyName)
+
+	require.NoError(t, os.WriteFile(configFile, []byte("skip_tables: Logs\n"), 0o644))
+	dialect, kmsKeyName = ParseCreateDatabase(configFile)
+	assert.Empty(t, dialect)
+	assert.Empty(t, kmsKeyName)
+}
+
+func TestMissingDatabase(t *testing.T) {
+	db := &MissingDatabase{Path: "projects/p/instances/i/databases/d"}
+
+	// Plans against an empty schema, but can't apply them
+	ddls, err := db.DumpDDLs()
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	assert.EqualError(t, db.ExecDDL("CREATE TABLE Users (Id INT64) PRIMARY KEY (Id)"),
+		"database projects/p/instances/i/databases/d doesn't exist")
+	assert.Equal(t, "projects/p/instances/i/databases/d", databasePath(db))
+}
+
+func TestBatchTimeout(t *testing.T) {
+	timeouts := map[string]time.Duration{
+		"default":        2 * time.Minute,
+		"create_index":   6 * time.Hour,
+		"add_constraint": time.Hour,
+	}
+
+	assert.Equal(t, 2*time.Minute, batchTimeout(timeouts, []string{
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"ALTER TABLE Users ALTER COLUMN Name STRING(200)",
+	}))
+	assert.Equal(t, time.Hour, batchTimeout(timeouts, []string{
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"ALTER TABLE Posts ADD CONSTRAINT FkPostsUsers FOREIGN KEY (UserId) REFERENCES Users (Id)",
+	}))
+	assert.Equal(t, 6*time.Hour, batchTimeout(timeouts, []string{"CREATE INDEX IdxUsersEmail ON Users (Email)"}))
+
+	// Kinds without a timeout and no default aren't bounded
+	delete(timeouts, "default")
+	assert.Zero(t, batchTimeout(timeouts, []string{
+		"CREATE INDEX IdxUsersEmail ON Users (Email)",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+	}))
+	assert.Zero(t, batchTimeout(nil, []string{"CREATE INDEX IdxUsersEmail ON Users (Email)"}))
+}
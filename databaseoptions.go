@@ -0,0 +1,72 @@
+package spannerdef
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// processAlterDatabase folds the options set by an ALTER DATABASE into the
+// database options of schema, and reports whether it sets any. Options set
+// to NULL are kept as "NULL", so that the desired schema can reset them.
+func processAlterDatabase(schema *Schema, stmt *ast.AlterDatabase) bool {
+	if stmt.Options == nil {
+		return false
+	}
+	schema.DatabaseName = stmt.Name.Name
+	if schema.DatabaseOptions == nil {
+		schema.DatabaseOptions = make(map[string]string)
+	}
+	for _, record := range stmt.Options.Records {
+		value := record.Value.SQL()
+		if _, null := record.Value.(*ast.NullLiteral); null {
+			value = "NULL"
+		}
+		schema.DatabaseOptions[record.Name.Name] = value
+	}
+	return true
+}
+
+// databaseOptionEqual reports whether the current value of a database
+// option, "" if it isn't set, is the desired one
+func databaseOptionEqual(current, desired string) bool {
+	if current == "" {
+		current = "NULL"
+	}
+	return current == desired
+}
+
+// generateAlterDatabaseDDLs generates the ALTER DATABASE setting the
+// options of desired that differ from current. Options desired doesn't set
+// are left as they are. The statement names the database of current, or
+// of desired when current sets no option, as the name differs between
+// environments.
+func generateAlterDatabaseDDLs(current, desired *Schema) []string {
+	var assignments []string
+	for _, name := range sortedKeys(desired.DatabaseOptions) {
+		value := desired.DatabaseOptions[name]
+		if !databaseOptionEqual(current.DatabaseOptions[name], value) {
+			assignments = append(assignments, name+" = "+value)
+		}
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	name := current.DatabaseName
+	if name == "" {
+		name = desired.DatabaseName
+	}
+	return []string{fmt.Sprintf("ALTER DATABASE `%s` SET OPTIONS (%s)", name, strings.Join(assignments, ", "))}
+}
+
+// databaseID returns the last part of a database path such as
+// projects/p/instances/i/databases/d, or "" if databasePath is empty
+func databaseID(databasePath string) string {
+	if databasePath == "" {
+		return ""
+	}
+	return path.Base(databasePath)
+}
@@ -0,0 +1,77 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDDLs_DatabaseOptions(t *testing.T) {
+	schema, err := ParseDDLs("ALTER DATABASE `app-dev` SET OPTIONS (version_retention_period = '7d', default_leader = 'us-east1');\n" +
+		"ALTER DATABASE `app-dev` SET OPTIONS (default_leader = NULL)")
+	require.NoError(t, err)
+	assert.Empty(t, schema.Unsupported)
+
+	assert.Equal(t, "app-dev", schema.DatabaseName)
+	assert.Equal(t, map[string]string{"version_retention_period": `"7d"`, "default_leader": "NULL"}, schema.DatabaseOptions)
+}
+
+func TestGenerateIdempotentDDLs_DatabaseOptions(t *testing.T) {
+	current := "ALTER DATABASE `app-prod` SET OPTIONS (version_retention_period = '1h', optimizer_version = 6);\n" +
+		"CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)"
+	desired := "ALTER DATABASE app SET OPTIONS (version_retention_period = '7d', default_leader = 'us-east1');\n" +
+		"CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(MAX)) PRIMARY KEY (Id)"
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		// The database of current is altered, and optimizer_version, which
+		// desired doesn't set, is left as it is
+		"ALTER DATABASE `app-prod` SET OPTIONS (default_leader = \"us-east1\", version_retention_period = \"7d\")",
+		"ALTER TABLE Users ADD COLUMN Name STRING(MAX)",
+	}, ddls)
+	assert.Empty(t, warnings)
+	assert.False(t, isDestructive(ddls[0], nil))
+
+	// Once set, the options match
+	ddls, _, err = GenerateIdempotentDDLs(desired, current+";\n"+ddls[0], GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALTER TABLE Users ADD COLUMN Name STRING(MAX)"}, ddls)
+}
+
+func TestGenerateIdempotentDDLs_ResetDatabaseOptions(t *testing.T) {
+	current := "ALTER DATABASE app SET OPTIONS (default_leader = 'us-east1')"
+
+	// NULL resets an option, unless it isn't set
+	ddls, _, err := GenerateIdempotentDDLs("ALTER DATABASE app SET OPTIONS (default_leader = NULL, optimizer_version = NULL)", current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALTER DATABASE `app` SET OPTIONS (default_leader = NULL)"}, ddls)
+
+	// Without ALTER DATABASE, options are left alone
+	ddls, _, err = GenerateIdempotentDDLs("", current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
+
+func TestGenerateIdempotentDDLs_DatabaseOptionsWithoutCurrentName(t *testing.T) {
+	ddls, _, err := GenerateIdempotentDDLs("ALTER DATABASE app SET OPTIONS (version_retention_period = '7d')", "", GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALTER DATABASE `app` SET OPTIONS (version_retention_period = \"7d\")"}, ddls)
+}
+
+func TestGenerateIdempotentDDLs_DatabaseOptionsTargetTables(t *testing.T) {
+	// Introspected tables come without the database options
+	desired := "ALTER DATABASE app SET OPTIONS (version_retention_period = '7d');\n" +
+		"CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)"
+	current := "CREATE TABLE Users (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)"
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{TargetTables: []string{"Users"}})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
+
+func TestDatabaseID(t *testing.T) {
+	assert.Equal(t, "app-dev", databaseID("projects/p/instances/i/databases/app-dev"))
+	assert.Equal(t, "", databaseID(""))
+}
@@ -0,0 +1,76 @@
+package spannerdef
+
+import (
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// normalizeDefault returns the DEFAULT expression expr, with or without
+// parentheses, in the form Column.Default keeps it
+func normalizeDefault(expr string) (string, error) {
+	parsed, err := memefish.ParseExpr("", expr)
+	if err != nil {
+		return "", err
+	}
+	if paren, ok := parsed.(*ast.ParenExpr); ok {
+		parsed = paren.Expr
+	}
+	return "(" + parsed.SQL() + ")", nil
+}
+
+// defaultsEqual compares the DEFAULT expressions of two columns, "" if
+// they have none, so that a DEFAULT written differently from the way
+// Spanner dumps it, e.g. `(false)` or `((0))`, isn't a change
+func defaultsEqual(current, desired string) bool {
+	if current == "" || desired == "" {
+		return current == desired
+	}
+	return expressionsEqual(current, desired)
+}
+
+// equateDefaults returns desired with the DEFAULT of each column replaced
+// by that of the same column in current when both are in the same group of
+// equivalents, so that defaults GetDatabaseDdl returns differently aren't
+// reported as a change or drift on every run. Tables are copied before
+// being changed.
+func equateDefaults(current, desired *Schema, equivalents [][]string) *Schema {
+	group := make(map[string]int)
+	for i, exprs := range equivalents {
+		for _, expr := range exprs {
+			if normalized, err := normalizeDefault(expr); err == nil {
+				group[normalized] = i + 1
+			}
+		}
+	}
+	if len(group) == 0 {
+		return desired
+	}
+
+	equated := *desired
+	equated.Tables = make(map[string]*Table, len(desired.Tables))
+	for name, table := range desired.Tables {
+		equated.Tables[name] = table
+		currentTable, ok := current.Tables[name]
+		if !ok {
+			continue
+		}
+		for colName, col := range table.Columns {
+			currentCol, ok := currentTable.Columns[colName]
+			if !ok || currentCol.Default == col.Default || group[col.Default] == 0 || group[col.Default] != group[currentCol.Default] {
+				continue
+			}
+			if equated.Tables[name] == table {
+				copied := *table
+				copied.Columns = make(map[string]*Column, len(table.Columns))
+				for n, c := range table.Columns {
+					copied.Columns[n] = c
+				}
+				equated.Tables[name] = &copied
+			}
+			equatedCol := *col
+			equatedCol.Default = currentCol.Default
+			equated.Tables[name].Columns[colName] = &equatedCol
+		}
+	}
+	return &equated
+}
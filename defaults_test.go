@@ -0,0 +1,105 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDefault(t *testing.T) {
+	for _, expr := range []string{"CURRENT_TIMESTAMP()", "(CURRENT_TIMESTAMP())", " ( CURRENT_TIMESTAMP ( ) ) "} {
+		normalized, err := normalizeDefault(expr)
+		require.NoError(t, err)
+		assert.Equal(t, "(CURRENT_TIMESTAMP())", normalized, expr)
+	}
+
+	_, err := normalizeDefault("CURRENT_TIMESTAMP(")
+	assert.Error(t, err)
+}
+
+func TestGenerateIdempotentDDLs_EquivalentDefaults(t *testing.T) {
+	current := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  CreatedAt TIMESTAMP DEFAULT (PENDING_COMMIT_TIMESTAMP()),
+  Status STRING(10) DEFAULT ('new'),
+) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  CreatedAt TIMESTAMP DEFAULT (CURRENT_TIMESTAMP()),
+  Status STRING(10) DEFAULT ('active'),
+) PRIMARY KEY (Id)`
+	config := GeneratorConfig{EquivalentDefaults: [][]string{{"CURRENT_TIMESTAMP()", "PENDING_COMMIT_TIMESTAMP()"}}}
+
+	// Only defaults outside of the group differ
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`ALTER TABLE Users ALTER COLUMN Status SET DEFAULT ("active")`}, ddls)
+	assert.Empty(t, warnings)
+
+	// The desired schema itself isn't changed
+	currentSchema, err := ParseDDLs(current)
+	require.NoError(t, err)
+	desiredSchema, err := ParseDDLs(desired)
+	require.NoError(t, err)
+	equated := equateDefaults(currentSchema, desiredSchema, config.EquivalentDefaults)
+	assert.Equal(t, "(PENDING_COMMIT_TIMESTAMP())", equated.Tables["Users"].Columns["CreatedAt"].Default)
+	assert.Equal(t, "(CURRENT_TIMESTAMP())", desiredSchema.Tables["Users"].Columns["CreatedAt"].Default)
+}
+
+func TestGenerateIdempotentDDLs_DefaultChanges(t *testing.T) {
+	current := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Status STRING(10) DEFAULT ('new'),
+  Score INT64 DEFAULT (0),
+  CreatedAt TIMESTAMP,
+) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Status STRING(10) DEFAULT ('active'),
+  Score INT64,
+  CreatedAt TIMESTAMP DEFAULT (CURRENT_TIMESTAMP()),
+) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`ALTER TABLE Users ALTER COLUMN Status SET DEFAULT ("active")`,
+		"ALTER TABLE Users ALTER COLUMN Score DROP DEFAULT",
+		"ALTER TABLE Users ALTER COLUMN CreatedAt SET DEFAULT (CURRENT_TIMESTAMP())",
+	}, ddls)
+	assert.Empty(t, warnings)
+	for _, ddl := range ddls {
+		assert.False(t, isDestructive(ddl, nil), ddl)
+	}
+}
+
+func TestDefaultsEqual(t *testing.T) {
+	assert.True(t, defaultsEqual("(FALSE)", "(false)"))
+	assert.True(t, defaultsEqual("(0)", "((0))"))
+	assert.True(t, defaultsEqual("(CURRENT_TIMESTAMP())", "(current_timestamp())"))
+	assert.True(t, defaultsEqual(`("new")`, "('new')"))
+	assert.True(t, defaultsEqual("", ""))
+	assert.False(t, defaultsEqual("(0)", ""))
+	assert.False(t, defaultsEqual("(0)", "(1)"))
+}
+
+func TestGenerateIdempotentDDLs_DefaultsAsDumped(t *testing.T) {
+	current := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Active BOOL DEFAULT (FALSE),
+  Score INT64 DEFAULT (0),
+  CreatedAt TIMESTAMP DEFAULT (CURRENT_TIMESTAMP()),
+) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Active BOOL DEFAULT (false),
+  Score INT64 DEFAULT ((0)),
+  CreatedAt TIMESTAMP DEFAULT (current_timestamp()),
+) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	assert.Empty(t, warnings)
+}
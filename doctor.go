@@ -0,0 +1,132 @@
+package spannerdef
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	dbadmin "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// requiredPermissions are the IAM permissions spannerdef needs on the
+// database to plan and apply schema changes
+var requiredPermissions = []string{
+	"spanner.databases.getDdl",
+	"spanner.databases.updateDdl",
+}
+
+// doctorTimeout bounds all the remote checks of Diagnose
+const doctorTimeout = 30 * time.Second
+
+// Diagnosis is the result of one check run by Diagnose. Err is nil when the
+// check passed.
+type Diagnosis struct {
+	Check  string
+	Detail string
+	Err    error
+}
+
+// Diagnose checks whether spannerdef can work against the database of
+// config: emulator detection, credentials, connectivity, database dialect
+// and IAM permissions. Checks that depend on a failed one are not run.
+func Diagnose(config Config) []Diagnosis {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	var results []Diagnosis
+
+	emulatorHost := os.Getenv("SPANNER_EMULATOR_HOST")
+	if emulatorHost != "" {
+		results = append(results, Diagnosis{Check: "emulator", Detail: "using SPANNER_EMULATOR_HOST=" + emulatorHost})
+	} else {
+		results = append(results, Diagnosis{Check: "emulator", Detail: "not used, connecting to Cloud Spanner"})
+
+		creds, err := google.FindDefaultCredentials(ctx, dbadmin.DefaultAuthScopes()...)
+		if err != nil {
+			return append(results, Diagnosis{
+				Check: "credentials",
+				Err:   fmt.Errorf("%v: run `gcloud auth application-default login` or set GOOGLE_APPLICATION_CREDENTIALS", err),
+			})
+		}
+		detail := "found application default credentials"
+		if creds.ProjectID != "" {
+			detail += " (project " + creds.ProjectID + ")"
+		}
+		results = append(results, Diagnosis{Check: "credentials", Detail: detail})
+	}
+
+	adminClient, err := dbadmin.NewDatabaseAdminClient(ctx, clientOptions(config)...)
+	if err != nil {
+		return append(results, Diagnosis{Check: "connectivity", Err: fmt.Errorf("failed to create admin client: %v", err)})
+	}
+	defer adminClient.Close()
+	applyAdminRetryPolicy(adminClient, config.Retry)
+
+	ctx = withRequestTags(ctx, config.RequestTags)
+	databasePath := fmt.Sprintf("projects/%s/instances/%s/databases/%s",
+		config.ProjectID, config.InstanceID, config.DatabaseID)
+
+	database, err := adminClient.GetDatabase(ctx, &databasepb.GetDatabaseRequest{Name: databasePath})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.NotFound:
+			err = fmt.Errorf("%s does not exist: check --project, --instance and --database", databasePath)
+		case codes.PermissionDenied:
+			err = fmt.Errorf("%v: grant roles/spanner.databaseAdmin or a role with spanner.databases.get", err)
+		}
+		return append(results, Diagnosis{Check: "connectivity", Err: err})
+	}
+	results = append(results, Diagnosis{Check: "connectivity", Detail: databasePath + " is " + database.State.String()})
+
+	switch database.DatabaseDialect {
+	case databasepb.DatabaseDialect_GOOGLE_STANDARD_SQL, databasepb.DatabaseDialect_DATABASE_DIALECT_UNSPECIFIED:
+		results = append(results, Diagnosis{Check: "dialect", Detail: "GoogleSQL"})
+	default:
+		results = append(results, Diagnosis{
+			Check: "dialect",
+			Err:   fmt.Errorf("%s is not supported: spannerdef only handles GoogleSQL databases", database.DatabaseDialect),
+		})
+	}
+
+	results = append(results, checkPermissions(ctx, adminClient, databasePath))
+	return results
+}
+
+// checkPermissions reports which of requiredPermissions the caller lacks
+func checkPermissions(ctx context.Context, adminClient *dbadmin.DatabaseAdminClient, databasePath string) Diagnosis {
+	resp, err := adminClient.TestIamPermissions(ctx, &iampb.TestIamPermissionsRequest{
+		Resource:    databasePath,
+		Permissions: requiredPermissions,
+	})
+	if err != nil {
+		// The emulator doesn't implement IAM
+		if status.Code(err) == codes.Unimplemented {
+			return Diagnosis{Check: "permissions", Detail: "skipped, IAM is not supported by the server"}
+		}
+		return Diagnosis{Check: "permissions", Err: fmt.Errorf("failed to test IAM permissions: %v", err)}
+	}
+
+	granted := make(map[string]bool)
+	for _, permission := range resp.Permissions {
+		granted[permission] = true
+	}
+	var missing []string
+	for _, permission := range requiredPermissions {
+		if !granted[permission] {
+			missing = append(missing, permission)
+		}
+	}
+	if len(missing) > 0 {
+		return Diagnosis{
+			Check: "permissions",
+			Err:   fmt.Errorf("missing %v on %s: grant roles/spanner.databaseAdmin or a custom role with them", missing, databasePath),
+		}
+	}
+	return Diagnosis{Check: "permissions", Detail: fmt.Sprintf("%v granted", requiredPermissions)}
+}
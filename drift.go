@@ -0,0 +1,359 @@
+package spannerdef
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// Kinds of drift of an object in an environment
+const (
+	DriftMissing    = "missing"    // in the desired schema but not in the environment
+	DriftUnexpected = "unexpected" // in the environment but not in the desired schema
+	DriftDiffers    = "differs"    // in both, defined differently
+)
+
+// Severities of database option drift, see DriftMatrix.SetOptionSeverity
+const (
+	DriftSeverityIgnore  = "ignore"
+	DriftSeverityWarning = "warning"
+	DriftSeverityError   = "error"
+)
+
+// DriftMatrix tells which schema objects differ from the desired schema in
+// which environment
+type DriftMatrix struct {
+	Environments []string `json:"environments"`
+	// Objects maps an object such as "column Users.Email" to its drift
+	// kind per environment; environments where it matches are left out
+	Objects map[string]map[string]string `json:"objects"`
+	// Errors maps environments that couldn't be checked to the error
+	Errors map[string]string `json:"errors,omitempty"`
+	// Owners maps drifted objects to their owner, as annotated with
+	// "-- owner:" in the desired schema
+	Owners map[string]string `json:"owners,omitempty"`
+	// Severities maps drifted database options, such as "database option
+	// default_leader", to their severity
+	Severities map[string]string `json:"severities,omitempty"`
+}
+
+// BuildDriftMatrix compares the schema of each database to desired. names
+// label dbs in the matrix, e.g. "staging" or a tenant database ID. The
+// schemas are dumped concurrently with DumpAll.
+func BuildDriftMatrix(desiredDDLs string, names []string, dbs []Database, config GeneratorConfig) (*DriftMatrix, error) {
+	desired, err := parseSchema(desiredDDLs, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse desired DDLs: %v", err)
+	}
+	desired = filterSchema(desired, config)
+
+	matrix := &DriftMatrix{
+		Environments: names,
+		Objects:      make(map[string]map[string]string),
+		Errors:       make(map[string]string),
+		Owners:       make(map[string]string),
+		Severities:   make(map[string]string),
+	}
+	for i, result := range DumpAll(dbs, DefaultDumpConcurrency) {
+		name := names[i]
+		if result.Err != nil {
+			matrix.Errors[name] = result.Err.Error()
+			continue
+		}
+		current, err := parseSchema(result.DDLs, config)
+		if err != nil {
+			matrix.Errors[name] = fmt.Sprintf("failed to parse current DDLs: %v", err)
+			continue
+		}
+
+		current = filterSchema(current, config)
+		owners := objectOwners(current, desired)
+		for object, kind := range diffObjects(current, equateDefaults(current, desired, config.EquivalentDefaults)) {
+			if matrix.Objects[object] == nil {
+				matrix.Objects[object] = make(map[string]string)
+			}
+			matrix.Objects[object][name] = kind
+			if owner, ok := owners[object]; ok {
+				matrix.Owners[object] = owner
+			}
+		}
+		for object, kind := range diffOptions(databaseOptions(current), databaseOptions(desired)) {
+			if matrix.Objects[object] == nil {
+				matrix.Objects[object] = make(map[string]string)
+			}
+			matrix.Objects[object][name] = kind
+			matrix.Severities[object] = DriftSeverityWarning
+		}
+	}
+	return matrix, nil
+}
+
+// SetOptionSeverity sets the severity of drifted database options. Options
+// are left out of the matrix with DriftSeverityIgnore.
+func (m *DriftMatrix) SetOptionSeverity(severity string) {
+	for object := range m.Severities {
+		if severity == DriftSeverityIgnore {
+			delete(m.Objects, object)
+			delete(m.Severities, object)
+		} else {
+			m.Severities[object] = severity
+		}
+	}
+}
+
+// HasErrors tells whether an environment couldn't be checked or has drift
+// of severity DriftSeverityError
+func (m *DriftMatrix) HasErrors() bool {
+	if len(m.Errors) > 0 {
+		return true
+	}
+	for _, severity := range m.Severities {
+		if severity == DriftSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// databaseOptions returns the database options of schema. Options set to
+// NULL are reset to their default and left out.
+func databaseOptions(schema *Schema) map[string]string {
+	options := make(map[string]string)
+	for name, value := range schema.DatabaseOptions {
+		if value != "NULL" {
+			options[name] = value
+		}
+	}
+	return options
+}
+
+// diffOptions compares the current database options to the desired ones,
+// and returns the drift kind of every option that doesn't match. The
+// database name of the ALTER DATABASE statements is ignored, as it differs
+// between environments.
+func diffOptions(current, desired map[string]string) map[string]string {
+	drift := make(map[string]string)
+	for _, name := range unionKeys(current, desired) {
+		currentValue, inCurrent := current[name]
+		desiredValue, inDesired := desired[name]
+		object := "database option " + name
+		switch {
+		case !inCurrent:
+			drift[object] = DriftMissing
+		case !inDesired:
+			drift[object] = DriftUnexpected
+		case currentValue != desiredValue:
+			drift[object] = DriftDiffers
+		}
+	}
+	return drift
+}
+
+// diffObjects compares current to desired object by object, and returns the
+// drift kind of every object that doesn't match
+func diffObjects(current, desired *Schema) map[string]string {
+	drift := make(map[string]string)
+	compare := func(object string, inCurrent, inDesired, equal bool) {
+		switch {
+		case !inCurrent:
+			drift[object] = DriftMissing
+		case !inDesired:
+			drift[object] = DriftUnexpected
+		case !equal:
+			drift[object] = DriftDiffers
+		}
+	}
+
+	for _, name := range unionKeys(current.Tables, desired.Tables) {
+		currentTable, inCurrent := current.Tables[name]
+		desiredTable, inDesired := desired.Tables[name]
+		if !inCurrent || !inDesired {
+			compare("table "+name, inCurrent, inDesired, false)
+			continue
+		}
+		compare("table "+name, true, true, tablesEqual(currentTable, desiredTable))
+
+		for _, col := range unionKeys(currentTable.Columns, desiredTable.Columns) {
+			currentCol, inCurrent := currentTable.Columns[col]
+			desiredCol, inDesired := desiredTable.Columns[col]
+			equal := inCurrent && inDesired && columnsEqual(currentCol, desiredCol)
+			compare(fmt.Sprintf("column %s.%s", name, col), inCurrent, inDesired, equal)
+		}
+		for _, constraint := range unionKeys(currentTable.Constraints, desiredTable.Constraints) {
+			currentConstraint, inCurrent := currentTable.Constraints[constraint]
+			desiredConstraint, inDesired := desiredTable.Constraints[constraint]
+			equal := inCurrent && inDesired && constraintsEqual(currentConstraint, desiredConstraint)
+			compare(fmt.Sprintf("constraint %s.%s", name, constraint), inCurrent, inDesired, equal)
+		}
+	}
+
+	for _, name := range unionKeys(current.Indexes, desired.Indexes) {
+		currentIndex, inCurrent := current.Indexes[name]
+		desiredIndex, inDesired := desired.Indexes[name]
+		equal := inCurrent && inDesired && indexesEqual(currentIndex, desiredIndex)
+		compare("index "+name, inCurrent, inDesired, equal)
+	}
+
+	for _, name := range unionKeys(current.VectorIndexes, desired.VectorIndexes) {
+		currentIndex, inCurrent := current.VectorIndexes[name]
+		desiredIndex, inDesired := desired.VectorIndexes[name]
+		equal := inCurrent && inDesired && vectorIndexesEqual(currentIndex, desiredIndex)
+		compare("vector index "+name, inCurrent, inDesired, equal)
+	}
+
+	for _, name := range unionKeys(current.Views, desired.Views) {
+		currentView, inCurrent := current.Views[name]
+		desiredView, inDesired := desired.Views[name]
+		equal := inCurrent && inDesired && viewsEqual(currentView, desiredView)
+		compare("view "+name, inCurrent, inDesired, equal)
+	}
+
+	for _, name := range unionKeys(current.ChangeStreams, desired.ChangeStreams) {
+		currentStream, inCurrent := current.ChangeStreams[name]
+		desiredStream, inDesired := desired.ChangeStreams[name]
+		equal := inCurrent && inDesired && changeStreamsEqual(currentStream, desiredStream)
+		compare("change stream "+name, inCurrent, inDesired, equal)
+	}
+
+	for _, name := range unionKeys(current.Placements, desired.Placements) {
+		currentPlacement, inCurrent := current.Placements[name]
+		desiredPlacement, inDesired := desired.Placements[name]
+		equal := inCurrent && inDesired && placementsEqual(currentPlacement, desiredPlacement)
+		compare("placement "+name, inCurrent, inDesired, equal)
+	}
+
+	for _, name := range unionKeys(current.NamedSchemas, desired.NamedSchemas) {
+		_, inCurrent := current.NamedSchemas[name]
+		_, inDesired := desired.NamedSchemas[name]
+		compare("schema "+name, inCurrent, inDesired, inCurrent && inDesired)
+	}
+
+	for _, name := range unionKeys(current.Roles, desired.Roles) {
+		currentRole, inCurrent := current.Roles[name]
+		desiredRole, inDesired := desired.Roles[name]
+		equal := inCurrent && inDesired && rolesEqual(currentRole, desiredRole)
+		compare("role "+name, inCurrent, inDesired, equal)
+	}
+
+	for _, name := range unionKeys(current.Sequences, desired.Sequences) {
+		currentSequence, inCurrent := current.Sequences[name]
+		desiredSequence, inDesired := desired.Sequences[name]
+		equal := inCurrent && inDesired && sequencesEqual(currentSequence, desiredSequence)
+		compare("sequence "+name, inCurrent, inDesired, equal)
+	}
+
+	// The proto bundle is compared type by type
+	var currentTypes, desiredTypes []string
+	if current.ProtoBundle != nil {
+		currentTypes = current.ProtoBundle.Types
+	}
+	if desired.ProtoBundle != nil {
+		desiredTypes = desired.ProtoBundle.Types
+	}
+	for _, name := range currentTypes {
+		compare("proto type "+name, true, slices.Contains(desiredTypes, name), true)
+	}
+	for _, name := range desiredTypes {
+		compare("proto type "+name, slices.Contains(currentTypes, name), true, true)
+	}
+	return drift
+}
+
+// tablesEqual compares the table-level attributes of two tables, leaving
+// columns and constraints to be compared one by one
+func tablesEqual(current, desired *Table) bool {
+	return primaryKey(current) == primaryKey(desired) &&
+		current.ParentTable == desired.ParentTable &&
+		onDeleteAction(current.OnDelete) == onDeleteAction(desired.OnDelete) &&
+		current.Synonym == desired.Synonym &&
+		current.RowDeletionPolicyColumn == desired.RowDeletionPolicyColumn &&
+		current.RowDeletionPolicyDays == desired.RowDeletionPolicyDays
+}
+
+// columnsEqual compares the definitions of two columns, ignoring their
+// position in the table
+func columnsEqual(current, desired *Column) bool {
+	return current.Type == desired.Type &&
+		current.NotNull == desired.NotNull &&
+		defaultsEqual(current.Default, desired.Default) &&
+		identitiesEqual(current, desired) &&
+		generatedClause(current) == generatedClause(desired) &&
+		maps.Equal(current.Options, desired.Options)
+}
+
+// unionKeys returns the keys of a and b in sorted order
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := sortedKeys(a)
+	for _, key := range sortedKeys(b) {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// WriteText writes the matrix as a table with one row per drifted object
+// and one column per environment, plus an owner column if any object has
+// an owner and a severity column if any database option drifted
+func (m *DriftMatrix) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "OBJECT"
+	if len(m.Owners) > 0 {
+		header += "\tOWNER"
+	}
+	if len(m.Severities) > 0 {
+		header += "\tSEVERITY"
+	}
+	fmt.Fprintf(tw, "%s\t%s\n", header, strings.Join(m.Environments, "\t"))
+	for _, object := range sortedKeys(m.Objects) {
+		cells := make([]string, len(m.Environments))
+		for i, env := range m.Environments {
+			cells[i] = "-"
+			if kind, ok := m.Objects[object][env]; ok {
+				cells[i] = kind
+			} else if _, failed := m.Errors[env]; failed {
+				cells[i] = "?"
+			}
+		}
+		row := object
+		if len(m.Owners) > 0 {
+			owner := m.Owners[object]
+			if owner == "" {
+				owner = "-"
+			}
+			row += "\t" + owner
+		}
+		if len(m.Severities) > 0 {
+			severity := m.Severities[object]
+			if severity == "" {
+				severity = "-"
+			}
+			row += "\t" + severity
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", row, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, env := range m.Environments {
+		if msg, ok := m.Errors[env]; ok {
+			if _, err := fmt.Fprintf(w, "-- %s: %s --\n", env, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the matrix as a JSON object
+func (m *DriftMatrix) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
@@ -0,0 +1,109 @@
+package spannerdef
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildDriftMatrix(t *testing.T) {
+	desired := `
+		CREATE TABLE Users (
+			Id INT64 NOT NULL,
+			Email STRING(255),
+		) PRIMARY KEY (Id);
+		CREATE INDEX IdxUsersEmail ON Users (Email);
+	`
+	dbs := []Database{
+		&fakeDatabase{ddls: desired},
+		&fakeDatabase{ddls: `
+			CREATE TABLE Users (
+				Id INT64 NOT NULL,
+				Email STRING(100),
+			) PRIMARY KEY (Id);
+			CREATE TABLE Debug (Id INT64 NOT NULL) PRIMARY KEY (Id);
+		`},
+		&fakeDatabase{dumpErr: errors.New("permission denied")},
+	}
+
+	matrix, err := BuildDriftMatrix(desired, []string{"dev", "prod", "tenant-1"}, dbs, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"column Users.Email":  {"prod": DriftDiffers},
+		"index IdxUsersEmail": {"prod": DriftMissing},
+		"table Debug":         {"prod": DriftUnexpected},
+	}, matrix.Objects)
+	assert.Equal(t, map[string]string{"tenant-1": "permission denied"}, matrix.Errors)
+
+	var buf bytes.Buffer
+	require.NoError(t, matrix.WriteText(&buf))
+	assert.Equal(t, `OBJECT               dev  prod        tenant-1
+column Users.Email   -    differs     ?
+index IdxUsersEmail  -    missing     ?
+table Debug          -    unexpected  ?
+-- tenant-1: permission denied --
+`, buf.String())
+}
+
+func TestDiffObjects_ColumnOrder(t *testing.T) {
+	current, err := ParseDDLs("CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(10)) PRIMARY KEY (Id)")
+	require.NoError(t, err)
+	desired, err := ParseDDLs("CREATE TABLE Users (Name STRING(10), Id INT64 NOT NULL) PRIMARY KEY (Id)")
+	require.NoError(t, err)
+
+	// Column order alone is not drift
+	assert.Empty(t, diffObjects(current, desired))
+}
+
+func TestDiffObjects_StoringOrder(t *testing.T) {
+	table := "CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(10), Email STRING(10)) PRIMARY KEY (Id);"
+	current, err := ParseDDLs(table + "CREATE INDEX IdxUsersName ON Users (Name) STORING (Email, Id)")
+	require.NoError(t, err)
+	desired, err := ParseDDLs(table + "CREATE INDEX IdxUsersName ON Users (Name) STORING (Id, Email)")
+	require.NoError(t, err)
+
+	// The order of stored columns is not drift
+	assert.Empty(t, diffObjects(current, desired))
+	assert.Empty(t, GenerateDDLs(current, desired))
+}
+
+func TestBuildDriftMatrix_DatabaseOptions(t *testing.T) {
+	desired := `
+		ALTER DATABASE app SET OPTIONS (version_retention_period = '7d', default_leader = 'us-east1');
+		CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+	`
+	dbs := []Database{
+		&fakeDatabase{ddls: "ALTER DATABASE `app-dev` SET OPTIONS (version_retention_period = '7d', default_leader = 'us-east1');\n" +
+			"CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)"},
+		&fakeDatabase{ddls: "ALTER DATABASE `app-prod` SET OPTIONS (version_retention_period = '1h', optimizer_version = 6);\n" +
+			"CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)"},
+	}
+
+	matrix, err := BuildDriftMatrix(desired, []string{"dev", "prod"}, dbs, GeneratorConfig{})
+	require.NoError(t, err)
+	// Database names differ between environments and aren't drift
+	assert.Equal(t, map[string]map[string]string{
+		"database option default_leader":           {"prod": DriftMissing},
+		"database option optimizer_version":        {"prod": DriftUnexpected},
+		"database option version_retention_period": {"prod": DriftDiffers},
+	}, matrix.Objects)
+	assert.False(t, matrix.HasErrors())
+
+	matrix.SetOptionSeverity(DriftSeverityError)
+	assert.True(t, matrix.HasErrors())
+
+	var buf bytes.Buffer
+	require.NoError(t, matrix.WriteText(&buf))
+	assert.Equal(t, `OBJECT                                    SEVERITY  dev  prod
+database option default_leader            error     -    missing
+database option optimizer_version         error     -    unexpected
+database option version_retention_period  error     -    differs
+`, buf.String())
+
+	matrix.SetOptionSeverity(DriftSeverityIgnore)
+	assert.Empty(t, matrix.Objects)
+	assert.False(t, matrix.HasErrors())
+}
@@ -0,0 +1,40 @@
+package spannerdef
+
+// Duration classes of a statement, see estimateDuration
+const (
+	DurationInstant = "instant" // a metadata-only change
+	DurationMinutes = "minutes" // a backfill or validation of a small table
+	DurationHours   = "hours"   // a backfill or validation of a large table
+)
+
+// hoursThreshold is the size of a table, in bytes, from which backfilling
+// or validating it is expected to take hours
+const hoursThreshold = 50 << 30
+
+// TableSizer is implemented by databases that can tell the size of their
+// tables, from which statements are estimated to be quick or slow
+type TableSizer interface {
+	// TableSizes returns the used bytes by table. Tables that aren't in
+	// the statistics yet are left out.
+	TableSizes() (map[string]int64, error)
+}
+
+// estimateDuration returns the duration class of ddl, generated from
+// current. Statements that backfill an index or validate existing rows
+// take time depending on the size of their table: sizes, or hours if the
+// sizes are unknown (nil). Tables created by the same plan are empty.
+func estimateDuration(ddl string, current *Schema, sizes map[string]int64) string {
+	op := classifyDDL(ddl)
+	switch op.kind {
+	case opCreateIndex, opAlterColumnType, opAddConstraint:
+	default:
+		return DurationInstant
+	}
+	if _, ok := current.Tables[op.table]; !ok {
+		return DurationInstant
+	}
+	if sizes == nil || sizes[op.table] >= hoursThreshold {
+		return DurationHours
+	}
+	return DurationMinutes
+}
@@ -0,0 +1,361 @@
+package spannerdef
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// explainDDLs returns, for each generated statement, why it was generated.
+// When the statement comes from a definition in the desired schema, its
+// location in sources is appended so reviewers can jump to it.
+func explainDDLs(ddls []string, current, desired *Schema, sources []Source) []string {
+	reasons := make([]string, len(ddls))
+	for i, ddl := range ddls {
+		reason, pos := explainDDL(ddl, current, desired)
+		if reason == "" {
+			continue
+		}
+		if tombstoned(ddl, current) {
+			reason += ", and is marked as dropped"
+		}
+		if location := locate(sources, pos); pos >= 0 && location != "" {
+			reason += " (" + location + ")"
+		}
+		reasons[i] = reason
+	}
+	return reasons
+}
+
+// locateDDLs returns, for each generated statement, the "path:line" in
+// sources of the desired definition it comes from, or "" if there is none
+func locateDDLs(ddls []string, current, desired *Schema, sources []Source) []string {
+	locations := make([]string, len(ddls))
+	for i, ddl := range ddls {
+		if _, pos := explainDDL(ddl, current, desired); pos >= 0 {
+			locations[i] = locate(sources, pos)
+		}
+	}
+	return locations
+}
+
+// explainDDL describes the difference between current and desired that
+// made GenerateDDLs emit ddl, e.g. "column Users.Email exists in desired
+// but not current", along with the offset of the desired definition or -1
+// if there is none. It returns "" for statements it doesn't know about.
+func explainDDL(ddl string, current, desired *Schema) (string, int) {
+	stmt, err := memefish.ParseDDL("", ddl)
+	if err != nil {
+		return "", -1
+	}
+
+	switch s := stmt.(type) {
+	case *ast.CreateTable:
+		name := getPathName(s.Name)
+		if _, ok := current.Tables[name]; ok {
+			return fmt.Sprintf("table %s is recreated to change its primary key", name), tablePos(desired, name)
+		}
+		return fmt.Sprintf("table %s exists in desired but not current", name), tablePos(desired, name)
+	case *ast.DropTable:
+		name := getPathName(s.Name)
+		if _, ok := desired.Tables[name]; ok {
+			return fmt.Sprintf("table %s is recreated to change its primary key", name), -1
+		}
+		return fmt.Sprintf("table %s exists in current but not desired", name), -1
+	case *ast.CreateIndex:
+		name := getPathName(s.Name)
+		pos := -1
+		if index, ok := desired.Indexes[name]; ok {
+			pos = index.Pos
+			if _, ok := current.Indexes[name]; ok && recreatedTables(current, desired)[index.TableName] {
+				return fmt.Sprintf("table %s of index %s is recreated", index.TableName, name), pos
+			}
+		}
+		if _, ok := current.Indexes[name]; ok {
+			return describeRecreatedIndex(name, current, desired), pos
+		}
+		return fmt.Sprintf("index %s exists in desired but not current", name), pos
+	case *ast.DropIndex:
+		name := getPathName(s.Name)
+		if index, ok := current.Indexes[name]; ok {
+			if _, ok := desired.Tables[index.TableName]; !ok {
+				return fmt.Sprintf("table %s of index %s exists in current but not desired", index.TableName, name), -1
+			}
+			if recreatedTables(current, desired)[index.TableName] {
+				return fmt.Sprintf("table %s of index %s is recreated", index.TableName, name), -1
+			}
+		}
+		if _, ok := desired.Indexes[name]; ok {
+			return describeRecreatedIndex(name, current, desired), -1
+		}
+		return fmt.Sprintf("index %s exists in current but not desired", name), -1
+	case *ast.CreateVectorIndex:
+		pos := -1
+		if index, ok := desired.VectorIndexes[s.Name.Name]; ok {
+			pos = index.Pos
+		}
+		return fmt.Sprintf("vector index %s exists in desired but not current", s.Name.Name), pos
+	case *ast.DropVectorIndex:
+		if index, ok := current.VectorIndexes[s.Name.Name]; ok {
+			if _, ok := desired.Tables[index.TableName]; !ok {
+				return fmt.Sprintf("table %s of vector index %s exists in current but not desired", index.TableName, s.Name.Name), -1
+			}
+			if recreatedTables(current, desired)[index.TableName] {
+				return fmt.Sprintf("table %s of vector index %s is recreated", index.TableName, s.Name.Name), -1
+			}
+		}
+		return fmt.Sprintf("vector index %s exists in current but not desired", s.Name.Name), -1
+	case *ast.AlterVectorIndex:
+		name := getPathName(s.Name)
+		pos := -1
+		if index, ok := desired.VectorIndexes[name]; ok {
+			pos = index.Pos
+		}
+		return fmt.Sprintf("vector index %s differs between current and desired", name), pos
+	case *ast.AlterTable:
+		return explainAlterTable(s, current, desired)
+	case *ast.CreateView:
+		name := getPathName(s.Name)
+		pos := -1
+		view, ok := desired.Views[name]
+		if ok {
+			pos = view.Pos
+		}
+		if s.OrReplace {
+			if currentView := current.Views[name]; ok && currentView != nil && currentView.SecurityType != view.SecurityType {
+				return fmt.Sprintf("view %s SQL SECURITY differs: current %s, desired %s", name, currentView.SecurityType, view.SecurityType), pos
+			}
+			return fmt.Sprintf("view %s query differs between current and desired", name), pos
+		}
+		return fmt.Sprintf("view %s exists in desired but not current", name), pos
+	case *ast.DropView:
+		return fmt.Sprintf("view %s exists in current but not desired", getPathName(s.Name)), -1
+	case *ast.CreateChangeStream:
+		pos := -1
+		if stream, ok := desired.ChangeStreams[s.Name.Name]; ok {
+			pos = stream.Pos
+		}
+		return fmt.Sprintf("change stream %s exists in desired but not current", s.Name.Name), pos
+	case *ast.DropChangeStream:
+		return fmt.Sprintf("change stream %s exists in current but not desired", s.Name.Name), -1
+	case *ast.AlterChangeStream:
+		pos := -1
+		if stream, ok := desired.ChangeStreams[s.Name.Name]; ok {
+			pos = stream.Pos
+		}
+		return fmt.Sprintf("change stream %s differs between current and desired", s.Name.Name), pos
+	case *ast.CreatePlacement:
+		pos := -1
+		if placement, ok := desired.Placements[s.Name.Name]; ok {
+			pos = placement.Pos
+		}
+		return fmt.Sprintf("placement %s exists in desired but not current", s.Name.Name), pos
+	case *ast.CreateSchema:
+		pos := -1
+		if namedSchema, ok := desired.NamedSchemas[s.Name.Name]; ok {
+			pos = namedSchema.Pos
+		}
+		return fmt.Sprintf("schema %s exists in desired but not current", s.Name.Name), pos
+	case *ast.DropSchema:
+		return fmt.Sprintf("schema %s exists in current but not desired", s.Name.Name), -1
+	case *ast.CreateRole:
+		pos := -1
+		if role, ok := desired.Roles[s.Name.Name]; ok {
+			pos = role.Pos
+		}
+		return fmt.Sprintf("role %s exists in desired but not current", s.Name.Name), pos
+	case *ast.DropRole:
+		return fmt.Sprintf("role %s exists in current but not desired", s.Name.Name), -1
+	case *ast.Grant:
+		pos := -1
+		if role, ok := desired.Roles[s.Roles[0].Name]; ok {
+			pos = role.Pos
+		}
+		return fmt.Sprintf("role %s is granted %s in desired but not current", s.Roles[0].Name, s.Privilege.SQL()), pos
+	case *ast.Revoke:
+		return fmt.Sprintf("role %s is granted %s in current but not desired", s.Roles[0].Name, s.Privilege.SQL()), -1
+	case *ast.CreateProtoBundle:
+		pos := -1
+		if desired.ProtoBundle != nil {
+			pos = desired.ProtoBundle.Pos
+		}
+		return "proto bundle exists in desired but not current", pos
+	case *ast.AlterProtoBundle:
+		pos := -1
+		if desired.ProtoBundle != nil {
+			pos = desired.ProtoBundle.Pos
+		}
+		if s.Delete != nil {
+			return fmt.Sprintf("proto types %s exist in current but not desired", s.Delete.Types.SQL()), -1
+		}
+		return fmt.Sprintf("proto types %s exist in desired but not current", s.Insert.Types.SQL()), pos
+	case *ast.DropProtoBundle:
+		return "proto bundle exists in current but not desired", -1
+	case *ast.CreateSequence:
+		name := getPathName(s.Name)
+		pos := -1
+		if sequence, ok := desired.Sequences[name]; ok {
+			pos = sequence.Pos
+		}
+		return fmt.Sprintf("sequence %s exists in desired but not current", name), pos
+	case *ast.DropSequence:
+		return fmt.Sprintf("sequence %s exists in current but not desired", getPathName(s.Name)), -1
+	case *ast.AlterSequence:
+		name := getPathName(s.Name)
+		pos := -1
+		if sequence, ok := desired.Sequences[name]; ok {
+			pos = sequence.Pos
+		}
+		return fmt.Sprintf("sequence %s differs between current and desired", name), pos
+	case *ast.AlterDatabase:
+		names := make([]string, len(s.Options.Records))
+		for i, record := range s.Options.Records {
+			names[i] = record.Name.Name
+		}
+		return fmt.Sprintf("database options %s differ between current and desired", strings.Join(names, ", ")), -1
+	}
+	return "", -1
+}
+
+// tablePos returns the offset of the definition of table name, or -1
+func tablePos(schema *Schema, name string) int {
+	if table, ok := schema.Tables[name]; ok {
+		return table.Pos
+	}
+	return -1
+}
+
+// explainAlterTable is explainDDL for ALTER TABLE statements
+func explainAlterTable(stmt *ast.AlterTable, current, desired *Schema) (string, int) {
+	tableName := getPathName(stmt.Name)
+	if a, ok := stmt.TableAlteration.(*ast.RenameTo); ok {
+		return fmt.Sprintf("table %s is renamed from %s", a.Name.Name, tableName), tablePos(desired, a.Name.Name)
+	}
+	currentTable, desiredTable := current.Tables[tableName], desired.Tables[tableName]
+	if currentTable == nil && desiredTable != nil {
+		currentTable = current.Tables[desiredTable.RenamedFrom]
+	}
+	if currentTable == nil || desiredTable == nil {
+		return "", -1
+	}
+
+	switch a := stmt.TableAlteration.(type) {
+	case *ast.AddColumn:
+		name := a.Column.Name.Name
+		pos := -1
+		if col, ok := desiredTable.Columns[name]; ok {
+			pos = col.Pos
+		}
+		if _, ok := currentTable.Columns[name]; ok {
+			return fmt.Sprintf("column %s.%s is added back with its desired generated expression", tableName, name), pos
+		}
+		if col, ok := desiredTable.Columns[name]; ok && col.RenamedFrom != "" {
+			return fmt.Sprintf("column %s.%s replaces %s, which Spanner can't rename", tableName, name, col.RenamedFrom), pos
+		}
+		return fmt.Sprintf("column %s.%s exists in desired but not current", tableName, name), pos
+	case *ast.DropColumn:
+		if col, ok := desiredTable.Columns[a.Name.Name]; ok {
+			return fmt.Sprintf("column %s.%s is recreated to change its generated expression", tableName, a.Name.Name), col.Pos
+		}
+		return fmt.Sprintf("column %s.%s exists in current but not desired", tableName, a.Name.Name), -1
+	case *ast.AddTableConstraint:
+		name := ""
+		if a.TableConstraint.Name != nil {
+			name = a.TableConstraint.Name.Name
+		}
+		pos := -1
+		if constraint, ok := desiredTable.Constraints[name]; ok {
+			pos = constraint.Pos
+		}
+		if constraint, ok := currentTable.Constraints[name]; ok {
+			if recreatedTables(current, desired)[constraint.ReferenceTable] {
+				return fmt.Sprintf("table %s referenced by constraint %s.%s is recreated", constraint.ReferenceTable, tableName, name), pos
+			}
+			return fmt.Sprintf("constraint %s.%s differs between current and desired", tableName, name), pos
+		}
+		return fmt.Sprintf("constraint %s.%s exists in desired but not current", tableName, name), pos
+	case *ast.DropConstraint:
+		name := a.Name.Name
+		if constraint, ok := desiredTable.Constraints[name]; ok {
+			if dropped, ok := currentTable.Constraints[name]; ok && recreatedTables(current, desired)[dropped.ReferenceTable] {
+				return fmt.Sprintf("table %s referenced by constraint %s.%s is recreated", dropped.ReferenceTable, tableName, name), constraint.Pos
+			}
+			return fmt.Sprintf("constraint %s.%s differs between current and desired", tableName, name), constraint.Pos
+		}
+		return fmt.Sprintf("constraint %s.%s exists in current but not desired", tableName, name), -1
+	case *ast.AddSynonym, *ast.DropSynonym:
+		return fmt.Sprintf("synonym of %s differs: current %s, desired %s",
+			tableName, describeSynonym(currentTable), describeSynonym(desiredTable)), desiredTable.Pos
+	case *ast.SetOnDelete:
+		return fmt.Sprintf("ON DELETE action of %s differs: current %s, desired %s",
+			tableName, onDeleteAction(currentTable.OnDelete), onDeleteAction(desiredTable.OnDelete)), desiredTable.Pos
+	case *ast.AddRowDeletionPolicy, *ast.ReplaceRowDeletionPolicy, *ast.DropRowDeletionPolicy:
+		return fmt.Sprintf("row deletion policy of %s differs: current %s, desired %s",
+			tableName, describePolicy(currentTable), describePolicy(desiredTable)), desiredTable.Pos
+	case *ast.AlterColumn:
+		name := a.Name.Name
+		currentCol, desiredCol := currentTable.Columns[name], desiredTable.Columns[name]
+		if currentCol == nil || desiredCol == nil {
+			return "", -1
+		}
+		switch a.Alteration.(type) {
+		case *ast.AlterColumnType:
+			return fmt.Sprintf("column %s.%s type differs: current %s, desired %s",
+				tableName, name, describeColumnType(currentCol), describeColumnType(desiredCol)), desiredCol.Pos
+		case *ast.AlterColumnSetOptions:
+			return fmt.Sprintf("column %s.%s options differ: current %s, desired %s",
+				tableName, name, describeOptions(currentCol), describeOptions(desiredCol)), desiredCol.Pos
+		case *ast.AlterColumnSetDefault, *ast.AlterColumnDropDefault:
+			return fmt.Sprintf("column %s.%s default differs: current %s, desired %s",
+				tableName, name, describeDefault(currentCol), describeDefault(desiredCol)), desiredCol.Pos
+		case *ast.AlterColumnAlterIdentity:
+			return fmt.Sprintf("column %s.%s identity differs: current %s, desired %s",
+				tableName, name, currentCol.Identity, desiredCol.Identity), desiredCol.Pos
+		}
+	}
+	return "", -1
+}
+
+func describeColumnType(col *Column) string {
+	if col.NotNull {
+		return col.Type + " NOT NULL"
+	}
+	return col.Type
+}
+
+func describePolicy(table *Table) string {
+	if policy := rowDeletionPolicy(table); policy != "" {
+		return fmt.Sprintf("OLDER_THAN(%s, INTERVAL %d DAY)", table.RowDeletionPolicyColumn, table.RowDeletionPolicyDays)
+	}
+	return "none"
+}
+
+func describeDefault(col *Column) string {
+	if col.Default == "" {
+		return "none"
+	}
+	return col.Default
+}
+
+func describeOptions(col *Column) string {
+	if options := formatColumnOptions(col); options != "" {
+		return options
+	}
+	return "none"
+}
+
+func describeRecreatedIndex(name string, current, desired *Schema) string {
+	if !indexesEqual(current.Indexes[name], desired.Indexes[name]) {
+		return fmt.Sprintf("index %s is recreated to change its definition", name)
+	}
+	return fmt.Sprintf("index %s is recreated to change the type of its columns", name)
+}
+
+func describeSynonym(table *Table) string {
+	if table.Synonym == "" {
+		return "none"
+	}
+	return table.Synonym
+}
@@ -0,0 +1,100 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainDDLs(t *testing.T) {
+	current, err := ParseDDLs(`
+		CREATE TABLE Users (
+			Id INT64 NOT NULL,
+			Name STRING(100),
+			Legacy STRING(10),
+			CreatedAt TIMESTAMP OPTIONS (allow_commit_timestamp = true),
+			CONSTRAINT ChkName CHECK (Name != ''),
+		) PRIMARY KEY (Id);
+		CREATE INDEX IdxUsersName ON Users (Name);
+		CREATE INDEX IdxUsersIdName ON Users (Id, Name);
+		CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);
+		CREATE INDEX IdxLogs ON Logs (Id);
+		CREATE INDEX IdxUsersId ON Users (Id);
+	`)
+	require.NoError(t, err)
+
+	desired, err := ParseDDLs(`
+		CREATE TABLE Users (
+			Id INT64 NOT NULL,
+			Name STRING(200) NOT NULL,
+			Email STRING(255),
+			CreatedAt TIMESTAMP,
+			CONSTRAINT ChkName CHECK (LENGTH(Name) > 0),
+		) PRIMARY KEY (Id);
+		CREATE INDEX IdxUsersEmail ON Users (Email);
+		CREATE INDEX IdxUsersIdName ON Users (Id, Name);
+		CREATE TABLE Posts (Id INT64 NOT NULL) PRIMARY KEY (Id);
+		CREATE UNIQUE INDEX IdxUsersId ON Users (Id);
+	`)
+	require.NoError(t, err)
+
+	ddls := GenerateDDLs(current, desired)
+	reasons := explainDDLs(ddls, current, desired, nil)
+
+	explained := make(map[string]string)
+	for i, ddl := range ddls {
+		explained[ddl] = reasons[i]
+	}
+	assert.Equal(t, map[string]string{
+		"DROP INDEX IdxLogs":                                            "table Logs of index IdxLogs exists in current but not desired",
+		"DROP INDEX IdxUsersName":                                       "index IdxUsersName exists in current but not desired",
+		"DROP INDEX IdxUsersIdName":                                     "index IdxUsersIdName is recreated to change the type of its columns",
+		"DROP INDEX IdxUsersId":                                         "index IdxUsersId is recreated to change its definition",
+		"ALTER TABLE Users DROP CONSTRAINT ChkName":                     "constraint Users.ChkName differs between current and desired",
+		"DROP TABLE Logs":                                               "table Logs exists in current but not desired",
+		"ALTER TABLE Users DROP COLUMN Legacy":                          "column Users.Legacy exists in current but not desired",
+		"CREATE TABLE Posts (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)": "table Posts exists in desired but not current",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)":                "column Users.Email exists in desired but not current",
+		"ALTER TABLE Users ALTER COLUMN Name STRING(200) NOT NULL":      "column Users.Name type differs: current STRING(100), desired STRING(200) NOT NULL",
+		"ALTER TABLE Users ALTER COLUMN CreatedAt SET OPTIONS (allow_commit_timestamp = null)": "column Users.CreatedAt options differ: current OPTIONS (allow_commit_timestamp = true), desired none",
+		"ALTER TABLE Users ADD CONSTRAINT ChkName CHECK (LENGTH(Name) > 0)":                    "constraint Users.ChkName differs between current and desired",
+		"CREATE INDEX IdxUsersEmail ON Users (Email)":                                          "index IdxUsersEmail exists in desired but not current",
+		"CREATE INDEX IdxUsersIdName ON Users (Id, Name)":                                      "index IdxUsersIdName is recreated to change the type of its columns",
+		"CREATE UNIQUE INDEX IdxUsersId ON Users (Id)":                                         "index IdxUsersId is recreated to change its definition",
+	}, explained)
+}
+
+func TestExplainDDLs_SourceLocations(t *testing.T) {
+	sources := []Source{
+		{Path: "schema/users.sql", DDLs: "CREATE TABLE Users (\n  Id INT64 NOT NULL,\n  Email STRING(255),\n) PRIMARY KEY (Id);\n"},
+		{Path: "schema/posts.sql", DDLs: "\nCREATE TABLE Posts (\n  Id INT64 NOT NULL,\n) PRIMARY KEY (Id);\n\nCREATE INDEX IdxUsersEmail ON Users (Email);\n"},
+	}
+
+	current, err := ParseDDLs("CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)")
+	require.NoError(t, err)
+	desired, err := ParseDDLs(JoinSources(sources))
+	require.NoError(t, err)
+
+	ddls := GenerateDDLs(current, desired)
+	assert.Equal(t, []string{
+		"table Posts exists in desired but not current (schema/posts.sql:2)",
+		"column Users.Email exists in desired but not current (schema/users.sql:3)",
+		"index IdxUsersEmail exists in desired but not current (schema/posts.sql:6)",
+	}, explainDDLs(ddls, current, desired, sources))
+
+	// The JSON report has them as well
+	report := newReport("", true, ddls, nil, nil, current, false, nil)
+	report.locate(current, desired, sources)
+	out, err := renderReportJSON(report)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"source": "schema/users.sql:3"`)
+	var locations []string
+	for _, statement := range report.Statements {
+		locations = append(locations, statement.Source)
+	}
+	assert.Equal(t, []string{"schema/posts.sql:2", "schema/users.sql:3", "schema/posts.sql:6"}, locations)
+
+	// Statements without a desired definition have none
+	assert.Equal(t, []string{""}, locateDDLs([]string{"DROP TABLE Logs"}, current, desired, sources))
+}
@@ -0,0 +1,34 @@
+package spannerdef
+
+import (
+	"fmt"
+)
+
+// metadataTable is the table of the database spannerdef reads markers from,
+// such as the row freezing schema changes. It isn't part of the managed
+// schema: spannerdef neither creates nor drops it.
+const metadataTable = "SpannerdefMetadata"
+
+// FreezeReader is implemented by databases that can read the freeze marker
+// of the metadata table, the row with Key 'freeze', whose Value is the
+// reason schema changes are frozen
+type FreezeReader interface {
+	ReadFreeze() (string, error)
+}
+
+// freezeReason returns why schema changes to db are frozen, from the config
+// or else from the metadata table, or "" if they aren't
+func freezeReason(db Database, config GeneratorConfig) (string, error) {
+	if config.Freeze != "" {
+		return config.Freeze, nil
+	}
+	reader, ok := db.(FreezeReader)
+	if !ok {
+		return "", nil
+	}
+	reason, err := reader.ReadFreeze()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the freeze marker of %s: %v", metadataTable, err)
+	}
+	return reason, nil
+}
@@ -0,0 +1,48 @@
+package spannerdef
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freezeDatabase is a fakeDatabase with a freeze marker
+type freezeDatabase struct {
+	fakeDatabase
+	reason string
+	err    error
+}
+
+func (d *freezeDatabase) ReadFreeze() (string, error) {
+	return d.reason, d.err
+}
+
+func TestFreezeReason(t *testing.T) {
+	// The config takes precedence over the marker
+	reason, err := freezeReason(&freezeDatabase{reason: "Marker"}, GeneratorConfig{Freeze: "Config"})
+	require.NoError(t, err)
+	assert.Equal(t, "Config", reason)
+
+	reason, err = freezeReason(&freezeDatabase{reason: "Marker"}, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, "Marker", reason)
+
+	reason, err = freezeReason(&fakeDatabase{}, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+
+	_, err = freezeReason(&freezeDatabase{err: errors.New("permission denied")}, GeneratorConfig{})
+	assert.EqualError(t, err, "failed to read the freeze marker of SpannerdefMetadata: permission denied")
+}
+
+func TestGenerateIdempotentDDLs_MetadataTableIsKept(t *testing.T) {
+	current := `CREATE TABLE SpannerdefMetadata (Key STRING(MAX) NOT NULL, Value STRING(MAX)) PRIMARY KEY (Key);
+CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)`
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
@@ -0,0 +1,207 @@
+package spannerdef
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// generatedClause returns the AS clause of a generated column, or "" for
+// other columns
+func generatedClause(col *Column) string {
+	if col.GeneratedExpr == "" {
+		return ""
+	}
+	if col.Stored {
+		return "AS " + col.GeneratedExpr + " STORED"
+	}
+	return "AS " + col.GeneratedExpr
+}
+
+// columnReferences returns the names of the columns the expression of a
+// generated column reads
+func columnReferences(expr string) []string {
+	parsed, err := memefish.ParseExpr("", expr)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	ast.Inspect(parsed, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Ident:
+			names = append(names, n.Name)
+		case *ast.Path:
+			// Function names, such as SAFE.SUBSTR
+			return false
+		}
+		return true
+	})
+	return names
+}
+
+// sortColumnsForDrop sorts columns to be dropped from a table so that
+// generated columns come before the columns their expressions read, which
+// Spanner refuses to drop while they are read
+func sortColumnsForDrop(columns []*Column) []*Column {
+	readers := make(map[string][]*Column)
+	for _, col := range columns {
+		for _, name := range columnReferences(col.GeneratedExpr) {
+			readers[strings.ToLower(name)] = append(readers[strings.ToLower(name)], col)
+		}
+	}
+
+	var sorted []*Column
+	visited := make(map[string]bool)
+	var visit func(col *Column)
+	visit = func(col *Column) {
+		if visited[col.Name] {
+			return
+		}
+		visited[col.Name] = true
+		for _, reader := range readers[strings.ToLower(col.Name)] {
+			visit(reader)
+		}
+		sorted = append(sorted, col)
+	}
+	for _, col := range columns {
+		visit(col)
+	}
+	return sorted
+}
+
+// sortColumnsForAdd sorts columns to be added to a table so that generated
+// columns come after the columns their expressions read, keeping the order
+// of columns otherwise
+func sortColumnsForAdd(columns []*Column) []*Column {
+	byName := make(map[string]*Column, len(columns))
+	for _, col := range columns {
+		byName[strings.ToLower(col.Name)] = col
+	}
+
+	var sorted []*Column
+	visited := make(map[string]bool)
+	var visit func(col *Column)
+	visit = func(col *Column) {
+		if visited[col.Name] {
+			return
+		}
+		visited[col.Name] = true
+		for _, name := range columnReferences(col.GeneratedExpr) {
+			if read, ok := byName[strings.ToLower(name)]; ok {
+				visit(read)
+			}
+		}
+		sorted = append(sorted, col)
+	}
+	for _, col := range columns {
+		visit(col)
+	}
+	return sorted
+}
+
+// changedGeneratedColumn reports whether col is a generated column whose
+// expression differs in desiredTable
+func changedGeneratedColumn(col *Column, desiredTable *Table) bool {
+	desiredCol, ok := desiredTable.Columns[col.Name]
+	return ok && col.GeneratedExpr != "" && desiredCol.GeneratedExpr != "" &&
+		generatedClause(col) != generatedClause(desiredCol)
+}
+
+// checkGeneratedColumns returns an error listing the generated columns
+// whose expression differs between current and desired, which Spanner
+// can't change
+func checkGeneratedColumns(current, desired *Schema) error {
+	var errs []error
+	for _, name := range sortedKeys(current.Tables) {
+		table, desiredTable := current.Tables[name], desired.Tables[name]
+		if desiredTable == nil {
+			continue
+		}
+		for _, col := range sortedColumns(table) {
+			if changedGeneratedColumn(col, desiredTable) {
+				errs = append(errs, fmt.Errorf("expression of generated column %s.%s differs: current %s, desired %s; Spanner can't change it, set recreate_generated_columns in --config to drop and add the column again",
+					name, col.Name, col.GeneratedExpr, desiredTable.Columns[col.Name].GeneratedExpr))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recreatedColumns returns the columns of table dropped and added back to
+// change generated expressions, in the order they are dropped: those whose
+// expression differs in desiredTable, and the generated columns reading
+// them, which Spanner refuses to leave reading a dropped column
+func recreatedColumns(table, desiredTable *Table) []*Column {
+	recreated := make(map[string]bool)
+	var columns []*Column
+	for changed := true; changed; {
+		changed = false
+		for _, col := range sortedColumns(table) {
+			if recreated[strings.ToLower(col.Name)] {
+				continue
+			}
+			desiredCol, ok := desiredTable.Columns[col.Name]
+			if !ok || col.GeneratedExpr == "" || desiredCol.GeneratedExpr == "" {
+				continue
+			}
+			reads := slices.ContainsFunc(columnReferences(col.GeneratedExpr), func(name string) bool {
+				return recreated[strings.ToLower(name)]
+			})
+			if reads || changedGeneratedColumn(col, desiredTable) {
+				recreated[strings.ToLower(col.Name)] = true
+				columns = append(columns, col)
+				changed = true
+			}
+		}
+	}
+	return sortColumnsForDrop(columns)
+}
+
+// recreateGeneratedColumns returns the statements dropping the columns of
+// recreatedColumns, which Spanner can't alter, along with the indexes on
+// them, and a copy of current without them, from which GenerateDDLs adds
+// them back as desired
+func recreateGeneratedColumns(current, desired *Schema) ([]string, *Schema) {
+	recreated := *current
+	recreated.Tables = make(map[string]*Table, len(current.Tables))
+	for name, table := range current.Tables {
+		recreated.Tables[name] = table
+	}
+	recreated.Indexes = make(map[string]*Index, len(current.Indexes))
+	for name, index := range current.Indexes {
+		recreated.Indexes[name] = index
+	}
+
+	var dropIndexes, dropColumns []string
+	for _, tableName := range sortedKeys(current.Tables) {
+		table, desiredTable := current.Tables[tableName], desired.Tables[tableName]
+		if desiredTable == nil {
+			continue
+		}
+		for _, col := range recreatedColumns(table, desiredTable) {
+			if recreated.Tables[tableName] == table {
+				copied := *table
+				copied.Columns = make(map[string]*Column, len(table.Columns))
+				for n, c := range table.Columns {
+					copied.Columns[n] = c
+				}
+				recreated.Tables[tableName] = &copied
+			}
+			delete(recreated.Tables[tableName].Columns, col.Name)
+			dropColumns = append(dropColumns, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", quoteName(tableName), quoteName(col.Name)))
+
+			for _, indexName := range sortedKeys(recreated.Indexes) {
+				index := recreated.Indexes[indexName]
+				if index.TableName == tableName && (slices.Contains(index.Columns, col.Name) || slices.Contains(index.Storing, col.Name)) {
+					delete(recreated.Indexes, indexName)
+					dropIndexes = append(dropIndexes, fmt.Sprintf("DROP INDEX %s", quoteName(indexName)))
+				}
+			}
+		}
+	}
+	return append(dropIndexes, dropColumns...), &recreated
+}
@@ -0,0 +1,115 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_GeneratedColumns(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, FirstName STRING(50), LastName STRING(50)) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  FirstName STRING(50),
+  LastName STRING(50),
+  FullName STRING(MAX) AS (ARRAY_TO_STRING([FirstName, LastName], " ")) STORED,
+  Initial STRING(1) AS (SUBSTR(FirstName, 1, 1)),
+) PRIMARY KEY (Id);
+CREATE TABLE Orders (Id INT64 NOT NULL, Total FLOAT64, Tax FLOAT64 AS (Total * 0.1) STORED) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE Orders (\n  Id INT64 NOT NULL,\n  Total FLOAT64,\n  Tax FLOAT64 AS (Total * 0.1) STORED\n) PRIMARY KEY (Id)",
+		`ALTER TABLE Users ADD COLUMN FullName STRING(MAX) AS (ARRAY_TO_STRING([FirstName, LastName], " ")) STORED`,
+		"ALTER TABLE Users ADD COLUMN Initial STRING(1) AS (SUBSTR(FirstName, 1, 1))",
+	}, ddls)
+	assert.Empty(t, warnings)
+}
+
+func TestGenerateIdempotentDDLs_DropGeneratedColumnFirst(t *testing.T) {
+	current := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  FirstName STRING(50),
+  LastName STRING(50),
+  Initial STRING(1) AS (SUBSTR(FirstName, 1, 1)),
+  Monogram STRING(2) AS (CONCAT(Initial, SUBSTR(LastName, 1, 1))) STORED,
+) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, LastName STRING(50)) PRIMARY KEY (Id)`
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE Users DROP COLUMN Monogram",
+		"ALTER TABLE Users DROP COLUMN Initial",
+		"ALTER TABLE Users DROP COLUMN FirstName",
+	}, ddls)
+}
+
+func TestGenerateIdempotentDDLs_GeneratedExpressionChange(t *testing.T) {
+	current := `CREATE TABLE Orders (Id INT64 NOT NULL, Total FLOAT64, Tax FLOAT64 AS (Total * 0.1) STORED) PRIMARY KEY (Id);
+CREATE INDEX IdxOrdersTax ON Orders (Tax)`
+	desired := `CREATE TABLE Orders (Id INT64 NOT NULL, Total FLOAT64, Tax FLOAT64 AS (Total * 0.2) STORED) PRIMARY KEY (Id);
+CREATE INDEX IdxOrdersTax ON Orders (Tax)`
+
+	// Spanner can't change the expression in place
+	_, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	assert.EqualError(t, err, "expression of generated column Orders.Tax differs: current (Total * 0.1), desired (Total * 0.2); Spanner can't change it, set recreate_generated_columns in --config to drop and add the column again")
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{RecreateGeneratedColumns: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DROP INDEX IdxOrdersTax",
+		"ALTER TABLE Orders DROP COLUMN Tax",
+		"ALTER TABLE Orders ADD COLUMN Tax FLOAT64 AS (Total * 0.2) STORED",
+		"CREATE INDEX IdxOrdersTax ON Orders (Tax)",
+	}, ddls)
+	assert.Empty(t, warnings)
+}
+
+func TestGenerateIdempotentDDLs_RecreateDependentGeneratedColumns(t *testing.T) {
+	current := `CREATE TABLE Orders (Id INT64 NOT NULL, Total FLOAT64, Tax FLOAT64 AS (Total * 0.1) STORED, Gross FLOAT64 AS (Total + Tax) STORED) PRIMARY KEY (Id);
+CREATE INDEX IdxOrdersGross ON Orders (Gross)`
+	desired := `CREATE TABLE Orders (Id INT64 NOT NULL, Total FLOAT64, Tax FLOAT64 AS (Total * 0.2) STORED, Gross FLOAT64 AS (Total + Tax) STORED) PRIMARY KEY (Id);
+CREATE INDEX IdxOrdersGross ON Orders (Gross)`
+
+	// Gross reads Tax, so it is dropped first and added back after it
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{RecreateGeneratedColumns: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DROP INDEX IdxOrdersGross",
+		"ALTER TABLE Orders DROP COLUMN Gross",
+		"ALTER TABLE Orders DROP COLUMN Tax",
+		"ALTER TABLE Orders ADD COLUMN Tax FLOAT64 AS (Total * 0.2) STORED",
+		"ALTER TABLE Orders ADD COLUMN Gross FLOAT64 AS (Total + Tax) STORED",
+		"CREATE INDEX IdxOrdersGross ON Orders (Gross)",
+	}, ddls)
+}
+
+func TestGenerateIdempotentDDLs_AddGeneratedColumnAfterReadColumn(t *testing.T) {
+	current := "CREATE TABLE Orders (Id INT64 NOT NULL) PRIMARY KEY (Id)"
+	desired := "CREATE TABLE Orders (Id INT64 NOT NULL, Gross FLOAT64 AS (Total * 1.1) STORED, Total FLOAT64) PRIMARY KEY (Id)"
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE Orders ADD COLUMN Total FLOAT64",
+		"ALTER TABLE Orders ADD COLUMN Gross FLOAT64 AS (Total * 1.1) STORED",
+	}, ddls)
+}
+
+func TestRunDDLs_RecreatedGeneratedColumnNeedsEnableDrop(t *testing.T) {
+	ddls := []string{
+		"ALTER TABLE Orders DROP COLUMN Tax",
+		"ALTER TABLE Orders ADD COLUMN Tax FLOAT64 AS (Total * 0.2) STORED",
+	}
+
+	db := &fakeDatabase{}
+	err := RunDDLs(db, ddls, false, true)
+	assert.EqualError(t, err, "column Orders.Tax must be dropped and added back to change its generated expression; use --enable-drop to allow it")
+	assert.Empty(t, db.batches)
+
+	require.NoError(t, RunDDLs(db, ddls, true, true))
+	assert.Equal(t, [][]string{ddls}, db.batches)
+}
@@ -0,0 +1,77 @@
+package spannerdef
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+var (
+	// skipRangeRe matches the SKIP RANGE parameter of an identity clause
+	skipRangeRe = regexp.MustCompile(`SKIP RANGE \d+, \d+`)
+	// startCounterRe matches the START COUNTER WITH parameter of an
+	// identity clause
+	startCounterRe = regexp.MustCompile(`START COUNTER WITH (\d+)`)
+)
+
+// normalizeIdentity returns the identity clause of a column in a form that
+// compares equal for equivalent clauses. The sequence kind, which may be
+// left to the default_sequence_kind database option, is written out, as
+// BIT_REVERSED_POSITIVE is the only kind, the parameters are put in the
+// order Spanner dumps them, and START COUNTER WITH 1, the default, is left
+// out.
+func normalizeIdentity(identity string) string {
+	if identity == "" {
+		return ""
+	}
+	normalized := "GENERATED BY DEFAULT AS IDENTITY (BIT_REVERSED_POSITIVE"
+	if skipRange := skipRangeRe.FindString(identity); skipRange != "" {
+		normalized += " " + skipRange
+	}
+	if match := startCounterRe.FindStringSubmatch(identity); match != nil && match[1] != "1" {
+		normalized += " " + match[0]
+	}
+	return normalized + ")"
+}
+
+// identitiesEqual compares the identity clauses of two columns
+func identitiesEqual(current, desired *Column) bool {
+	return normalizeIdentity(current.Identity) == normalizeIdentity(desired.Identity)
+}
+
+// generateAlterIdentity generates the ALTER IDENTITY DDL changing the skip
+// range of an identity column, or "" if its identity differs otherwise,
+// which can't be altered in place
+func generateAlterIdentity(table string, current, desired *Column) string {
+	if current.Identity == "" || desired.Identity == "" ||
+		withoutSkipRange(current.Identity) != withoutSkipRange(desired.Identity) {
+		return ""
+	}
+	alteration := "SET NO SKIP RANGE"
+	if skipRange := skipRangeRe.FindString(desired.Identity); skipRange != "" {
+		alteration = "SET " + skipRange
+	}
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ALTER IDENTITY %s", quoteName(table), quoteName(desired.Name), alteration)
+}
+
+// replayAlterIdentity applies an ALTER IDENTITY alteration to column.
+// RESTART COUNTER WITH leaves the definition as is.
+func replayAlterIdentity(column *Column, alteration ast.IdentityAlteration) error {
+	if column.Identity == "" {
+		return fmt.Errorf("ALTER IDENTITY on column %s, which isn't an identity column", column.Name)
+	}
+	switch a := alteration.(type) {
+	case *ast.SetSkipRange:
+		column.Identity = normalizeIdentity(withoutSkipRange(column.Identity) + " " + a.SkipRange.SQL())
+	case *ast.SetNoSkipRange:
+		column.Identity = withoutSkipRange(column.Identity)
+	}
+	return nil
+}
+
+// withoutSkipRange returns an identity clause without its SKIP RANGE
+// parameter, normalized
+func withoutSkipRange(identity string) string {
+	return normalizeIdentity(skipRangeRe.ReplaceAllString(identity, ""))
+}
@@ -0,0 +1,55 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_IdentityColumns(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Seq INT64 GENERATED BY DEFAULT AS IDENTITY (BIT_REVERSED_POSITIVE)) PRIMARY KEY (Id);
+CREATE TABLE Orders (Id INT64 NOT NULL GENERATED BY DEFAULT AS IDENTITY (SKIP RANGE 1, 1000)) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE Orders (\n  Id INT64 NOT NULL GENERATED BY DEFAULT AS IDENTITY (SKIP RANGE 1, 1000)\n) PRIMARY KEY (Id)",
+		"ALTER TABLE Users ADD COLUMN Seq INT64 GENERATED BY DEFAULT AS IDENTITY (BIT_REVERSED_POSITIVE)",
+	}, ddls)
+	assert.Empty(t, warnings)
+}
+
+func TestGenerateIdempotentDDLs_IdentityColumnsAsDumped(t *testing.T) {
+	// Spanner dumps the sequence kind, which the desired schema may leave
+	// to default_sequence_kind, and its own order of parameters
+	current := `CREATE TABLE Orders (
+  Id INT64 NOT NULL GENERATED BY DEFAULT AS IDENTITY (BIT_REVERSED_POSITIVE SKIP RANGE 1, 1000 START COUNTER WITH 1),
+) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Orders (Id INT64 NOT NULL GENERATED BY DEFAULT AS IDENTITY (SKIP RANGE 1, 1000)) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	assert.Empty(t, warnings)
+}
+
+func TestGenerateIdempotentDDLs_IdentitySkipRange(t *testing.T) {
+	current := `CREATE TABLE Orders (Id INT64 NOT NULL GENERATED BY DEFAULT AS IDENTITY (BIT_REVERSED_POSITIVE)) PRIMARY KEY (Id);
+CREATE TABLE Tickets (Id INT64 NOT NULL GENERATED BY DEFAULT AS IDENTITY (BIT_REVERSED_POSITIVE SKIP RANGE 1, 1000)) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL GENERATED BY DEFAULT AS IDENTITY) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Orders (Id INT64 NOT NULL GENERATED BY DEFAULT AS IDENTITY (SKIP RANGE 1, 1000)) PRIMARY KEY (Id);
+CREATE TABLE Tickets (Id INT64 NOT NULL GENERATED BY DEFAULT AS IDENTITY) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL GENERATED BY DEFAULT AS IDENTITY (START COUNTER WITH 100)) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE Orders ALTER COLUMN Id ALTER IDENTITY SET SKIP RANGE 1, 1000",
+		"ALTER TABLE Tickets ALTER COLUMN Id ALTER IDENTITY SET NO SKIP RANGE",
+	}, ddls)
+	// The start of the counter can't be changed
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "unsupported change ignored: column Logs.Id differs", warnings[0].String())
+}
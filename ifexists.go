@@ -0,0 +1,44 @@
+package spannerdef
+
+import (
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// createsExisting reports whether stmt is a CREATE ... IF NOT EXISTS of an
+// object schema already has, which Spanner leaves as it is, so that schema
+// files shared with tools running them as they are can be parsed
+func createsExisting(schema *Schema, stmt ast.DDL) bool {
+	switch s := stmt.(type) {
+	case *ast.CreateTable:
+		_, exists := schema.Tables[getPathName(s.Name)]
+		return s.IfNotExists && exists
+	case *ast.CreateIndex:
+		_, exists := schema.Indexes[getPathName(s.Name)]
+		return s.IfNotExists && exists
+	case *ast.CreateVectorIndex:
+		_, exists := schema.VectorIndexes[s.Name.Name]
+		return s.IfNotExists && exists
+	case *ast.CreateSequence:
+		_, exists := schema.Sequences[getPathName(s.Name)]
+		return s.IfNotExists && exists
+	}
+	return false
+}
+
+// dropsIfExists reports whether stmt is a DROP ... IF EXISTS of a table,
+// index, vector index or sequence, which a schema file may have to reset
+// an object before creating it again. It is applied to the model in the
+// order of the statements: the object is removed if it was created before.
+func dropsIfExists(stmt ast.DDL) bool {
+	switch s := stmt.(type) {
+	case *ast.DropTable:
+		return s.IfExists
+	case *ast.DropIndex:
+		return s.IfExists
+	case *ast.DropVectorIndex:
+		return s.IfExists
+	case *ast.DropSequence:
+		return s.IfExists
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_IfNotExistsInput(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(255)) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersEmail ON Users (Email)`
+	desired := `CREATE TABLE IF NOT EXISTS Users (Id INT64 NOT NULL, Email STRING(255)) PRIMARY KEY (Id);
+CREATE INDEX IF NOT EXISTS IdxUsersEmail ON Users (Email);
+CREATE TABLE IF NOT EXISTS Users (Id INT64 NOT NULL) PRIMARY KEY (Id)`
+
+	// The second CREATE TABLE IF NOT EXISTS leaves Users as it is
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, WarningLossyStatement, warnings[0].Kind)
+}
+
+func TestParseDDLs_DropIfExists(t *testing.T) {
+	schema, err := ParseDDLs(`DROP TABLE IF EXISTS Users;
+CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(255)) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersEmail ON Users (Email);
+DROP INDEX IF EXISTS IdxUsersEmail;
+DROP SEQUENCE IF EXISTS Seq`)
+	require.NoError(t, err)
+	assert.Contains(t, schema.Tables, "Users")
+	assert.Empty(t, schema.Indexes)
+	assert.Empty(t, schema.Unsupported)
+}
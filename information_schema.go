@@ -0,0 +1,556 @@
+package spannerdef
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+// TableDumper is implemented by databases that can dump the DDLs of a subset
+// of tables without fetching the whole schema. Run uses it when the
+// generator config targets specific tables.
+type TableDumper interface {
+	DumpTableDDLs(tables []string) (string, error)
+}
+
+// DumpTableDDLs reconstructs from INFORMATION_SCHEMA the DDLs of the given
+// tables, with their indexes and constraints, and of the views, change
+// streams and sequences among them. This is much cheaper than
+// GetDatabaseDdl on large databases when only a handful of tables are of
+// interest. Vector indexes and database-wide objects are left out.
+func (db *SpannerDatabase) DumpTableDDLs(tables []string) (string, error) {
+	ctx := withRequestTags(context.Background(), db.requestTags)
+
+	schema := &Schema{
+		Tables:        make(map[string]*Table),
+		Indexes:       make(map[string]*Index),
+		VectorIndexes: make(map[string]*VectorIndex),
+		Views:         make(map[string]*View),
+		ChangeStreams: make(map[string]*ChangeStream),
+		Sequences:     make(map[string]*Sequence),
+		NamedSchemas:  make(map[string]*NamedSchema),
+		Roles:         make(map[string]*Role),
+	}
+
+	loaders := []func(context.Context, *Schema, []string) error{
+		db.loadTables,
+		db.loadColumns,
+		db.loadColumnOptions,
+		db.loadIndexes,
+		db.loadIndexColumns,
+		db.loadCheckConstraints,
+		db.loadForeignKeys,
+		db.loadSynonyms,
+		db.loadViews,
+		db.loadChangeStreams,
+		db.loadSequences,
+	}
+	for _, load := range loaders {
+		if err := load(ctx, schema, tables); err != nil {
+			return "", fmt.Errorf("failed to query INFORMATION_SCHEMA: %v", err)
+		}
+	}
+
+	var statements []string
+	for _, table := range schema.Tables {
+		statements = append(statements, generateCreateTable(table))
+	}
+	for _, index := range schema.Indexes {
+		statements = append(statements, generateCreateIndex(index))
+	}
+	for _, view := range schema.Views {
+		statements = append(statements, generateCreateView(view, false))
+	}
+	for _, stream := range schema.ChangeStreams {
+		statements = append(statements, generateCreateChangeStream(stream))
+	}
+	for _, sequence := range schema.Sequences {
+		statements = append(statements, generateCreateSequence(sequence))
+	}
+	if len(statements) == 0 {
+		return "", nil
+	}
+	sort.Strings(statements)
+
+	return strings.Join(statements, ";\n\n") + ";", nil
+}
+
+// rowDeletionPolicyRe matches ROW_DELETION_POLICY_EXPRESSION values such as
+// "OLDER_THAN(CreatedAt, INTERVAL 30 DAY)".
+var rowDeletionPolicyRe = regexp.MustCompile(`^OLDER_THAN\((\w+),\s*INTERVAL\s+(\d+)\s+DAY\)$`)
+
+// queryTables runs an INFORMATION_SCHEMA query restricted to @tables
+func (db *SpannerDatabase) queryTables(ctx context.Context, sql string, tables []string, f func(row *spanner.Row) error) error {
+	stmt := spanner.Statement{
+		SQL:    sql,
+		Params: map[string]interface{}{"tables": tables},
+	}
+	client, err := db.dataClient()
+	if err != nil {
+		return err
+	}
+	return client.Single().Query(ctx, stmt).Do(f)
+}
+
+func (db *SpannerDatabase) loadTables(ctx context.Context, schema *Schema, tables []string) error {
+	sql := `SELECT TABLE_NAME, PARENT_TABLE_NAME, ON_DELETE_ACTION, ROW_DELETION_POLICY_EXPRESSION
+		FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_SCHEMA = '' AND TABLE_TYPE = 'BASE TABLE' AND TABLE_NAME IN UNNEST(@tables)`
+
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var name string
+		var parent, onDelete, policy spanner.NullString
+		if err := row.Columns(&name, &parent, &onDelete, &policy); err != nil {
+			return err
+		}
+
+		table := &Table{
+			Name:        name,
+			Columns:     make(map[string]*Column),
+			Constraints: make(map[string]*Constraint),
+			ParentTable: parent.StringVal,
+		}
+		// NO ACTION is the default and GetDatabaseDdl omits it as well
+		if parent.Valid && onDelete.StringVal == "CASCADE" {
+			table.OnDelete = "ON DELETE CASCADE"
+		}
+		if m := rowDeletionPolicyRe.FindStringSubmatch(policy.StringVal); m != nil {
+			days, err := strconv.ParseInt(m[2], 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse row deletion policy days: %v", err)
+			}
+			table.RowDeletionPolicyColumn = m[1]
+			table.RowDeletionPolicyDays = days
+		}
+
+		schema.Tables[name] = table
+		return nil
+	})
+}
+
+func (db *SpannerDatabase) loadSynonyms(ctx context.Context, schema *Schema, tables []string) error {
+	sql := `SELECT TABLE_NAME, SYNONYM_TABLE_NAME
+		FROM INFORMATION_SCHEMA.TABLE_SYNONYMS
+		WHERE TABLE_SCHEMA = '' AND TABLE_NAME IN UNNEST(@tables)`
+
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var tableName, synonym string
+		if err := row.Columns(&tableName, &synonym); err != nil {
+			return err
+		}
+		if table, ok := schema.Tables[tableName]; ok {
+			table.Synonym = synonym
+		}
+		return nil
+	})
+}
+
+func (db *SpannerDatabase) loadViews(ctx context.Context, schema *Schema, tables []string) error {
+	sql := `SELECT TABLE_NAME, SECURITY_TYPE, VIEW_DEFINITION
+		FROM INFORMATION_SCHEMA.VIEWS
+		WHERE TABLE_SCHEMA = '' AND TABLE_NAME IN UNNEST(@tables)`
+
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var name, securityType, definition string
+		if err := row.Columns(&name, &securityType, &definition); err != nil {
+			return err
+		}
+		// VIEW_DEFINITION is the query as it was written
+		schema.Views[name] = &View{Name: name, SecurityType: securityType, Query: normalizeQuery(definition)}
+		return nil
+	})
+}
+
+func (db *SpannerDatabase) loadChangeStreams(ctx context.Context, schema *Schema, tables []string) error {
+	sql := `SELECT CHANGE_STREAM_NAME, ALL
+		FROM INFORMATION_SCHEMA.CHANGE_STREAMS
+		WHERE CHANGE_STREAM_SCHEMA = '' AND CHANGE_STREAM_NAME IN UNNEST(@tables)`
+	err := db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var name string
+		var all bool
+		if err := row.Columns(&name, &all); err != nil {
+			return err
+		}
+		schema.ChangeStreams[name] = &ChangeStream{
+			Name:    name,
+			All:     all,
+			Tables:  make(map[string]string),
+			Options: make(map[string]string),
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sql = `SELECT CHANGE_STREAM_NAME, TABLE_NAME, ALL_COLUMNS
+		FROM INFORMATION_SCHEMA.CHANGE_STREAM_TABLES
+		WHERE CHANGE_STREAM_SCHEMA = '' AND CHANGE_STREAM_NAME IN UNNEST(@tables)`
+	err = db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var name, table string
+		var allColumns bool
+		if err := row.Columns(&name, &table, &allColumns); err != nil {
+			return err
+		}
+		if stream, ok := schema.ChangeStreams[name]; ok && !stream.All {
+			stream.Tables[table] = ""
+			if !allColumns {
+				stream.Tables[table] = "()"
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	watched := make(map[string][]string)
+	sql = `SELECT CHANGE_STREAM_NAME, TABLE_NAME, COLUMN_NAME
+		FROM INFORMATION_SCHEMA.CHANGE_STREAM_COLUMNS
+		WHERE CHANGE_STREAM_SCHEMA = '' AND CHANGE_STREAM_NAME IN UNNEST(@tables)
+		ORDER BY CHANGE_STREAM_NAME, TABLE_NAME, COLUMN_NAME`
+	err = db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var name, table, column string
+		if err := row.Columns(&name, &table, &column); err != nil {
+			return err
+		}
+		watched[name+"."+table] = append(watched[name+"."+table], column)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for name, stream := range schema.ChangeStreams {
+		for table, columns := range stream.Tables {
+			if columns == "()" {
+				stream.Tables[table] = "(" + strings.Join(watched[name+"."+table], ", ") + ")"
+			}
+		}
+	}
+
+	sql = `SELECT CHANGE_STREAM_NAME, OPTION_NAME, OPTION_TYPE, OPTION_VALUE
+		FROM INFORMATION_SCHEMA.CHANGE_STREAM_OPTIONS
+		WHERE CHANGE_STREAM_SCHEMA = '' AND CHANGE_STREAM_NAME IN UNNEST(@tables)`
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var name, option, typ, value string
+		if err := row.Columns(&name, &option, &typ, &value); err != nil {
+			return err
+		}
+		if stream, ok := schema.ChangeStreams[name]; ok {
+			if typ == "STRING" {
+				value = "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+			}
+			stream.Options[option] = value
+		}
+		return nil
+	})
+}
+
+func (db *SpannerDatabase) loadSequences(ctx context.Context, schema *Schema, tables []string) error {
+	sql := `SELECT NAME
+		FROM INFORMATION_SCHEMA.SEQUENCES
+		WHERE SCHEMA = '' AND NAME IN UNNEST(@tables)`
+	err := db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var name string
+		if err := row.Columns(&name); err != nil {
+			return err
+		}
+		schema.Sequences[name] = &Sequence{Name: name, Options: make(map[string]string)}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sql = `SELECT NAME, OPTION_NAME, OPTION_TYPE, OPTION_VALUE
+		FROM INFORMATION_SCHEMA.SEQUENCE_OPTIONS
+		WHERE SCHEMA = '' AND NAME IN UNNEST(@tables)`
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var name, option, typ, value string
+		if err := row.Columns(&name, &option, &typ, &value); err != nil {
+			return err
+		}
+		if sequence, ok := schema.Sequences[name]; ok {
+			if typ == "STRING" {
+				value = "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+			}
+			sequence.Options[option] = value
+		}
+		return nil
+	})
+}
+
+func (db *SpannerDatabase) loadColumns(ctx context.Context, schema *Schema, tables []string) error {
+	sql := `SELECT TABLE_NAME, COLUMN_NAME, SPANNER_TYPE, IS_NULLABLE, COLUMN_DEFAULT, ORDINAL_POSITION,
+			IS_IDENTITY, IDENTITY_START_WITH_COUNTER, IDENTITY_SKIP_RANGE_MIN, IDENTITY_SKIP_RANGE_MAX,
+			GENERATION_EXPRESSION, IS_STORED
+		FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE TABLE_SCHEMA = '' AND TABLE_NAME IN UNNEST(@tables)
+		ORDER BY TABLE_NAME, ORDINAL_POSITION`
+
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var tableName, name, typ, nullable string
+		var def, identity, startCounter, skipRangeMin, skipRangeMax, generation, stored spanner.NullString
+		var position int64
+		if err := row.Columns(&tableName, &name, &typ, &nullable, &def, &position,
+			&identity, &startCounter, &skipRangeMin, &skipRangeMax, &generation, &stored); err != nil {
+			return err
+		}
+
+		table, ok := schema.Tables[tableName]
+		if !ok {
+			return nil
+		}
+		column := &Column{
+			Name:    name,
+			Type:    typ,
+			NotNull: nullable == "NO",
+			Order:   int(position),
+		}
+		if def.Valid {
+			column.Default = "(" + def.StringVal + ")"
+		}
+		if identity.StringVal == "YES" {
+			identity := "GENERATED BY DEFAULT AS IDENTITY (BIT_REVERSED_POSITIVE"
+			if skipRangeMin.Valid && skipRangeMax.Valid {
+				identity += fmt.Sprintf(" SKIP RANGE %s, %s", skipRangeMin.StringVal, skipRangeMax.StringVal)
+			}
+			if startCounter.Valid {
+				identity += " START COUNTER WITH " + startCounter.StringVal
+			}
+			column.Identity = normalizeIdentity(identity + ")")
+		}
+		if generation.Valid {
+			column.GeneratedExpr = "(" + generation.StringVal + ")"
+			column.Stored = stored.StringVal == "YES"
+		}
+		table.Columns[name] = column
+		return nil
+	})
+}
+
+func (db *SpannerDatabase) loadColumnOptions(ctx context.Context, schema *Schema, tables []string) error {
+	sql := `SELECT TABLE_NAME, COLUMN_NAME, OPTION_NAME, OPTION_TYPE, OPTION_VALUE
+		FROM INFORMATION_SCHEMA.COLUMN_OPTIONS
+		WHERE TABLE_SCHEMA = '' AND TABLE_NAME IN UNNEST(@tables)
+		ORDER BY TABLE_NAME, COLUMN_NAME, OPTION_NAME`
+
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var tableName, columnName, name, typ, value string
+		if err := row.Columns(&tableName, &columnName, &name, &typ, &value); err != nil {
+			return err
+		}
+
+		table, ok := schema.Tables[tableName]
+		if !ok {
+			return nil
+		}
+		column, ok := table.Columns[columnName]
+		if !ok {
+			return nil
+		}
+		// Values are formatted as memefish formats them in OPTIONS clauses
+		if typ == "STRING" {
+			value = fmt.Sprintf("%q", value)
+		} else {
+			value = strings.ToLower(value)
+		}
+		if name == "allow_commit_timestamp" && value == "false" {
+			return nil
+		}
+		if column.Options == nil {
+			column.Options = make(map[string]string)
+		}
+		column.Options[name] = value
+		return nil
+	})
+}
+
+func (db *SpannerDatabase) loadIndexes(ctx context.Context, schema *Schema, tables []string) error {
+	sql := `SELECT TABLE_NAME, INDEX_NAME, IS_UNIQUE, IS_NULL_FILTERED
+		FROM INFORMATION_SCHEMA.INDEXES
+		WHERE TABLE_SCHEMA = '' AND INDEX_TYPE = 'INDEX' AND NOT SPANNER_IS_MANAGED
+			AND TABLE_NAME IN UNNEST(@tables)`
+
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var tableName, name string
+		var unique, nullFiltered bool
+		if err := row.Columns(&tableName, &name, &unique, &nullFiltered); err != nil {
+			return err
+		}
+
+		schema.Indexes[name] = &Index{
+			Name:         name,
+			TableName:    tableName,
+			Unique:       unique,
+			NullFiltered: nullFiltered,
+		}
+		return nil
+	})
+}
+
+func (db *SpannerDatabase) loadIndexColumns(ctx context.Context, schema *Schema, tables []string) error {
+	sql := `SELECT TABLE_NAME, INDEX_NAME, INDEX_TYPE, COLUMN_NAME, ORDINAL_POSITION, COLUMN_ORDERING
+		FROM INFORMATION_SCHEMA.INDEX_COLUMNS
+		WHERE TABLE_SCHEMA = '' AND TABLE_NAME IN UNNEST(@tables)
+		ORDER BY TABLE_NAME, INDEX_NAME, ORDINAL_POSITION, COLUMN_NAME`
+
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var tableName, indexName, indexType, columnName string
+		var position spanner.NullInt64
+		var ordering spanner.NullString
+		if err := row.Columns(&tableName, &indexName, &indexType, &columnName, &position, &ordering); err != nil {
+			return err
+		}
+		descending := ordering.StringVal == "DESC"
+
+		if indexType == "PRIMARY_KEY" {
+			if table, ok := schema.Tables[tableName]; ok {
+				table.PrimaryKey = append(table.PrimaryKey, columnName)
+				if descending {
+					table.DescendingKeys = append(table.DescendingKeys, columnName)
+				}
+			}
+			return nil
+		}
+
+		index, ok := schema.Indexes[indexName]
+		if !ok {
+			return nil
+		}
+		// Key columns have an ordinal position, STORING columns don't
+		if position.Valid {
+			index.Columns = append(index.Columns, columnName)
+			if descending {
+				index.DescendingKeys = append(index.DescendingKeys, columnName)
+			}
+		} else {
+			index.Storing = append(index.Storing, columnName)
+		}
+		return nil
+	})
+}
+
+func (db *SpannerDatabase) loadCheckConstraints(ctx context.Context, schema *Schema, tables []string) error {
+	// Spanner exposes NOT NULL columns as CK_IS_NOT_NULL_* check constraints;
+	// those are already covered by Column.NotNull.
+	sql := `SELECT tc.TABLE_NAME, cc.CONSTRAINT_NAME, cc.CHECK_CLAUSE
+		FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc
+		JOIN INFORMATION_SCHEMA.CHECK_CONSTRAINTS cc
+			ON cc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND cc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+		WHERE tc.TABLE_SCHEMA = '' AND tc.CONSTRAINT_TYPE = 'CHECK'
+			AND NOT STARTS_WITH(cc.CONSTRAINT_NAME, 'CK_IS_NOT_NULL_')
+			AND tc.TABLE_NAME IN UNNEST(@tables)`
+
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var tableName, name, clause string
+		if err := row.Columns(&tableName, &name, &clause); err != nil {
+			return err
+		}
+
+		if table, ok := schema.Tables[tableName]; ok {
+			table.Constraints[name] = &Constraint{
+				Name:       name,
+				Type:       "CHECK",
+				Expression: "(" + clause + ")",
+			}
+		}
+		return nil
+	})
+}
+
+func (db *SpannerDatabase) loadForeignKeys(ctx context.Context, schema *Schema, tables []string) error {
+	sql := `SELECT k.TABLE_NAME, k.CONSTRAINT_NAME, k.COLUMN_NAME, u.TABLE_NAME, u.COLUMN_NAME, rc.DELETE_RULE, tc.ENFORCED
+		FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS rc
+		JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS tc
+			ON tc.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND tc.CONSTRAINT_NAME = rc.CONSTRAINT_NAME
+		JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE k
+			ON k.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND k.CONSTRAINT_NAME = rc.CONSTRAINT_NAME
+		JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE u
+			ON u.CONSTRAINT_SCHEMA = rc.UNIQUE_CONSTRAINT_SCHEMA AND u.CONSTRAINT_NAME = rc.UNIQUE_CONSTRAINT_NAME
+			AND u.ORDINAL_POSITION = k.POSITION_IN_UNIQUE_CONSTRAINT
+		WHERE rc.CONSTRAINT_SCHEMA = '' AND k.TABLE_NAME IN UNNEST(@tables)
+		ORDER BY k.TABLE_NAME, k.CONSTRAINT_NAME, k.ORDINAL_POSITION`
+
+	return db.queryTables(ctx, sql, tables, func(row *spanner.Row) error {
+		var tableName, name, column, refTable, refColumn, deleteRule, enforced string
+		if err := row.Columns(&tableName, &name, &column, &refTable, &refColumn, &deleteRule, &enforced); err != nil {
+			return err
+		}
+
+		table, ok := schema.Tables[tableName]
+		if !ok {
+			return nil
+		}
+		constraint, ok := table.Constraints[name]
+		if !ok {
+			constraint = &Constraint{
+				Name:           name,
+				Type:           "FOREIGN KEY",
+				ReferenceTable: refTable,
+			}
+			if deleteRule == "CASCADE" {
+				constraint.OnDelete = "ON DELETE CASCADE"
+			}
+			if enforced == "NO" {
+				constraint.Enforcement = "NOT ENFORCED"
+			}
+			table.Constraints[name] = constraint
+		}
+		constraint.Columns = append(constraint.Columns, column)
+		constraint.ReferenceColumns = append(constraint.ReferenceColumns, refColumn)
+		return nil
+	})
+}
+
+// CountObjects counts the columns and indexes of every table of the
+// database from INFORMATION_SCHEMA.
+func (db *SpannerDatabase) CountObjects() (*ObjectCounts, error) {
+	ctx := withRequestTags(context.Background(), db.requestTags)
+	client, err := db.dataClient()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := &ObjectCounts{
+		Columns: make(map[string]int),
+		Indexes: make(map[string]string),
+	}
+	sql := `SELECT t.TABLE_NAME, (SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS c
+			WHERE c.TABLE_SCHEMA = t.TABLE_SCHEMA AND c.TABLE_NAME = t.TABLE_NAME)
+		FROM INFORMATION_SCHEMA.TABLES t
+		WHERE t.TABLE_SCHEMA = '' AND t.TABLE_TYPE = 'BASE TABLE'`
+	err = client.Single().Query(ctx, spanner.Statement{SQL: sql}).Do(func(row *spanner.Row) error {
+		var table string
+		var columns int64
+		if err := row.Columns(&table, &columns); err != nil {
+			return err
+		}
+		counts.Columns[table] = int(columns)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query INFORMATION_SCHEMA: %v", err)
+	}
+
+	sql = `SELECT TABLE_NAME, INDEX_NAME
+		FROM INFORMATION_SCHEMA.INDEXES
+		WHERE TABLE_SCHEMA = '' AND INDEX_TYPE = 'INDEX' AND NOT SPANNER_IS_MANAGED`
+	err = client.Single().Query(ctx, spanner.Statement{SQL: sql}).Do(func(row *spanner.Row) error {
+		var table, index string
+		if err := row.Columns(&table, &index); err != nil {
+			return err
+		}
+		counts.Indexes[index] = table
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query INFORMATION_SCHEMA: %v", err)
+	}
+	return counts, nil
+}
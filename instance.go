@@ -0,0 +1,142 @@
+package spannerdef
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instadmin "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+)
+
+// WarningReplication is a statement that builds a large index on a
+// multi-region instance, where the backfill is replicated to every region
+const WarningReplication WarningKind = "replicated to every region"
+
+// InstanceInfo describes the instance configuration of a database
+type InstanceInfo struct {
+	Config string // instance config ID, e.g. "nam3" or "regional-us-central1"
+	// ReadWrite, ReadOnly and Witness are the locations of the replicas
+	// of each type
+	ReadWrite []string
+	ReadOnly  []string
+	Witness   []string
+	// Leader is the default leader region of the database, if known
+	Leader string
+}
+
+// MultiRegion tells whether the replicas span more than one region
+func (i *InstanceInfo) MultiRegion() bool {
+	return len(i.regions()) > 1
+}
+
+// regions returns the distinct locations of the replicas, in order
+func (i *InstanceInfo) regions() []string {
+	return distinct(slices.Concat(i.ReadWrite, i.ReadOnly, i.Witness))
+}
+
+// distinct returns locations without duplicates, in order
+func distinct(locations []string) []string {
+	var result []string
+	for _, location := range locations {
+		if !slices.Contains(result, location) {
+			result = append(result, location)
+		}
+	}
+	return result
+}
+
+// InstanceDescriber is implemented by databases that can describe the
+// configuration of their instance
+type InstanceDescriber interface {
+	DescribeInstance() (*InstanceInfo, error)
+}
+
+// instanceHeader returns the line printed before applying, e.g.
+// "-- Instance config: nam3 (multi-region: us-east4, us-east1; read-only:
+// us-west1), leader: us-east4 --"
+func instanceHeader(info *InstanceInfo) string {
+	header := "-- Instance config: " + info.Config
+	if info.MultiRegion() {
+		header += " (multi-region: " + strings.Join(distinct(info.ReadWrite), ", ")
+		if len(info.ReadOnly) > 0 {
+			header += "; read-only: " + strings.Join(distinct(info.ReadOnly), ", ")
+		}
+		header += ")"
+	}
+	if info.Leader != "" {
+		header += ", leader: " + info.Leader
+	}
+	return header + " --"
+}
+
+// replicationWarnings warns about the statements of ddls that build large
+// indexes, as estimated from sizes, when info is a multi-region instance
+func replicationWarnings(info *InstanceInfo, ddls []string, current *Schema, sizes map[string]int64) []Warning {
+	if !info.MultiRegion() {
+		return nil
+	}
+	var warnings []Warning
+	for _, ddl := range ddls {
+		op := classifyDDL(ddl)
+		if op.kind != opCreateIndex || estimateDuration(ddl, current, sizes) != DurationHours {
+			continue
+		}
+		warnings = append(warnings, Warning{
+			Kind: WarningReplication,
+			Detail: fmt.Sprintf("index %s on %s is backfilled in %d regions of %s (%s)",
+				op.name, op.table, len(info.regions()), info.Config, strings.Join(info.regions(), ", ")),
+			Pos: -1,
+		})
+	}
+	return warnings
+}
+
+// DescribeInstance returns the instance configuration of the database and
+// its default leader region
+func (db *SpannerDatabase) DescribeInstance() (*InstanceInfo, error) {
+	ctx := withRequestTags(context.Background(), db.requestTags)
+	client, err := instadmin.NewInstanceAdminClient(ctx, db.clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create instance admin client: %v", err)
+	}
+	defer client.Close()
+
+	instance, err := client.GetInstance(ctx, &instancepb.GetInstanceRequest{
+		Name: fmt.Sprintf("projects/%s/instances/%s", db.projectID, db.instanceID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance: %v", err)
+	}
+	config, err := client.GetInstanceConfig(ctx, &instancepb.GetInstanceConfigRequest{Name: instance.Config})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance config: %v", err)
+	}
+
+	info := &InstanceInfo{Config: path.Base(instance.Config)}
+	for _, replica := range config.Replicas {
+		switch replica.Type {
+		case instancepb.ReplicaInfo_READ_WRITE:
+			info.ReadWrite = append(info.ReadWrite, replica.Location)
+		case instancepb.ReplicaInfo_READ_ONLY:
+			info.ReadOnly = append(info.ReadOnly, replica.Location)
+		case instancepb.ReplicaInfo_WITNESS:
+			info.Witness = append(info.Witness, replica.Location)
+		}
+		if replica.DefaultLeaderLocation {
+			info.Leader = replica.Location
+		}
+	}
+
+	database, err := db.adminClient.GetDatabase(ctx, &databasepb.GetDatabaseRequest{Name: db.databasePath})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database: %v", err)
+	}
+	if database.DefaultLeader != "" {
+		info.Leader = database.DefaultLeader
+	}
+	return info, nil
+}
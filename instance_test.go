@@ -0,0 +1,46 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceHeader(t *testing.T) {
+	regional := &InstanceInfo{Config: "regional-us-central1", ReadWrite: []string{"us-central1", "us-central1", "us-central1"}}
+	assert.False(t, regional.MultiRegion())
+	assert.Equal(t, "-- Instance config: regional-us-central1 --", instanceHeader(regional))
+
+	multiRegion := &InstanceInfo{
+		Config:    "nam3",
+		ReadWrite: []string{"us-east4", "us-east4", "us-east1", "us-east1"},
+		ReadOnly:  []string{"us-west1"},
+		Witness:   []string{"us-central1"},
+		Leader:    "us-east4",
+	}
+	assert.True(t, multiRegion.MultiRegion())
+	assert.Equal(t, "-- Instance config: nam3 (multi-region: us-east4, us-east1; read-only: us-west1), leader: us-east4 --",
+		instanceHeader(multiRegion))
+}
+
+func TestReplicationWarnings(t *testing.T) {
+	current, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE TABLE Orders (Id INT64 NOT NULL, CreatedAt TIMESTAMP) PRIMARY KEY (Id)`)
+	require.NoError(t, err)
+	ddls := []string{
+		"CREATE INDEX IdxUsersName ON Users (Name)",
+		"CREATE INDEX IdxOrdersCreatedAt ON Orders (CreatedAt)",
+	}
+	sizes := map[string]int64{"Users": 1 << 20, "Orders": 200 << 30}
+	info := &InstanceInfo{Config: "nam3", ReadWrite: []string{"us-east4", "us-east1"}, ReadOnly: []string{"us-west1"}}
+
+	// Only large index builds are worth a warning
+	assert.Equal(t, []Warning{{
+		Kind:   WarningReplication,
+		Detail: "index IdxOrdersCreatedAt on Orders is backfilled in 3 regions of nam3 (us-east4, us-east1, us-west1)",
+		Pos:    -1,
+	}}, replicationWarnings(info, ddls, current, sizes))
+
+	assert.Empty(t, replicationWarnings(&InstanceInfo{Config: "regional-us-east4", ReadWrite: []string{"us-east4"}}, ddls, current, nil))
+}
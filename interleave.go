@@ -0,0 +1,47 @@
+package spannerdef
+
+import (
+	"errors"
+	"fmt"
+)
+
+// onDeleteAction returns the ON DELETE clause of an interleaved table or a
+// foreign key, which is ON DELETE NO ACTION when it isn't written
+func onDeleteAction(onDelete string) string {
+	if onDelete == "" {
+		return "ON DELETE NO ACTION"
+	}
+	return onDelete
+}
+
+// generateAlterOnDelete generates the DDL changing the ON DELETE action of
+// an existing table interleaved in the same parent, if it differs
+func generateAlterOnDelete(current, desired *Table) []string {
+	if desired.ParentTable == "" || current.ParentTable != desired.ParentTable ||
+		onDeleteAction(current.OnDelete) == onDeleteAction(desired.OnDelete) {
+		return nil
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s SET %s", quoteName(desired.Name), onDeleteAction(desired.OnDelete))}
+}
+
+// checkInterleaving returns an error listing the tables whose parent
+// differs between current and desired, which Spanner can't change, except
+// those that are recreated anyway
+func checkInterleaving(current, desired *Schema, recreated map[string]bool) error {
+	var errs []error
+	for _, name := range sortedKeys(current.Tables) {
+		table, desiredTable := current.Tables[name], desired.Tables[name]
+		if desiredTable != nil && table.ParentTable != desiredTable.ParentTable && !recreated[name] {
+			errs = append(errs, fmt.Errorf("parent of %s differs: current %s, desired %s; Spanner can't change it",
+				name, describeParent(table), describeParent(desiredTable)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func describeParent(table *Table) string {
+	if table.ParentTable == "" {
+		return "none"
+	}
+	return table.ParentTable
+}
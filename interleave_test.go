@@ -0,0 +1,56 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_OnDeleteChange(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Sessions (Id INT64 NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Users;
+CREATE TABLE Orders (Id INT64 NOT NULL, OrderId INT64 NOT NULL) PRIMARY KEY (Id, OrderId), INTERLEAVE IN PARENT Users ON DELETE CASCADE`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Sessions (Id INT64 NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Users ON DELETE CASCADE;
+CREATE TABLE Orders (Id INT64 NOT NULL, OrderId INT64 NOT NULL) PRIMARY KEY (Id, OrderId), INTERLEAVE IN PARENT Users ON DELETE NO ACTION`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{
+		"ALTER TABLE Orders SET ON DELETE NO ACTION",
+		"ALTER TABLE Sessions SET ON DELETE CASCADE",
+	}, ddls)
+
+	currentSchema, err := ParseDDLs(current)
+	require.NoError(t, err)
+	desiredSchema, err := ParseDDLs(desired)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ON DELETE action of Orders differs: current ON DELETE CASCADE, desired ON DELETE NO ACTION",
+		"ON DELETE action of Sessions differs: current ON DELETE NO ACTION, desired ON DELETE CASCADE",
+	}, explainDDLs(ddls, currentSchema, desiredSchema, nil))
+
+	// NO ACTION is the default
+	ddls, _, err = GenerateIdempotentDDLs(desired, `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Sessions (Id INT64 NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Users ON DELETE CASCADE;
+CREATE TABLE Orders (Id INT64 NOT NULL, OrderId INT64 NOT NULL) PRIMARY KEY (Id, OrderId), INTERLEAVE IN PARENT Users`, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
+
+func TestGenerateIdempotentDDLs_ParentChange(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Accounts (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Sessions (Id INT64 NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Users;
+CREATE TABLE Orders (Id INT64 NOT NULL, OrderId INT64 NOT NULL) PRIMARY KEY (Id, OrderId)`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Accounts (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Sessions (Id INT64 NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Accounts;
+CREATE TABLE Orders (Id INT64 NOT NULL, OrderId INT64 NOT NULL) PRIMARY KEY (Id, OrderId), INTERLEAVE IN PARENT Users`
+
+	_, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	assert.EqualError(t, err, "parent of Orders differs: current none, desired Users; Spanner can't change it\n"+
+		"parent of Sessions differs: current Users, desired Accounts; Spanner can't change it")
+}
@@ -0,0 +1,61 @@
+package spannerdef
+
+import (
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish"
+)
+
+// parseSchema parses ddls with parseDDLsCached. With config.Lenient, the
+// statements that can't be parsed, such as those of Spanner features newer
+// than the parser, are skipped and kept in Schema.Unparsed instead of
+// failing the whole parse.
+func parseSchema(ddls string, config GeneratorConfig) (*Schema, error) {
+	if !config.Lenient {
+		return parseDDLsCached(ddls, config.CacheDir)
+	}
+
+	masked, unparsed, err := maskUnparsed(ddls)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := parseDDLsCached(masked, config.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+	schema.Unparsed = unparsed
+	return schema, nil
+}
+
+// maskUnparsed returns ddls with the statements that can't be parsed
+// blanked out, line breaks aside, so that the other statements keep their
+// offsets, and the statements blanked out
+func maskUnparsed(ddls string) (string, []Statement, error) {
+	raws, err := memefish.SplitRawStatements("", ddls)
+	if err != nil {
+		return "", nil, err
+	}
+
+	masked := []byte(ddls)
+	var unparsed []Statement
+	for _, raw := range raws {
+		if strings.TrimSpace(stripComments(raw.Statement)) == "" {
+			continue
+		}
+		if _, err := memefish.ParseDDL("", raw.Statement); err == nil {
+			continue
+		}
+
+		unparsed = append(unparsed, Statement{SQL: strings.TrimSpace(raw.Statement), Pos: int(raw.Pos)})
+		end := int(raw.End)
+		if end < len(masked) && masked[end] == ';' {
+			end++
+		}
+		for i := int(raw.Pos); i < end; i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+	}
+	return string(masked), unparsed, nil
+}
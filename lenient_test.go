@@ -0,0 +1,38 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_Lenient(t *testing.T) {
+	current := "CREATE TABLE Singers (\n  Id INT64 NOT NULL,\n  Location STRING(MAX) NOT NULL PLACEMENT KEY\n) PRIMARY KEY (Id)"
+	desired := "CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);\n" +
+		"DROP PLACEMENT europe;\n" +
+		"CREATE INDEX IdxUsersId ON Users (Id), INTERLEAVE IN Users"
+
+	_, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	assert.ErrorContains(t, err, "failed to parse current DDLs")
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{Lenient: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE Users (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"CREATE INDEX IdxUsersId ON Users (Id)",
+	}, ddls)
+	// Statements after the skipped one keep their position
+	assert.Equal(t, []Warning{
+		{Kind: WarningUnparsedStatement, Detail: "DROP PLACEMENT europe", Pos: 57},
+		{Kind: WarningUnparsedCurrentStatement, Detail: current, Pos: -1},
+		{Kind: WarningLossyStatement, Detail: "CREATE INDEX IdxUsersId ON Users (Id), INTERLEAVE IN Users", Pos: 80},
+	}, warnings)
+}
+
+func TestMaskUnparsed(t *testing.T) {
+	masked, unparsed, err := maskUnparsed("CREATE SCHEMA sales;\nDROP PLACEMENT\n  europe;\nCREATE SCHEMA s")
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE SCHEMA sales;\n              \n         \nCREATE SCHEMA s", masked)
+	assert.Equal(t, []Statement{{SQL: "DROP PLACEMENT\n  europe", Pos: 21}}, unparsed)
+}
@@ -0,0 +1,341 @@
+package spannerdef
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// flywayFileRe matches Flyway script names: V1_2__desc.sql (versioned),
+// U1_2__desc.sql (undo) and R__desc.sql (repeatable).
+var flywayFileRe = regexp.MustCompile(`^([VUR])(\d+(?:[._]\d+)*)?__`)
+
+// versionedFileRe matches numbered scripts as used by wrench (000001.sql)
+// and Liquibase formatted SQL changelogs (001-create-users.sql).
+var versionedFileRe = regexp.MustCompile(`^(\d+(?:[._]\d+)*)`)
+
+// migrationFile is a migration script with the information used to order it
+type migrationFile struct {
+	path       string
+	repeatable bool  // Flyway R__ scripts run after all versioned ones
+	version    []int // empty if the file name has no version
+}
+
+// ImportMigrations replays the DDL statements of a legacy migrations
+// directory (Flyway, Liquibase formatted SQL or wrench) in order and returns
+// the resulting schema as a consolidated desired schema. DML statements and
+// tool-specific markers, which are SQL comments, are ignored.
+func ImportMigrations(dir string) (string, error) {
+	files, err := listMigrationFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	return replayMigrations(files)
+}
+
+// ImportMigrationsAt is ImportMigrations as of a past version, e.g. "41"
+// for V41__release.sql or 000041.sql: the versioned scripts after it are
+// left out. Repeatable scripts are replayed as they are now, since the
+// directory doesn't tell how they were back then.
+func ImportMigrationsAt(dir, version string) (string, error) {
+	files, err := listMigrationFiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	at := parseVersion(version)
+	if len(at) == 0 || !slices.ContainsFunc(files, func(file migrationFile) bool {
+		return !file.repeatable && slices.Equal(file.version, at)
+	}) {
+		return "", fmt.Errorf("no migration with version %s in %s", version, dir)
+	}
+	files = slices.DeleteFunc(files, func(file migrationFile) bool {
+		return !file.repeatable && slices.Compare(file.version, at) > 0
+	})
+	return replayMigrations(files)
+}
+
+// replayMigrations replays files in order into a new schema and returns it
+// as a consolidated desired schema
+func replayMigrations(files []migrationFile) (string, error) {
+	schema := &Schema{
+		Tables:        make(map[string]*Table),
+		Indexes:       make(map[string]*Index),
+		VectorIndexes: make(map[string]*VectorIndex),
+		Views:         make(map[string]*View),
+		ChangeStreams: make(map[string]*ChangeStream),
+		Sequences:     make(map[string]*Sequence),
+		NamedSchemas:  make(map[string]*NamedSchema),
+		Roles:         make(map[string]*Role),
+	}
+	for _, file := range files {
+		if err := replayMigration(schema, file.path); err != nil {
+			return "", err
+		}
+	}
+
+	return schemaDDLs(schema), nil
+}
+
+// listMigrationFiles returns the .sql files in dir in the order they were
+// applied: versioned scripts by version, then repeatable scripts by name.
+// Flyway undo scripts (U1__desc.sql) are skipped.
+func listMigrationFiles(dir string) ([]migrationFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []migrationFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".sql") {
+			continue
+		}
+
+		file := migrationFile{path: filepath.Join(dir, entry.Name())}
+		version := ""
+		if m := flywayFileRe.FindStringSubmatch(entry.Name()); m != nil {
+			if m[1] == "U" {
+				continue
+			}
+			file.repeatable = m[1] == "R"
+			version = m[2]
+		} else if m := versionedFileRe.FindStringSubmatch(entry.Name()); m != nil {
+			version = m[1]
+		}
+		file.version = parseVersion(version)
+		files = append(files, file)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.repeatable != b.repeatable {
+			return !a.repeatable
+		}
+		if c := slices.Compare(a.version, b.version); c != 0 {
+			return c < 0
+		}
+		return a.path < b.path
+	})
+	return files, nil
+}
+
+// parseVersion splits a version such as "1.2" or "1_2" into its numbers
+func parseVersion(version string) []int {
+	var parsed []int
+	for _, part := range strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '_' }) {
+		n, _ := strconv.Atoi(part)
+		parsed = append(parsed, n)
+	}
+	return parsed
+}
+
+// replayMigration applies the DDL statements of one migration file to schema
+func replayMigration(schema *Schema, path string) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return replayDDLs(schema, path, string(buf))
+}
+
+// replayDDLs applies the DDL statements of sql, read from path, to schema
+func replayDDLs(schema *Schema, path string, sql string) error {
+	raws, err := memefish.SplitRawStatements(path, sql)
+	if err != nil {
+		return fmt.Errorf("failed to split %s: %v", path, err)
+	}
+
+	for _, raw := range raws {
+		if strings.TrimSpace(stripComments(raw.Statement)) == "" {
+			continue
+		}
+
+		stmt, err := memefish.ParseStatement(path, raw.Statement)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		ddl, ok := stmt.(ast.DDL)
+		if !ok {
+			// DML and queries don't change the schema
+			continue
+		}
+		if err := replayDDL(schema, ddl); err != nil {
+			return fmt.Errorf("failed to replay %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// replayDDL applies a single DDL statement to schema
+func replayDDL(schema *Schema, stmt ast.DDL) error {
+	if createsExisting(schema, stmt) {
+		return nil
+	}
+	switch s := stmt.(type) {
+	case *ast.CreateTable:
+		return processCreateTable(schema, s)
+	case *ast.CreateIndex:
+		return processCreateIndex(schema, s)
+	case *ast.DropTable:
+		tableName := getPathName(s.Name)
+		delete(schema.Tables, tableName)
+		for name, index := range schema.Indexes {
+			if index.TableName == tableName {
+				delete(schema.Indexes, name)
+			}
+		}
+		for name, index := range schema.VectorIndexes {
+			if index.TableName == tableName {
+				delete(schema.VectorIndexes, name)
+			}
+		}
+	case *ast.DropIndex:
+		delete(schema.Indexes, getPathName(s.Name))
+	case *ast.CreateVectorIndex:
+		processCreateVectorIndex(schema, s)
+	case *ast.DropVectorIndex:
+		delete(schema.VectorIndexes, s.Name.Name)
+	case *ast.CreateView:
+		processCreateView(schema, s)
+	case *ast.DropView:
+		delete(schema.Views, getPathName(s.Name))
+	case *ast.CreateChangeStream:
+		processCreateChangeStream(schema, s)
+	case *ast.DropChangeStream:
+		delete(schema.ChangeStreams, s.Name.Name)
+	case *ast.AlterChangeStream:
+		return replayAlterChangeStream(schema, s)
+	case *ast.CreateRole:
+		processCreateRole(schema, s)
+	case *ast.DropRole:
+		delete(schema.Roles, s.Name.Name)
+	case *ast.Grant:
+		return processGrant(schema, s)
+	case *ast.Revoke:
+		return processRevoke(schema, s)
+	case *ast.CreateSchema:
+		processCreateSchema(schema, s)
+	case *ast.DropSchema:
+		delete(schema.NamedSchemas, s.Name.Name)
+	case *ast.CreateProtoBundle:
+		processCreateProtoBundle(schema, s)
+	case *ast.AlterProtoBundle:
+		if !processAlterProtoBundle(schema, s) {
+			return fmt.Errorf("ALTER PROTO BUNDLE without a proto bundle")
+		}
+	case *ast.DropProtoBundle:
+		schema.ProtoBundle = nil
+	case *ast.AlterDatabase:
+		processAlterDatabase(schema, s)
+	case *ast.CreatePlacement:
+		processCreatePlacement(schema, s)
+	case *ast.CreateSequence:
+		processCreateSequence(schema, s)
+	case *ast.AlterSequence:
+		return replayAlterSequence(schema, s)
+	case *ast.DropSequence:
+		delete(schema.Sequences, getPathName(s.Name))
+	case *ast.AlterTable:
+		return replayAlterTable(schema, s)
+	case *ast.AlterIndex:
+		if !processAlterIndex(schema, s) {
+			return fmt.Errorf("unsupported ALTER INDEX %s", getPathName(s.Name))
+		}
+	case *ast.AlterVectorIndex:
+		if !processAlterVectorIndex(schema, s) {
+			return fmt.Errorf("unsupported ALTER VECTOR INDEX %s", getPathName(s.Name))
+		}
+	}
+	return nil
+}
+
+// replayAlterTable applies an ALTER TABLE statement to schema
+func replayAlterTable(schema *Schema, stmt *ast.AlterTable) error {
+	table, ok := schema.Tables[getPathName(stmt.Name)]
+	if !ok {
+		return fmt.Errorf("ALTER TABLE on unknown table %s", getPathName(stmt.Name))
+	}
+
+	switch a := stmt.TableAlteration.(type) {
+	case *ast.AddColumn:
+		if _, exists := table.Columns[a.Column.Name.Name]; exists && a.IfNotExists {
+			return nil
+		}
+		column := newColumn(a.Column, nextColumnOrder(table))
+		table.Columns[column.Name] = column
+	case *ast.DropColumn:
+		delete(table.Columns, a.Name.Name)
+	case *ast.AddTableConstraint:
+		registerTableConstraint(table, a.TableConstraint)
+	case *ast.DropConstraint:
+		delete(table.Constraints, a.Name.Name)
+	case *ast.AddRowDeletionPolicy:
+		return setRowDeletionPolicy(table, a.RowDeletionPolicy)
+	case *ast.ReplaceRowDeletionPolicy:
+		return setRowDeletionPolicy(table, a.RowDeletionPolicy)
+	case *ast.DropRowDeletionPolicy:
+		table.RowDeletionPolicyColumn = ""
+		table.RowDeletionPolicyDays = 0
+	case *ast.SetOnDelete:
+		table.OnDelete = string(a.OnDelete)
+	case *ast.AddSynonym:
+		table.Synonym = a.Name.Name
+	case *ast.DropSynonym:
+		table.Synonym = ""
+	case *ast.RenameTo:
+		renameTable(schema, table.Name, a.Name.Name)
+		if a.AddSynonym != nil {
+			table.Synonym = a.AddSynonym.Name.Name
+		}
+	case *ast.AlterColumn:
+		column, ok := table.Columns[a.Name.Name]
+		if !ok {
+			return fmt.Errorf("ALTER COLUMN on unknown column %s.%s", table.Name, a.Name.Name)
+		}
+		switch c := a.Alteration.(type) {
+		case *ast.AlterColumnType:
+			column.Type = formatColumnType(c.Type)
+			column.NotNull = c.NotNull
+			// The column is redefined, without a DEFAULT unless repeated
+			column.Default = ""
+			if c.DefaultExpr != nil {
+				column.Default = "(" + c.DefaultExpr.Expr.SQL() + ")"
+			}
+		case *ast.AlterColumnSetOptions:
+			setColumnOptions(column, c.Options)
+		case *ast.AlterColumnSetDefault:
+			column.Default = "(" + c.DefaultExpr.Expr.SQL() + ")"
+		case *ast.AlterColumnDropDefault:
+			column.Default = ""
+		case *ast.AlterColumnAlterIdentity:
+			return replayAlterIdentity(column, c.Alteration)
+		}
+	}
+	return nil
+}
+
+// stripComments removes -- and # line comments and /* */ block comments,
+// which is enough to tell whether a raw statement is empty.
+func stripComments(sql string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return blockCommentRe.ReplaceAllString(b.String(), "")
+}
+
+var blockCommentRe = regexp.MustCompile(`(?s)/\*.*?\*/`)
@@ -0,0 +1,127 @@
+package spannerdef
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestListMigrationFiles(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"V10__add_index.sql":  "",
+		"V2__add_column.sql":  "",
+		"V1__init.sql":        "",
+		"V1_1__fix.sql":       "",
+		"U2__undo.sql":        "",
+		"R__views.sql":        "",
+		"README.md":           "",
+		"000003_wrench.sql":   "",
+		"update_comments.sql": "",
+	})
+
+	files, err := listMigrationFiles(dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f.path))
+	}
+	assert.Equal(t, []string{
+		"update_comments.sql",
+		"V1__init.sql",
+		"V1_1__fix.sql",
+		"V2__add_column.sql",
+		"000003_wrench.sql",
+		"V10__add_index.sql",
+		"R__views.sql",
+	}, names)
+}
+
+func TestImportMigrations(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"V1__init.sql": `
+			--liquibase formatted sql
+			--changeset alice:1
+			CREATE TABLE Users (
+				Id INT64 NOT NULL,
+				Name STRING(100),
+				Legacy STRING(10)
+			) PRIMARY KEY (Id);
+			--rollback DROP TABLE Users;
+
+			INSERT INTO Users (Id, Name) VALUES (1, 'admin');
+		`,
+		"V2__alter.sql": `
+			ALTER TABLE Users ADD COLUMN Email STRING(255);
+			ALTER TABLE Users DROP COLUMN Legacy;
+			ALTER TABLE Users ALTER COLUMN Name STRING(200) NOT NULL;
+			CREATE INDEX IdxUsersEmail ON Users (Email);
+			CREATE TABLE Temp (Id INT64 NOT NULL) PRIMARY KEY (Id);
+			UPDATE Users SET Email = 'admin@example.com' WHERE Id = 1;
+		`,
+		"V3__cleanup.sql": `
+			DROP TABLE Temp;
+			-- only a comment here
+			ALTER INDEX IdxUsersEmail ADD STORED COLUMN Name;
+		`,
+	})
+
+	ddls, err := ImportMigrations(dir)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Name STRING(200) NOT NULL,
+  Email STRING(255)
+) PRIMARY KEY (Id);
+
+CREATE INDEX IdxUsersEmail ON Users (Email) STORING (Name);
+`, ddls)
+
+	// The consolidated schema must parse back to the same model
+	_, err = ParseDDLs(ddls)
+	require.NoError(t, err)
+}
+
+func TestImportMigrationsAt(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"V1__init.sql":   "CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)",
+		"V2__email.sql":  "ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"V10__posts.sql": "CREATE TABLE Posts (Id INT64 NOT NULL) PRIMARY KEY (Id)",
+		"R__indexes.sql": "CREATE INDEX IdxUsersId ON Users (Id)",
+		"U2__revert.sql": "ALTER TABLE Users DROP COLUMN Email",
+	})
+
+	ddls, err := ImportMigrationsAt(dir, "2")
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Email STRING(255)
+) PRIMARY KEY (Id);
+
+CREATE INDEX IdxUsersId ON Users (Id);
+`, ddls)
+
+	_, err = ImportMigrationsAt(dir, "3")
+	assert.EqualError(t, err, "no migration with version 3 in "+dir)
+}
+
+func TestImportMigrations_UnknownTable(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"V1__alter.sql": "ALTER TABLE Missing ADD COLUMN Name STRING(100)",
+	})
+
+	_, err := ImportMigrations(dir)
+	assert.ErrorContains(t, err, "unknown table Missing")
+}
@@ -0,0 +1,67 @@
+package spannerdef
+
+import (
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// NamedSchema represents a Spanner named schema, which qualifies the names
+// of the objects created in it, e.g. accounting.Invoices
+type NamedSchema struct {
+	Name  string
+	Pos   int    // byte offset of the definition in the parsed DDLs
+	Owner string // team from an "-- owner:" annotation, if any
+}
+
+// processCreateSchema processes CREATE SCHEMA statement
+func processCreateSchema(schema *Schema, stmt *ast.CreateSchema) {
+	schema.NamedSchemas[stmt.Name.Name] = &NamedSchema{
+		Name: stmt.Name.Name,
+		Pos:  int(stmt.Pos()),
+	}
+}
+
+// generateCreateSchemaDDLs generates DDLs to create new named schemas
+func generateCreateSchemaDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(desired.NamedSchemas) {
+		if _, exists := current.NamedSchemas[name]; !exists {
+			ddls = append(ddls, "CREATE SCHEMA "+name)
+		}
+	}
+	return ddls
+}
+
+// generateDropSchemaDDLs generates DDLs to drop the named schemas that are
+// no longer desired. A schema desired objects are still qualified with is
+// left alone, as Spanner only drops empty schemas; the objects that are no
+// longer desired in the others are dropped by the statements before.
+func generateDropSchemaDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(current.NamedSchemas) {
+		if _, exists := desired.NamedSchemas[name]; !exists && !hasObjectsIn(desired, name) {
+			ddls = append(ddls, "DROP SCHEMA "+name)
+		}
+	}
+	return ddls
+}
+
+// hasObjectsIn reports whether schema has tables, indexes, views or
+// sequences in the named schema name
+func hasObjectsIn(schema *Schema, name string) bool {
+	prefix := name + "."
+	return hasKeyWithPrefix(schema.Tables, prefix) || hasKeyWithPrefix(schema.Indexes, prefix) ||
+		hasKeyWithPrefix(schema.VectorIndexes, prefix) || hasKeyWithPrefix(schema.Views, prefix) ||
+		hasKeyWithPrefix(schema.Sequences, prefix)
+}
+
+// hasKeyWithPrefix reports whether a key of m starts with prefix
+func hasKeyWithPrefix[V any](m map[string]V, prefix string) bool {
+	for key := range m {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_NamedSchemas(t *testing.T) {
+	current := `CREATE SCHEMA accounting;
+CREATE TABLE accounting.Events (Id INT64 NOT NULL) PRIMARY KEY (Id)`
+	desired := `CREATE SCHEMA accounting;
+CREATE SCHEMA analytics;
+CREATE TABLE accounting.Events (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE analytics.Events (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE INDEX analytics.IdxEventsName ON analytics.Events (Name)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	// Tables of the same name in different schemas don't collide
+	assert.Equal(t, []string{
+		"CREATE SCHEMA analytics",
+		"CREATE TABLE analytics.Events (\n  Id INT64 NOT NULL,\n  Name STRING(100)\n) PRIMARY KEY (Id)",
+		"CREATE INDEX analytics.IdxEventsName ON analytics.Events (Name)",
+	}, ddls)
+	assert.Empty(t, warnings)
+
+	// A schema is created with --schema only when it is the one planned
+	ddls, _, err = GenerateIdempotentDDLs(desired, current, GeneratorConfig{Schema: "accounting"})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	ddls, _, err = GenerateIdempotentDDLs(desired, current, GeneratorConfig{Schema: "analytics"})
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE SCHEMA analytics", ddls[0])
+}
+
+func TestGenerateIdempotentDDLs_DropNamedSchema(t *testing.T) {
+	current := `CREATE SCHEMA accounting;
+CREATE SCHEMA legacy;
+CREATE TABLE legacy.Events (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE INDEX legacy.IdxEventsName ON legacy.Events (Name);
+CREATE VIEW legacy.EventIds SQL SECURITY INVOKER AS SELECT Id FROM legacy.Events;
+CREATE TABLE accounting.Events (Id INT64 NOT NULL) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE accounting.Events (Id INT64 NOT NULL) PRIMARY KEY (Id)`
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	// The schema is dropped after its objects, and one desired objects are
+	// still in is kept
+	assert.Equal(t, []string{
+		"DROP VIEW legacy.EventIds",
+		"DROP INDEX legacy.IdxEventsName",
+		"DROP TABLE legacy.Events",
+		"DROP SCHEMA legacy",
+	}, ddls)
+
+	// Dropping a schema is destructive, unless it has a tombstone
+	assert.True(t, isDestructive("DROP SCHEMA legacy", nil))
+	assert.Equal(t, map[int]bool{0: true, 1: true, 2: true, 3: true}, skippedDDLs(ddls, nil, false))
+	tombstoned := &Schema{Dropped: map[string]bool{"legacy": true}}
+	assert.False(t, skippedDDLs([]string{"DROP SCHEMA legacy"}, tombstoned, false)[0])
+	assert.Equal(t, "1 schema dropped", summarizeDDLs([]string{"DROP SCHEMA legacy"}, nil))
+}
@@ -0,0 +1,73 @@
+package spannerdef
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// NullCounter is implemented by databases that can count the NULL values of
+// a column, to check that it can be made NOT NULL
+type NullCounter interface {
+	CountNulls(table, column string) (int64, error)
+}
+
+// checkNotNull counts the NULL values of the nullable columns of current
+// that ddls make NOT NULL, and returns an error listing those that have any,
+// since the ALTER COLUMN would fail on them. Skipped statements aren't
+// checked. Databases that can't count NULL values aren't checked either.
+func checkNotNull(d Database, ddls []string, current *Schema, enableDrop bool) error {
+	counter, ok := d.(NullCounter)
+	if !ok {
+		return nil
+	}
+
+	skipped := skippedDDLs(ddls, current, enableDrop)
+	var errs []error
+	for i, ddl := range ddls {
+		op := classifyDDL(ddl)
+		if skipped[i] || op.kind != opAlterColumnType || !op.notNull {
+			continue
+		}
+		if table, ok := current.Tables[op.table]; !ok || table.Columns[op.name] == nil || table.Columns[op.name].NotNull {
+			continue
+		}
+
+		count, err := counter.CountNulls(op.table, op.name)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			errs = append(errs, fmt.Errorf("column %s.%s has %d NULL value(s); set them before making it NOT NULL", op.table, op.name, count))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkNullability returns an error listing the columns of current whose
+// NOT NULL ddls change in a way Spanner doesn't allow: that of primary key
+// columns, which can't be changed at all, and ARRAY columns, which can't
+// be made NOT NULL. The statements would otherwise fail when applied,
+// possibly after others have been.
+func checkNullability(ddls []string, current *Schema) error {
+	var errs []error
+	for _, ddl := range ddls {
+		op := classifyDDL(ddl)
+		if op.kind != opAlterColumnType {
+			continue
+		}
+		table, ok := current.Tables[op.table]
+		if !ok || table.Columns[op.name] == nil || table.Columns[op.name].NotNull == op.notNull {
+			continue
+		}
+
+		switch {
+		case slices.Contains(table.PrimaryKey, op.name):
+			errs = append(errs, fmt.Errorf("column %s.%s is part of the primary key, whose NOT NULL Spanner can't change; recreate the table instead", op.table, op.name))
+		case op.notNull && strings.HasPrefix(op.typ, "ARRAY<"):
+			errs = append(errs, fmt.Errorf("column %s.%s is an ARRAY column, which Spanner can't make NOT NULL", op.table, op.name))
+		}
+	}
+	return errors.Join(errs...)
+}
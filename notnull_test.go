@@ -0,0 +1,89 @@
+package spannerdef
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nullCountingDatabase is a fakeDatabase that counts NULL values from nulls,
+// keyed by "table.column"
+type nullCountingDatabase struct {
+	fakeDatabase
+	nulls   map[string]int64
+	err     error
+	counted []string
+}
+
+func (d *nullCountingDatabase) CountNulls(table, column string) (int64, error) {
+	d.counted = append(d.counted, table+"."+column)
+	return d.nulls[table+"."+column], d.err
+}
+
+func TestCheckNotNull(t *testing.T) {
+	current, err := ParseDDLs(`CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Email STRING(255),
+  Name STRING(100),
+  Nickname STRING(100),
+  Age INT64 NOT NULL,
+) PRIMARY KEY (Id)`)
+	require.NoError(t, err)
+	ddls := []string{
+		"ALTER TABLE Users ALTER COLUMN Email STRING(255) NOT NULL",
+		"ALTER TABLE Users ALTER COLUMN Name STRING(100) NOT NULL",
+		"ALTER TABLE Users ALTER COLUMN Nickname STRING(50) NOT NULL",
+		"ALTER TABLE Users ALTER COLUMN Age INT64",
+	}
+
+	// The narrowing of Nickname is skipped, and Age is loosened
+	db := &nullCountingDatabase{nulls: map[string]int64{"Users.Email": 3, "Users.Nickname": 5}}
+	err = checkNotNull(db, ddls, current, false)
+	assert.EqualError(t, err, "column Users.Email has 3 NULL value(s); set them before making it NOT NULL")
+	assert.Equal(t, []string{"Users.Email", "Users.Name"}, db.counted)
+
+	db.counted = nil
+	err = checkNotNull(db, ddls, current, true)
+	assert.EqualError(t, err, "column Users.Email has 3 NULL value(s); set them before making it NOT NULL\n"+
+		"column Users.Nickname has 5 NULL value(s); set them before making it NOT NULL")
+	assert.Equal(t, []string{"Users.Email", "Users.Name", "Users.Nickname"}, db.counted)
+
+	db = &nullCountingDatabase{err: errors.New("permission denied")}
+	assert.EqualError(t, checkNotNull(db, ddls, current, false), "permission denied")
+
+	// Databases that can't count aren't checked
+	assert.NoError(t, checkNotNull(&fakeDatabase{}, ddls, current, false))
+}
+
+func TestGenerateIdempotentDDLs_NotNullKeepsDefault(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, Active BOOL DEFAULT (TRUE), Name STRING(100) DEFAULT ('x')) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Active BOOL NOT NULL DEFAULT (TRUE), Name STRING(200) DEFAULT ('y')) PRIMARY KEY (Id)`
+
+	// The DEFAULT is repeated, as the ALTER COLUMN would drop it otherwise
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE Users ALTER COLUMN Active BOOL NOT NULL DEFAULT (TRUE)",
+		"ALTER TABLE Users ALTER COLUMN Name STRING(200) DEFAULT (\"y\")",
+	}, ddls)
+}
+
+func TestGenerateIdempotentDDLs_ForbiddenNullability(t *testing.T) {
+	current := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Tags ARRAY<STRING(MAX)>,
+  Aliases ARRAY<STRING(MAX)> NOT NULL,
+) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (
+  Id INT64,
+  Tags ARRAY<STRING(MAX)> NOT NULL,
+  Aliases ARRAY<STRING(MAX)>,
+) PRIMARY KEY (Id)`
+
+	// ARRAY columns can still be made nullable
+	_, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	assert.EqualError(t, err, "column Users.Id is part of the primary key, whose NOT NULL Spanner can't change; recreate the table instead\n"+
+		"column Users.Tags is an ARRAY column, which Spanner can't make NOT NULL")
+}
@@ -0,0 +1,165 @@
+package spannerdef
+
+import (
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// operationKind is the kind of change a generated DDL statement makes
+type operationKind int
+
+const (
+	opUnknown operationKind = iota
+	opCreateTable
+	opDropTable
+	opCreateIndex
+	opDropIndex
+	opAddColumn
+	opDropColumn
+	opAlterColumnType
+	opAlterColumn // SET OPTIONS, SET DEFAULT, DROP DEFAULT
+	opAddConstraint
+	opDropConstraint
+	opAlterTable // other ALTER TABLE statements such as row deletion policies
+	opCreateView
+	opReplaceView
+	opDropView
+	opCreateChangeStream
+	opDropChangeStream
+	opAlterChangeStream
+	opCreateSequence
+	opAlterSequence
+	opDropSequence
+	opCreateSchema
+	opDropSchema
+	opCreateRole
+	opDropRole
+	opGrant
+	opRevoke
+	opCreateVectorIndex
+	opDropVectorIndex
+	opAlterVectorIndex
+	opCreateProtoBundle
+	opAlterProtoBundle
+	opDropProtoBundle
+	opAlterDatabase
+	opCreatePlacement
+)
+
+// operation describes a DDL statement. Destructive statements are told
+// apart by their operation rather than their text, which may contain
+// keywords in identifiers, string literals or CHECK expressions.
+type operation struct {
+	kind  operationKind
+	table string // empty for DROP INDEX, which only names the index, and schema objects other than tables and indexes
+	name  string // the index, column, constraint or other schema object, if any, or the role of GRANT and REVOKE
+	typ   string // the new column type of opAlterColumnType
+	// notNull is whether opAlterColumnType makes the column NOT NULL
+	notNull bool
+	// restart is whether opAlterSequence sets the counter of the sequence
+	restart bool
+	// deletesTypes is whether opAlterProtoBundle deletes types
+	deletesTypes bool
+	// retentionDays is the number of days rows are kept by the row
+	// deletion policy an opAlterTable adds or replaces, on column name
+	retentionDays int64
+}
+
+// classifyDDL parses ddl and returns its operation. Statements that don't
+// parse are opUnknown; generated statements always parse.
+func classifyDDL(ddl string) operation {
+	stmt, err := memefish.ParseDDL("", ddl)
+	if err != nil {
+		return operation{}
+	}
+
+	switch s := stmt.(type) {
+	case *ast.CreateTable:
+		return operation{kind: opCreateTable, table: getPathName(s.Name)}
+	case *ast.DropTable:
+		return operation{kind: opDropTable, table: getPathName(s.Name)}
+	case *ast.CreateIndex:
+		return operation{kind: opCreateIndex, table: getPathName(s.TableName), name: getPathName(s.Name)}
+	case *ast.DropIndex:
+		return operation{kind: opDropIndex, name: getPathName(s.Name)}
+	case *ast.CreateVectorIndex:
+		return operation{kind: opCreateVectorIndex, table: s.TableName.Name, name: s.Name.Name}
+	case *ast.DropVectorIndex:
+		return operation{kind: opDropVectorIndex, name: s.Name.Name}
+	case *ast.AlterVectorIndex:
+		return operation{kind: opAlterVectorIndex, name: getPathName(s.Name)}
+	case *ast.CreateProtoBundle:
+		return operation{kind: opCreateProtoBundle}
+	case *ast.AlterProtoBundle:
+		return operation{kind: opAlterProtoBundle, deletesTypes: s.Delete != nil}
+	case *ast.DropProtoBundle:
+		return operation{kind: opDropProtoBundle}
+	case *ast.AlterDatabase:
+		return operation{kind: opAlterDatabase, name: s.Name.Name}
+	case *ast.CreatePlacement:
+		return operation{kind: opCreatePlacement, name: s.Name.Name}
+	case *ast.CreateView:
+		if s.OrReplace {
+			return operation{kind: opReplaceView, name: getPathName(s.Name)}
+		}
+		return operation{kind: opCreateView, name: getPathName(s.Name)}
+	case *ast.DropView:
+		return operation{kind: opDropView, name: getPathName(s.Name)}
+	case *ast.CreateChangeStream:
+		return operation{kind: opCreateChangeStream, name: s.Name.Name}
+	case *ast.DropChangeStream:
+		return operation{kind: opDropChangeStream, name: s.Name.Name}
+	case *ast.AlterChangeStream:
+		return operation{kind: opAlterChangeStream, name: s.Name.Name}
+	case *ast.CreateSequence:
+		return operation{kind: opCreateSequence, name: getPathName(s.Name)}
+	case *ast.AlterSequence:
+		return operation{kind: opAlterSequence, name: getPathName(s.Name), restart: restartsCounter(s)}
+	case *ast.DropSequence:
+		return operation{kind: opDropSequence, name: getPathName(s.Name)}
+	case *ast.CreateSchema:
+		return operation{kind: opCreateSchema, name: s.Name.Name}
+	case *ast.DropSchema:
+		return operation{kind: opDropSchema, name: s.Name.Name}
+	case *ast.CreateRole:
+		return operation{kind: opCreateRole, name: s.Name.Name}
+	case *ast.DropRole:
+		return operation{kind: opDropRole, name: s.Name.Name}
+	case *ast.Grant:
+		return operation{kind: opGrant, name: s.Roles[0].Name}
+	case *ast.Revoke:
+		return operation{kind: opRevoke, name: s.Roles[0].Name}
+	case *ast.AlterTable:
+		op := operation{kind: opAlterTable, table: getPathName(s.Name)}
+		switch a := s.TableAlteration.(type) {
+		case *ast.AddColumn:
+			op.kind = opAddColumn
+			op.name = a.Column.Name.Name
+		case *ast.DropColumn:
+			op.kind = opDropColumn
+			op.name = a.Name.Name
+		case *ast.AlterColumn:
+			op.kind = opAlterColumn
+			op.name = a.Name.Name
+			if c, ok := a.Alteration.(*ast.AlterColumnType); ok {
+				op.kind = opAlterColumnType
+				op.typ = formatColumnType(c.Type)
+				op.notNull = c.NotNull
+			}
+		case *ast.AddTableConstraint:
+			op.kind = opAddConstraint
+			if a.TableConstraint.Name != nil {
+				op.name = a.TableConstraint.Name.Name
+			}
+		case *ast.DropConstraint:
+			op.kind = opDropConstraint
+			op.name = a.Name.Name
+		case *ast.AddRowDeletionPolicy:
+			op.name, op.retentionDays = retention(a.RowDeletionPolicy)
+		case *ast.ReplaceRowDeletionPolicy:
+			op.name, op.retentionDays = retention(a.RowDeletionPolicy)
+		}
+		return op
+	}
+	return operation{}
+}
@@ -0,0 +1,62 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyDDL(t *testing.T) {
+	tests := []struct {
+		ddl  string
+		want operation
+	}{
+		{"CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)", operation{kind: opCreateTable, table: "Users"}},
+		{"DROP TABLE Users", operation{kind: opDropTable, table: "Users"}},
+		{"CREATE UNIQUE INDEX IdxUsersEmail ON Users (Email)", operation{kind: opCreateIndex, table: "Users", name: "IdxUsersEmail"}},
+		{"DROP INDEX IdxUsersEmail", operation{kind: opDropIndex, name: "IdxUsersEmail"}},
+		{"ALTER TABLE Users ADD COLUMN Email STRING(255)", operation{kind: opAddColumn, table: "Users", name: "Email"}},
+		{"ALTER TABLE Users DROP COLUMN Email", operation{kind: opDropColumn, table: "Users", name: "Email"}},
+		{"ALTER TABLE Users ALTER COLUMN Email STRING(100) NOT NULL", operation{kind: opAlterColumnType, table: "Users", name: "Email", typ: "STRING(100)", notNull: true}},
+		{"ALTER TABLE Users ALTER COLUMN Email SET OPTIONS (allow_commit_timestamp = null)", operation{kind: opAlterColumn, table: "Users", name: "Email"}},
+		{"ALTER TABLE Users ADD CONSTRAINT ChkEmail CHECK (Email != '')", operation{kind: opAddConstraint, table: "Users", name: "ChkEmail"}},
+		{"ALTER TABLE Users DROP CONSTRAINT ChkEmail", operation{kind: opDropConstraint, table: "Users", name: "ChkEmail"}},
+		{"ALTER TABLE Users DROP ROW DELETION POLICY", operation{kind: opAlterTable, table: "Users"}},
+		{"ALTER TABLE Users REPLACE ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 30 DAY))", operation{kind: opAlterTable, table: "Users", name: "CreatedAt", retentionDays: 30}},
+		{"CREATE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users", operation{kind: opCreateView, name: "UserIds"}},
+		{"CREATE OR REPLACE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users", operation{kind: opReplaceView, name: "UserIds"}},
+		{"DROP VIEW UserIds", operation{kind: opDropView, name: "UserIds"}},
+		{"CREATE CHANGE STREAM UserChanges FOR Users", operation{kind: opCreateChangeStream, name: "UserChanges"}},
+		{"DROP CHANGE STREAM UserChanges", operation{kind: opDropChangeStream, name: "UserChanges"}},
+		{"CREATE SEQUENCE OrderSeq OPTIONS (sequence_kind = 'bit_reversed_positive')", operation{kind: opCreateSequence, name: "OrderSeq"}},
+		{"ALTER SEQUENCE OrderSeq SET OPTIONS (skip_range_min = 1)", operation{kind: opAlterSequence, name: "OrderSeq"}},
+		{"ALTER SEQUENCE OrderSeq SET OPTIONS (start_with_counter = 1)", operation{kind: opAlterSequence, name: "OrderSeq", restart: true}},
+		{"DROP SEQUENCE OrderSeq", operation{kind: opDropSequence, name: "OrderSeq"}},
+		{"CREATE SCHEMA accounting", operation{kind: opCreateSchema, name: "accounting"}},
+		{"CREATE VECTOR INDEX IdxDocsEmbedding ON Docs (Embedding) OPTIONS (distance_type = 'COSINE')", operation{kind: opCreateVectorIndex, table: "Docs", name: "IdxDocsEmbedding"}},
+		{"DROP VECTOR INDEX IdxDocsEmbedding", operation{kind: opDropVectorIndex, name: "IdxDocsEmbedding"}},
+		{"ALTER VECTOR INDEX IdxDocsEmbedding ADD STORED COLUMN Title", operation{kind: opAlterVectorIndex, name: "IdxDocsEmbedding"}},
+		{"CREATE PROTO BUNDLE (`examples.shipping.Order`)", operation{kind: opCreateProtoBundle}},
+		{"ALTER PROTO BUNDLE INSERT (`examples.shipping.Order`)", operation{kind: opAlterProtoBundle}},
+		{"ALTER PROTO BUNDLE DELETE (`examples.shipping.Order`)", operation{kind: opAlterProtoBundle, deletesTypes: true}},
+		{"ALTER DATABASE `app` SET OPTIONS (default_leader = \"us-east1\")", operation{kind: opAlterDatabase, name: "app"}},
+		{"CREATE PLACEMENT europe OPTIONS (instance_partition = \"europe-partition\")", operation{kind: opCreatePlacement, name: "europe"}},
+		{"DROP PROTO BUNDLE", operation{kind: opDropProtoBundle}},
+		{"CREATE ROLE analyst", operation{kind: opCreateRole, name: "analyst"}},
+		{"DROP ROLE analyst", operation{kind: opDropRole, name: "analyst"}},
+		{"GRANT SELECT ON TABLE Users TO ROLE analyst", operation{kind: opGrant, name: "analyst"}},
+		{"REVOKE SELECT ON TABLE Users FROM ROLE analyst", operation{kind: opRevoke, name: "analyst"}},
+		{"not a statement", operation{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, classifyDDL(tt.ddl), tt.ddl)
+	}
+}
+
+func TestIsDestructive_KeywordsInText(t *testing.T) {
+	// Keywords in expressions, literals and identifiers aren't operations
+	assert.False(t, isDestructive("ALTER TABLE Logs ADD CONSTRAINT ChkQuery CHECK (Query != 'DROP TABLE Users')", nil))
+	assert.False(t, isDestructive("ALTER TABLE Logs ADD COLUMN DropColumnLog STRING(MAX)", nil))
+	assert.False(t, isDestructive("CREATE TABLE DropIndexHistory (Id INT64 NOT NULL) PRIMARY KEY (Id)", nil))
+	assert.False(t, isDestructive("ALTER TABLE Logs ALTER COLUMN Note SET DEFAULT ('DROP COLUMN')", nil))
+}
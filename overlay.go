@@ -0,0 +1,30 @@
+package spannerdef
+
+// MergeOverlays applies the statements of overlays, in order, on top of the
+// base desired schema and returns the merged schema. Overlays are
+// environment-specific additions to a shared schema, e.g. indexes that
+// only exist in production: CREATE TABLE and CREATE INDEX add objects (or
+// replace them), ALTER TABLE adds columns and constraints or overrides
+// column OPTIONS, and ALTER INDEX changes STORING columns, so that the
+// base schema doesn't need to be duplicated per environment. Statements
+// of base that aren't part of the schema model are appended as they are.
+// Owners and the tombstones of base and overlays are kept as comments.
+func MergeOverlays(base string, overlays []Source) (string, error) {
+	schema, err := ParseDDLs(base)
+	if err != nil {
+		return "", err
+	}
+	for _, overlay := range overlays {
+		if err := replayDDLs(schema, overlay.Path, overlay.DDLs); err != nil {
+			return "", err
+		}
+		for object := range parseTombstones(overlay.DDLs) {
+			if schema.Dropped == nil {
+				schema.Dropped = make(map[string]bool)
+			}
+			schema.Dropped[object] = true
+		}
+	}
+	// Unsupported statements are kept so that they are still reported
+	return Render(schema, FormatOptions{Annotations: true, Unsupported: true}), nil
+}
@@ -0,0 +1,57 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeOverlays(t *testing.T) {
+	base := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Name STRING(100),
+  UpdatedAt TIMESTAMP
+) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersName ON Users (Name);
+CREATE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users`
+
+	merged, err := MergeOverlays(base, []Source{
+		{Path: "prod.sql", DDLs: `
+			CREATE INDEX IdxUsersUpdatedAt ON Users (UpdatedAt);
+			ALTER TABLE Users ALTER COLUMN UpdatedAt SET OPTIONS (allow_commit_timestamp = true);
+			ALTER INDEX IdxUsersName ADD STORED COLUMN UpdatedAt;
+		`},
+		// Later overlays take priority
+		{Path: "prod-eu.sql", DDLs: `
+			DROP INDEX IdxUsersUpdatedAt;
+			ALTER TABLE Users ADD COLUMN Region STRING(10);
+		`},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Name STRING(100),
+  UpdatedAt TIMESTAMP OPTIONS (allow_commit_timestamp = true),
+  Region STRING(10)
+) PRIMARY KEY (Id);
+
+CREATE INDEX IdxUsersName ON Users (Name) STORING (UpdatedAt);
+
+CREATE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users;
+`, merged)
+
+	_, err = MergeOverlays(base, []Source{{Path: "bad.sql", DDLs: "ALTER TABLE Missing ADD COLUMN Name STRING(10)"}})
+	assert.EqualError(t, err, "failed to replay bad.sql: ALTER TABLE on unknown table Missing")
+}
+
+func TestMergeOverlays_Tombstones(t *testing.T) {
+	base := `-- spannerdef:dropped Users_old
+CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)`
+
+	merged, err := MergeOverlays(base, []Source{
+		{Path: "prod.sql", DDLs: "-- spannerdef:dropped IdxPerfUsers\n"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"Users_old": true, "IdxPerfUsers": true}, parseTombstones(merged))
+}
@@ -0,0 +1,197 @@
+package spannerdef
+
+import (
+	"regexp"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// ownerAnnotationRe matches a `-- owner: <team>` line, which attributes the
+// CREATE TABLE or CREATE INDEX statement following it to a team
+var ownerAnnotationRe = regexp.MustCompile(`(?m)^[ \t]*--\s*owner:\s*(\S+)\s*$`)
+
+// annotateOwners sets the owners of the tables, indexes, views and change
+// streams created by
+// parsed, which was parsed from ddls, from the annotations between each
+// statement and the previous one. Indexes without an annotation are owned
+// by the owner of their table.
+func annotateOwners(schema *Schema, ddls string, parsed []ast.DDL) {
+	prevEnd := 0
+	for _, stmt := range parsed {
+		gap := ddls[prevEnd:stmt.Pos()]
+		prevEnd = statementEnd(ddls, stmt)
+
+		matches := ownerAnnotationRe.FindAllStringSubmatch(gap, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		owner := matches[len(matches)-1][1]
+		switch s := stmt.(type) {
+		case *ast.CreateTable:
+			if table, ok := schema.Tables[getPathName(s.Name)]; ok {
+				table.Owner = owner
+			}
+		case *ast.CreateIndex:
+			if index, ok := schema.Indexes[getPathName(s.Name)]; ok {
+				index.Owner = owner
+			}
+		case *ast.CreateVectorIndex:
+			if index, ok := schema.VectorIndexes[s.Name.Name]; ok {
+				index.Owner = owner
+			}
+		case *ast.CreateView:
+			if view, ok := schema.Views[getPathName(s.Name)]; ok {
+				view.Owner = owner
+			}
+		case *ast.CreateChangeStream:
+			if stream, ok := schema.ChangeStreams[s.Name.Name]; ok {
+				stream.Owner = owner
+			}
+		case *ast.CreateSchema:
+			if namedSchema, ok := schema.NamedSchemas[s.Name.Name]; ok {
+				namedSchema.Owner = owner
+			}
+		case *ast.CreateRole:
+			if role, ok := schema.Roles[s.Name.Name]; ok {
+				role.Owner = owner
+			}
+		case *ast.CreateSequence:
+			if sequence, ok := schema.Sequences[getPathName(s.Name)]; ok {
+				sequence.Owner = owner
+			}
+		}
+	}
+
+	for _, index := range schema.Indexes {
+		if table, ok := schema.Tables[index.TableName]; ok && index.Owner == "" {
+			index.Owner = table.Owner
+		}
+	}
+	for _, index := range schema.VectorIndexes {
+		if table, ok := schema.Tables[index.TableName]; ok && index.Owner == "" {
+			index.Owner = table.Owner
+		}
+	}
+}
+
+// copyOwners sets the owners of the tables and indexes of schema to those
+// of the same objects in annotated, typically the desired schema, since
+// the database doesn't keep them
+func copyOwners(schema, annotated *Schema) {
+	for name, table := range schema.Tables {
+		if t, ok := annotated.Tables[name]; ok {
+			table.Owner = t.Owner
+		}
+	}
+	for name, index := range schema.Indexes {
+		if i, ok := annotated.Indexes[name]; ok {
+			index.Owner = i.Owner
+		} else if t, ok := annotated.Tables[index.TableName]; ok {
+			index.Owner = t.Owner
+		}
+	}
+	for name, index := range schema.VectorIndexes {
+		if i, ok := annotated.VectorIndexes[name]; ok {
+			index.Owner = i.Owner
+		} else if t, ok := annotated.Tables[index.TableName]; ok {
+			index.Owner = t.Owner
+		}
+	}
+	for name, view := range schema.Views {
+		if v, ok := annotated.Views[name]; ok {
+			view.Owner = v.Owner
+		}
+	}
+	for name, stream := range schema.ChangeStreams {
+		if s, ok := annotated.ChangeStreams[name]; ok {
+			stream.Owner = s.Owner
+		}
+	}
+	for name, sequence := range schema.Sequences {
+		if s, ok := annotated.Sequences[name]; ok {
+			sequence.Owner = s.Owner
+		}
+	}
+	for name, namedSchema := range schema.NamedSchemas {
+		if s, ok := annotated.NamedSchemas[name]; ok {
+			namedSchema.Owner = s.Owner
+		}
+	}
+	for name, role := range schema.Roles {
+		if r, ok := annotated.Roles[name]; ok {
+			role.Owner = r.Owner
+		}
+	}
+}
+
+// objectOwners returns the owner of each object of current and desired, by
+// the names used in drift reports. Objects are owned as annotated in
+// desired; columns and constraints by the owner of their table.
+func objectOwners(current, desired *Schema) map[string]string {
+	owners := make(map[string]string)
+	for _, name := range unionKeys(current.Tables, desired.Tables) {
+		table, ok := desired.Tables[name]
+		if !ok || table.Owner == "" {
+			continue
+		}
+		owners["table "+name] = table.Owner
+		for _, schema := range []*Schema{current, desired} {
+			t, ok := schema.Tables[name]
+			if !ok {
+				continue
+			}
+			for col := range t.Columns {
+				owners["column "+name+"."+col] = table.Owner
+			}
+			for constraint := range t.Constraints {
+				owners["constraint "+name+"."+constraint] = table.Owner
+			}
+		}
+	}
+
+	for _, name := range unionKeys(current.Indexes, desired.Indexes) {
+		if index, ok := desired.Indexes[name]; ok {
+			if index.Owner != "" {
+				owners["index "+name] = index.Owner
+			}
+		} else if table, ok := desired.Tables[current.Indexes[name].TableName]; ok && table.Owner != "" {
+			owners["index "+name] = table.Owner
+		}
+	}
+	for _, name := range unionKeys(current.VectorIndexes, desired.VectorIndexes) {
+		if index, ok := desired.VectorIndexes[name]; ok {
+			if index.Owner != "" {
+				owners["vector index "+name] = index.Owner
+			}
+		} else if table, ok := desired.Tables[current.VectorIndexes[name].TableName]; ok && table.Owner != "" {
+			owners["vector index "+name] = table.Owner
+		}
+	}
+
+	for name, view := range desired.Views {
+		if view.Owner != "" {
+			owners["view "+name] = view.Owner
+		}
+	}
+	for name, stream := range desired.ChangeStreams {
+		if stream.Owner != "" {
+			owners["change stream "+name] = stream.Owner
+		}
+	}
+	for name, sequence := range desired.Sequences {
+		if sequence.Owner != "" {
+			owners["sequence "+name] = sequence.Owner
+		}
+	}
+	for name, namedSchema := range desired.NamedSchemas {
+		if namedSchema.Owner != "" {
+			owners["schema "+name] = namedSchema.Owner
+		}
+	}
+	for name, role := range desired.Roles {
+		if role.Owner != "" {
+			owners["role "+name] = role.Owner
+		}
+	}
+	return owners
+}
@@ -0,0 +1,80 @@
+package spannerdef
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ownerTestDDLs = `-- owner: team-accounts
+CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Email STRING(255),
+) PRIMARY KEY (Id);
+
+CREATE INDEX IdxUsersEmail ON Users (Email);
+
+-- Reporting reads users by email too
+-- owner: team-analytics
+CREATE INDEX IdxUsersEmailId ON Users (Email, Id);
+
+-- owner: team-payments
+CREATE TABLE Payments (Id INT64 NOT NULL) PRIMARY KEY (Id);
+
+CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);
+`
+
+func TestParseDDLs_Owners(t *testing.T) {
+	schema, err := ParseDDLs(ownerTestDDLs)
+	require.NoError(t, err)
+
+	assert.Equal(t, "team-accounts", schema.Tables["Users"].Owner)
+	assert.Equal(t, "team-payments", schema.Tables["Payments"].Owner)
+	assert.Empty(t, schema.Tables["Logs"].Owner)
+	assert.Equal(t, "team-accounts", schema.Indexes["IdxUsersEmail"].Owner)
+	assert.Equal(t, "team-analytics", schema.Indexes["IdxUsersEmailId"].Owner)
+}
+
+func TestCopyOwners(t *testing.T) {
+	desired, err := ParseDDLs(ownerTestDDLs)
+	require.NoError(t, err)
+	current, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(255)) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersEmailId ON Users (Email, Id);
+CREATE INDEX IdxUsersLegacy ON Users (Email)`)
+	require.NoError(t, err)
+
+	copyOwners(current, desired)
+	assert.Equal(t, "team-accounts", current.Tables["Users"].Owner)
+	assert.Equal(t, "team-analytics", current.Indexes["IdxUsersEmailId"].Owner)
+	assert.Equal(t, "team-accounts", current.Indexes["IdxUsersLegacy"].Owner)
+}
+
+func TestBuildDriftMatrix_Owners(t *testing.T) {
+	dbs := []Database{&fakeDatabase{ddls: `CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(100), Legacy BOOL) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersEmail ON Users (Email);
+CREATE INDEX IdxUsersLegacy ON Users (Legacy);
+CREATE TABLE Payments (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Debug (Id INT64 NOT NULL) PRIMARY KEY (Id)`}}
+
+	matrix, err := BuildDriftMatrix(ownerTestDDLs, []string{"prod"}, dbs, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"column Users.Email":    "team-accounts",
+		"column Users.Legacy":   "team-accounts",
+		"index IdxUsersEmailId": "team-analytics",
+		"index IdxUsersLegacy":  "team-accounts",
+	}, matrix.Owners)
+
+	var buf bytes.Buffer
+	require.NoError(t, matrix.WriteText(&buf))
+	assert.Equal(t, `OBJECT                 OWNER           prod
+column Users.Email     team-accounts   differs
+column Users.Legacy    team-accounts   unexpected
+index IdxUsersEmailId  team-analytics  missing
+index IdxUsersLegacy   team-accounts   unexpected
+table Debug            -               unexpected
+table Logs             -               missing
+`, buf.String())
+}
@@ -2,19 +2,53 @@ package spannerdef
 
 import (
 	"fmt"
-	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/cloudspannerecosystem/memefish"
 	"github.com/cloudspannerecosystem/memefish/ast"
+	"github.com/cloudspannerecosystem/memefish/token"
 )
 
 // Schema represents a database schema
 type Schema struct {
-	Tables  map[string]*Table
-	Indexes map[string]*Index
+	Tables        map[string]*Table
+	Indexes       map[string]*Index
+	VectorIndexes map[string]*VectorIndex
+	Views         map[string]*View
+	ChangeStreams map[string]*ChangeStream
+	Sequences     map[string]*Sequence
+	NamedSchemas  map[string]*NamedSchema
+	Roles         map[string]*Role
+	ProtoBundle   *ProtoBundle // nil if the database has none
+	// Placements are the placements of a geo-partitioned database by name,
+	// nil if it has none
+	Placements map[string]*Placement
+	// DatabaseName is the database named by the ALTER DATABASE statements
+	// setting DatabaseOptions, if any
+	DatabaseName string
+	// DatabaseOptions are the values of the database options set with
+	// ALTER DATABASE by name, e.g. "'7d'" for version_retention_period,
+	// and "NULL" for options reset to their default. nil if none is set.
+	DatabaseOptions map[string]string
+	// Unsupported are the statements that aren't part of the schema model
+	// and are ignored when generating DDLs
+	Unsupported []Statement
+	// Unparsed are the statements that couldn't be parsed, skipped with
+	// GeneratorConfig.Lenient
+	Unparsed []Statement
+	// Dropped are the objects whose drop is authorized without
+	// --enable-drop. They are parsed from the tombstones of the desired
+	// DDLs and set on the current schema, which the drops are planned from.
+	Dropped map[string]bool
+}
+
+// Statement is a statement of the parsed DDLs
+type Statement struct {
+	SQL string
+	Pos int // byte offset of the statement in the parsed DDLs
 }
 
 // Table represents a Spanner table
@@ -22,11 +56,18 @@ type Table struct {
 	Name                    string
 	Columns                 map[string]*Column
 	PrimaryKey              []string
+	DescendingKeys          []string               // primary key columns ordered DESC
 	ParentTable             string                 // empty if not interleaved
 	OnDelete                string                 // "ON DELETE CASCADE", "ON DELETE NO ACTION", or empty
 	Constraints             map[string]*Constraint // Named constraints (CHECK, etc.)
 	RowDeletionPolicyColumn string                 // column name for row deletion policy
 	RowDeletionPolicyDays   int64                  // number of days for row deletion policy
+	Synonym                 string                 // other name of the table, if any
+	Pos                     int                    // byte offset of the definition in the parsed DDLs
+	Owner                   string                 // team from an "-- owner:" annotation, if any
+	// RenamedFrom is the name of the table in current, from a
+	// "-- spannerdef:renamed_from" annotation, if it is renamed
+	RenamedFrom string
 }
 
 // Column represents a table column
@@ -35,8 +76,21 @@ type Column struct {
 	Type    string
 	NotNull bool
 	Default string // For DEFAULT clause value
-	Options string // For column options like ALLOW COMMIT TIMESTAMP
-	Order   int    // Original order in the DDL
+	// Identity is the GENERATED BY DEFAULT AS IDENTITY clause of an
+	// identity column, compared with identitiesEqual
+	Identity string
+	// GeneratedExpr is the expression of a generated column, in
+	// parentheses like Default, and Stored whether it is STORED
+	GeneratedExpr string
+	Stored        bool
+	// Options are the values of the options set on the column by name,
+	// e.g. "true" for allow_commit_timestamp, or nil if there are none
+	Options map[string]string
+	Order   int // Original order in the DDL
+	Pos     int // byte offset of the definition in the parsed DDLs
+	// RenamedFrom is the name of the column in current, from a
+	// "-- spannerdef:renamed_from" annotation, if it replaces one
+	RenamedFrom string
 }
 
 // Index represents a Spanner index
@@ -47,6 +101,10 @@ type Index struct {
 	Unique       bool
 	NullFiltered bool
 	Storing      []string
+	Pos          int    // byte offset of the definition in the parsed DDLs
+	Owner        string // team from an "-- owner:" annotation, or the table's owner
+	// DescendingKeys are the key columns ordered DESC
+	DescendingKeys []string
 }
 
 // Constraint represents a table constraint
@@ -57,14 +115,37 @@ type Constraint struct {
 	Columns          []string // For FOREIGN KEY constraint
 	ReferenceTable   string   // For FOREIGN KEY constraint
 	ReferenceColumns []string // For FOREIGN KEY constraint
-	OnDelete         string   // "CASCADE", "NO ACTION", or empty
+	OnDelete         string   // "ON DELETE CASCADE", "ON DELETE NO ACTION", or empty
+	Enforcement      string   // "NOT ENFORCED", "ENFORCED", or empty, which enforces it
+	Pos              int      // byte offset of the definition in the parsed DDLs
+}
+
+// NormalizeDDLs parses ddls and generates them again, which is the form
+// spannerdef sees the schema in
+func NormalizeDDLs(ddls string) (string, error) {
+	schema, err := ParseDDLs(ddls)
+	if err != nil {
+		return "", err
+	}
+	return schemaDDLs(schema), nil
+}
+
+// schemaDDLs returns the statements creating schema from scratch
+func schemaDDLs(schema *Schema) string {
+	return Render(schema, FormatOptions{})
 }
 
 // ParseDDLs parses DDL statements and returns a Schema
 func ParseDDLs(ddls string) (*Schema, error) {
 	schema := &Schema{
-		Tables:  make(map[string]*Table),
-		Indexes: make(map[string]*Index),
+		Tables:        make(map[string]*Table),
+		Indexes:       make(map[string]*Index),
+		VectorIndexes: make(map[string]*VectorIndex),
+		Views:         make(map[string]*View),
+		ChangeStreams: make(map[string]*ChangeStream),
+		Sequences:     make(map[string]*Sequence),
+		NamedSchemas:  make(map[string]*NamedSchema),
+		Roles:         make(map[string]*Role),
 	}
 
 	if strings.TrimSpace(ddls) == "" {
@@ -82,7 +163,17 @@ func ParseDDLs(ddls string) (*Schema, error) {
 	// statements, and the statements may not be ordered so that tables
 	// precede their own ALTERs (in particular, spanner.DumpDDLs sorts them
 	// alphabetically, which puts ALTER before CREATE).
+	var unsupported []ast.DDL
 	for _, stmt := range parsed {
+		if createsExisting(schema, stmt) {
+			continue
+		}
+		if dropsIfExists(stmt) {
+			if err := replayDDL(schema, stmt); err != nil {
+				return nil, fmt.Errorf("failed to process statement: %v", err)
+			}
+			continue
+		}
 		switch s := stmt.(type) {
 		case *ast.CreateTable:
 			if err := processCreateTable(schema, s); err != nil {
@@ -92,14 +183,61 @@ func ParseDDLs(ddls string) (*Schema, error) {
 			if err := processCreateIndex(schema, s); err != nil {
 				return nil, fmt.Errorf("failed to process statement: %v", err)
 			}
+		case *ast.CreateVectorIndex:
+			processCreateVectorIndex(schema, s)
+		case *ast.CreateView:
+			processCreateView(schema, s)
+		case *ast.CreateChangeStream:
+			processCreateChangeStream(schema, s)
+		case *ast.CreateSequence:
+			processCreateSequence(schema, s)
+		case *ast.CreateSchema:
+			processCreateSchema(schema, s)
+		case *ast.CreateRole:
+			processCreateRole(schema, s)
+		case *ast.CreateProtoBundle:
+			processCreateProtoBundle(schema, s)
+		case *ast.CreatePlacement:
+			processCreatePlacement(schema, s)
+		case *ast.AlterTable, *ast.AlterIndex, *ast.AlterVectorIndex, *ast.AlterProtoBundle, *ast.AlterDatabase, *ast.Grant, *ast.Revoke:
+		default:
+			unsupported = append(unsupported, stmt)
 		}
 	}
 	for _, stmt := range parsed {
-		if s, ok := stmt.(*ast.AlterTable); ok {
-			if err := processAlterTable(schema, s); err != nil {
-				return nil, fmt.Errorf("failed to process statement: %v", err)
-			}
+		supported := true
+		switch s := stmt.(type) {
+		case *ast.AlterTable:
+			supported = processAlterTable(schema, s)
+		case *ast.AlterIndex:
+			supported = processAlterIndex(schema, s)
+		case *ast.AlterVectorIndex:
+			supported = processAlterVectorIndex(schema, s)
+		case *ast.AlterProtoBundle:
+			supported = processAlterProtoBundle(schema, s)
+		case *ast.AlterDatabase:
+			supported = processAlterDatabase(schema, s)
+		case *ast.Grant:
+			supported = processGrant(schema, s) == nil
+		case *ast.Revoke:
+			supported = processRevoke(schema, s) == nil
 		}
+		if !supported {
+			unsupported = append(unsupported, stmt)
+		}
+	}
+
+	annotateOwners(schema, ddls, parsed)
+	annotateRenames(schema, ddls, parsed)
+	schema.Dropped = parseTombstones(ddls)
+
+	// In the order of the DDLs, ALTER statements being handled last
+	sort.Slice(unsupported, func(i, j int) bool { return unsupported[i].Pos() < unsupported[j].Pos() })
+	for _, stmt := range unsupported {
+		schema.Unsupported = append(schema.Unsupported, Statement{
+			SQL: ddls[stmt.Pos():statementEnd(ddls, stmt)],
+			Pos: int(stmt.Pos()),
+		})
 	}
 
 	return schema, nil
@@ -113,29 +251,12 @@ func processCreateTable(schema *Schema, stmt *ast.CreateTable) error {
 		Name:        tableName,
 		Columns:     make(map[string]*Column),
 		Constraints: make(map[string]*Constraint),
+		Pos:         int(stmt.Pos()),
 	}
 
 	// Process columns
 	for i, col := range stmt.Columns {
-		column := &Column{
-			Name:    col.Name.Name,
-			Type:    formatColumnType(col.Type),
-			NotNull: col.NotNull,
-			Order:   i,
-		}
-
-		// Extract DEFAULT clause if present
-		if col.DefaultSemantics != nil {
-			if defaultExpr, ok := col.DefaultSemantics.(*ast.ColumnDefaultExpr); ok {
-				column.Default = "(" + defaultExpr.Expr.SQL() + ")"
-			}
-		}
-
-		// Extract OPTIONS clause if present
-		if col.Options != nil {
-			column.Options = col.Options.SQL()
-		}
-
+		column := newColumn(col, i)
 		table.Columns[column.Name] = column
 	}
 
@@ -143,53 +264,219 @@ func processCreateTable(schema *Schema, stmt *ast.CreateTable) error {
 	for _, key := range stmt.PrimaryKeys {
 		table.PrimaryKey = append(table.PrimaryKey, key.Name.Name)
 	}
+	table.DescendingKeys = descendingKeys(stmt.PrimaryKeys)
 
 	// Process table constraints
 	for _, tc := range stmt.TableConstraints {
 		registerTableConstraint(table, tc)
 	}
 
+	for _, synonym := range stmt.Synonyms {
+		table.Synonym = synonym.Name.Name
+	}
+
 	// Process interleave information
 	if stmt.Cluster != nil {
 		cluster := stmt.Cluster
 		if cluster.TableName != nil && len(cluster.TableName.Idents) > 0 {
-			table.ParentTable = cluster.TableName.Idents[len(cluster.TableName.Idents)-1].Name
+			table.ParentTable = getPathName(cluster.TableName)
 		}
 		table.OnDelete = string(cluster.OnDelete)
 	}
 
 	// Process row deletion policy
 	if stmt.RowDeletionPolicy != nil && stmt.RowDeletionPolicy.RowDeletionPolicy != nil {
-		policy := stmt.RowDeletionPolicy.RowDeletionPolicy
-		table.RowDeletionPolicyColumn = policy.ColumnName.Name
-		// Convert string value to int64
-		days, err := strconv.ParseInt(policy.NumDays.Value, policy.NumDays.Base, 64)
-		if err != nil {
-			return fmt.Errorf("failed to parse row deletion policy days: %v", err)
+		if err := setRowDeletionPolicy(table, stmt.RowDeletionPolicy.RowDeletionPolicy); err != nil {
+			return err
 		}
-		table.RowDeletionPolicyDays = days
 	}
 
 	schema.Tables[tableName] = table
 	return nil
 }
 
+// nextColumnOrder returns the order of a column added to table after its
+// existing ones
+func nextColumnOrder(table *Table) int {
+	order := 0
+	for _, col := range table.Columns {
+		order = max(order, col.Order+1)
+	}
+	return order
+}
+
+// newColumn converts a column definition to a Column at the given position
+func newColumn(col *ast.ColumnDef, order int) *Column {
+	column := &Column{
+		Name:    col.Name.Name,
+		Type:    formatColumnType(col.Type),
+		NotNull: col.NotNull,
+		Order:   order,
+		Pos:     int(col.Pos()),
+	}
+
+	// Extract DEFAULT, generated or identity clause if present
+	switch semantics := col.DefaultSemantics.(type) {
+	case *ast.ColumnDefaultExpr:
+		column.Default = "(" + semantics.Expr.SQL() + ")"
+	case *ast.GeneratedColumnExpr:
+		column.GeneratedExpr = "(" + semantics.Expr.SQL() + ")"
+		column.Stored = !semantics.Stored.Invalid()
+	case *ast.IdentityColumn:
+		column.Identity = semantics.SQL()
+	}
+
+	// Extract OPTIONS clause if present
+	if col.Options != nil {
+		setColumnOptions(column, col.Options)
+	}
+
+	return column
+}
+
+// setColumnOptions sets the options of column to those of an OPTIONS
+// clause, removing the ones set to null. allow_commit_timestamp = false is
+// the same as leaving the option out, so it is removed too.
+func setColumnOptions(column *Column, options *ast.Options) {
+	for _, record := range options.Records {
+		name := record.Name.Name
+		// Values are formatted as in OPTIONS clauses, e.g. "true" rather
+		// than "TRUE"
+		value := strings.TrimPrefix(record.SQL(), record.Name.SQL()+" = ")
+		if value == "null" || name == "allow_commit_timestamp" && value == "false" {
+			delete(column.Options, name)
+			continue
+		}
+		if column.Options == nil {
+			column.Options = make(map[string]string)
+		}
+		column.Options[name] = value
+	}
+	if len(column.Options) == 0 {
+		column.Options = nil
+	}
+}
+
+// normalizeColumnOptions rewrites the OPTIONS clauses of the columns of
+// stmt to the options setColumnOptions keeps, in name order, so they
+// format as formatColumnOptions does
+func normalizeColumnOptions(stmt *ast.CreateTable) {
+	for _, col := range stmt.Columns {
+		if col.Options == nil {
+			continue
+		}
+		column := &Column{}
+		setColumnOptions(column, col.Options)
+		col.Options.Records = slices.DeleteFunc(col.Options.Records, func(record *ast.OptionsDef) bool {
+			_, kept := column.Options[record.Name.Name]
+			return !kept
+		})
+		slices.SortStableFunc(col.Options.Records, func(a, b *ast.OptionsDef) int {
+			return strings.Compare(a.Name.Name, b.Name.Name)
+		})
+		if len(col.Options.Records) == 0 {
+			col.Options = nil
+		}
+	}
+}
+
+// formatColumnOptions returns the OPTIONS clause of column, or "" if it
+// has no options
+func formatColumnOptions(column *Column) string {
+	if len(column.Options) == 0 {
+		return ""
+	}
+	options := make([]string, 0, len(column.Options))
+	for _, name := range sortedKeys(column.Options) {
+		options = append(options, name+" = "+column.Options[name])
+	}
+	return "OPTIONS (" + strings.Join(options, ", ") + ")"
+}
+
+// setRowDeletionPolicy records a ROW DELETION POLICY clause on a table
+func setRowDeletionPolicy(table *Table, policy *ast.RowDeletionPolicy) error {
+	table.RowDeletionPolicyColumn = policy.ColumnName.Name
+	// Convert string value to int64
+	days, err := strconv.ParseInt(policy.NumDays.Value, policy.NumDays.Base, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse row deletion policy days: %v", err)
+	}
+	table.RowDeletionPolicyDays = days
+	return nil
+}
+
 // processAlterTable processes ALTER TABLE statement. Only the actions that
-// affect the schema model (currently ADD CONSTRAINT) are handled — others
-// are ignored so round-tripping DDL from Spanner/Omni's GetDatabaseDdl does
-// not error out.
-func processAlterTable(schema *Schema, stmt *ast.AlterTable) error {
-	tableName := getPathName(stmt.Name)
-	table, ok := schema.Tables[tableName]
+// affect the schema model (currently ADD COLUMN, ADD CONSTRAINT, SET ON
+// DELETE, the synonym and the row deletion policy actions) are handled, so
+// that a table can be defined by CREATE TABLE and later ALTER TABLE
+// statements. It reports whether the statement was, so that the others can
+// be listed as unsupported rather than silently dropped.
+func processAlterTable(schema *Schema, stmt *ast.AlterTable) bool {
+	table, ok := schema.Tables[getPathName(stmt.Name)]
 	if !ok {
-		return nil
+		return false
 	}
 
-	if add, ok := stmt.TableAlteration.(*ast.AddTableConstraint); ok && add.TableConstraint != nil {
-		registerTableConstraint(table, add.TableConstraint)
+	switch a := stmt.TableAlteration.(type) {
+	case *ast.AddColumn:
+		// A column defined twice is unsupported unless IF NOT EXISTS
+		// leaves the first definition
+		if _, exists := table.Columns[a.Column.Name.Name]; exists {
+			return a.IfNotExists
+		}
+		column := newColumn(a.Column, nextColumnOrder(table))
+		table.Columns[column.Name] = column
+		return true
+	case *ast.AddTableConstraint:
+		if a.TableConstraint != nil {
+			registerTableConstraint(table, a.TableConstraint)
+			return true
+		}
+	case *ast.AddRowDeletionPolicy:
+		return setRowDeletionPolicy(table, a.RowDeletionPolicy) == nil
+	case *ast.ReplaceRowDeletionPolicy:
+		return setRowDeletionPolicy(table, a.RowDeletionPolicy) == nil
+	case *ast.DropRowDeletionPolicy:
+		table.RowDeletionPolicyColumn = ""
+		table.RowDeletionPolicyDays = 0
+		return true
+	case *ast.SetOnDelete:
+		if table.ParentTable != "" {
+			table.OnDelete = string(a.OnDelete)
+			return true
+		}
+	case *ast.AddSynonym:
+		table.Synonym = a.Name.Name
+		return true
+	case *ast.DropSynonym:
+		if table.Synonym == a.Name.Name {
+			table.Synonym = ""
+			return true
+		}
 	}
+	return false
+}
 
-	return nil
+// processAlterIndex folds ADD/DROP STORED COLUMN of an ALTER INDEX
+// statement into the index, so that dumps containing them can be fed back
+// in unchanged. It reports whether the statement was handled.
+func processAlterIndex(schema *Schema, stmt *ast.AlterIndex) bool {
+	index, ok := schema.Indexes[getPathName(stmt.Name)]
+	if !ok {
+		return false
+	}
+
+	switch a := stmt.IndexAlteration.(type) {
+	case *ast.AddStoredColumn:
+		if !slices.Contains(index.Storing, a.Name.Name) {
+			index.Storing = append(index.Storing, a.Name.Name)
+		}
+		return true
+	case *ast.DropStoredColumn:
+		index.Storing = slices.DeleteFunc(index.Storing, func(column string) bool { return column == a.Name.Name })
+		return true
+	}
+	return false
 }
 
 // registerTableConstraint records a CHECK or FOREIGN KEY constraint on a table.
@@ -211,6 +498,7 @@ func registerTableConstraint(table *Table, tc *ast.TableConstraint) {
 			Name:       constraintName,
 			Type:       "CHECK",
 			Expression: "(" + c.Expr.SQL() + ")",
+			Pos:        int(tc.Pos()),
 		}
 	case *ast.ForeignKey:
 		if constraintName == "" {
@@ -234,6 +522,8 @@ func registerTableConstraint(table *Table, tc *ast.TableConstraint) {
 			ReferenceTable:   getPathName(c.ReferenceTable),
 			ReferenceColumns: refColumns,
 			OnDelete:         string(c.OnDelete),
+			Enforcement:      string(c.Enforcement),
+			Pos:              int(tc.Pos()),
 		}
 	}
 }
@@ -248,12 +538,14 @@ func processCreateIndex(schema *Schema, stmt *ast.CreateIndex) error {
 		TableName:    tableName,
 		Unique:       stmt.Unique,
 		NullFiltered: stmt.NullFiltered,
+		Pos:          int(stmt.Pos()),
 	}
 
 	// Process key columns
 	for _, key := range stmt.Keys {
 		index.Columns = append(index.Columns, key.Name.Name)
 	}
+	index.DescendingKeys = descendingKeys(stmt.Keys)
 
 	// Process storing columns
 	if stmt.Storing != nil {
@@ -271,8 +563,38 @@ func getPathName(path *ast.Path) string {
 	if path == nil || len(path.Idents) == 0 {
 		return ""
 	}
-	// For simple cases, just return the last identifier
-	return path.Idents[len(path.Idents)-1].Name
+	// Objects of named schemas are qualified, e.g. "accounting.Invoices"
+	names := make([]string, len(path.Idents))
+	for i, ident := range path.Idents {
+		names[i] = ident.Name
+	}
+	return strings.Join(names, ".")
+}
+
+// quoteName backquotes each part of an object name that can't be written
+// bare, e.g. a reserved word such as `Order`, as GetDatabaseDdl does. The
+// names of a schema are kept unquoted, so that `Users` and Users are the
+// same table, and quoted when written into generated DDLs.
+func quoteName(name string) string {
+	if name == "" {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = token.QuoteSQLIdent(part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteNames returns names, quoted by quoteName, as a comma-separated list
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteName(name)
+	}
+	return strings.Join(quoted, ", ")
 }
 
 // formatColumnType formats a column type from AST to string
@@ -280,43 +602,251 @@ func formatColumnType(typeNode ast.SchemaType) string {
 	if typeNode == nil {
 		return "UNKNOWN"
 	}
+	// Proto types are formatted as Spanner dumps them, whether they are
+	// written quoted or not
+	switch t := typeNode.(type) {
+	case *ast.NamedType:
+		return formatProtoType(protoTypeName(t))
+	case *ast.ArraySchemaType:
+		if item, ok := t.Item.(*ast.NamedType); ok {
+			return "ARRAY<" + formatProtoType(protoTypeName(item)) + ">"
+		}
+	}
 	// Use the SQL() method provided by memefish AST
 	return typeNode.SQL()
 }
 
-// GenerateDDLs generates DDL statements to transform current schema to desired schema
+// GenerateDDLs generates DDL statements to transform current schema to desired schema.
+// Statements of the same kind are grouped across tables and emitted in a
+// deterministic order, so compatible changes (e.g. every ADD COLUMN) end up
+// next to each other and can be applied together rather than interleaved
+// with statements that need validation or backfills.
 func GenerateDDLs(current, desired *Schema) []string {
 	var ddls []string
 
-	// 1. Drop indexes first (required before dropping tables with indexes)
-	dropIndexDDLs := generateDropIndexDDLs(current, desired)
-	ddls = append(ddls, dropIndexDDLs...)
+	renames, current := generateRenameTableDDLs(current, desired)
+	alters := generateAlterTableDDLs(current, desired)
+
+	earlyStreams, lateStreams := generateAlterChangeStreamDDLs(current, desired)
+	unstored, stored := generateAlterVectorIndexDDLs(current, desired)
+	protoTypes, unusedProtoTypes := generateProtoBundleDDLs(current, desired)
+	earlyViews, lateViews := generateCreateViewDDLs(current, desired)
+
+	// 0. Set database options (such as default_sequence_kind, which
+	// statements below may rely on), rename tables, so that the statements
+	// below can use their new names, then revoke privileges, drop views,
+	// replace views whose new query only reads existing objects, drop change
+	// streams, and stop watching tables and columns with change streams
+	// (they may be on, read or watch objects dropped below)
+	ddls = append(ddls, generateAlterDatabaseDDLs(current, desired)...)
+	ddls = append(ddls, renames...)
+	ddls = append(ddls, generateRevokeDDLs(current, desired)...)
+	ddls = append(ddls, generateDropViewDDLs(current, desired)...)
+	ddls = append(ddls, earlyViews...)
+	ddls = append(ddls, generateDropChangeStreamDDLs(current, desired)...)
+	ddls = append(ddls, earlyStreams...)
+
+	// 1. Drop indexes and stored columns of vector indexes (required before
+	// dropping tables or columns with indexes)
+	ddls = append(ddls, generateDropIndexDDLs(current, desired)...)
+	ddls = append(ddls, generateDropVectorIndexDDLs(current, desired)...)
+	ddls = append(ddls, unstored...)
+
+	// 2. Drop constraints (foreign keys may reference tables or columns
+	// dropped below), row deletion policies (which may be on columns
+	// dropped below) and synonyms (whose names tables created below may
+	// take)
+	ddls = append(ddls, alters.dropConstraints...)
+	ddls = append(ddls, alters.dropPolicies...)
+	ddls = append(ddls, alters.dropSynonyms...)
+
+	// 3. Drop tables
+	ddls = append(ddls, generateDropTableDDLs(current, desired)...)
+
+	// 4. Drop columns
+	ddls = append(ddls, alters.dropColumns...)
+
+	// 5. Create the proto bundle or insert new types into it, then new
+	// placements, named schemas, roles, sequences and tables (proto types
+	// first, as columns may be declared with them, schemas next, as the
+	// others may be created in them, and sequences before tables, as
+	// column defaults may take values from them)
+	ddls = append(ddls, protoTypes...)
+	ddls = append(ddls, generateCreatePlacementDDLs(current, desired)...)
+	ddls = append(ddls, generateCreateSchemaDDLs(current, desired)...)
+	ddls = append(ddls, generateCreateRoleDDLs(current, desired)...)
+	ddls = append(ddls, generateCreateSequenceDDLs(current, desired)...)
+	ddls = append(ddls, generateAlterSequenceDDLs(current, desired)...)
+	ddls = append(ddls, generateCreateTableDDLs(current, desired)...)
+
+	// 6. Add columns to existing tables
+	ddls = append(ddls, alters.addColumns...)
+
+	// 7. Alter existing columns, then set row deletion policies (which may
+	// be on columns added above) and add synonyms (which may take the names
+	// of tables dropped above)
+	ddls = append(ddls, alters.alterColumns...)
+	ddls = append(ddls, alters.setPolicies...)
+	ddls = append(ddls, alters.addSynonyms...)
+
+	// 8. Add constraints (after new tables exist so foreign keys can reference them)
+	ddls = append(ddls, alters.addConstraints...)
+
+	// 9. Create new indexes and store new columns in vector indexes
+	ddls = append(ddls, generateCreateIndexDDLs(current, desired)...)
+	ddls = append(ddls, generateCreateVectorIndexDDLs(current, desired)...)
+	ddls = append(ddls, stored...)
+
+	// 10. Create and replace views (after the tables and columns they read)
+	ddls = append(ddls, lateViews...)
+
+	// 11. Create change streams and watch new tables and columns (after
+	// they are created)
+	ddls = append(ddls, generateCreateChangeStreamDDLs(current, desired)...)
+	ddls = append(ddls, lateStreams...)
+
+	// 12. Grant privileges (after the objects they are on are created)
+	ddls = append(ddls, generateGrantDDLs(current, desired)...)
+
+	// 13. Drop sequences (after the columns taking values from them are
+	// dropped or altered), roles (after their privileges are revoked) and
+	// proto types (after the columns declared with them)
+	ddls = append(ddls, generateDropSequenceDDLs(current, desired)...)
+	ddls = append(ddls, generateDropRoleDDLs(current, desired)...)
+	ddls = append(ddls, unusedProtoTypes...)
+
+	// 14. Drop named schemas (after everything in them is dropped)
+	ddls = append(ddls, generateDropSchemaDDLs(current, desired)...)
 
-	// 2. Drop tables
-	dropTableDDLs := generateDropTableDDLs(current, desired)
-	ddls = append(ddls, dropTableDDLs...)
+	return ddls
+}
+
+// addIfNotExists adds IF NOT EXISTS to the CREATE TABLE, CREATE INDEX,
+// CREATE VECTOR INDEX, CREATE SEQUENCE and ADD COLUMN statements of ddls,
+// and makes CREATE VIEW statements, which don't take IF NOT EXISTS, CREATE
+// OR REPLACE VIEW. Others are left as they are.
+func addIfNotExists(ddls []string) []string {
+	result := make([]string, len(ddls))
+	for i, ddl := range ddls {
+		switch classifyDDL(ddl).kind {
+		case opCreateTable:
+			ddl = strings.Replace(ddl, "CREATE TABLE ", "CREATE TABLE IF NOT EXISTS ", 1)
+		case opCreateIndex:
+			ddl = strings.Replace(ddl, " INDEX ", " INDEX IF NOT EXISTS ", 1)
+		case opCreateVectorIndex:
+			ddl = strings.Replace(ddl, "CREATE VECTOR INDEX ", "CREATE VECTOR INDEX IF NOT EXISTS ", 1)
+		case opAddColumn:
+			ddl = strings.Replace(ddl, " ADD COLUMN ", " ADD COLUMN IF NOT EXISTS ", 1)
+		case opCreateView:
+			ddl = strings.Replace(ddl, "CREATE VIEW ", "CREATE OR REPLACE VIEW ", 1)
+		case opCreateSequence:
+			ddl = strings.Replace(ddl, "CREATE SEQUENCE ", "CREATE SEQUENCE IF NOT EXISTS ", 1)
+		}
+		result[i] = ddl
+	}
+	return result
+}
 
-	// 3. Alter existing tables
-	alterTableDDLs := generateAlterTableDDLs(current, desired)
-	ddls = append(ddls, alterTableDDLs...)
+// quoteIdentifiers backquotes the identifiers of ddls that name schema
+// objects: tables, columns, indexes and constraints, including column
+// references in expressions, and each part of schema-qualified names.
+// Function names, option names and other identifiers that aren't object
+// names are left as they are.
+func quoteIdentifiers(ddls []string) []string {
+	result := make([]string, len(ddls))
+	for i, ddl := range ddls {
+		result[i] = ddl
+		stmt, err := memefish.ParseDDL("", ddl)
+		if err != nil {
+			continue
+		}
 
-	// 4. Create new tables
-	createTableDDLs := generateCreateTableDDLs(current, desired)
-	ddls = append(ddls, createTableDDLs...)
+		notNames := make(map[*ast.Ident]bool)
+		var idents []*ast.Ident
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.OptionsDef:
+				notNames[n.Name] = true
+			case *ast.CallExpr:
+				for _, ident := range n.Func.Idents {
+					notNames[ident] = true
+				}
+			case *ast.NamedArg:
+				notNames[n.Name] = true
+			case *ast.ExtractExpr:
+				notNames[n.Part] = true
+			case *ast.NamedType, *ast.StructField, *ast.LambdaArg:
+				return false
+			case *ast.Ident:
+				idents = append(idents, n)
+			}
+			return true
+		})
+
+		sort.Slice(idents, func(i, j int) bool { return idents[i].Pos() < idents[j].Pos() })
+		var b strings.Builder
+		last := 0
+		for _, ident := range idents {
+			if notNames[ident] {
+				continue
+			}
+			b.WriteString(ddl[last:ident.Pos()])
+			b.WriteString("`" + strings.ReplaceAll(ident.Name, "`", "\\`") + "`")
+			last = int(ident.End())
+		}
+		b.WriteString(ddl[last:])
+		result[i] = b.String()
+	}
+	return result
+}
 
-	// 5. Create new indexes
-	createIndexDDLs := generateCreateIndexDDLs(current, desired)
-	ddls = append(ddls, createIndexDDLs...)
+// sortedKeys returns the keys of m in sorted order, for deterministic output
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
 
-	return ddls
+// recreatedIndexes returns the current indexes that are kept in desired
+// but whose definition changes, or that contain a column whose type
+// changes. Spanner can't alter either in place, so they are dropped before
+// the ALTER COLUMN and created again after it.
+func recreatedIndexes(current, desired *Schema) map[string]bool {
+	recreated := make(map[string]bool)
+	for name, index := range current.Indexes {
+		desiredIndex, exists := desired.Indexes[name]
+		if !exists {
+			continue
+		}
+		if !indexesEqual(index, desiredIndex) {
+			recreated[name] = true
+			continue
+		}
+		currentTable, desiredTable := current.Tables[index.TableName], desired.Tables[index.TableName]
+		if currentTable == nil || desiredTable == nil {
+			continue
+		}
+		for _, col := range append(slices.Clone(index.Columns), index.Storing...) {
+			currentCol, desiredCol := currentTable.Columns[col], desiredTable.Columns[col]
+			if currentCol != nil && desiredCol != nil && currentCol.Type != desiredCol.Type {
+				recreated[name] = true
+			}
+		}
+	}
+	return recreated
 }
 
 // generateDropIndexDDLs generates DDLs to drop indexes
 func generateDropIndexDDLs(current, desired *Schema) []string {
 	var ddls []string
+	recreated := recreatedIndexes(current, desired)
 
 	// Drop indexes that no longer exist or whose tables will be dropped
-	for indexName, index := range current.Indexes {
+	for _, indexName := range sortedKeys(current.Indexes) {
+		index := current.Indexes[indexName]
 		shouldDrop := false
 
 		// Drop if index doesn't exist in desired schema
@@ -329,40 +859,77 @@ func generateDropIndexDDLs(current, desired *Schema) []string {
 			shouldDrop = true
 		}
 
+		// Drop to recreate it with its new definition or after changing
+		// the type of its columns
+		if recreated[indexName] {
+			shouldDrop = true
+		}
+
 		if shouldDrop {
-			ddls = append(ddls, fmt.Sprintf("DROP INDEX %s", indexName))
+			ddls = append(ddls, fmt.Sprintf("DROP INDEX %s", quoteName(indexName)))
 		}
 	}
 
 	return ddls
 }
 
-// generateDropTableDDLs generates DDLs to drop tables
+// generateDropTableDDLs generates DDLs to drop tables, interleaved tables
+// and tables with foreign keys before the tables they are interleaved in or
+// reference
 func generateDropTableDDLs(current, desired *Schema) []string {
-	var ddls []string
-
-	// Drop tables that no longer exist
-	for tableName := range current.Tables {
+	var dropped []*Table
+	for _, tableName := range sortedKeys(current.Tables) {
 		if _, exists := desired.Tables[tableName]; !exists {
-			ddls = append(ddls, fmt.Sprintf("DROP TABLE %s", tableName))
+			dropped = append(dropped, current.Tables[tableName])
 		}
 	}
 
+	sorted := sortTablesByDependency(dropped)
+	ddls := make([]string, len(sorted))
+	for i, table := range sorted {
+		ddls[len(sorted)-1-i] = fmt.Sprintf("DROP TABLE %s", quoteName(table.Name))
+	}
 	return ddls
 }
 
+// alterDDLs holds ALTER TABLE statements grouped by kind, so that
+// GenerateDDLs can order each kind across all tables
+type alterDDLs struct {
+	dropConstraints []string
+	dropPolicies    []string
+	dropSynonyms    []string
+	dropColumns     []string
+	addColumns      []string
+	alterColumns    []string
+	setPolicies     []string
+	addSynonyms     []string
+	addConstraints  []string
+}
+
+func (a *alterDDLs) append(other *alterDDLs) {
+	a.dropConstraints = append(a.dropConstraints, other.dropConstraints...)
+	a.dropPolicies = append(a.dropPolicies, other.dropPolicies...)
+	a.dropSynonyms = append(a.dropSynonyms, other.dropSynonyms...)
+	a.dropColumns = append(a.dropColumns, other.dropColumns...)
+	a.addColumns = append(a.addColumns, other.addColumns...)
+	a.alterColumns = append(a.alterColumns, other.alterColumns...)
+	a.setPolicies = append(a.setPolicies, other.setPolicies...)
+	a.addSynonyms = append(a.addSynonyms, other.addSynonyms...)
+	a.addConstraints = append(a.addConstraints, other.addConstraints...)
+}
+
 // generateAlterTableDDLs generates DDLs to alter existing tables
-func generateAlterTableDDLs(current, desired *Schema) []string {
-	var ddls []string
+func generateAlterTableDDLs(current, desired *Schema) *alterDDLs {
+	alters := &alterDDLs{}
 
 	// Alter existing tables
-	for tableName, desiredTable := range desired.Tables {
+	for _, tableName := range sortedKeys(desired.Tables) {
 		if currentTable, exists := current.Tables[tableName]; exists {
-			ddls = append(ddls, generateAlterTable(currentTable, desiredTable)...)
+			alters.append(generateAlterTable(currentTable, desired.Tables[tableName]))
 		}
 	}
 
-	return ddls
+	return alters
 }
 
 // generateCreateTableDDLs generates DDLs to create new tables
@@ -371,9 +938,9 @@ func generateCreateTableDDLs(current, desired *Schema) []string {
 
 	// Find new tables that need to be created
 	var newTables []*Table
-	for tableName, table := range desired.Tables {
+	for _, tableName := range sortedKeys(desired.Tables) {
 		if _, exists := current.Tables[tableName]; !exists {
-			newTables = append(newTables, table)
+			newTables = append(newTables, desired.Tables[tableName])
 		}
 	}
 
@@ -400,11 +967,15 @@ func sortTablesByDependency(tables []*Table) []*Table {
 		tableMap[table.Name] = table
 	}
 
+	// visiting breaks cycles of foreign keys, such as a table referencing
+	// itself
+	visiting := make(map[string]bool)
 	var processTable func(table *Table)
 	processTable = func(table *Table) {
-		if processed[table.Name] {
+		if processed[table.Name] || visiting[table.Name] {
 			return
 		}
+		visiting[table.Name] = true
 
 		// If this table has a parent, process the parent first
 		if table.ParentTable != "" {
@@ -414,7 +985,8 @@ func sortTablesByDependency(tables []*Table) []*Table {
 		}
 
 		// If this table has foreign key constraints, process referenced tables first
-		for _, constraint := range table.Constraints {
+		for _, name := range sortedKeys(table.Constraints) {
+			constraint := table.Constraints[name]
 			if constraint.Type == "FOREIGN KEY" {
 				if referencedTable, exists := tableMap[constraint.ReferenceTable]; exists {
 					processTable(referencedTable)
@@ -438,51 +1010,57 @@ func sortTablesByDependency(tables []*Table) []*Table {
 // generateCreateIndexDDLs generates DDLs to create new indexes
 func generateCreateIndexDDLs(current, desired *Schema) []string {
 	var ddls []string
+	recreated := recreatedIndexes(current, desired)
 
-	// Create new indexes
-	for indexName, index := range desired.Indexes {
-		if _, exists := current.Indexes[indexName]; !exists {
-			ddls = append(ddls, generateCreateIndex(index))
+	// Create new indexes, and recreate those dropped to change them
+	for _, indexName := range sortedKeys(desired.Indexes) {
+		if _, exists := current.Indexes[indexName]; !exists || recreated[indexName] {
+			ddls = append(ddls, generateCreateIndex(desired.Indexes[indexName]))
 		}
 	}
 
 	return ddls
 }
 
-// generateCreateTable generates CREATE TABLE DDL
-func generateCreateTable(table *Table) string {
-	var ddl strings.Builder
-	ddl.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", table.Name))
-
-	// Sort columns by original order
-	type columnInfo struct {
-		name  string
-		order int
-	}
-	var columns []columnInfo
-	for name, col := range table.Columns {
-		columns = append(columns, columnInfo{name: name, order: col.Order})
+// sortedColumns returns the columns of table in their original DDL order
+func sortedColumns(table *Table) []*Column {
+	columns := make([]*Column, 0, len(table.Columns))
+	for _, col := range table.Columns {
+		columns = append(columns, col)
 	}
 	sort.Slice(columns, func(i, j int) bool {
-		if columns[i].order != columns[j].order {
-			return columns[i].order < columns[j].order
+		if columns[i].Order != columns[j].Order {
+			return columns[i].Order < columns[j].Order
 		}
 		// If order is the same, sort by name for stable output
-		return columns[i].name < columns[j].name
+		return columns[i].Name < columns[j].Name
 	})
+	return columns
+}
 
-	var columnDefs []string
-	for _, colInfo := range columns {
-		col := table.Columns[colInfo.name]
-		def := fmt.Sprintf("  %s %s", col.Name, col.Type)
+// generateCreateTable generates CREATE TABLE DDL
+func generateCreateTable(table *Table) string {
+	var ddl strings.Builder
+	fmt.Fprintf(&ddl, "CREATE TABLE %s (\n", quoteName(table.Name))
+
+	columns := sortedColumns(table)
+	columnDefs := make([]string, 0, len(columns))
+	for _, col := range columns {
+		def := fmt.Sprintf("  %s %s", quoteName(col.Name), col.Type)
 		if col.NotNull {
 			def += " NOT NULL"
 		}
 		if col.Default != "" {
 			def += " DEFAULT " + col.Default
 		}
-		if col.Options != "" {
-			def += " " + col.Options
+		if col.Identity != "" {
+			def += " " + col.Identity
+		}
+		if generated := generatedClause(col); generated != "" {
+			def += " " + generated
+		}
+		if options := formatColumnOptions(col); options != "" {
+			def += " " + options
 		}
 		columnDefs = append(columnDefs, def)
 	}
@@ -502,30 +1080,38 @@ func generateCreateTable(table *Table) string {
 			constraint := table.Constraints[name]
 			if constraint.Type == "CHECK" {
 				ddl.WriteString(",\n  CONSTRAINT ")
-				ddl.WriteString(name)
+				ddl.WriteString(quoteName(name))
 				ddl.WriteString(" CHECK ")
 				ddl.WriteString(constraint.Expression)
 			} else if constraint.Type == "FOREIGN KEY" {
 				ddl.WriteString(",\n  CONSTRAINT ")
-				ddl.WriteString(name)
+				ddl.WriteString(quoteName(name))
 				ddl.WriteString(" FOREIGN KEY (")
-				ddl.WriteString(strings.Join(constraint.Columns, ", "))
+				ddl.WriteString(quoteNames(constraint.Columns))
 				ddl.WriteString(") REFERENCES ")
-				ddl.WriteString(constraint.ReferenceTable)
+				ddl.WriteString(quoteName(constraint.ReferenceTable))
 				ddl.WriteString(" (")
-				ddl.WriteString(strings.Join(constraint.ReferenceColumns, ", "))
+				ddl.WriteString(quoteNames(constraint.ReferenceColumns))
 				ddl.WriteString(")")
 				if constraint.OnDelete != "" {
 					ddl.WriteString(" ")
 					ddl.WriteString(constraint.OnDelete)
 				}
+				if constraint.Enforcement != "" {
+					ddl.WriteString(" ")
+					ddl.WriteString(constraint.Enforcement)
+				}
 			}
 		}
 	}
 
+	if table.Synonym != "" {
+		fmt.Fprintf(&ddl, ",\n  SYNONYM (%s)", quoteName(table.Synonym))
+	}
+
 	// Add primary key
 	if len(table.PrimaryKey) > 0 {
-		ddl.WriteString(fmt.Sprintf("\n) PRIMARY KEY (%s)", strings.Join(table.PrimaryKey, ", ")))
+		fmt.Fprintf(&ddl, "\n) PRIMARY KEY (%s)", primaryKey(table))
 	} else {
 		ddl.WriteString("\n)")
 	}
@@ -533,152 +1119,216 @@ func generateCreateTable(table *Table) string {
 	// Add interleave clause if present
 	if table.ParentTable != "" {
 		ddl.WriteString(",\n")
-		ddl.WriteString(fmt.Sprintf("INTERLEAVE IN PARENT %s", table.ParentTable))
+		fmt.Fprintf(&ddl, "INTERLEAVE IN PARENT %s", quoteName(table.ParentTable))
 		if table.OnDelete != "" {
-			ddl.WriteString(fmt.Sprintf(" %s", table.OnDelete))
+			fmt.Fprintf(&ddl, " %s", table.OnDelete)
 		}
 	}
 
 	// Add row deletion policy if present
-	if table.RowDeletionPolicyColumn != "" && table.RowDeletionPolicyDays > 0 {
-		ddl.WriteString(",\n")
-		ddl.WriteString(fmt.Sprintf("ROW DELETION POLICY (OLDER_THAN(%s, INTERVAL %d DAY))",
-			table.RowDeletionPolicyColumn, table.RowDeletionPolicyDays))
+	if policy := rowDeletionPolicy(table); policy != "" {
+		ddl.WriteString(",\n" + policy)
 	}
 
 	return ddl.String()
 }
 
+// descendingKeys returns the columns of keys ordered DESC
+func descendingKeys(keys []*ast.IndexKey) []string {
+	var descending []string
+	for _, key := range keys {
+		if key.Dir == ast.DirectionDesc {
+			descending = append(descending, key.Name.Name)
+		}
+	}
+	return descending
+}
+
+// formatKeys returns the key columns of an index or primary key as written
+// between the parentheses of their definition, with DESC after those of
+// descending. ASC, the default, is left out, as Spanner dumps keys.
+func formatKeys(columns, descending []string) string {
+	keys := make([]string, len(columns))
+	for i, column := range columns {
+		keys[i] = quoteName(column)
+		if slices.Contains(descending, column) {
+			keys[i] += " DESC"
+		}
+	}
+	return strings.Join(keys, ", ")
+}
+
 // generateCreateIndex generates CREATE INDEX DDL
 func generateCreateIndex(index *Index) string {
 	var parts []string
 
+	parts = append(parts, "CREATE")
 	if index.Unique {
-		parts = append(parts, "CREATE UNIQUE INDEX")
-	} else {
-		parts = append(parts, "CREATE INDEX")
+		parts = append(parts, "UNIQUE")
 	}
+	if index.NullFiltered {
+		parts = append(parts, "NULL_FILTERED")
+	}
+	parts = append(parts, "INDEX")
 
-	parts = append(parts, index.Name, "ON", index.TableName)
-	parts = append(parts, fmt.Sprintf("(%s)", strings.Join(index.Columns, ", ")))
+	parts = append(parts, quoteName(index.Name), "ON", quoteName(index.TableName))
+	parts = append(parts, fmt.Sprintf("(%s)", formatKeys(index.Columns, index.DescendingKeys)))
 
 	if len(index.Storing) > 0 {
-		parts = append(parts, fmt.Sprintf("STORING (%s)", strings.Join(index.Storing, ", ")))
+		parts = append(parts, fmt.Sprintf("STORING (%s)", quoteNames(index.Storing)))
 	}
 
 	return strings.Join(parts, " ")
 }
 
-// generateAlterTable generates ALTER TABLE DDLs for differences between tables
-func generateAlterTable(current, desired *Table) []string {
-	var ddls []string
+// indexesEqual compares the definitions of two indexes, ignoring the
+// order of their stored columns
+func indexesEqual(current, desired *Index) bool {
+	return current.TableName == desired.TableName &&
+		current.Unique == desired.Unique &&
+		current.NullFiltered == desired.NullFiltered &&
+		formatKeys(current.Columns, current.DescendingKeys) == formatKeys(desired.Columns, desired.DescendingKeys) &&
+		slices.Equal(slices.Sorted(slices.Values(current.Storing)), slices.Sorted(slices.Values(desired.Storing)))
+}
 
-	// Add new columns
-	for colName, col := range desired.Columns {
-		if _, exists := current.Columns[colName]; !exists {
-			def := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", desired.Name, col.Name, col.Type)
-			if col.NotNull {
-				def += " NOT NULL"
-			}
-			if col.Default != "" {
-				def += " DEFAULT " + col.Default
-			}
-			if col.Options != "" {
-				def += " " + col.Options
-			}
-			ddls = append(ddls, def)
+// generateAlterTable generates ALTER TABLE DDLs for differences between tables
+func generateAlterTable(current, desired *Table) *alterDDLs {
+	ddls := &alterDDLs{}
+
+	// Add new columns, generated columns after the columns they read
+	var added []*Column
+	for _, col := range sortedColumns(desired) {
+		if _, exists := current.Columns[col.Name]; !exists {
+			added = append(added, col)
 		}
 	}
+	for _, col := range sortColumnsForAdd(added) {
+		def := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteName(desired.Name), quoteName(col.Name), col.Type)
+		if col.NotNull {
+			def += " NOT NULL"
+		}
+		if col.Default != "" {
+			def += " DEFAULT " + col.Default
+		}
+		if col.Identity != "" {
+			def += " " + col.Identity
+		}
+		if generated := generatedClause(col); generated != "" {
+			def += " " + generated
+		}
+		if options := formatColumnOptions(col); options != "" {
+			def += " " + options
+		}
+		ddls.addColumns = append(ddls.addColumns, def)
+	}
 
 	// Handle constraints
 	// Drop constraints that no longer exist or have changed
-	for constraintName, currentConstraint := range current.Constraints {
+	for _, constraintName := range sortedKeys(current.Constraints) {
 		desiredConstraint, exists := desired.Constraints[constraintName]
 		needsDrop := !exists
 
-		if exists {
-			// Check if constraint needs to be dropped and recreated
-			if currentConstraint.Type == "CHECK" && currentConstraint.Expression != desiredConstraint.Expression {
-				needsDrop = true
-			} else if currentConstraint.Type == "FOREIGN KEY" &&
-				(strings.Join(currentConstraint.Columns, ",") != strings.Join(desiredConstraint.Columns, ",") ||
-					currentConstraint.ReferenceTable != desiredConstraint.ReferenceTable ||
-					strings.Join(currentConstraint.ReferenceColumns, ",") != strings.Join(desiredConstraint.ReferenceColumns, ",") ||
-					currentConstraint.OnDelete != desiredConstraint.OnDelete) {
-				needsDrop = true
-			}
+		if exists && !constraintsEqual(current.Constraints[constraintName], desiredConstraint) {
+			// Constraint changed, so it needs to be dropped and recreated
+			needsDrop = true
 		}
 
 		if needsDrop {
-			ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", desired.Name, constraintName))
+			ddls.dropConstraints = append(ddls.dropConstraints,
+				fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", quoteName(desired.Name), quoteName(constraintName)))
 		}
 	}
 
-	// Drop columns that no longer exist
-	for colName := range current.Columns {
-		if _, exists := desired.Columns[colName]; !exists {
-			ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", desired.Name, colName))
+	// Drop columns that no longer exist, except those renamed, which are
+	// kept until their values are copied, generated columns before the
+	// columns they read
+	var dropped []*Column
+	for _, col := range sortedColumns(current) {
+		if _, exists := desired.Columns[col.Name]; !exists && renamedTo(desired, col.Name) == "" {
+			dropped = append(dropped, col)
 		}
 	}
+	for _, col := range sortColumnsForDrop(dropped) {
+		ddls.dropColumns = append(ddls.dropColumns,
+			fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", quoteName(desired.Name), quoteName(col.Name)))
+	}
 
 	// Handle column type changes and OPTIONS changes
-	for colName, desiredCol := range desired.Columns {
+	for _, desiredCol := range sortedColumns(desired) {
+		colName := desiredCol.Name
 		if currentCol, exists := current.Columns[colName]; exists {
-			// Check if column type has changed
-			if currentCol.Type != desiredCol.Type {
-				def := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s", desired.Name, colName, desiredCol.Type)
+			// Check if column type or nullability has changed. The
+			// statement redefines the column, dropping a DEFAULT it
+			// doesn't repeat, so it sets the desired one as well.
+			redefined := currentCol.Type != desiredCol.Type || currentCol.NotNull != desiredCol.NotNull
+			if redefined {
+				def := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s", quoteName(desired.Name), quoteName(colName), desiredCol.Type)
 				if desiredCol.NotNull {
 					def += " NOT NULL"
 				}
-				ddls = append(ddls, def)
+				if desiredCol.Default != "" && desiredCol.GeneratedExpr == "" {
+					def += " DEFAULT " + desiredCol.Default
+				}
+				ddls.alterColumns = append(ddls.alterColumns, def)
 			}
 
-			// Handle OPTIONS changes independently from type changes
-			if currentCol.Options != desiredCol.Options {
-				if desiredCol.Options != "" {
-					ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET %s",
-						desired.Name, colName, desiredCol.Options))
+			// Set or drop the DEFAULT of the column. Generated columns
+			// can't have one, see recreateGeneratedColumns.
+			if !redefined && !defaultsEqual(currentCol.Default, desiredCol.Default) && currentCol.GeneratedExpr == "" && desiredCol.GeneratedExpr == "" {
+				if desiredCol.Default != "" {
+					ddls.alterColumns = append(ddls.alterColumns, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s",
+						quoteName(desired.Name), quoteName(colName), desiredCol.Default))
 				} else {
-					// Remove OPTIONS by setting each key to null
-					ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET %s",
-						desired.Name, colName, nullifyOptions(currentCol.Options)))
+					ddls.alterColumns = append(ddls.alterColumns, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT",
+						quoteName(desired.Name), quoteName(colName)))
+				}
+			}
+
+			// Changes to the skip range of an identity column are made in
+			// place; other changes to its identity aren't supported
+			if !identitiesEqual(currentCol, desiredCol) {
+				if ddl := generateAlterIdentity(desired.Name, currentCol, desiredCol); ddl != "" {
+					ddls.alterColumns = append(ddls.alterColumns, ddl)
 				}
 			}
+
+			// Handle OPTIONS changes independently from type changes,
+			// setting removed options to null
+			if options := changedOptions(currentCol.Options, desiredCol.Options); options != "" {
+				ddls.alterColumns = append(ddls.alterColumns, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET OPTIONS (%s)",
+					quoteName(desired.Name), quoteName(colName), options))
+			}
 		}
 	}
 
+	// Add, replace or drop the row deletion policy
+	ddls.dropPolicies, ddls.setPolicies = generateAlterRowDeletionPolicy(current, desired)
+	ddls.dropSynonyms, ddls.addSynonyms = generateAlterSynonym(current, desired)
+	ddls.alterColumns = append(ddls.alterColumns, generateAlterOnDelete(current, desired)...)
+
 	// Add new constraints or re-add modified ones
-	for constraintName, desiredConstraint := range desired.Constraints {
+	for _, constraintName := range sortedKeys(desired.Constraints) {
+		desiredConstraint := desired.Constraints[constraintName]
 		currentConstraint, exists := current.Constraints[constraintName]
-		needsRecreate := false
-
-		if exists {
-			// Check if constraint needs to be recreated
-			if desiredConstraint.Type == "CHECK" && currentConstraint.Expression != desiredConstraint.Expression {
-				needsRecreate = true
-			} else if desiredConstraint.Type == "FOREIGN KEY" &&
-				(strings.Join(currentConstraint.Columns, ",") != strings.Join(desiredConstraint.Columns, ",") ||
-					currentConstraint.ReferenceTable != desiredConstraint.ReferenceTable ||
-					strings.Join(currentConstraint.ReferenceColumns, ",") != strings.Join(desiredConstraint.ReferenceColumns, ",") ||
-					currentConstraint.OnDelete != desiredConstraint.OnDelete) {
-				needsRecreate = true
-			}
-		}
+		needsRecreate := exists && !constraintsEqual(currentConstraint, desiredConstraint)
 
 		if !exists || needsRecreate {
 			if desiredConstraint.Type == "CHECK" {
-				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK %s",
-					desired.Name, constraintName, desiredConstraint.Expression))
+				ddls.addConstraints = append(ddls.addConstraints, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK %s",
+					quoteName(desired.Name), quoteName(constraintName), desiredConstraint.Expression))
 			} else if desiredConstraint.Type == "FOREIGN KEY" {
 				ddl := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
-					desired.Name, constraintName,
-					strings.Join(desiredConstraint.Columns, ", "),
-					desiredConstraint.ReferenceTable,
-					strings.Join(desiredConstraint.ReferenceColumns, ", "))
+					quoteName(desired.Name), quoteName(constraintName),
+					quoteNames(desiredConstraint.Columns),
+					quoteName(desiredConstraint.ReferenceTable),
+					quoteNames(desiredConstraint.ReferenceColumns))
 				if desiredConstraint.OnDelete != "" {
 					ddl += " " + desiredConstraint.OnDelete
 				}
-				ddls = append(ddls, ddl)
+				if desiredConstraint.Enforcement != "" {
+					ddl += " " + desiredConstraint.Enforcement
+				}
+				ddls.addConstraints = append(ddls.addConstraints, ddl)
 			}
 		}
 	}
@@ -686,12 +1336,27 @@ func generateAlterTable(current, desired *Table) []string {
 	return ddls
 }
 
-// optionKeyValueRe matches "key = value" pairs in OPTIONS clause,
-// handling quoted string values that may contain commas or parentheses.
-var optionKeyValueRe = regexp.MustCompile(`(\w+)\s*=\s*(?:"[^"]*"|[^,)]+)`)
+// enforced reports whether a foreign key is enforced, which it is unless
+// declared NOT ENFORCED
+func enforced(constraint *Constraint) bool {
+	return constraint.Enforcement != "NOT ENFORCED"
+}
 
-// nullifyOptions takes an OPTIONS SQL string like "OPTIONS (key1 = value1, key2 = value2)"
-// and returns "OPTIONS (key1 = null, key2 = null)" for removing options.
-func nullifyOptions(optionsSQL string) string {
-	return optionKeyValueRe.ReplaceAllString(optionsSQL, "${1} = null")
+// constraintsEqual reports whether two constraints with the same name are
+// equivalent, i.e. whether the existing one can be kept as is.
+func constraintsEqual(current, desired *Constraint) bool {
+	if current.Type != desired.Type {
+		return false
+	}
+	switch desired.Type {
+	case "CHECK":
+		return checksEqual(current.Expression, desired.Expression)
+	case "FOREIGN KEY":
+		return slices.Equal(current.Columns, desired.Columns) &&
+			current.ReferenceTable == desired.ReferenceTable &&
+			slices.Equal(current.ReferenceColumns, desired.ReferenceColumns) &&
+			onDeleteAction(current.OnDelete) == onDeleteAction(desired.OnDelete) &&
+			enforced(current) == enforced(desired)
+	}
+	return true
 }
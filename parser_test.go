@@ -1,12 +1,114 @@
 package spannerdef
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+func TestNormalizeDDLs(t *testing.T) {
+	normalized, err := NormalizeDDLs(`create index IdxUsersName on Users (Name);
+		create table Users (Id int64 not null, Name string(100)) primary key (Id)`)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Name STRING(100)
+) PRIMARY KEY (Id);
+
+CREATE INDEX IdxUsersName ON Users (Name);
+`, normalized)
+
+	// Normalizing is idempotent
+	again, err := NormalizeDDLs(normalized)
+	require.NoError(t, err)
+	assert.Equal(t, normalized, again)
+
+	empty, err := NormalizeDDLs("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestParseDDLs_Unsupported(t *testing.T) {
+	ddls := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE SEARCH INDEX UsersNameSearch ON Users(NameTokens);
+ALTER TABLE Missing ADD COLUMN Name STRING(100);
+ALTER TABLE Users ADD CONSTRAINT ChkId CHECK (Id > 0);
+ALTER TABLE Missing ADD CONSTRAINT ChkId CHECK (Id > 0)`
+
+	schema, err := ParseDDLs(ddls)
+	require.NoError(t, err)
+	assert.Equal(t, []Statement{
+		{SQL: "CREATE SEARCH INDEX UsersNameSearch ON Users(NameTokens)", Pos: strings.Index(ddls, "CREATE SEARCH INDEX")},
+		{SQL: "ALTER TABLE Missing ADD COLUMN Name STRING(100)", Pos: strings.Index(ddls, "ALTER TABLE Missing ADD COLUMN")},
+		{SQL: "ALTER TABLE Missing ADD CONSTRAINT ChkId CHECK (Id > 0)", Pos: strings.Index(ddls, "ALTER TABLE Missing ADD CONSTRAINT")},
+	}, schema.Unsupported)
+	assert.Contains(t, schema.Tables["Users"].Constraints, "ChkId")
+}
+
+func TestParseDDLs_AlterTableAddColumn(t *testing.T) {
+	ddls := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+ALTER TABLE Users ADD COLUMN Name STRING(100) NOT NULL;
+ALTER TABLE Users ADD COLUMN Email STRING(255);
+ALTER TABLE Users ADD COLUMN IF NOT EXISTS Email STRING(100);
+ALTER TABLE Users ADD COLUMN Name STRING(10)`
+
+	schema, err := ParseDDLs(ddls)
+	require.NoError(t, err)
+	// A column added twice without IF NOT EXISTS is unsupported
+	require.Len(t, schema.Unsupported, 1)
+	assert.Equal(t, "ALTER TABLE Users ADD COLUMN Name STRING(10)", schema.Unsupported[0].SQL)
+	assert.Equal(t, "CREATE TABLE Users (\n  Id INT64 NOT NULL,\n  Name STRING(100) NOT NULL,\n  Email STRING(255)\n) PRIMARY KEY (Id)",
+		generateCreateTable(schema.Tables["Users"]))
+
+	// The folded statements are represented faithfully
+	lossy, err := lossyStatements(ddls, schema)
+	require.NoError(t, err)
+	assert.Empty(t, lossy)
+
+	generated, warnings, err := GenerateIdempotentDDLs(`CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+ALTER TABLE Users ADD COLUMN Name STRING(100)`, "CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id)", GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, generated)
+	assert.Empty(t, warnings)
+}
+
+func TestParseDDLs_AlterIndex(t *testing.T) {
+	ddls := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100), Email STRING(255)) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersName ON Users (Name) STORING (Email);
+ALTER INDEX IdxUsersName ADD STORED COLUMN Id;
+ALTER INDEX IdxUsersName DROP STORED COLUMN Email;
+ALTER INDEX Missing ADD STORED COLUMN Id`
+
+	schema, err := ParseDDLs(ddls)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Id"}, schema.Indexes["IdxUsersName"].Storing)
+	require.Len(t, schema.Unsupported, 1)
+	assert.Equal(t, "ALTER INDEX Missing ADD STORED COLUMN Id", schema.Unsupported[0].SQL)
+
+	// The folded statements are represented faithfully
+	lossy, err := lossyStatements(ddls, schema)
+	require.NoError(t, err)
+	assert.Empty(t, lossy)
+}
+
+func TestParseDDLs_NamedSchema(t *testing.T) {
+	schema, err := ParseDDLs(`CREATE TABLE accounting.Invoices (Id INT64 NOT NULL, UserId INT64) PRIMARY KEY (Id);
+CREATE TABLE accounting.InvoiceLines (Id INT64 NOT NULL, LineId INT64 NOT NULL) PRIMARY KEY (Id, LineId),
+  INTERLEAVE IN PARENT accounting.Invoices ON DELETE CASCADE;
+CREATE INDEX accounting.IdxInvoicesUser ON accounting.Invoices (UserId);
+CREATE TABLE Invoices (Id INT64 NOT NULL) PRIMARY KEY (Id)`)
+	require.NoError(t, err)
+
+	// Objects of named schemas don't clash with those of the default one
+	assert.ElementsMatch(t, []string{"accounting.Invoices", "accounting.InvoiceLines", "Invoices"}, sortedKeys(schema.Tables))
+	assert.Equal(t, "accounting.Invoices", schema.Tables["accounting.InvoiceLines"].ParentTable)
+	assert.Equal(t, "accounting.Invoices", schema.Indexes["accounting.IdxInvoicesUser"].TableName)
+	assert.Contains(t, schemaDDLs(schema), "CREATE INDEX accounting.IdxInvoicesUser ON accounting.Invoices (UserId)")
+}
+
 func TestParseDDLs_EmptyInput(t *testing.T) {
 	schema, err := ParseDDLs("")
 	require.NoError(t, err)
@@ -594,7 +696,7 @@ func TestParseDDLs_ColumnOptions(t *testing.T) {
 		createdAtCol := table.Columns["CreatedAt"]
 		assert.Equal(t, "TIMESTAMP", createdAtCol.Type)
 		assert.True(t, createdAtCol.NotNull)
-		assert.Equal(t, "OPTIONS (allow_commit_timestamp = true)", createdAtCol.Options)
+		assert.Equal(t, map[string]string{"allow_commit_timestamp": "true"}, createdAtCol.Options)
 
 		// Column without OPTIONS
 		nameCol := table.Columns["Name"]
@@ -613,7 +715,7 @@ func TestParseDDLs_ColumnOptions(t *testing.T) {
 		require.NoError(t, err)
 
 		col := schema.Tables["Singers"].Columns["Awards"]
-		assert.Equal(t, `OPTIONS (locality_group = "spill_to_hdd")`, col.Options)
+		assert.Equal(t, map[string]string{"locality_group": `"spill_to_hdd"`}, col.Options)
 	})
 
 }
@@ -640,7 +742,7 @@ func TestGenerateDDLs_CreateTableWithOptions(t *testing.T) {
 							Name:    "CreatedAt",
 							Type:    "TIMESTAMP",
 							NotNull: true,
-							Options: "OPTIONS (allow_commit_timestamp = true)",
+							Options: map[string]string{"allow_commit_timestamp": "true"},
 							Order:   1,
 						},
 					},
@@ -681,7 +783,7 @@ func TestGenerateDDLs_CreateTableWithOptions(t *testing.T) {
 						"Awards": {
 							Name:    "Awards",
 							Type:    "ARRAY<STRING(MAX)>",
-							Options: `OPTIONS (locality_group = "spill_to_hdd")`,
+							Options: map[string]string{"locality_group": `"spill_to_hdd"`},
 							Order:   1,
 						},
 					},
@@ -725,7 +827,7 @@ func TestGenerateDDLs_AddColumnWithOptions(t *testing.T) {
 					Name: "Events",
 					Columns: map[string]*Column{
 						"Id":        {Name: "Id", Type: "INT64", NotNull: true},
-						"CreatedAt": {Name: "CreatedAt", Type: "TIMESTAMP", Options: "OPTIONS (allow_commit_timestamp = true)"},
+						"CreatedAt": {Name: "CreatedAt", Type: "TIMESTAMP", Options: map[string]string{"allow_commit_timestamp": "true"}},
 					},
 					PrimaryKey: []string{"Id"},
 				},
@@ -758,7 +860,7 @@ func TestGenerateDDLs_AddColumnWithOptions(t *testing.T) {
 					Name: "Singers",
 					Columns: map[string]*Column{
 						"SingerId": {Name: "SingerId", Type: "INT64", NotNull: true},
-						"Awards":   {Name: "Awards", Type: "ARRAY<STRING(MAX)>", Options: `OPTIONS (locality_group = "spill_to_hdd")`},
+						"Awards":   {Name: "Awards", Type: "ARRAY<STRING(MAX)>", Options: map[string]string{"locality_group": `"spill_to_hdd"`}},
 					},
 					PrimaryKey: []string{"SingerId"},
 				},
@@ -794,7 +896,7 @@ func TestGenerateDDLs_AlterColumnSetOptions(t *testing.T) {
 					Name: "Events",
 					Columns: map[string]*Column{
 						"Id":        {Name: "Id", Type: "INT64", NotNull: true},
-						"CreatedAt": {Name: "CreatedAt", Type: "TIMESTAMP", NotNull: true, Options: "OPTIONS (allow_commit_timestamp = true)"},
+						"CreatedAt": {Name: "CreatedAt", Type: "TIMESTAMP", NotNull: true, Options: map[string]string{"allow_commit_timestamp": "true"}},
 					},
 					PrimaryKey: []string{"Id"},
 				},
@@ -828,7 +930,7 @@ func TestGenerateDDLs_AlterColumnSetOptions(t *testing.T) {
 					Name: "Singers",
 					Columns: map[string]*Column{
 						"SingerId": {Name: "SingerId", Type: "INT64", NotNull: true},
-						"Awards":   {Name: "Awards", Type: "ARRAY<STRING(MAX)>", Options: `OPTIONS (locality_group = "spill_to_hdd")`},
+						"Awards":   {Name: "Awards", Type: "ARRAY<STRING(MAX)>", Options: map[string]string{"locality_group": `"spill_to_hdd"`}},
 					},
 					PrimaryKey: []string{"SingerId"},
 				},
@@ -850,7 +952,7 @@ func TestGenerateDDLs_AlterColumnRemoveOptions(t *testing.T) {
 					Name: "Events",
 					Columns: map[string]*Column{
 						"Id":        {Name: "Id", Type: "INT64", NotNull: true},
-						"CreatedAt": {Name: "CreatedAt", Type: "TIMESTAMP", NotNull: true, Options: "OPTIONS (allow_commit_timestamp = true)"},
+						"CreatedAt": {Name: "CreatedAt", Type: "TIMESTAMP", NotNull: true, Options: map[string]string{"allow_commit_timestamp": "true"}},
 					},
 					PrimaryKey: []string{"Id"},
 				},
@@ -884,7 +986,7 @@ func TestGenerateDDLs_AlterColumnRemoveOptions(t *testing.T) {
 					Name: "Singers",
 					Columns: map[string]*Column{
 						"SingerId": {Name: "SingerId", Type: "INT64", NotNull: true},
-						"Awards":   {Name: "Awards", Type: "ARRAY<STRING(MAX)>", Options: `OPTIONS (locality_group = "spill_to_hdd")`},
+						"Awards":   {Name: "Awards", Type: "ARRAY<STRING(MAX)>", Options: map[string]string{"locality_group": `"spill_to_hdd"`}},
 					},
 					PrimaryKey: []string{"SingerId"},
 				},
@@ -912,6 +1014,50 @@ func TestGenerateDDLs_AlterColumnRemoveOptions(t *testing.T) {
 	})
 }
 
+func TestGenerateIdempotentDDLs_ColumnOptions(t *testing.T) {
+	t.Run("Equivalent", func(t *testing.T) {
+		// Options are compared by value, and allow_commit_timestamp = false
+		// is the same as no option
+		current := `CREATE TABLE Events (
+  Id INT64 NOT NULL,
+  CreatedAt TIMESTAMP OPTIONS (allow_commit_timestamp=TRUE),
+  UpdatedAt TIMESTAMP
+) PRIMARY KEY (Id)`
+		desired := `CREATE TABLE Events (
+  Id INT64 NOT NULL,
+  CreatedAt TIMESTAMP OPTIONS (allow_commit_timestamp = true),
+  UpdatedAt TIMESTAMP OPTIONS (allow_commit_timestamp = false)
+) PRIMARY KEY (Id)`
+
+		ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+		require.NoError(t, err)
+		assert.Empty(t, ddls)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("Toggled", func(t *testing.T) {
+		current := `CREATE TABLE Events (
+  Id INT64 NOT NULL,
+  CreatedAt TIMESTAMP OPTIONS (allow_commit_timestamp = true, locality_group = 'spill_to_hdd'),
+  UpdatedAt TIMESTAMP
+) PRIMARY KEY (Id)`
+		desired := `CREATE TABLE Events (
+  Id INT64 NOT NULL,
+  CreatedAt TIMESTAMP OPTIONS (allow_commit_timestamp = false, locality_group = 'spill_to_hdd'),
+  UpdatedAt TIMESTAMP OPTIONS (allow_commit_timestamp = true)
+) PRIMARY KEY (Id)`
+
+		// Only the option that changed is set
+		ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"ALTER TABLE Events ALTER COLUMN CreatedAt SET OPTIONS (allow_commit_timestamp = null)",
+			"ALTER TABLE Events ALTER COLUMN UpdatedAt SET OPTIONS (allow_commit_timestamp = true)",
+		}, ddls)
+		assert.Empty(t, warnings)
+	})
+}
+
 func TestParseDDLs_RowDeletionPolicy(t *testing.T) {
 	ddl := `
 		CREATE TABLE events (
@@ -1179,3 +1325,337 @@ func TestGenerateDDLs_NoDiffWhenForeignKeyEmittedAsAlterTable(t *testing.T) {
 	ddls := GenerateDDLs(current, desired)
 	assert.Empty(t, ddls, "expected no diff, got: %v", ddls)
 }
+
+func TestGenerateDDLs_StatementOrder(t *testing.T) {
+	current, err := ParseDDLs(`
+		CREATE TABLE a (id INT64 NOT NULL) PRIMARY KEY (id);
+		CREATE TABLE b (id INT64 NOT NULL, old_id INT64,
+			CONSTRAINT fk_b_old FOREIGN KEY (old_id) REFERENCES old (id)) PRIMARY KEY (id);
+		CREATE TABLE old (id INT64 NOT NULL) PRIMARY KEY (id);
+	`)
+	require.NoError(t, err)
+
+	desired, err := ParseDDLs(`
+		CREATE TABLE a (id INT64 NOT NULL, name STRING(100)) PRIMARY KEY (id);
+		CREATE TABLE b (id INT64 NOT NULL, added_id INT64, name STRING(100),
+			CONSTRAINT fk_b_added FOREIGN KEY (added_id) REFERENCES added (id)) PRIMARY KEY (id);
+		CREATE TABLE added (id INT64 NOT NULL) PRIMARY KEY (id);
+	`)
+	require.NoError(t, err)
+
+	ddls := GenerateDDLs(current, desired)
+	assert.Equal(t, []string{
+		"ALTER TABLE b DROP CONSTRAINT fk_b_old",
+		"DROP TABLE old",
+		"ALTER TABLE b DROP COLUMN old_id",
+		"CREATE TABLE added (\n  id INT64 NOT NULL\n) PRIMARY KEY (id)",
+		"ALTER TABLE a ADD COLUMN name STRING(100)",
+		"ALTER TABLE b ADD COLUMN added_id INT64",
+		"ALTER TABLE b ADD COLUMN name STRING(100)",
+		"ALTER TABLE b ADD CONSTRAINT fk_b_added FOREIGN KEY (added_id) REFERENCES added (id)",
+	}, ddls)
+}
+
+func TestGenerateDDLs_AlterIndexedColumnType(t *testing.T) {
+	current, err := ParseDDLs(`
+		CREATE TABLE users (id INT64 NOT NULL, email STRING(100), name STRING(100)) PRIMARY KEY (id);
+		CREATE INDEX idx_email ON users (email);
+		CREATE INDEX idx_name ON users (name) STORING (email);
+		CREATE INDEX idx_id_name ON users (id, name);
+	`)
+	require.NoError(t, err)
+
+	desired, err := ParseDDLs(`
+		CREATE TABLE users (id INT64 NOT NULL, email STRING(255), name STRING(100)) PRIMARY KEY (id);
+		CREATE INDEX idx_email ON users (email);
+		CREATE INDEX idx_name ON users (name) STORING (email);
+		CREATE INDEX idx_id_name ON users (id, name);
+	`)
+	require.NoError(t, err)
+
+	// Indexes containing the column are dropped around the ALTER COLUMN,
+	// others are left alone
+	ddls := GenerateDDLs(current, desired)
+	assert.Equal(t, []string{
+		"DROP INDEX idx_email",
+		"DROP INDEX idx_name",
+		"ALTER TABLE users ALTER COLUMN email STRING(255)",
+		"CREATE INDEX idx_email ON users (email)",
+		"CREATE INDEX idx_name ON users (name) STORING (email)",
+	}, ddls)
+}
+
+func TestGenerateDDLs_ChangedIndex(t *testing.T) {
+	current, err := ParseDDLs(`
+		CREATE TABLE users (id INT64 NOT NULL, email STRING(100), name STRING(100)) PRIMARY KEY (id);
+		CREATE INDEX idx_email ON users (email);
+		CREATE INDEX idx_name ON users (name) STORING (email);
+		CREATE INDEX idx_name_email ON users (name, email);
+		CREATE INDEX idx_stored ON users (id) STORING (email, name);
+	`)
+	require.NoError(t, err)
+
+	desired, err := ParseDDLs(`
+		CREATE TABLE users (id INT64 NOT NULL, email STRING(100), name STRING(100)) PRIMARY KEY (id);
+		CREATE UNIQUE INDEX idx_email ON users (email);
+		CREATE INDEX idx_name ON users (name);
+		CREATE INDEX idx_name_email ON users (email, name);
+		CREATE INDEX idx_stored ON users (id) STORING (name, email);
+	`)
+	require.NoError(t, err)
+
+	// The order of stored columns doesn't matter, that of keys does
+	ddls := GenerateDDLs(current, desired)
+	assert.Equal(t, []string{
+		"DROP INDEX idx_email",
+		"DROP INDEX idx_name",
+		"DROP INDEX idx_name_email",
+		"CREATE UNIQUE INDEX idx_email ON users (email)",
+		"CREATE INDEX idx_name ON users (name)",
+		"CREATE INDEX idx_name_email ON users (email, name)",
+	}, ddls)
+}
+
+func TestGenerateIdempotentDDLs_NullFilteredIndex(t *testing.T) {
+	current := `CREATE TABLE users (id INT64 NOT NULL, email STRING(100)) PRIMARY KEY (id);
+CREATE INDEX idx_email ON users (email)`
+	desired := `CREATE TABLE users (id INT64 NOT NULL, email STRING(100)) PRIMARY KEY (id);
+CREATE UNIQUE NULL_FILTERED INDEX idx_email ON users (email)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{
+		"DROP INDEX idx_email",
+		"CREATE UNIQUE NULL_FILTERED INDEX idx_email ON users (email)",
+	}, ddls)
+
+	ddls, warnings, err = GenerateIdempotentDDLs(desired, desired, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Empty(t, ddls)
+}
+
+func TestGenerateDDLs_AlterColumnNotNull(t *testing.T) {
+	current, err := ParseDDLs("CREATE TABLE users (id INT64 NOT NULL, email STRING(100), name STRING(100) NOT NULL) PRIMARY KEY (id)")
+	require.NoError(t, err)
+	desired, err := ParseDDLs("CREATE TABLE users (id INT64 NOT NULL, email STRING(100) NOT NULL, name STRING(100)) PRIMARY KEY (id)")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"ALTER TABLE users ALTER COLUMN email STRING(100) NOT NULL",
+		"ALTER TABLE users ALTER COLUMN name STRING(100)",
+	}, GenerateDDLs(current, desired))
+}
+
+func TestConstraintsEqual(t *testing.T) {
+	fk := &Constraint{
+		Name:             "fk",
+		Type:             "FOREIGN KEY",
+		Columns:          []string{"a", "b"},
+		ReferenceTable:   "parent",
+		ReferenceColumns: []string{"x", "y"},
+	}
+	same := *fk
+	assert.True(t, constraintsEqual(fk, &same))
+
+	// Column lists must not be compared as joined strings
+	split := *fk
+	split.Columns = []string{"a,b"}
+	split.ReferenceColumns = []string{"x,y"}
+	assert.False(t, constraintsEqual(fk, &split))
+
+	check := &Constraint{Name: "fk", Type: "CHECK", Expression: "(a > 0)"}
+	assert.False(t, constraintsEqual(fk, check))
+
+	// NO ACTION is the default
+	noAction := *fk
+	noAction.OnDelete = "ON DELETE NO ACTION"
+	assert.True(t, constraintsEqual(fk, &noAction))
+	cascade := *fk
+	cascade.OnDelete = "ON DELETE CASCADE"
+	assert.False(t, constraintsEqual(fk, &cascade))
+
+	// So is ENFORCED
+	enforcedFK := *fk
+	enforcedFK.Enforcement = "ENFORCED"
+	assert.True(t, constraintsEqual(fk, &enforcedFK))
+	notEnforced := *fk
+	notEnforced.Enforcement = "NOT ENFORCED"
+	assert.False(t, constraintsEqual(fk, &notEnforced))
+}
+
+func TestGenerateIdempotentDDLs_ForeignKeyOnDelete(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Orders (Id INT64 NOT NULL, UserId INT64, CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id)) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Orders (Id INT64 NOT NULL, UserId INT64, CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id) ON DELETE CASCADE) PRIMARY KEY (Id);
+CREATE TABLE Payments (Id INT64 NOT NULL, OrderId INT64, CONSTRAINT FK_PaymentsOrders FOREIGN KEY (OrderId) REFERENCES Orders (Id) ON DELETE CASCADE) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{
+		"ALTER TABLE Orders DROP CONSTRAINT FK_OrdersUsers",
+		"CREATE TABLE Payments (\n  Id INT64 NOT NULL,\n  OrderId INT64,\n  CONSTRAINT FK_PaymentsOrders FOREIGN KEY (OrderId) REFERENCES Orders (Id) ON DELETE CASCADE\n) PRIMARY KEY (Id)",
+		"ALTER TABLE Orders ADD CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id) ON DELETE CASCADE",
+	}, ddls)
+
+	// Removing the action drops and adds back the foreign key without it
+	ddls, _, err = GenerateIdempotentDDLs(current, desired, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Contains(t, ddls, "ALTER TABLE Orders ADD CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id)")
+
+	// NO ACTION is the default, as INFORMATION_SCHEMA reports it
+	ddls, _, err = GenerateIdempotentDDLs(strings.Replace(current, "REFERENCES Users (Id)", "REFERENCES Users (Id) ON DELETE NO ACTION", 1), current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
+
+// largeSchemaDDL returns a schema with the given number of tables, each with
+// a few columns, a CHECK constraint, a foreign key and a secondary index.
+// When variant is true, every tenth table gets an extra column so the diff
+// has some work to do.
+func largeSchemaDDL(tables int, variant bool) string {
+	var b strings.Builder
+	for i := 0; i < tables; i++ {
+		fmt.Fprintf(&b, "CREATE TABLE t%d (\n", i)
+		b.WriteString("  id INT64 NOT NULL,\n  parent_id INT64,\n  name STRING(100),\n  created_at TIMESTAMP OPTIONS (allow_commit_timestamp = true),\n")
+		if variant && i%10 == 0 {
+			b.WriteString("  extra STRING(MAX),\n")
+		}
+		fmt.Fprintf(&b, "  CONSTRAINT ck_t%d CHECK (id > 0)", i)
+		if i > 0 {
+			fmt.Fprintf(&b, ",\n  CONSTRAINT fk_t%d FOREIGN KEY (parent_id) REFERENCES t%d (id)", i, i-1)
+		}
+		b.WriteString("\n) PRIMARY KEY (id);\n")
+		fmt.Fprintf(&b, "CREATE INDEX idx_t%d_name ON t%d (name) STORING (created_at);\n", i, i)
+	}
+	return b.String()
+}
+
+func BenchmarkParseDDLs(b *testing.B) {
+	for _, n := range []int{100, 1000, 4000} {
+		ddl := largeSchemaDDL(n, false)
+		b.Run(fmt.Sprintf("tables=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseDDLs(ddl); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateDDLs(b *testing.B) {
+	for _, n := range []int{100, 1000, 4000} {
+		current, err := ParseDDLs(largeSchemaDDL(n, false))
+		if err != nil {
+			b.Fatal(err)
+		}
+		desired, err := ParseDDLs(largeSchemaDDL(n, true))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("tables=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				GenerateDDLs(current, desired)
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateDDLs_CreateAll(b *testing.B) {
+	empty, err := ParseDDLs("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	desired, err := ParseDDLs(largeSchemaDDL(4000, false))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		GenerateDDLs(empty, desired)
+	}
+}
+
+func TestGenerateIdempotentDDLs_ForeignKeyEnforcement(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Orders (Id INT64 NOT NULL, UserId INT64, CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id)) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Orders (Id INT64 NOT NULL, UserId INT64, CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id) ON DELETE CASCADE NOT ENFORCED) PRIMARY KEY (Id);
+CREATE TABLE Payments (Id INT64 NOT NULL, OrderId INT64, CONSTRAINT FK_PaymentsOrders FOREIGN KEY (OrderId) REFERENCES Orders (Id) NOT ENFORCED) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{
+		"ALTER TABLE Orders DROP CONSTRAINT FK_OrdersUsers",
+		"CREATE TABLE Payments (\n  Id INT64 NOT NULL,\n  OrderId INT64,\n  CONSTRAINT FK_PaymentsOrders FOREIGN KEY (OrderId) REFERENCES Orders (Id) NOT ENFORCED\n) PRIMARY KEY (Id)",
+		"ALTER TABLE Orders ADD CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id) ON DELETE CASCADE NOT ENFORCED",
+	}, ddls)
+
+	ddls, _, err = GenerateIdempotentDDLs(desired, desired, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
+
+func TestGenerateIdempotentDDLs_KeyDirections(t *testing.T) {
+	current := `CREATE TABLE Events (Id INT64 NOT NULL, CreatedAt TIMESTAMP NOT NULL) PRIMARY KEY (Id ASC, CreatedAt DESC);
+CREATE INDEX IdxEventsCreatedAt ON Events (CreatedAt)`
+	desired := `CREATE TABLE Events (Id INT64 NOT NULL, CreatedAt TIMESTAMP NOT NULL) PRIMARY KEY (Id, CreatedAt DESC);
+CREATE INDEX IdxEventsCreatedAt ON Events (CreatedAt DESC)`
+
+	// ASC is the default, DESC changes the index
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{
+		"DROP INDEX IdxEventsCreatedAt",
+		"CREATE INDEX IdxEventsCreatedAt ON Events (CreatedAt DESC)",
+	}, ddls)
+
+	ddls, warnings, err = GenerateIdempotentDDLs(desired, "", GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{
+		"CREATE TABLE Events (\n  Id INT64 NOT NULL,\n  CreatedAt TIMESTAMP NOT NULL\n) PRIMARY KEY (Id, CreatedAt DESC)",
+		"CREATE INDEX IdxEventsCreatedAt ON Events (CreatedAt DESC)",
+	}, ddls)
+
+	ddls, _, err = GenerateIdempotentDDLs(desired, ddls[0]+";\n"+ddls[1], GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
+
+func TestGenerateIdempotentDDLs_DropTableOrder(t *testing.T) {
+	// Interleaved tables are dropped before their parent
+	current := `CREATE TABLE A (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE B (Id INT64 NOT NULL, BId INT64 NOT NULL) PRIMARY KEY (Id, BId), INTERLEAVE IN PARENT A ON DELETE CASCADE`
+	ddls, _, err := GenerateIdempotentDDLs("", current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DROP TABLE B", "DROP TABLE A"}, ddls)
+
+	// Tables with foreign keys are dropped before the tables they reference
+	current = `CREATE TABLE A (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Z (Id INT64 NOT NULL, AId INT64, CONSTRAINT FK_ZA FOREIGN KEY (AId) REFERENCES A (Id)) PRIMARY KEY (Id)`
+	ddls, _, err = GenerateIdempotentDDLs("", current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DROP TABLE Z", "DROP TABLE A"}, ddls)
+}
+
+func TestGenerateIdempotentDDLs_SelfReferencingForeignKey(t *testing.T) {
+	desired := "CREATE TABLE A (Id INT64 NOT NULL, ParentId INT64, CONSTRAINT FK_AA FOREIGN KEY (ParentId) REFERENCES A (Id)) PRIMARY KEY (Id)"
+	ddls, _, err := GenerateIdempotentDDLs(desired, "", GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Len(t, ddls, 1)
+
+	ddls, _, err = GenerateIdempotentDDLs("", desired, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DROP TABLE A"}, ddls)
+}
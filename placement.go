@@ -0,0 +1,70 @@
+package spannerdef
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// Placement represents a placement of a geo-partitioned database: the
+// instance partition, and optionally the leader region, the rows whose
+// placement key names it are stored in
+type Placement struct {
+	Name string
+	// Options are the values of the options set on the placement by name,
+	// e.g. "'europe-partition'" for instance_partition
+	Options map[string]string
+	Pos     int // byte offset of the definition in the parsed DDLs
+}
+
+// processCreatePlacement processes CREATE PLACEMENT statement
+func processCreatePlacement(schema *Schema, stmt *ast.CreatePlacement) {
+	placement := &Placement{
+		Name:    stmt.Name.Name,
+		Options: make(map[string]string),
+		Pos:     int(stmt.Pos()),
+	}
+	if stmt.Options != nil {
+		for _, option := range stmt.Options.Records {
+			placement.Options[option.Name.Name] = option.Value.SQL()
+		}
+	}
+	if schema.Placements == nil {
+		schema.Placements = make(map[string]*Placement)
+	}
+	schema.Placements[placement.Name] = placement
+}
+
+// generateCreatePlacement generates CREATE PLACEMENT DDL
+func generateCreatePlacement(placement *Placement) string {
+	ddl := "CREATE PLACEMENT " + placement.Name
+	if len(placement.Options) > 0 {
+		options := make([]string, 0, len(placement.Options))
+		for _, name := range sortedKeys(placement.Options) {
+			options = append(options, name+" = "+placement.Options[name])
+		}
+		ddl += fmt.Sprintf(" OPTIONS (%s)", strings.Join(options, ", "))
+	}
+	return ddl
+}
+
+// placementsEqual reports whether two placements have the same options
+func placementsEqual(current, desired *Placement) bool {
+	return maps.Equal(current.Options, desired.Options)
+}
+
+// generateCreatePlacementDDLs generates DDLs to create new placements.
+// Placements that are no longer desired or whose options differ are left
+// alone, as DROP PLACEMENT can't be parsed, and are reported by
+// unsupportedChanges.
+func generateCreatePlacementDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(desired.Placements) {
+		if _, exists := current.Placements[name]; !exists {
+			ddls = append(ddls, generateCreatePlacement(desired.Placements[name]))
+		}
+	}
+	return ddls
+}
@@ -0,0 +1,52 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_Placement(t *testing.T) {
+	table := "CREATE TABLE Singers (Id INT64 NOT NULL, Location STRING(MAX) NOT NULL) PRIMARY KEY (Id)"
+	desired := "CREATE PLACEMENT europe OPTIONS (instance_partition = 'europe-partition', default_leader = 'europe-west1');\n" + table
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, "", GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE PLACEMENT europe OPTIONS (default_leader = \"europe-west1\", instance_partition = \"europe-partition\")",
+		"CREATE TABLE Singers (\n  Id INT64 NOT NULL,\n  Location STRING(MAX) NOT NULL\n) PRIMARY KEY (Id)",
+	}, ddls)
+	assert.Empty(t, warnings)
+
+	ddls, _, err = GenerateIdempotentDDLs(desired, desired, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
+
+func TestGenerateIdempotentDDLs_PlacementTargetTables(t *testing.T) {
+	// Introspected tables come without the placements
+	desired := "CREATE PLACEMENT europe OPTIONS (instance_partition = 'europe-partition');\n" +
+		"CREATE TABLE Singers (Id INT64 NOT NULL) PRIMARY KEY (Id)"
+	current := "CREATE TABLE Singers (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)"
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{TargetTables: []string{"Singers"}})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	assert.Empty(t, warnings)
+}
+
+func TestGenerateIdempotentDDLs_ChangedPlacement(t *testing.T) {
+	current := "CREATE PLACEMENT europe OPTIONS (instance_partition = 'europe-partition');\n" +
+		"CREATE PLACEMENT asia OPTIONS (instance_partition = 'asia-partition')"
+	desired := "CREATE PLACEMENT europe OPTIONS (instance_partition = 'eu-partition')"
+
+	// Placements can't be dropped, so they are left as they are
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	assert.Equal(t, []Warning{
+		{Kind: WarningUnsupportedChange, Detail: "placement asia unexpected", Pos: -1},
+		{Kind: WarningUnsupportedChange, Detail: "placement europe differs", Pos: -1},
+	}, warnings)
+}
@@ -0,0 +1,118 @@
+package spannerdef
+
+import (
+	"errors"
+	"fmt"
+)
+
+// primaryKey returns the primary key of table, with the direction of its
+// columns, as written in PRIMARY KEY
+func primaryKey(table *Table) string {
+	return formatKeys(table.PrimaryKey, table.DescendingKeys)
+}
+
+// checkPrimaryKeys returns an error listing the tables whose primary key
+// differs between current and desired, which Spanner can't change
+func checkPrimaryKeys(current, desired *Schema) error {
+	var errs []error
+	for _, name := range sortedKeys(current.Tables) {
+		table, desiredTable := current.Tables[name], desired.Tables[name]
+		if desiredTable != nil && primaryKey(table) != primaryKey(desiredTable) {
+			errs = append(errs, fmt.Errorf("primary key of %s differs: current (%s), desired (%s); Spanner can't change it, set recreate_primary_keys in --config to drop and create the table again",
+				name, primaryKey(table), primaryKey(desiredTable)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recreatedTables returns the current tables that are dropped and created
+// again to change their primary key: those whose primary key differs from
+// desired, and the tables interleaved in them, which can't outlive them
+func recreatedTables(current, desired *Schema) map[string]bool {
+	recreated := make(map[string]bool)
+	var recreate func(name string)
+	recreate = func(name string) {
+		recreated[name] = true
+		for _, child := range sortedKeys(current.Tables) {
+			if current.Tables[child].ParentTable == name && !recreated[child] {
+				recreate(child)
+			}
+		}
+	}
+	for name, table := range current.Tables {
+		if desiredTable, ok := desired.Tables[name]; ok && primaryKey(table) != primaryKey(desiredTable) {
+			recreate(name)
+		}
+	}
+	return recreated
+}
+
+// recreatePrimaryKeys returns the statements dropping the tables of
+// recreatedTables, after the indexes on them and the foreign keys of other
+// tables referencing them, and a copy of current without them, from which
+// GenerateDDLs creates them again as desired
+func recreatePrimaryKeys(current, desired *Schema) ([]string, *Schema) {
+	dropped := recreatedTables(current, desired)
+	if len(dropped) == 0 {
+		return nil, current
+	}
+
+	recreated := *current
+	recreated.Tables = make(map[string]*Table, len(current.Tables))
+	recreated.Indexes = make(map[string]*Index, len(current.Indexes))
+	recreated.VectorIndexes = make(map[string]*VectorIndex, len(current.VectorIndexes))
+
+	var ddls []string
+	for _, name := range sortedKeys(current.Indexes) {
+		if index := current.Indexes[name]; dropped[index.TableName] {
+			ddls = append(ddls, fmt.Sprintf("DROP INDEX %s", quoteName(name)))
+		} else {
+			recreated.Indexes[name] = index
+		}
+	}
+	for _, name := range sortedKeys(current.VectorIndexes) {
+		if index := current.VectorIndexes[name]; dropped[index.TableName] {
+			ddls = append(ddls, fmt.Sprintf("DROP VECTOR INDEX %s", quoteName(name)))
+		} else {
+			recreated.VectorIndexes[name] = index
+		}
+	}
+	for _, tableName := range sortedKeys(current.Tables) {
+		table := current.Tables[tableName]
+		if dropped[tableName] {
+			continue
+		}
+		recreated.Tables[tableName] = table
+		for _, name := range sortedKeys(table.Constraints) {
+			if constraint := table.Constraints[name]; constraint.Type == "FOREIGN KEY" && dropped[constraint.ReferenceTable] {
+				if recreated.Tables[tableName] == table {
+					copied := *table
+					copied.Constraints = make(map[string]*Constraint, len(table.Constraints))
+					for n, c := range table.Constraints {
+						copied.Constraints[n] = c
+					}
+					recreated.Tables[tableName] = &copied
+				}
+				delete(recreated.Tables[tableName].Constraints, name)
+				ddls = append(ddls, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", quoteName(tableName), quoteName(name)))
+			}
+		}
+	}
+
+	// Interleaved tables are dropped before their parent
+	var dropTable func(name string)
+	dropTable = func(name string) {
+		for _, child := range sortedKeys(current.Tables) {
+			if current.Tables[child].ParentTable == name {
+				dropTable(child)
+			}
+		}
+		ddls = append(ddls, fmt.Sprintf("DROP TABLE %s", quoteName(name)))
+	}
+	for _, name := range sortedKeys(current.Tables) {
+		if dropped[name] && !dropped[current.Tables[name].ParentTable] {
+			dropTable(name)
+		}
+	}
+	return ddls, &recreated
+}
@@ -0,0 +1,102 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_PrimaryKeyChange(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(255) NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Sessions (Id INT64 NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Users ON DELETE CASCADE;
+CREATE TABLE Orders (Id INT64 NOT NULL, UserId INT64, CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id)) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersEmail ON Users (Email)`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(255) NOT NULL) PRIMARY KEY (Email, Id);
+CREATE TABLE Orders (Id INT64 NOT NULL, UserId INT64) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersEmail ON Users (Email)`
+
+	_, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	assert.EqualError(t, err, "primary key of Users differs: current (Id), desired (Email, Id); Spanner can't change it, set recreate_primary_keys in --config to drop and create the table again")
+
+	// Sessions, interleaved in Users, is dropped with it, and isn't
+	// desired any more
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{RecreatePrimaryKeys: true})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{
+		"DROP INDEX IdxUsersEmail",
+		"ALTER TABLE Orders DROP CONSTRAINT FK_OrdersUsers",
+		"DROP TABLE Sessions",
+		"DROP TABLE Users",
+		"CREATE TABLE Users (\n  Id INT64 NOT NULL,\n  Email STRING(255) NOT NULL\n) PRIMARY KEY (Email, Id)",
+		"CREATE INDEX IdxUsersEmail ON Users (Email)",
+	}, ddls)
+}
+
+func TestGenerateIdempotentDDLs_PrimaryKeyChangeRecreatesChildren(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Sessions (Id INT64 NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Users`
+	desired := `CREATE TABLE Users (Id STRING(36) NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Sessions (Id STRING(36) NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Users`
+
+	// The primary key of Users is the same columns, but of another type
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{RecreatePrimaryKeys: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE Sessions ALTER COLUMN Id STRING(36) NOT NULL",
+		"ALTER TABLE Users ALTER COLUMN Id STRING(36) NOT NULL",
+	}, ddls)
+
+	desired = `CREATE TABLE Users (Id INT64 NOT NULL, Region STRING(10) NOT NULL) PRIMARY KEY (Region, Id);
+CREATE TABLE Sessions (Region STRING(10) NOT NULL, Id INT64 NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Region, Id, SessionId), INTERLEAVE IN PARENT Users`
+	ddls, _, err = GenerateIdempotentDDLs(desired, current, GeneratorConfig{RecreatePrimaryKeys: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DROP TABLE Sessions",
+		"DROP TABLE Users",
+		"CREATE TABLE Users (\n  Id INT64 NOT NULL,\n  Region STRING(10) NOT NULL\n) PRIMARY KEY (Region, Id)",
+		"CREATE TABLE Sessions (\n  Region STRING(10) NOT NULL,\n  Id INT64 NOT NULL,\n  SessionId STRING(36) NOT NULL\n) PRIMARY KEY (Region, Id, SessionId),\nINTERLEAVE IN PARENT Users",
+	}, ddls)
+
+	currentSchema, err := ParseDDLs(current)
+	require.NoError(t, err)
+	desiredSchema, err := ParseDDLs(desired)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"table Sessions is recreated to change its primary key",
+		"table Users is recreated to change its primary key",
+		"table Users is recreated to change its primary key",
+		"table Sessions is recreated to change its primary key",
+	}, explainDDLs(ddls, currentSchema, desiredSchema, nil))
+}
+
+func TestRunDDLs_RecreatedTableNeedsEnableDrop(t *testing.T) {
+	ddls := []string{
+		"DROP TABLE Users",
+		"CREATE TABLE Users (\n  Id INT64 NOT NULL,\n  Region STRING(10) NOT NULL\n) PRIMARY KEY (Region, Id)",
+	}
+
+	db := &fakeDatabase{}
+	err := RunDDLs(db, ddls, false, true)
+	assert.EqualError(t, err, "table Users must be dropped and recreated to change its primary key; use --enable-drop to allow it")
+	assert.Empty(t, db.batches)
+
+	require.NoError(t, RunDDLs(db, ddls, true, true))
+	assert.Equal(t, [][]string{ddls}, db.batches)
+}
+
+func TestGenerateIdempotentDDLs_PrimaryKeyDirectionChange(t *testing.T) {
+	current := "CREATE TABLE Events (Id INT64 NOT NULL, CreatedAt TIMESTAMP NOT NULL) PRIMARY KEY (Id, CreatedAt)"
+	desired := "CREATE TABLE Events (Id INT64 NOT NULL, CreatedAt TIMESTAMP NOT NULL) PRIMARY KEY (Id, CreatedAt DESC)"
+
+	_, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	assert.EqualError(t, err, "primary key of Events differs: current (Id, CreatedAt), desired (Id, CreatedAt DESC); Spanner can't change it, set recreate_primary_keys in --config to drop and create the table again")
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{RecreatePrimaryKeys: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DROP TABLE Events",
+		"CREATE TABLE Events (\n  Id INT64 NOT NULL,\n  CreatedAt TIMESTAMP NOT NULL\n) PRIMARY KEY (Id, CreatedAt DESC)",
+	}, ddls)
+}
@@ -0,0 +1,130 @@
+package spannerdef
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// ProtoBundle represents the proto bundle of a database: the protocol
+// buffer messages and enums its columns can be declared with. A database
+// has at most one.
+type ProtoBundle struct {
+	Types []string // fully qualified names, e.g. "examples.shipping.Order"
+	Pos   int      // byte offset of the definition in the parsed DDLs
+}
+
+// protoTypeName returns the fully qualified name of a proto type, the same
+// whether it is written examples.shipping.Order or `examples.shipping.Order`
+func protoTypeName(t *ast.NamedType) string {
+	parts := make([]string, len(t.Path))
+	for i, ident := range t.Path {
+		parts[i] = ident.Name
+	}
+	return strings.Join(parts, ".")
+}
+
+// normalizeProtoTypes rewrites the proto types of the columns of stmt as
+// single names, so it formats them as formatColumnType does
+func normalizeProtoTypes(stmt *ast.CreateTable) {
+	for _, col := range stmt.Columns {
+		t, ok := col.Type.(*ast.NamedType)
+		if array, isArray := col.Type.(*ast.ArraySchemaType); isArray {
+			t, ok = array.Item.(*ast.NamedType)
+		}
+		if ok {
+			t.Path = []*ast.Ident{{NamePos: t.Pos(), NameEnd: t.End(), Name: protoTypeName(t)}}
+		}
+	}
+}
+
+// formatProtoType returns name quoted as Spanner dumps proto types
+func formatProtoType(name string) string {
+	return "`" + name + "`"
+}
+
+// formatProtoTypes returns the parenthesized list of types of proto bundle
+// statements
+func formatProtoTypes(types []string) string {
+	quoted := make([]string, len(types))
+	for i, name := range types {
+		quoted[i] = formatProtoType(name)
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+// processCreateProtoBundle processes CREATE PROTO BUNDLE statement
+func processCreateProtoBundle(schema *Schema, stmt *ast.CreateProtoBundle) {
+	bundle := &ProtoBundle{Pos: int(stmt.Pos())}
+	for _, t := range stmt.Types.Types {
+		bundle.Types = append(bundle.Types, protoTypeName(t))
+	}
+	slices.Sort(bundle.Types)
+	schema.ProtoBundle = bundle
+}
+
+// processAlterProtoBundle folds the types inserted and deleted by an ALTER
+// PROTO BUNDLE into the bundle, and reports whether there was one. Updated
+// types only change their descriptors, which aren't part of the schema.
+func processAlterProtoBundle(schema *Schema, stmt *ast.AlterProtoBundle) bool {
+	bundle := schema.ProtoBundle
+	if bundle == nil {
+		return false
+	}
+
+	if stmt.Insert != nil {
+		for _, t := range stmt.Insert.Types.Types {
+			if name := protoTypeName(t); !slices.Contains(bundle.Types, name) {
+				bundle.Types = append(bundle.Types, name)
+			}
+		}
+		slices.Sort(bundle.Types)
+	}
+	if stmt.Delete != nil {
+		for _, t := range stmt.Delete.Types.Types {
+			name := protoTypeName(t)
+			bundle.Types = slices.DeleteFunc(bundle.Types, func(s string) bool { return s == name })
+		}
+	}
+	return true
+}
+
+// generateCreateProtoBundle generates CREATE PROTO BUNDLE DDL
+func generateCreateProtoBundle(bundle *ProtoBundle) string {
+	return "CREATE PROTO BUNDLE " + formatProtoTypes(bundle.Types)
+}
+
+// generateProtoBundleDDLs generates DDLs to bring the proto bundle in line:
+// creates, which insert types before columns are declared with them, and
+// drops, which delete types once no column is
+func generateProtoBundleDDLs(current, desired *Schema) (creates, drops []string) {
+	switch {
+	case current.ProtoBundle == nil && desired.ProtoBundle == nil:
+		return nil, nil
+	case current.ProtoBundle == nil:
+		return []string{generateCreateProtoBundle(desired.ProtoBundle)}, nil
+	case desired.ProtoBundle == nil:
+		return nil, []string{"DROP PROTO BUNDLE"}
+	}
+
+	var inserted, deleted []string
+	for _, name := range desired.ProtoBundle.Types {
+		if !slices.Contains(current.ProtoBundle.Types, name) {
+			inserted = append(inserted, name)
+		}
+	}
+	for _, name := range current.ProtoBundle.Types {
+		if !slices.Contains(desired.ProtoBundle.Types, name) {
+			deleted = append(deleted, name)
+		}
+	}
+	if len(inserted) > 0 {
+		creates = append(creates, fmt.Sprintf("ALTER PROTO BUNDLE INSERT %s", formatProtoTypes(inserted)))
+	}
+	if len(deleted) > 0 {
+		drops = append(drops, fmt.Sprintf("ALTER PROTO BUNDLE DELETE %s", formatProtoTypes(deleted)))
+	}
+	return creates, drops
+}
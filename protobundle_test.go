@@ -0,0 +1,75 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDDLs_ProtoBundle(t *testing.T) {
+	schema, err := ParseDDLs("CREATE PROTO BUNDLE (examples.shipping.Order, `examples.shipping.Kind`);\n" +
+		"ALTER PROTO BUNDLE INSERT (examples.shipping.Parcel) UPDATE (examples.shipping.Order) DELETE (examples.shipping.Kind);\n" +
+		"CREATE TABLE Orders (\n" +
+		"  Id INT64 NOT NULL,\n" +
+		"  Detail examples.shipping.Order,\n" +
+		"  Parcels ARRAY<`examples.shipping.Parcel`>\n" +
+		") PRIMARY KEY (Id)")
+	require.NoError(t, err)
+	assert.Empty(t, schema.Unsupported)
+
+	assert.Equal(t, []string{"examples.shipping.Order", "examples.shipping.Parcel"}, schema.ProtoBundle.Types)
+	// Proto types are quoted as Spanner dumps them, however they are written
+	assert.Equal(t, "`examples.shipping.Order`", schema.Tables["Orders"].Columns["Detail"].Type)
+	assert.Equal(t, "ARRAY<`examples.shipping.Parcel`>", schema.Tables["Orders"].Columns["Parcels"].Type)
+}
+
+func TestGenerateIdempotentDDLs_ProtoBundle(t *testing.T) {
+	current := "CREATE PROTO BUNDLE (`examples.shipping.Kind`, `examples.shipping.Order`);\n" +
+		"CREATE TABLE Orders (Id INT64 NOT NULL, Kind `examples.shipping.Kind`) PRIMARY KEY (Id)"
+	desired := "CREATE PROTO BUNDLE (examples.shipping.Order, examples.shipping.Parcel);\n" +
+		"CREATE TABLE Orders (Id INT64 NOT NULL, Parcel examples.shipping.Parcel) PRIMARY KEY (Id)"
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE Orders DROP COLUMN Kind",
+		// Types are inserted before columns are declared with them, and
+		// deleted once no column is
+		"ALTER PROTO BUNDLE INSERT (`examples.shipping.Parcel`)",
+		"ALTER TABLE Orders ADD COLUMN Parcel `examples.shipping.Parcel`",
+		"ALTER PROTO BUNDLE DELETE (`examples.shipping.Kind`)",
+	}, ddls)
+	assert.Empty(t, warnings)
+
+	assert.True(t, isDestructive(ddls[3], nil))
+	assert.False(t, isDestructive(ddls[1], nil))
+}
+
+func TestGenerateIdempotentDDLs_CreateAndDropProtoBundle(t *testing.T) {
+	bundle := "CREATE PROTO BUNDLE (examples.shipping.Order);\n"
+	table := "CREATE TABLE Orders (Id INT64 NOT NULL, Detail examples.shipping.Order) PRIMARY KEY (Id)"
+
+	ddls, _, err := GenerateIdempotentDDLs(bundle+table, "", GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE PROTO BUNDLE (`examples.shipping.Order`)",
+		"CREATE TABLE Orders (\n  Id INT64 NOT NULL,\n  Detail `examples.shipping.Order`\n) PRIMARY KEY (Id)",
+	}, ddls)
+
+	ddls, _, err = GenerateIdempotentDDLs("", bundle+table, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DROP TABLE Orders", "DROP PROTO BUNDLE"}, ddls)
+	assert.True(t, isDestructive(ddls[1], nil))
+}
+
+func TestGenerateIdempotentDDLs_ProtoBundleTargetTables(t *testing.T) {
+	// Introspected tables come without the proto bundle
+	desired := "CREATE PROTO BUNDLE (examples.shipping.Order);\n" +
+		"CREATE TABLE Orders (Id INT64 NOT NULL, Detail examples.shipping.Order) PRIMARY KEY (Id)"
+	current := "CREATE TABLE Orders (\n  Id INT64 NOT NULL,\n  Detail `examples.shipping.Order`\n) PRIMARY KEY (Id)"
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{TargetTables: []string{"Orders"}})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
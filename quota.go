@@ -0,0 +1,104 @@
+package spannerdef
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Spanner's object-count limits, see
+// https://cloud.google.com/spanner/quotas#database-limits
+const (
+	maxTables          = 5000
+	maxIndexesPerTable = 128
+	maxColumnsPerTable = 1024
+)
+
+// quotaThreshold is the fraction of a limit from which growing towards it
+// is warned about
+const quotaThreshold = 0.9
+
+// WarningQuota is a plan that brings the database close to one of Spanner's
+// object-count limits, which can't be raised
+const WarningQuota WarningKind = "close to a Spanner limit"
+
+// ObjectCounts is the number of schema objects of a database
+type ObjectCounts struct {
+	Columns map[string]int    // number of columns by table
+	Indexes map[string]string // table by index
+}
+
+// ObjectCounter is implemented by databases that can count their schema
+// objects, including those of tables filtered out of the generator config
+type ObjectCounter interface {
+	CountObjects() (*ObjectCounts, error)
+}
+
+// quotaWarnings returns a warning for each limit that ddls bring the counts
+// of the database close to, or over. New tables get their columns from
+// desired.
+func quotaWarnings(counts *ObjectCounts, desired *Schema, ddls []string) []Warning {
+	columns := make(map[string]int, len(counts.Columns))
+	for table, n := range counts.Columns {
+		columns[table] = n
+	}
+	indexes := make(map[string]string, len(counts.Indexes))
+	for index, table := range counts.Indexes {
+		indexes[index] = table
+	}
+
+	for _, ddl := range ddls {
+		op := classifyDDL(ddl)
+		switch op.kind {
+		case opCreateTable:
+			columns[op.table] = 0
+			if table, ok := desired.Tables[op.table]; ok {
+				columns[op.table] = len(table.Columns)
+			}
+		case opDropTable:
+			delete(columns, op.table)
+		case opAddColumn:
+			columns[op.table]++
+		case opDropColumn:
+			columns[op.table]--
+		case opCreateIndex:
+			indexes[op.name] = op.table
+		case opDropIndex:
+			delete(indexes, op.name)
+		}
+	}
+
+	var warnings []Warning
+	warn := func(object string, before, after, limit int, objects string) {
+		if after > before && float64(after) >= quotaThreshold*float64(limit) {
+			warnings = append(warnings, Warning{
+				Kind:   WarningQuota,
+				Detail: fmt.Sprintf("%s would have %d of at most %d %s", object, after, limit, objects),
+				Pos:    -1,
+			})
+		}
+	}
+
+	warn("database", len(counts.Columns), len(columns), maxTables, "tables")
+
+	tables := make([]string, 0, len(columns))
+	for table := range columns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	indexesBefore := countIndexes(counts.Indexes)
+	indexesAfter := countIndexes(indexes)
+	for _, table := range tables {
+		warn("table "+table, counts.Columns[table], columns[table], maxColumnsPerTable, "columns")
+		warn("table "+table, indexesBefore[table], indexesAfter[table], maxIndexesPerTable, "indexes")
+	}
+	return warnings
+}
+
+// countIndexes returns the number of indexes by table
+func countIndexes(indexes map[string]string) map[string]int {
+	counts := make(map[string]int)
+	for _, table := range indexes {
+		counts[table]++
+	}
+	return counts
+}
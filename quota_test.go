@@ -0,0 +1,38 @@
+package spannerdef
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaWarnings(t *testing.T) {
+	counts := &ObjectCounts{
+		Columns: map[string]int{"Users": 1020, "Posts": 3},
+		Indexes: map[string]string{},
+	}
+	for i := 0; i < 120; i++ {
+		counts.Indexes[fmt.Sprintf("IdxPosts%d", i)] = "Posts"
+	}
+	desired, err := ParseDDLs("CREATE TABLE Events (Id INT64 NOT NULL, OccurredAt TIMESTAMP) PRIMARY KEY (Id)")
+	require.NoError(t, err)
+
+	ddls := []string{
+		"ALTER TABLE Users ADD COLUMN Extra STRING(MAX)",
+		"CREATE INDEX IdxPostsNew ON Posts (Title)",
+		"CREATE TABLE Events (\n  Id INT64 NOT NULL,\n  OccurredAt TIMESTAMP\n) PRIMARY KEY (Id)",
+	}
+	assert.Equal(t, []Warning{
+		{Kind: WarningQuota, Detail: "table Posts would have 121 of at most 128 indexes", Pos: -1},
+		{Kind: WarningQuota, Detail: "table Users would have 1021 of at most 1024 columns", Pos: -1},
+	}, quotaWarnings(counts, desired, ddls))
+
+	// Shrinking, or staying the same, isn't warned about even when close
+	ddls = []string{
+		"DROP INDEX IdxPosts0",
+		"ALTER TABLE Users DROP COLUMN Extra",
+	}
+	assert.Empty(t, quotaWarnings(counts, desired, ddls))
+}
@@ -0,0 +1,162 @@
+package spannerdef
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// renamedFromRe matches a `-- spannerdef:renamed_from <name>` line, which
+// declares that the table created by the statement following it, or the
+// column defined on the next line of a CREATE TABLE, was named name in
+// current, so that it isn't simply dropped and created again.
+// `-- spannerdef: renamed_from=<name>` is accepted as well.
+var renamedFromRe = regexp.MustCompile(`(?m)^[ \t]*--\s*spannerdef:\s*renamed_from(?:\s+|\s*=\s*)(\S+)\s*$`)
+
+// annotateRenames sets the previous names of the tables created by parsed,
+// which was parsed from ddls, from the annotations between each statement
+// and the previous one, and those of their columns from the annotations
+// between each column and the previous one
+func annotateRenames(schema *Schema, ddls string, parsed []ast.DDL) {
+	prevEnd := 0
+	for _, stmt := range parsed {
+		gap := ddls[prevEnd:stmt.Pos()]
+		prevEnd = statementEnd(ddls, stmt)
+
+		s, ok := stmt.(*ast.CreateTable)
+		if !ok {
+			continue
+		}
+		table, ok := schema.Tables[getPathName(s.Name)]
+		if !ok {
+			continue
+		}
+		if matches := renamedFromRe.FindAllStringSubmatch(gap, -1); len(matches) > 0 {
+			table.RenamedFrom = matches[len(matches)-1][1]
+		}
+		prevColumnEnd := s.Name.End()
+		for _, col := range s.Columns {
+			matches := renamedFromRe.FindAllStringSubmatch(ddls[prevColumnEnd:col.Pos()], -1)
+			if column, ok := table.Columns[col.Name.Name]; ok && len(matches) > 0 {
+				column.RenamedFrom = matches[len(matches)-1][1]
+			}
+			prevColumnEnd = col.End()
+		}
+	}
+}
+
+// renamedTo returns the name of the column of table that replaces column,
+// or "" if none does
+func renamedTo(table *Table, column string) string {
+	for _, col := range sortedColumns(table) {
+		if col.RenamedFrom == column {
+			return col.Name
+		}
+	}
+	return ""
+}
+
+// generateRenameTableDDLs generates the ALTER TABLE ... RENAME TO of the
+// desired tables renamed from a table of current, and returns them with a
+// copy of current in which the tables have their new names. A rename
+// already made, or from a table that is still desired, is left out. When
+// the desired table keeps its previous name as a synonym, it is added in
+// the same statement, so that clients using it keep working.
+func generateRenameTableDDLs(current, desired *Schema) ([]string, *Schema) {
+	var ddls []string
+	renamed := current
+	for _, name := range sortedKeys(desired.Tables) {
+		table := desired.Tables[name]
+		from := table.RenamedFrom
+		if from == "" || current.Tables[from] == nil || current.Tables[name] != nil || desired.Tables[from] != nil {
+			continue
+		}
+		if renamed == current {
+			renamed = cloneTables(current)
+		}
+
+		ddl := fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteName(from), quoteName(name))
+		if table.Synonym == from && renamed.Tables[from].Synonym == "" {
+			ddl += fmt.Sprintf(", ADD SYNONYM %s", from)
+			renamed.Tables[from].Synonym = from
+		}
+		renameTable(renamed, from, name)
+		ddls = append(ddls, ddl)
+	}
+	return ddls, renamed
+}
+
+// renameTable renames table from of schema to to, along with the references
+// of its indexes, interleaved tables and foreign keys
+func renameTable(schema *Schema, from, to string) {
+	table := schema.Tables[from]
+	delete(schema.Tables, from)
+	table.Name = to
+	schema.Tables[to] = table
+
+	for _, t := range schema.Tables {
+		if t.ParentTable == from {
+			t.ParentTable = to
+		}
+		for _, constraint := range t.Constraints {
+			if constraint.ReferenceTable == from {
+				constraint.ReferenceTable = to
+			}
+		}
+	}
+	for _, index := range schema.Indexes {
+		if index.TableName == from {
+			index.TableName = to
+		}
+	}
+	for _, index := range schema.VectorIndexes {
+		if index.TableName == from {
+			index.TableName = to
+		}
+	}
+}
+
+// cloneTables returns a copy of schema whose tables, constraints and
+// indexes can be changed without changing those of schema. Columns are
+// shared.
+func cloneTables(schema *Schema) *Schema {
+	cloned := *schema
+	cloned.Tables = make(map[string]*Table, len(schema.Tables))
+	for name, table := range schema.Tables {
+		t := *table
+		t.Constraints = make(map[string]*Constraint, len(table.Constraints))
+		for n, c := range table.Constraints {
+			constraint := *c
+			t.Constraints[n] = &constraint
+		}
+		cloned.Tables[name] = &t
+	}
+	cloned.Indexes = make(map[string]*Index, len(schema.Indexes))
+	for name, index := range schema.Indexes {
+		i := *index
+		cloned.Indexes[name] = &i
+	}
+	cloned.VectorIndexes = make(map[string]*VectorIndex, len(schema.VectorIndexes))
+	for name, index := range schema.VectorIndexes {
+		i := *index
+		cloned.VectorIndexes[name] = &i
+	}
+	return &cloned
+}
+
+// generateAlterSynonym generates the DDLs changing the synonym of an
+// existing table: drops, to run before tables are created, as one may take
+// its name, and adds, to run after tables are dropped
+func generateAlterSynonym(current, desired *Table) (drops, adds []string) {
+	if current.Synonym == desired.Synonym {
+		return nil, nil
+	}
+	if current.Synonym != "" {
+		drops = append(drops, fmt.Sprintf("ALTER TABLE %s DROP SYNONYM %s", quoteName(desired.Name), quoteName(current.Synonym)))
+	}
+	if desired.Synonym != "" {
+		adds = append(adds, fmt.Sprintf("ALTER TABLE %s ADD SYNONYM %s", quoteName(desired.Name), quoteName(desired.Synonym)))
+	}
+	return drops, adds
+}
@@ -0,0 +1,106 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDDLs_RenamedFrom(t *testing.T) {
+	schema, err := ParseDDLs(`-- spannerdef:renamed_from Users
+CREATE TABLE Accounts (Id INT64 NOT NULL) PRIMARY KEY (Id);
+-- spannerdef: renamed_from=Posts
+CREATE TABLE Articles (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Comments (Id INT64 NOT NULL) PRIMARY KEY (Id)`)
+	require.NoError(t, err)
+	assert.Equal(t, "Users", schema.Tables["Accounts"].RenamedFrom)
+	assert.Equal(t, "Posts", schema.Tables["Articles"].RenamedFrom)
+	assert.Empty(t, schema.Tables["Comments"].RenamedFrom)
+}
+
+func TestGenerateIdempotentDDLs_RenameTable(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(255)) PRIMARY KEY (Id);
+CREATE TABLE Sessions (Id INT64 NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Users ON DELETE CASCADE;
+CREATE TABLE Orders (Id INT64 NOT NULL, UserId INT64, CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id)) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersEmail ON Users (Email)`
+	desired := `-- spannerdef:renamed_from Users
+CREATE TABLE Accounts (Id INT64 NOT NULL, Email STRING(255), Name STRING(100)) PRIMARY KEY (Id);
+CREATE TABLE Sessions (Id INT64 NOT NULL, SessionId STRING(36) NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Accounts ON DELETE CASCADE;
+CREATE TABLE Orders (Id INT64 NOT NULL, UserId INT64, CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Accounts (Id)) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersEmail ON Accounts (Email)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{
+		"ALTER TABLE Users RENAME TO Accounts",
+		"ALTER TABLE Accounts ADD COLUMN Name STRING(100)",
+	}, ddls)
+
+	// Once renamed, the annotation has no effect
+	renamed := `CREATE TABLE Accounts (Id INT64 NOT NULL, Email STRING(255), Name STRING(100)) PRIMARY KEY (Id)`
+	ddls, _, err = GenerateIdempotentDDLs(`-- spannerdef:renamed_from Users
+`+renamed, renamed, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
+
+func TestGenerateIdempotentDDLs_Synonyms(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Posts (Id INT64 NOT NULL, SYNONYM (Articles)) PRIMARY KEY (Id)`
+	desired := `-- spannerdef:renamed_from Users
+CREATE TABLE Accounts (Id INT64 NOT NULL, SYNONYM (Users)) PRIMARY KEY (Id);
+CREATE TABLE Posts (Id INT64 NOT NULL, SYNONYM (Entries)) PRIMARY KEY (Id);
+CREATE TABLE Comments (Id INT64 NOT NULL, SYNONYM (Replies)) PRIMARY KEY (Id)`
+
+	// The previous name is kept as a synonym in the same statement
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{
+		"ALTER TABLE Users RENAME TO Accounts, ADD SYNONYM Users",
+		"ALTER TABLE Posts DROP SYNONYM Articles",
+		"CREATE TABLE Comments (\n  Id INT64 NOT NULL,\n  SYNONYM (Replies)\n) PRIMARY KEY (Id)",
+		"ALTER TABLE Posts ADD SYNONYM Entries",
+	}, ddls)
+
+	currentSchema, err := ParseDDLs(current)
+	require.NoError(t, err)
+	desiredSchema, err := ParseDDLs(desired)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"table Accounts is renamed from Users",
+		"synonym of Posts differs: current Articles, desired Entries",
+		"table Comments exists in desired but not current",
+		"synonym of Posts differs: current Articles, desired Entries",
+	}, explainDDLs(ddls, currentSchema, desiredSchema, nil))
+}
+
+func TestGenerateIdempotentDDLs_RenameColumn(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, user_name STRING(100)) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  -- spannerdef:renamed_from user_name
+  username STRING(100),
+) PRIMARY KEY (Id)`
+
+	schema, err := ParseDDLs(desired)
+	require.NoError(t, err)
+	assert.Equal(t, "user_name", schema.Tables["Users"].Columns["username"].RenamedFrom)
+	assert.Empty(t, schema.Tables["Users"].Columns["Id"].RenamedFrom)
+
+	// Spanner can't rename columns, so the old one is kept for its values
+	// to be copied
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALTER TABLE Users ADD COLUMN username STRING(100)"}, ddls)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "unsupported change ignored: column Users.user_name is replaced by username, as Spanner can't rename columns; copy its values, then remove the renamed_from annotation to drop it", warnings[0].String())
+
+	// Without the annotation, it is dropped
+	ddls, _, err = GenerateIdempotentDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, username STRING(100)) PRIMARY KEY (Id)`,
+		`CREATE TABLE Users (Id INT64 NOT NULL, user_name STRING(100), username STRING(100)) PRIMARY KEY (Id)`, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALTER TABLE Users DROP COLUMN user_name"}, ddls)
+}
@@ -0,0 +1,139 @@
+package spannerdef
+
+import (
+	"strings"
+)
+
+// FormatOptions controls how Render formats a schema
+type FormatOptions struct {
+	// QualifiedNames backquotes every identifier, as with
+	// GeneratorConfig.QualifiedNames
+	QualifiedNames bool
+	// Annotations writes the owners and tombstones of the schema as
+	// comments, so that they are kept when the result is parsed again
+	Annotations bool
+	// Unsupported appends the statements that aren't part of the schema
+	// model as they were parsed
+	Unsupported bool
+}
+
+// Render returns the canonical DDL text of schema: the statements creating
+// it from scratch, in the order and format spannerdef generates them,
+// terminated by semicolons and separated by blank lines. Tools that build
+// or modify a Schema can use it to write the result back to a file.
+func Render(schema *Schema, options FormatOptions) string {
+	empty := &Schema{
+		Tables:  make(map[string]*Table),
+		Indexes: make(map[string]*Index),
+	}
+	ddls := GenerateDDLs(empty, schema)
+
+	var owners []string
+	if options.Annotations {
+		owners = make([]string, len(ddls))
+		for i, ddl := range ddls {
+			owners[i] = renderedOwner(schema, ddl)
+		}
+	}
+	if options.QualifiedNames {
+		ddls = quoteIdentifiers(ddls)
+	}
+
+	var b strings.Builder
+	if options.Annotations {
+		for _, object := range sortedKeys(schema.Dropped) {
+			b.WriteString("-- spannerdef:dropped " + object + "\n")
+		}
+		if len(schema.Dropped) > 0 && len(ddls) > 0 {
+			b.WriteString("\n")
+		}
+	}
+	for i, ddl := range ddls {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if options.Annotations && owners[i] != "" {
+			b.WriteString("-- owner: " + owners[i] + "\n")
+		}
+		b.WriteString(ddl + ";\n")
+	}
+	if options.Unsupported {
+		for _, stmt := range schema.Unsupported {
+			b.WriteString("\n" + stmt.SQL + ";\n")
+		}
+	}
+	return b.String()
+}
+
+// renderedOwner returns the owner annotation of the object that ddl
+// creates, or "" for indexes owned by the owner of their table, which
+// they inherit when parsed
+func renderedOwner(schema *Schema, ddl string) string {
+	op := classifyDDL(ddl)
+	switch op.kind {
+	case opCreateTable:
+		if table, ok := schema.Tables[op.table]; ok {
+			return table.Owner
+		}
+	case opCreateIndex:
+		index, ok := schema.Indexes[op.name]
+		if !ok {
+			return ""
+		}
+		if table, ok := schema.Tables[index.TableName]; ok && table.Owner == index.Owner {
+			return ""
+		}
+		return index.Owner
+	case opCreateVectorIndex:
+		index, ok := schema.VectorIndexes[op.name]
+		if !ok {
+			return ""
+		}
+		if table, ok := schema.Tables[index.TableName]; ok && table.Owner == index.Owner {
+			return ""
+		}
+		return index.Owner
+	case opCreateView:
+		if view, ok := schema.Views[op.name]; ok {
+			return view.Owner
+		}
+	case opCreateChangeStream:
+		if stream, ok := schema.ChangeStreams[op.name]; ok {
+			return stream.Owner
+		}
+	case opCreateSchema:
+		if namedSchema, ok := schema.NamedSchemas[op.name]; ok {
+			return namedSchema.Owner
+		}
+	case opCreateRole:
+		if role, ok := schema.Roles[op.name]; ok {
+			return role.Owner
+		}
+	case opCreateSequence:
+		if sequence, ok := schema.Sequences[op.name]; ok {
+			return sequence.Owner
+		}
+	}
+	return ""
+}
+
+// BootstrapDDLs returns the statements creating schema in an empty
+// database, in an order Spanner accepts: the database options and proto
+// bundles the tables may refer to, then the placements, the named schemas,
+// the roles, the sequences, the tables, parents and referenced tables
+// first, their indexes and vector indexes, the views and change streams,
+// the privileges granted to the roles, then the other statements the
+// schema model doesn't cover, such as search indexes, in the order they
+// were parsed.
+func BootstrapDDLs(schema *Schema) []string {
+	empty := &Schema{
+		Tables:  make(map[string]*Table),
+		Indexes: make(map[string]*Index),
+	}
+
+	ddls := GenerateDDLs(empty, schema)
+	for _, stmt := range schema.Unsupported {
+		ddls = append(ddls, stmt.SQL)
+	}
+	return ddls
+}
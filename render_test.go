@@ -0,0 +1,83 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	schema, err := ParseDDLs(`-- spannerdef:dropped Users_old
+-- owner: identity
+CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersName ON Users (Name);
+-- owner: search
+CREATE INDEX IdxUsersNameSearch ON Users (Name);
+CREATE SEARCH INDEX UsersNameSearch ON Users(NameTokens)`)
+	require.NoError(t, err)
+
+	assert.Equal(t, `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Name STRING(100)
+) PRIMARY KEY (Id);
+
+CREATE INDEX IdxUsersName ON Users (Name);
+
+CREATE INDEX IdxUsersNameSearch ON Users (Name);
+`, Render(schema, FormatOptions{}))
+
+	// Indexes inherit the owner of their table, so only others are written
+	rendered := Render(schema, FormatOptions{Annotations: true, Unsupported: true})
+	assert.Equal(t, `-- spannerdef:dropped Users_old
+
+-- owner: identity
+CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Name STRING(100)
+) PRIMARY KEY (Id);
+
+CREATE INDEX IdxUsersName ON Users (Name);
+
+-- owner: search
+CREATE INDEX IdxUsersNameSearch ON Users (Name);
+
+CREATE SEARCH INDEX UsersNameSearch ON Users(NameTokens);
+`, rendered)
+
+	reparsed, err := ParseDDLs(rendered)
+	require.NoError(t, err)
+	assert.Equal(t, schema.Dropped, reparsed.Dropped)
+	assert.Equal(t, "identity", reparsed.Indexes["IdxUsersName"].Owner)
+	assert.Equal(t, "search", reparsed.Indexes["IdxUsersNameSearch"].Owner)
+
+	assert.Contains(t, Render(schema, FormatOptions{QualifiedNames: true}),
+		"CREATE INDEX `IdxUsersName` ON `Users` (`Name`);")
+}
+
+func TestRender_Empty(t *testing.T) {
+	assert.Equal(t, "", Render(&Schema{}, FormatOptions{Annotations: true}))
+}
+
+func TestBootstrapDDLs(t *testing.T) {
+	schema, err := ParseDDLs(`CREATE VIEW OrderIds SQL SECURITY INVOKER AS SELECT Id FROM Orders;
+CREATE TABLE Orders (
+  Id INT64 NOT NULL DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE OrderSeq)),
+  UserId INT64,
+  CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id),
+) PRIMARY KEY (Id);
+CREATE INDEX IdxOrdersUserId ON Orders (UserId);
+CREATE TABLE Users (
+  Id INT64 NOT NULL,
+) PRIMARY KEY (Id);
+CREATE SEQUENCE OrderSeq OPTIONS (sequence_kind = 'bit_reversed_positive')`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`CREATE SEQUENCE OrderSeq OPTIONS (sequence_kind = "bit_reversed_positive")`,
+		"CREATE TABLE Users (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"CREATE TABLE Orders (\n  Id INT64 NOT NULL DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE OrderSeq)),\n  UserId INT64,\n  CONSTRAINT FK_OrdersUsers FOREIGN KEY (UserId) REFERENCES Users (Id)\n) PRIMARY KEY (Id)",
+		"CREATE INDEX IdxOrdersUserId ON Orders (UserId)",
+		"CREATE VIEW OrderIds SQL SECURITY INVOKER AS SELECT Id FROM Orders",
+	}, BootstrapDDLs(schema))
+}
@@ -0,0 +1,216 @@
+package spannerdef
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+// Report is the plan as rendered by report templates, see
+// Options.ReportTemplate, and printed as JSON, see Options.JSON
+type Report struct {
+	// Database is the path of the database, e.g.
+	// "projects/p/instances/i/databases/d", if known
+	Database   string            `json:"database,omitempty"`
+	DryRun     bool              `json:"dry_run"`
+	Statements []ReportStatement `json:"statements"`
+	Warnings   []Warning         `json:"warnings,omitempty"`
+	// Summary is the one-line summary of the applied statements, as
+	// printed by --stat
+	Summary string `json:"summary"`
+	// Error is why the apply failed, if it did
+	Error string `json:"error,omitempty"`
+}
+
+// ReportStatement is a statement of a Report
+type ReportStatement struct {
+	SQL string `json:"sql"`
+	// Kind is the change the statement makes: CREATE TABLE, DROP TABLE,
+	// CREATE INDEX, DROP INDEX, ADD COLUMN, DROP COLUMN, ALTER COLUMN, ADD
+	// CONSTRAINT, DROP CONSTRAINT, ALTER TABLE, CREATE VIEW, CREATE OR
+	// REPLACE VIEW, DROP VIEW, CREATE CHANGE STREAM, DROP CHANGE STREAM,
+	// ALTER CHANGE STREAM, CREATE SEQUENCE, ALTER SEQUENCE, DROP SEQUENCE,
+	// CREATE SCHEMA, CREATE ROLE, DROP ROLE, GRANT, REVOKE, CREATE VECTOR
+	// INDEX, DROP VECTOR INDEX, ALTER VECTOR INDEX, CREATE PROTO BUNDLE,
+	// ALTER PROTO BUNDLE or DROP PROTO BUNDLE
+	Kind string `json:"kind"`
+	// Table is empty for DROP INDEX and schema objects other than tables and
+	// indexes
+	Table string `json:"table,omitempty"`
+	// Name is the index, column, constraint or other schema object the
+	// statement is about, if any, or the role of GRANT and REVOKE
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason,omitempty"` // why the statement was generated, as by --annotate
+	// Source is the "path:line" of the desired definition the statement
+	// comes from, if any
+	Source      string `json:"source,omitempty"`
+	Destructive bool   `json:"destructive"`
+	Skipped     bool   `json:"skipped"` // not applied because it is destructive
+	Failed      bool   `json:"failed,omitempty"`
+	// Duration is how long the statement is expected to take: instant,
+	// minutes or hours, see estimateDuration
+	Duration string `json:"duration"`
+	// Progress is the percentage of the statement done when its DDL
+	// operation was last polled, and Throttled whether Spanner throttled
+	// the operation while the statement ran, once applied
+	Progress  int32 `json:"progress,omitempty"`
+	Throttled bool  `json:"throttled,omitempty"`
+}
+
+// ProgressRecorder is implemented by databases that record the progress of
+// the statements they apply, for reports
+type ProgressRecorder interface {
+	// StatementProgress returns the last progress of the statements
+	// applied so far, by statement
+	StatementProgress() map[string]StatementProgress
+}
+
+// StatementProgress is the progress of a statement as last reported by its
+// DDL operation
+type StatementProgress struct {
+	Percent   int32
+	Throttled bool
+}
+
+// reportKinds names the operation kinds in reports
+var reportKinds = map[operationKind]string{
+	opCreateTable:        "CREATE TABLE",
+	opDropTable:          "DROP TABLE",
+	opCreateIndex:        "CREATE INDEX",
+	opDropIndex:          "DROP INDEX",
+	opAddColumn:          "ADD COLUMN",
+	opDropColumn:         "DROP COLUMN",
+	opAlterColumnType:    "ALTER COLUMN",
+	opAlterColumn:        "ALTER COLUMN",
+	opAddConstraint:      "ADD CONSTRAINT",
+	opDropConstraint:     "DROP CONSTRAINT",
+	opAlterTable:         "ALTER TABLE",
+	opCreateView:         "CREATE VIEW",
+	opReplaceView:        "CREATE OR REPLACE VIEW",
+	opDropView:           "DROP VIEW",
+	opCreateChangeStream: "CREATE CHANGE STREAM",
+	opDropChangeStream:   "DROP CHANGE STREAM",
+	opAlterChangeStream:  "ALTER CHANGE STREAM",
+	opCreateSequence:     "CREATE SEQUENCE",
+	opAlterSequence:      "ALTER SEQUENCE",
+	opDropSequence:       "DROP SEQUENCE",
+	opCreateSchema:       "CREATE SCHEMA",
+	opDropSchema:         "DROP SCHEMA",
+	opCreateRole:         "CREATE ROLE",
+	opDropRole:           "DROP ROLE",
+	opGrant:              "GRANT",
+	opRevoke:             "REVOKE",
+	opCreateVectorIndex:  "CREATE VECTOR INDEX",
+	opDropVectorIndex:    "DROP VECTOR INDEX",
+	opAlterVectorIndex:   "ALTER VECTOR INDEX",
+	opCreateProtoBundle:  "CREATE PROTO BUNDLE",
+	opAlterProtoBundle:   "ALTER PROTO BUNDLE",
+	opDropProtoBundle:    "DROP PROTO BUNDLE",
+	opAlterDatabase:      "ALTER DATABASE",
+	opCreatePlacement:    "CREATE PLACEMENT",
+}
+
+// newReport returns the report of ddls, generated from current with the
+// given reasons, of which any may be empty. sizes are the table sizes
+// durations are estimated from, nil if unknown.
+func newReport(database string, dryRun bool, ddls, reasons []string, warnings []Warning, current *Schema, enableDrop bool, sizes map[string]int64) *Report {
+	report := &Report{
+		Database: database,
+		DryRun:   dryRun,
+		Warnings: warnings,
+		Summary:  summarizeDDLs(appliedDDLs(ddls, current, enableDrop), current),
+	}
+	skipped := skippedDDLs(ddls, current, enableDrop)
+	for i, ddl := range ddls {
+		op := classifyDDL(ddl)
+		statement := ReportStatement{
+			SQL:         ddl,
+			Kind:        reportKinds[op.kind],
+			Table:       op.table,
+			Name:        op.name,
+			Destructive: isDestructive(ddl, current),
+			Skipped:     skipped[i],
+			Duration:    estimateDuration(ddl, current, sizes),
+		}
+		if i < len(reasons) {
+			statement.Reason = reasons[i]
+		}
+		report.Statements = append(report.Statements, statement)
+	}
+	return report
+}
+
+// locate records the source of each statement, see locateDDLs
+func (r *Report) locate(current, desired *Schema, sources []Source) {
+	ddls := make([]string, len(r.Statements))
+	for i, statement := range r.Statements {
+		ddls[i] = statement.SQL
+	}
+	for i, location := range locateDDLs(ddls, current, desired, sources) {
+		r.Statements[i].Source = location
+	}
+}
+
+// record records the progress of the applied statements, if db recorded it
+func (r *Report) record(db Database) {
+	recorder, ok := db.(ProgressRecorder)
+	if !ok {
+		return
+	}
+	progress := recorder.StatementProgress()
+	for i := range r.Statements {
+		if p, ok := progress[r.Statements[i].SQL]; ok && !r.Statements[i].Skipped {
+			r.Statements[i].Progress = p.Percent
+			r.Statements[i].Throttled = p.Throttled
+		}
+	}
+}
+
+// fail records that applying the report failed with err, and which
+// statement failed if err tells
+func (r *Report) fail(err error) {
+	r.Error = err.Error()
+	var stmtErr *StatementError
+	if !errors.As(err, &stmtErr) {
+		return
+	}
+	for i := range r.Statements {
+		if r.Statements[i].SQL == stmtErr.Statement && !r.Statements[i].Skipped {
+			r.Statements[i].Failed = true
+			return
+		}
+	}
+}
+
+// parseReportTemplate reads the Go template in path
+func parseReportTemplate(path string) (*template.Template, error) {
+	buf, err := ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report template: %v", err)
+	}
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// renderReport executes tmpl with report
+func renderReport(tmpl *template.Template, report *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return nil, fmt.Errorf("failed to render report template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderReportJSON returns report as indented JSON
+func renderReportJSON(report *Report) ([]byte, error) {
+	buf, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %v", err)
+	}
+	return append(buf, '\n'), nil
+}
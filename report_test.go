@@ -0,0 +1,146 @@
+package spannerdef
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderReport(t *testing.T) {
+	current, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Legacy BOOL) PRIMARY KEY (Id)`)
+	require.NoError(t, err)
+	ddls := []string{
+		"ALTER TABLE Users DROP COLUMN Legacy",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+	}
+	reasons := []string{"", "column Users.Email exists in desired but not current (schema.sql:3)"}
+	warnings := []Warning{{Kind: WarningUnsupportedChange, Detail: "table Users differs", Pos: -1}}
+
+	path := filepath.Join(t.TempDir(), "ticket.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte(`Schema change for {{.Database}}: {{.Summary}}
+{{range .Statements}}- [{{.Kind}} {{.Table}}.{{.Name}}]{{if .Skipped}} (skipped){{end}} {{.SQL}}{{with .Reason}} # {{.}}{{end}}
+{{end}}{{range .Warnings}}! {{.}}
+{{end}}`), 0o644))
+	tmpl, err := parseReportTemplate(path)
+	require.NoError(t, err)
+
+	report := newReport("projects/p/instances/i/databases/d", true, ddls, reasons, warnings, current, false, nil)
+	assert.True(t, report.Statements[0].Destructive)
+	assert.False(t, report.Statements[1].Destructive)
+
+	out, err := renderReport(tmpl, report)
+	require.NoError(t, err)
+	assert.Equal(t, `Schema change for projects/p/instances/i/databases/d: 1 table changed, 1 column added
+- [DROP COLUMN Users.Legacy] (skipped) ALTER TABLE Users DROP COLUMN Legacy
+- [ADD COLUMN Users.Email] ALTER TABLE Users ADD COLUMN Email STRING(255) # column Users.Email exists in desired but not current (schema.sql:3)
+! unsupported change ignored: table Users differs
+`, string(out))
+
+	require.NoError(t, os.WriteFile(path, []byte("{{.Statement}}"), 0o644))
+	tmpl, err = parseReportTemplate(path)
+	require.NoError(t, err)
+	_, err = renderReport(tmpl, report)
+	assert.ErrorContains(t, err, "failed to render report template")
+
+	require.NoError(t, os.WriteFile(path, []byte("{{.Statements"), 0o644))
+	_, err = parseReportTemplate(path)
+	assert.ErrorContains(t, err, "failed to parse report template")
+}
+
+func TestRenderReportJSON(t *testing.T) {
+	current, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE TABLE Orders (Id INT64 NOT NULL, CreatedAt TIMESTAMP) PRIMARY KEY (Id)`)
+	require.NoError(t, err)
+	ddls := []string{
+		"CREATE TABLE Logs (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"CREATE INDEX IdxLogs ON Logs (Id)",
+		"CREATE INDEX IdxUsersName ON Users (Name)",
+		"CREATE INDEX IdxOrdersCreatedAt ON Orders (CreatedAt)",
+	}
+	sizes := map[string]int64{"Users": 1 << 20, "Orders": 200 << 30}
+
+	report := newReport("", true, ddls, nil, nil, current, false, sizes)
+	var durations []string
+	for _, statement := range report.Statements {
+		durations = append(durations, statement.Duration)
+	}
+	assert.Equal(t, []string{DurationInstant, DurationInstant, DurationInstant, DurationMinutes, DurationHours}, durations)
+
+	// Without statistics, backfills are assumed to be slow
+	report = newReport("", true, ddls[3:4], nil, nil, current, false, nil)
+	out, err := renderReportJSON(report)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "dry_run": true,
+  "statements": [
+    {
+      "sql": "CREATE INDEX IdxUsersName ON Users (Name)",
+      "kind": "CREATE INDEX",
+      "table": "Users",
+      "name": "IdxUsersName",
+      "destructive": false,
+      "skipped": false,
+      "duration": "hours"
+    }
+  ],
+  "summary": "1 table changed, 1 index created"
+}`, string(out))
+}
+
+func TestReportFail(t *testing.T) {
+	current, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id)`)
+	require.NoError(t, err)
+	ddls := []string{
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"CREATE UNIQUE INDEX IdxUsersName ON Users (Name)",
+	}
+	report := newReport("", false, ddls, nil, nil, current, false, nil)
+
+	stmtErr := &StatementError{Statement: ddls[1], Index: 0, Total: 1, Err: errors.New("uniqueness violation")}
+	report.fail(stmtErr)
+	assert.Equal(t, stmtErr.Error(), report.Error)
+	assert.False(t, report.Statements[0].Failed)
+	assert.True(t, report.Statements[1].Failed)
+}
+
+// progressDatabase is a fakeDatabase that recorded the progress of its
+// statements
+type progressDatabase struct {
+	fakeDatabase
+	progress map[string]StatementProgress
+}
+
+func (db *progressDatabase) StatementProgress() map[string]StatementProgress {
+	return db.progress
+}
+
+func TestReportRecord(t *testing.T) {
+	current, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id)`)
+	require.NoError(t, err)
+	ddls := []string{
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"CREATE INDEX IdxUsersName ON Users (Name)",
+	}
+	report := newReport("", false, ddls, nil, nil, current, false, nil)
+	report.record(&progressDatabase{progress: map[string]StatementProgress{
+		ddls[0]: {Percent: 100},
+		ddls[1]: {Percent: 40, Throttled: true},
+	}})
+
+	out, err := renderReportJSON(report)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"progress": 40,
+      "throttled": true`)
+	assert.Equal(t, int32(100), report.Statements[0].Progress)
+	assert.False(t, report.Statements[0].Throttled)
+
+	// Databases that don't record progress leave it out
+	report = newReport("", false, ddls, nil, nil, current, false, nil)
+	report.record(&fakeDatabase{})
+	assert.Zero(t, report.Statements[1].Progress)
+}
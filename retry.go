@@ -0,0 +1,131 @@
+package spannerdef
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	dbadmin "cloud.google.com/go/spanner/admin/database/apiv1"
+	vkit "cloud.google.com/go/spanner/apiv1"
+	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Defaults for the zero fields of RetryPolicy
+const (
+	defaultRetryInitialBackoff = 250 * time.Millisecond
+	defaultRetryMaxBackoff     = 32 * time.Second
+	retryBackoffMultiplier     = 1.3
+)
+
+// defaultRetryableCodes are retried when RetryPolicy.RetryableCodes is empty
+var defaultRetryableCodes = []codes.Code{codes.Unavailable, codes.DeadlineExceeded}
+
+// RetryPolicy overrides how the admin and data clients retry failed calls,
+// e.g. to absorb the bursts of retryable errors seen behind VPC Service
+// Controls. Zero fields fall back to the defaults above.
+type RetryPolicy struct {
+	MaxAttempts    int // including the first one; unlimited when 0
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	RetryableCodes []codes.Code
+}
+
+// ParseRetryCodes converts code names such as "UNAVAILABLE" to codes
+func ParseRetryCodes(names []string) ([]codes.Code, error) {
+	result := make([]codes.Code, 0, len(names))
+	for _, name := range names {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(`"` + name + `"`)); err != nil {
+			return nil, fmt.Errorf("unknown status code %s", name)
+		}
+		result = append(result, code)
+	}
+	return result, nil
+}
+
+// retryer implements gax.Retryer for a RetryPolicy
+type retryer struct {
+	policy   RetryPolicy
+	backoff  gax.Backoff
+	attempts int
+}
+
+func (r *retryer) Retry(err error) (time.Duration, bool) {
+	r.attempts++
+	if r.policy.MaxAttempts > 0 && r.attempts >= r.policy.MaxAttempts {
+		return 0, false
+	}
+	st, ok := status.FromError(err)
+	if !ok || !slices.Contains(r.policy.RetryableCodes, st.Code()) {
+		return 0, false
+	}
+	return r.backoff.Pause(), true
+}
+
+// callOption returns the gax option that retries calls according to p
+func (p RetryPolicy) callOption() gax.CallOption {
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if len(p.RetryableCodes) == 0 {
+		p.RetryableCodes = defaultRetryableCodes
+	}
+
+	return gax.WithRetry(func() gax.Retryer {
+		return &retryer{
+			policy: p,
+			backoff: gax.Backoff{
+				Initial:    p.InitialBackoff,
+				Max:        p.MaxBackoff,
+				Multiplier: retryBackoffMultiplier,
+			},
+		}
+	})
+}
+
+// applyAdminRetryPolicy makes the admin calls used by spannerdef, including
+// polling of long-running operations, retry according to p. Options set
+// later take precedence, so the defaults of the client are overridden.
+func applyAdminRetryPolicy(client *dbadmin.DatabaseAdminClient, p *RetryPolicy) {
+	if p == nil {
+		return
+	}
+	opt := p.callOption()
+
+	callOptions := client.CallOptions
+	for _, opts := range []*[]gax.CallOption{
+		&callOptions.GetDatabase,
+		&callOptions.GetDatabaseDdl,
+		&callOptions.UpdateDatabaseDdl,
+		&callOptions.CreateDatabase,
+		&callOptions.DropDatabase,
+		&callOptions.TestIamPermissions,
+		&callOptions.GetOperation,
+	} {
+		*opts = append(*opts, opt)
+	}
+	if client.LROClient != nil {
+		client.LROClient.CallOptions.GetOperation = append(client.LROClient.CallOptions.GetOperation, opt)
+	}
+}
+
+// dataCallOptions returns the data client call options retrying the calls
+// used by spannerdef according to p, or nil to keep the defaults
+func dataCallOptions(p *RetryPolicy) *vkit.CallOptions {
+	if p == nil {
+		return nil
+	}
+	opt := []gax.CallOption{p.callOption()}
+	return &vkit.CallOptions{
+		CreateSession:       opt,
+		BatchCreateSessions: opt,
+		GetSession:          opt,
+		ExecuteSql:          opt,
+		ExecuteStreamingSql: opt,
+	}
+}
@@ -0,0 +1,43 @@
+package spannerdef
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryer(t *testing.T) {
+	r := &retryer{
+		policy: RetryPolicy{MaxAttempts: 3, RetryableCodes: []codes.Code{codes.Unavailable}},
+	}
+	r.backoff.Initial = 10 * time.Millisecond
+	r.backoff.Max = 10 * time.Millisecond
+
+	_, retry := r.Retry(status.Error(codes.PermissionDenied, "denied"))
+	assert.False(t, retry, "non-retryable code")
+	_, retry = r.Retry(errors.New("not a status"))
+	assert.False(t, retry, "not a gRPC error")
+
+	r.attempts = 0
+	pause, retry := r.Retry(status.Error(codes.Unavailable, "unavailable"))
+	assert.True(t, retry)
+	assert.LessOrEqual(t, pause, 10*time.Millisecond)
+	_, retry = r.Retry(status.Error(codes.Unavailable, "unavailable"))
+	assert.True(t, retry)
+	_, retry = r.Retry(status.Error(codes.Unavailable, "unavailable"))
+	assert.False(t, retry, "gives up after MaxAttempts")
+}
+
+func TestParseRetryCodes(t *testing.T) {
+	parsed, err := ParseRetryCodes([]string{"UNAVAILABLE", "DEADLINE_EXCEEDED"})
+	require.NoError(t, err)
+	assert.Equal(t, []codes.Code{codes.Unavailable, codes.DeadlineExceeded}, parsed)
+
+	_, err = ParseRetryCodes([]string{"FLAKY"})
+	assert.EqualError(t, err, "unknown status code FLAKY")
+}
@@ -0,0 +1,179 @@
+package spannerdef
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ANSI colors for the risk of a statement in review mode
+const (
+	colorRed    = "\x1b[31m"
+	colorYellow = "\x1b[33m"
+	colorGreen  = "\x1b[32m"
+	colorReset  = "\x1b[0m"
+)
+
+// reviewItem is a statement in review mode
+type reviewItem struct {
+	index       int // position in the plan, which is also the apply order
+	ddl         string
+	reason      string
+	table       string
+	destructive bool
+	selected    bool
+}
+
+// review lets the operator toggle statements of the plan on and off on a
+// terminal, displayed grouped by table and colored by risk. It returns the
+// approved statements and their reasons in plan order, or nil if the
+// operator quit. Destructive statements start toggled off unless
+// enableDrop is set. A selection that leaves out statements the selected
+// ones need, such as the DROP INDEX before a DROP COLUMN of its column, is
+// refused and can be corrected.
+func review(ddls, reasons []string, current *Schema, enableDrop bool, in io.Reader, out io.Writer) ([]string, []string, error) {
+	// Group by table, tables in order of their first statement
+	var tables []string
+	groups := make(map[string][]*reviewItem)
+	skipped := skippedDDLs(ddls, current, enableDrop)
+	for i, ddl := range ddls {
+		item := &reviewItem{
+			index:       i,
+			ddl:         ddl,
+			table:       statementTable(ddl, current),
+			destructive: isDestructive(ddl, current),
+			selected:    !skipped[i],
+		}
+		if i < len(reasons) {
+			item.reason = reasons[i]
+		}
+		if _, ok := groups[item.table]; !ok {
+			tables = append(tables, item.table)
+		}
+		groups[item.table] = append(groups[item.table], item)
+	}
+	var items []*reviewItem // in display order
+	for _, table := range tables {
+		items = append(items, groups[table]...)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for {
+		renderReview(out, tables, groups, items)
+		fmt.Fprint(out, "Toggle statements by number (e.g. 1 3-5), 'a' to apply the selected ones, 'q' to quit: ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return nil, nil, scanner.Err()
+		}
+
+		switch input := strings.TrimSpace(scanner.Text()); input {
+		case "q":
+			return nil, nil, nil
+		case "a":
+			selected := make([]*reviewItem, len(ddls))
+			unselected := make(map[int]bool)
+			for _, item := range items {
+				if item.selected {
+					selected[item.index] = item
+				} else {
+					unselected[item.index] = true
+				}
+			}
+			// Statements left out may be needed by those kept
+			if err := checkSkippedRecreates(ddls, unselected); err != nil {
+				fmt.Fprintf(out, "%s\n", err)
+				continue
+			}
+			if err := checkSkippedDependencies(ddls, unselected, current); err != nil {
+				fmt.Fprintf(out, "%s\n", err)
+				continue
+			}
+			var approved, approvedReasons []string
+			for _, item := range selected {
+				if item != nil {
+					approved = append(approved, item.ddl)
+					approvedReasons = append(approvedReasons, item.reason)
+				}
+			}
+			return approved, approvedReasons, nil
+		default:
+			numbers, err := parseSelection(input, len(items))
+			if err != nil {
+				fmt.Fprintf(out, "%s\n", err)
+				continue
+			}
+			for _, n := range numbers {
+				items[n-1].selected = !items[n-1].selected
+			}
+		}
+	}
+}
+
+// renderReview prints the statements of review mode with their numbers
+func renderReview(out io.Writer, tables []string, groups map[string][]*reviewItem, items []*reviewItem) {
+	n := 0
+	for _, table := range tables {
+		fmt.Fprintf(out, "\n== %s ==\n", table)
+		for _, item := range groups[table] {
+			n++
+			mark := " "
+			if item.selected {
+				mark = "x"
+			}
+			fmt.Fprintf(out, "[%s] %3d %s%s%s\n", mark, n, riskColor(item.ddl, item.destructive), item.ddl, colorReset)
+			if item.reason != "" {
+				fmt.Fprintf(out, "        -- %s\n", item.reason)
+			}
+		}
+	}
+	fmt.Fprintln(out)
+}
+
+// parseSelection parses space or comma separated numbers and ranges such
+// as "1 3-5" into 1-based item numbers
+func parseSelection(input string, count int) ([]int, error) {
+	var numbers []int
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ' ' || r == ',' }) {
+		from, to, isRange := strings.Cut(field, "-")
+		if !isRange {
+			to = from
+		}
+		start, err1 := strconv.Atoi(from)
+		end, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || start < 1 || end > count || start > end {
+			return nil, fmt.Errorf("invalid selection '%s': expected numbers between 1 and %d", field, count)
+		}
+		for i := start; i <= end; i++ {
+			numbers = append(numbers, i)
+		}
+	}
+	return numbers, nil
+}
+
+// riskColor colors destructive statements red, statements that backfill or
+// validate existing data yellow, and the rest green
+func riskColor(ddl string, destructive bool) string {
+	if destructive {
+		return colorRed
+	}
+	switch classifyDDL(ddl).kind {
+	case opCreateIndex, opAlterColumnType, opAlterColumn, opAddConstraint:
+		return colorYellow
+	default:
+		return colorGreen
+	}
+}
+
+// statementTable returns the table ddl applies to. DROP INDEX statements
+// only name the index, so its table is looked up in current.
+func statementTable(ddl string, current *Schema) string {
+	op := classifyDDL(ddl)
+	if op.kind == opDropIndex {
+		if index, ok := current.Indexes[op.name]; ok {
+			return index.TableName
+		}
+	}
+	return op.table
+}
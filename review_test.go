@@ -0,0 +1,102 @@
+package spannerdef
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReview(t *testing.T) {
+	current, err := ParseDDLs(`
+		CREATE TABLE Users (Id INT64 NOT NULL, Legacy STRING(10)) PRIMARY KEY (Id);
+		CREATE INDEX IdxUsersLegacy ON Users (Legacy);
+	`)
+	require.NoError(t, err)
+
+	ddls := []string{
+		"DROP INDEX IdxUsersLegacy",
+		"ALTER TABLE Users DROP COLUMN Legacy",
+		"CREATE TABLE Posts (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"CREATE INDEX IdxUsersEmail ON Users (Email)",
+	}
+
+	// Displayed grouped by table: Users (1-4), then Posts (5). Destructive
+	// statements start unselected; approve the DROP INDEX, skip the new index.
+	var out bytes.Buffer
+	approved, _, err := review(ddls, nil, current, false, strings.NewReader("1\n9\n4\na\n"), &out)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DROP INDEX IdxUsersLegacy",
+		"CREATE TABLE Posts (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+	}, approved)
+	assert.Contains(t, out.String(), "== Users ==")
+	assert.Contains(t, out.String(), "[ ]   2 "+colorRed+"ALTER TABLE Users DROP COLUMN Legacy"+colorReset)
+	assert.Contains(t, out.String(), "invalid selection '9': expected numbers between 1 and 5")
+}
+
+func TestReview_Dependencies(t *testing.T) {
+	current, err := ParseDDLs(`
+		CREATE TABLE Users (Id INT64 NOT NULL, Legacy STRING(10)) PRIMARY KEY (Id);
+		CREATE INDEX IdxUsersLegacy ON Users (Legacy);
+	`)
+	require.NoError(t, err)
+	ddls := []string{
+		"DROP INDEX IdxUsersLegacy",
+		"ALTER TABLE Users DROP COLUMN Legacy",
+	}
+
+	// Selecting the DROP COLUMN alone is refused until the DROP INDEX is
+	var out bytes.Buffer
+	approved, _, err := review(ddls, nil, current, false, strings.NewReader("2\na\n1\na\n"), &out)
+	require.NoError(t, err)
+	assert.Equal(t, ddls, approved)
+	assert.Contains(t, out.String(), "column Users.Legacy can't be dropped while index IdxUsersLegacy is kept on it; drop the index too or keep the column")
+}
+
+func TestReview_LeaveOutRecreate(t *testing.T) {
+	current, err := ParseDDLs(`
+		CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(100)) PRIMARY KEY (Id);
+		CREATE INDEX IdxUsersEmail ON Users (Email);
+	`)
+	require.NoError(t, err)
+	ddls := []string{
+		"DROP INDEX IdxUsersEmail",
+		"ALTER TABLE Users ALTER COLUMN Email STRING(255)",
+		"CREATE INDEX IdxUsersEmail ON Users (Email)",
+	}
+
+	// Applying the CREATE INDEX without the DROP INDEX is refused, leaving
+	// out both is not
+	var out bytes.Buffer
+	approved, _, err := review(ddls, nil, current, false, strings.NewReader("a\n3\na\n"), &out)
+	require.NoError(t, err)
+	assert.Equal(t, ddls[1:2], approved)
+	assert.Equal(t, 1, strings.Count(out.String(), "index IdxUsersEmail must be dropped and recreated"))
+}
+
+func TestReview_Quit(t *testing.T) {
+	approved, _, err := review([]string{"DROP TABLE Users"}, nil, &Schema{}, true, strings.NewReader("q\n"), &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Nil(t, approved)
+
+	// Closing the input quits as well
+	approved, _, err = review([]string{"DROP TABLE Users"}, nil, &Schema{}, true, strings.NewReader(""), &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Nil(t, approved)
+}
+
+func TestParseSelection(t *testing.T) {
+	numbers, err := parseSelection("1 3-5,7", 7)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 3, 4, 5, 7}, numbers)
+
+	_, err = parseSelection("5-3", 7)
+	assert.Error(t, err)
+	_, err = parseSelection("x", 7)
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package spannerdef
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// Role represents a database role of fine-grained access control
+type Role struct {
+	Name string
+	// Privileges are the privileges granted to the role, one per object
+	// and column, e.g. "SELECT ON TABLE Users", "UPDATE(Name) ON TABLE
+	// Users" or "ROLE analyst" for membership of another role, so that
+	// GRANT statements listing them differently compare equal
+	Privileges map[string]bool
+	Pos        int    // byte offset of the definition in the parsed DDLs
+	Owner      string // team from an "-- owner:" annotation, if any
+}
+
+// processCreateRole processes CREATE ROLE statement
+func processCreateRole(schema *Schema, stmt *ast.CreateRole) {
+	schema.Roles[stmt.Name.Name] = &Role{
+		Name:       stmt.Name.Name,
+		Privileges: make(map[string]bool),
+		Pos:        int(stmt.Pos()),
+	}
+}
+
+// processGrant processes GRANT statement, after the roles are created
+func processGrant(schema *Schema, stmt *ast.Grant) error {
+	for _, name := range stmt.Roles {
+		role, ok := schema.Roles[name.Name]
+		if !ok {
+			return fmt.Errorf("GRANT to unknown role %s", name.Name)
+		}
+		for _, privilege := range splitPrivilege(stmt.Privilege) {
+			role.Privileges[privilege] = true
+		}
+	}
+	return nil
+}
+
+// processRevoke processes REVOKE statement
+func processRevoke(schema *Schema, stmt *ast.Revoke) error {
+	for _, name := range stmt.Roles {
+		role, ok := schema.Roles[name.Name]
+		if !ok {
+			return fmt.Errorf("REVOKE from unknown role %s", name.Name)
+		}
+		for _, privilege := range splitPrivilege(stmt.Privilege) {
+			delete(role.Privileges, privilege)
+		}
+	}
+	return nil
+}
+
+// splitPrivilege returns the privileges of a GRANT or REVOKE, one per
+// object and column, as stored in Role.Privileges
+func splitPrivilege(privilege ast.Privilege) []string {
+	var privileges []string
+	switch p := privilege.(type) {
+	case *ast.PrivilegeOnTable:
+		for _, table := range p.Names {
+			for _, tablePrivilege := range p.Privileges {
+				action, columns := tablePrivilegeColumns(tablePrivilege)
+				if len(columns) == 0 {
+					privileges = append(privileges, fmt.Sprintf("%s ON TABLE %s", action, table.Name))
+				}
+				for _, column := range columns {
+					privileges = append(privileges, fmt.Sprintf("%s(%s) ON TABLE %s", action, column.Name, table.Name))
+				}
+			}
+		}
+	case *ast.SelectPrivilegeOnView:
+		for _, view := range p.Names {
+			privileges = append(privileges, "SELECT ON VIEW "+view.Name)
+		}
+	case *ast.SelectPrivilegeOnChangeStream:
+		for _, stream := range p.Names {
+			privileges = append(privileges, "SELECT ON CHANGE STREAM "+stream.Name)
+		}
+	case *ast.ExecutePrivilegeOnTableFunction:
+		for _, function := range p.Names {
+			privileges = append(privileges, "EXECUTE ON TABLE FUNCTION "+function.Name)
+		}
+	case *ast.RolePrivilege:
+		for _, role := range p.Names {
+			privileges = append(privileges, "ROLE "+role.Name)
+		}
+	}
+	return privileges
+}
+
+// tablePrivilegeColumns returns the action of a privilege on a table and
+// the columns it is limited to, if any
+func tablePrivilegeColumns(privilege ast.TablePrivilege) (string, []*ast.Ident) {
+	switch p := privilege.(type) {
+	case *ast.SelectPrivilege:
+		return "SELECT", p.Columns
+	case *ast.InsertPrivilege:
+		return "INSERT", p.Columns
+	case *ast.UpdatePrivilege:
+		return "UPDATE", p.Columns
+	}
+	return "DELETE", nil
+}
+
+// rolesEqual compares the privileges of two roles
+func rolesEqual(current, desired *Role) bool {
+	return strings.Join(sortedKeys(current.Privileges), "\n") == strings.Join(sortedKeys(desired.Privileges), "\n")
+}
+
+// generateCreateRoleDDLs generates DDLs to create new roles
+func generateCreateRoleDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(desired.Roles) {
+		if _, exists := current.Roles[name]; !exists {
+			ddls = append(ddls, "CREATE ROLE "+name)
+		}
+	}
+	return ddls
+}
+
+// generateGrantDDLs generates DDLs to grant roles the privileges they are
+// desired to have, after the objects they are on are created
+func generateGrantDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(desired.Roles) {
+		var granted map[string]bool
+		if role, exists := current.Roles[name]; exists {
+			granted = role.Privileges
+		}
+		for _, privilege := range sortedKeys(desired.Roles[name].Privileges) {
+			if !granted[privilege] {
+				ddls = append(ddls, fmt.Sprintf("GRANT %s TO ROLE %s", privilege, name))
+			}
+		}
+	}
+	return ddls
+}
+
+// generateRevokeDDLs generates DDLs to revoke the privileges roles are no
+// longer desired to have, before the objects they are on are dropped.
+// Roles that are dropped lose all of theirs, as Spanner requires.
+func generateRevokeDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(current.Roles) {
+		var kept map[string]bool
+		if role, exists := desired.Roles[name]; exists {
+			kept = role.Privileges
+		}
+		for _, privilege := range sortedKeys(current.Roles[name].Privileges) {
+			if !kept[privilege] {
+				ddls = append(ddls, fmt.Sprintf("REVOKE %s FROM ROLE %s", privilege, name))
+			}
+		}
+	}
+	return ddls
+}
+
+// generateDropRoleDDLs generates DDLs to drop the roles that no longer
+// exist
+func generateDropRoleDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(current.Roles) {
+		if _, exists := desired.Roles[name]; !exists {
+			ddls = append(ddls, "DROP ROLE "+name)
+		}
+	}
+	return ddls
+}
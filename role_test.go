@@ -0,0 +1,71 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDDLs_Roles(t *testing.T) {
+	schema, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100), Email STRING(100)) PRIMARY KEY (Id);
+CREATE ROLE analyst;
+CREATE ROLE auditor;
+GRANT SELECT, UPDATE(Name, Email) ON TABLE Users TO ROLE analyst, auditor;
+REVOKE UPDATE(Email) ON TABLE Users FROM ROLE auditor;
+GRANT ROLE analyst TO ROLE auditor;
+GRANT SELECT ON TABLE Users TO ROLE unknown`)
+	require.NoError(t, err)
+
+	// Privileges are split by object and column
+	assert.Equal(t, []string{"SELECT ON TABLE Users", "UPDATE(Email) ON TABLE Users", "UPDATE(Name) ON TABLE Users"},
+		sortedKeys(schema.Roles["analyst"].Privileges))
+	assert.Equal(t, []string{"ROLE analyst", "SELECT ON TABLE Users", "UPDATE(Name) ON TABLE Users"},
+		sortedKeys(schema.Roles["auditor"].Privileges))
+	// Grants to roles that aren't created are left unsupported
+	require.Len(t, schema.Unsupported, 1)
+	assert.Equal(t, "GRANT SELECT ON TABLE Users TO ROLE unknown", schema.Unsupported[0].SQL)
+}
+
+func TestGenerateIdempotentDDLs_Roles(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE ROLE analyst;
+CREATE ROLE legacy;
+GRANT SELECT ON TABLE Users, Logs TO ROLE analyst;
+GRANT SELECT ON TABLE Logs TO ROLE legacy`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE TABLE Orders (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE VIEW UserNames SQL SECURITY INVOKER AS SELECT Name FROM Users;
+CREATE ROLE analyst;
+CREATE ROLE writer;
+GRANT SELECT ON TABLE Users TO ROLE analyst;
+GRANT SELECT ON VIEW UserNames TO ROLE analyst;
+GRANT INSERT, UPDATE(Name) ON TABLE Orders, Users TO ROLE writer`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		// Privileges are revoked before the objects they are on are dropped
+		"REVOKE SELECT ON TABLE Logs FROM ROLE analyst",
+		"REVOKE SELECT ON TABLE Logs FROM ROLE legacy",
+		"DROP TABLE Logs",
+		"CREATE ROLE writer",
+		"CREATE TABLE Orders (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"CREATE VIEW UserNames SQL SECURITY INVOKER AS SELECT Name FROM Users",
+		// and granted after the objects are created
+		"GRANT SELECT ON VIEW UserNames TO ROLE analyst",
+		"GRANT INSERT ON TABLE Orders TO ROLE writer",
+		"GRANT INSERT ON TABLE Users TO ROLE writer",
+		"GRANT UPDATE(Name) ON TABLE Orders TO ROLE writer",
+		"GRANT UPDATE(Name) ON TABLE Users TO ROLE writer",
+		"DROP ROLE legacy",
+	}, ddls)
+	// Replaying the statements brings the roles in line
+	assert.Empty(t, warnings)
+
+	// Dropping a role or revoking a privilege is destructive
+	assert.True(t, isDestructive("DROP ROLE legacy", nil))
+	assert.True(t, isDestructive("REVOKE SELECT ON TABLE Logs FROM ROLE analyst", nil))
+	assert.False(t, isDestructive("GRANT SELECT ON TABLE Users TO ROLE analyst", nil))
+}
@@ -0,0 +1,66 @@
+package spannerdef
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// databaseDirectiveRe matches a `-- database: <id>` line, which routes the
+// statements following it, up to the next directive, to another database
+var databaseDirectiveRe = regexp.MustCompile(`(?m)^--\s*database:\s*(\S+)\s*$`)
+
+// HasDatabaseDirectives reports whether any of sources routes statements
+// with a `-- database: <id>` directive
+func HasDatabaseDirectives(sources []Source) bool {
+	for _, source := range sources {
+		if databaseDirectiveRe.MatchString(source.DDLs) {
+			return true
+		}
+	}
+	return false
+}
+
+// RouteSources splits sources at `-- database: <id>` directives and groups
+// the fragments by database, so that a monorepo can keep the schemas of
+// several databases together. Statements before the first directive of a
+// file go to defaultDatabase. Fragments keep their line offset in the file
+// so that locations still point at the original file.
+func RouteSources(sources []Source, defaultDatabase string) (map[string][]Source, error) {
+	routes := make(map[string][]Source)
+	add := func(database string, source Source) error {
+		if strings.TrimSpace(stripComments(source.DDLs)) == "" {
+			return nil
+		}
+		if database == "" {
+			return fmt.Errorf("%s has statements without a database: use --database or a '-- database: <id>' directive", source.Path)
+		}
+		routes[database] = append(routes[database], source)
+		return nil
+	}
+
+	for _, source := range sources {
+		database := defaultDatabase
+		start := 0
+		for _, m := range databaseDirectiveRe.FindAllStringSubmatchIndex(source.DDLs, -1) {
+			if err := add(database, fragment(source, start, m[0])); err != nil {
+				return nil, err
+			}
+			database = source.DDLs[m[2]:m[3]]
+			start = m[0]
+		}
+		if err := add(database, fragment(source, start, len(source.DDLs))); err != nil {
+			return nil, err
+		}
+	}
+	return routes, nil
+}
+
+// fragment returns source.DDLs[start:end] as a Source of the same file
+func fragment(source Source, start, end int) Source {
+	return Source{
+		Path:       source.Path,
+		DDLs:       source.DDLs[start:end],
+		LineOffset: source.LineOffset + strings.Count(source.DDLs[:start], "\n"),
+	}
+}
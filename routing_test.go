@@ -0,0 +1,42 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteSources(t *testing.T) {
+	sources := []Source{
+		{Path: "users.sql", DDLs: "CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);\n"},
+		{Path: "orders.sql", DDLs: "-- Orders live in their own database\n-- database: orders\nCREATE TABLE Orders (Id INT64 NOT NULL) PRIMARY KEY (Id);\n\n-- database: main\nCREATE TABLE Carts (Id INT64 NOT NULL) PRIMARY KEY (Id);\n"},
+	}
+
+	routes, err := RouteSources(sources, "main")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]Source{
+		"main": {
+			{Path: "users.sql", DDLs: "CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);\n"},
+			{Path: "orders.sql", DDLs: "-- database: main\nCREATE TABLE Carts (Id INT64 NOT NULL) PRIMARY KEY (Id);\n", LineOffset: 4},
+		},
+		"orders": {
+			{Path: "orders.sql", DDLs: "-- database: orders\nCREATE TABLE Orders (Id INT64 NOT NULL) PRIMARY KEY (Id);\n\n", LineOffset: 1},
+		},
+	}, routes)
+
+	// Locations still point at the original file
+	schema, err := ParseDDLs(JoinSources(routes["main"]))
+	require.NoError(t, err)
+	assert.Equal(t, "orders.sql:6", locate(routes["main"], schema.Tables["Carts"].Pos))
+}
+
+func TestRouteSources_NoDatabase(t *testing.T) {
+	sources := []Source{
+		{Path: "schema.sql", DDLs: "CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);\n-- database: orders\n"},
+	}
+
+	assert.True(t, HasDatabaseDirectives(sources))
+	_, err := RouteSources(sources, "")
+	assert.EqualError(t, err, "schema.sql has statements without a database: use --database or a '-- database: <id>' directive")
+}
@@ -0,0 +1,63 @@
+package spannerdef
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// rowDeletionPolicy returns the ROW DELETION POLICY clause of table, or ""
+// if it has none
+func rowDeletionPolicy(table *Table) string {
+	if table.RowDeletionPolicyColumn == "" {
+		return ""
+	}
+	return fmt.Sprintf("ROW DELETION POLICY (OLDER_THAN(%s, INTERVAL %d DAY))",
+		quoteName(table.RowDeletionPolicyColumn), table.RowDeletionPolicyDays)
+}
+
+// generateAlterRowDeletionPolicy generates the DDLs changing the row
+// deletion policy of an existing table: drops, to run before columns are
+// dropped, and sets, to run after they are added. A policy on a column
+// that is dropped is dropped first and added back, as it can't be
+// replaced before the new column exists.
+func generateAlterRowDeletionPolicy(current, desired *Table) (drops, sets []string) {
+	currentPolicy, desiredPolicy := rowDeletionPolicy(current), rowDeletionPolicy(desired)
+	if currentPolicy == desiredPolicy {
+		return nil, nil
+	}
+
+	drop := fmt.Sprintf("ALTER TABLE %s DROP ROW DELETION POLICY", quoteName(desired.Name))
+	switch {
+	case desiredPolicy == "":
+		return []string{drop}, nil
+	case currentPolicy == "":
+		return nil, []string{fmt.Sprintf("ALTER TABLE %s ADD %s", quoteName(desired.Name), desiredPolicy)}
+	}
+	if _, ok := desired.Columns[current.RowDeletionPolicyColumn]; !ok {
+		return []string{drop}, []string{fmt.Sprintf("ALTER TABLE %s ADD %s", quoteName(desired.Name), desiredPolicy)}
+	}
+	return nil, []string{fmt.Sprintf("ALTER TABLE %s REPLACE %s", quoteName(desired.Name), desiredPolicy)}
+}
+
+// retention returns the column and number of days of a row deletion policy
+func retention(policy *ast.RowDeletionPolicy) (string, int64) {
+	days, _ := strconv.ParseInt(policy.NumDays.Value, policy.NumDays.Base, 64)
+	return policy.ColumnName.Name, days
+}
+
+// shortensRetention reports whether adding or replacing a row deletion
+// policy with op may delete rows the current policy of the table keeps:
+// when the table has no policy, it is on another column, or it keeps rows
+// longer. Without current, any policy is assumed to.
+func shortensRetention(current *Schema, op operation) bool {
+	if current == nil {
+		return true
+	}
+	table, ok := current.Tables[op.table]
+	if !ok || rowDeletionPolicy(table) == "" {
+		return true
+	}
+	return table.RowDeletionPolicyColumn != op.name || table.RowDeletionPolicyDays > op.retentionDays
+}
@@ -0,0 +1,85 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_RowDeletionPolicy(t *testing.T) {
+	table := func(columns, policy string) string {
+		ddl := "CREATE TABLE Events (\n  Id INT64 NOT NULL,\n  " + columns + "\n) PRIMARY KEY (Id)"
+		if policy != "" {
+			ddl += ", ROW DELETION POLICY (OLDER_THAN(" + policy + " DAY))"
+		}
+		return ddl
+	}
+
+	tests := []struct {
+		name     string
+		current  string
+		desired  string
+		expected []string
+	}{
+		{
+			name:     "Add",
+			current:  table("CreatedAt TIMESTAMP", ""),
+			desired:  table("CreatedAt TIMESTAMP", "CreatedAt, INTERVAL 30"),
+			expected: []string{"ALTER TABLE Events ADD ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 30 DAY))"},
+		},
+		{
+			name:     "Replace",
+			current:  table("CreatedAt TIMESTAMP", "CreatedAt, INTERVAL 30"),
+			desired:  table("CreatedAt TIMESTAMP", "CreatedAt, INTERVAL 90"),
+			expected: []string{"ALTER TABLE Events REPLACE ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 90 DAY))"},
+		},
+		{
+			name:     "Drop",
+			current:  table("CreatedAt TIMESTAMP", "CreatedAt, INTERVAL 30"),
+			desired:  table("CreatedAt TIMESTAMP", ""),
+			expected: []string{"ALTER TABLE Events DROP ROW DELETION POLICY"},
+		},
+		{
+			// The policy is dropped before its column, and added back once
+			// the new column exists
+			name:    "DroppedColumn",
+			current: table("CreatedAt TIMESTAMP", "CreatedAt, INTERVAL 30"),
+			desired: table("ExpiresAt TIMESTAMP", "ExpiresAt, INTERVAL 0"),
+			expected: []string{
+				"ALTER TABLE Events DROP ROW DELETION POLICY",
+				"ALTER TABLE Events DROP COLUMN CreatedAt",
+				"ALTER TABLE Events ADD COLUMN ExpiresAt TIMESTAMP",
+				"ALTER TABLE Events ADD ROW DELETION POLICY (OLDER_THAN(ExpiresAt, INTERVAL 0 DAY))",
+			},
+		},
+		{
+			// As Spanner may dump it
+			name:    "AlterTable",
+			current: table("CreatedAt TIMESTAMP", "") + ";\nALTER TABLE Events ADD ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 30 DAY))",
+			desired: table("CreatedAt TIMESTAMP", "CreatedAt, INTERVAL 30"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ddls, warnings, err := GenerateIdempotentDDLs(tt.desired, tt.current, GeneratorConfig{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ddls)
+			assert.Empty(t, warnings)
+		})
+	}
+}
+
+func TestIsDestructive_RowDeletionPolicy(t *testing.T) {
+	current, err := ParseDDLs(`CREATE TABLE Events (Id INT64 NOT NULL, CreatedAt TIMESTAMP) PRIMARY KEY (Id),
+ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 30 DAY));
+CREATE TABLE Logs (Id INT64 NOT NULL, CreatedAt TIMESTAMP) PRIMARY KEY (Id)`)
+	require.NoError(t, err)
+
+	// Keeping rows for less time deletes the ones in between
+	assert.True(t, isDestructive("ALTER TABLE Events REPLACE ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 7 DAY))", current))
+	assert.False(t, isDestructive("ALTER TABLE Events REPLACE ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 90 DAY))", current))
+	assert.True(t, isDestructive("ALTER TABLE Logs ADD ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 90 DAY))", current))
+	assert.True(t, isDestructive("ALTER TABLE Events REPLACE ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 90 DAY))", nil))
+	assert.False(t, isDestructive("ALTER TABLE Events DROP ROW DELETION POLICY", current))
+}
@@ -0,0 +1,183 @@
+package spannerdef
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// Sequence represents a Spanner sequence
+type Sequence struct {
+	Name string
+	// Params are the clauses between the name and the options, such as
+	// BIT_REVERSED_POSITIVE or SKIP RANGE, as memefish formats them
+	Params string
+	// Options are the values of the options set on the sequence by name,
+	// e.g. "'bit_reversed_positive'" for sequence_kind
+	Options map[string]string
+	Pos     int    // byte offset of the definition in the parsed DDLs
+	Owner   string // team from an "-- owner:" annotation, if any
+}
+
+// processCreateSequence processes CREATE SEQUENCE statement
+func processCreateSequence(schema *Schema, stmt *ast.CreateSequence) {
+	sequence := &Sequence{
+		Name:    getPathName(stmt.Name),
+		Options: make(map[string]string),
+		Pos:     int(stmt.Pos()),
+	}
+	params := make([]string, len(stmt.Params))
+	for i, param := range stmt.Params {
+		params[i] = param.SQL()
+	}
+	sequence.Params = strings.Join(params, " ")
+	if stmt.Options != nil {
+		for _, option := range stmt.Options.Records {
+			sequence.Options[option.Name.Name] = option.Value.SQL()
+		}
+	}
+	schema.Sequences[sequence.Name] = sequence
+}
+
+// generateCreateSequence generates CREATE SEQUENCE DDL
+func generateCreateSequence(sequence *Sequence) string {
+	ddl := "CREATE SEQUENCE " + sequence.Name
+	if sequence.Params != "" {
+		ddl += " " + sequence.Params
+	}
+	if len(sequence.Options) > 0 {
+		options := make([]string, 0, len(sequence.Options))
+		for _, name := range sortedKeys(sequence.Options) {
+			options = append(options, name+" = "+sequence.Options[name])
+		}
+		ddl += " OPTIONS (" + strings.Join(options, ", ") + ")"
+	}
+	return ddl
+}
+
+// sequencesEqual compares the definitions of two sequences
+func sequencesEqual(current, desired *Sequence) bool {
+	return generateCreateSequence(current) == generateCreateSequence(desired)
+}
+
+// generateCreateSequenceDDLs generates DDLs to create new sequences
+func generateCreateSequenceDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(desired.Sequences) {
+		if _, exists := current.Sequences[name]; !exists {
+			ddls = append(ddls, generateCreateSequence(desired.Sequences[name]))
+		}
+	}
+	return ddls
+}
+
+// generateAlterSequenceDDLs generates DDLs to change the options of
+// existing sequences. Changed params aren't altered, see unsupportedChanges.
+func generateAlterSequenceDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(desired.Sequences) {
+		currentSequence, exists := current.Sequences[name]
+		if !exists {
+			continue
+		}
+		if options := changedOptions(currentSequence.Options, desired.Sequences[name].Options); options != "" {
+			ddls = append(ddls, fmt.Sprintf("ALTER SEQUENCE %s SET OPTIONS (%s)", name, options))
+		}
+	}
+	return ddls
+}
+
+// generateDropSequenceDDLs generates DDLs to drop the sequences that no
+// longer exist
+func generateDropSequenceDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(current.Sequences) {
+		if _, exists := desired.Sequences[name]; !exists {
+			ddls = append(ddls, fmt.Sprintf("DROP SEQUENCE %s", name))
+		}
+	}
+	return ddls
+}
+
+// sequenceReferences returns the names of the sequences expr, such as the
+// DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE OrderSeq)) of a column, takes
+// values from
+func sequenceReferences(expr string) []string {
+	parsed, err := memefish.ParseExpr("", expr)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	ast.Inspect(parsed, func(node ast.Node) bool {
+		if arg, ok := node.(*ast.SequenceArg); ok {
+			switch name := arg.Expr.(type) {
+			case *ast.Ident:
+				names = append(names, name.Name)
+			case *ast.Path:
+				names = append(names, getPathName(name))
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// checkSequenceReferences returns an error listing the columns of desired
+// whose DEFAULT takes values from a sequence desired doesn't define, which
+// Spanner refuses to create or, for an existing sequence, to drop.
+// Sequences left out by the target or skip lists of config aren't managed
+// and may be referenced.
+func checkSequenceReferences(desired *Schema, config GeneratorConfig) error {
+	var errs []error
+	for _, tableName := range sortedKeys(desired.Tables) {
+		for _, col := range sortedColumns(desired.Tables[tableName]) {
+			if col.Default == "" {
+				continue
+			}
+			for _, name := range sequenceReferences(col.Default) {
+				if _, exists := desired.Sequences[name]; !exists && shouldIncludeTable(name, config) {
+					errs = append(errs, fmt.Errorf("column %s.%s takes its default from sequence %s, which isn't in the desired schema",
+						tableName, col.Name, name))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replayAlterSequence applies an ALTER SEQUENCE statement to schema
+func replayAlterSequence(schema *Schema, stmt *ast.AlterSequence) error {
+	sequence, ok := schema.Sequences[getPathName(stmt.Name)]
+	if !ok {
+		return fmt.Errorf("ALTER SEQUENCE on unknown sequence %s", getPathName(stmt.Name))
+	}
+	if stmt.Options != nil {
+		for _, option := range stmt.Options.Records {
+			if _, isNull := option.Value.(*ast.NullLiteral); isNull {
+				delete(sequence.Options, option.Name.Name)
+			} else {
+				sequence.Options[option.Name.Name] = option.Value.SQL()
+			}
+		}
+	}
+	return nil
+}
+
+// restartsCounter reports whether stmt sets the counter of the sequence,
+// which may make it return values that were already used
+func restartsCounter(stmt *ast.AlterSequence) bool {
+	if stmt.RestartCounterWith != nil {
+		return true
+	}
+	if stmt.Options != nil {
+		for _, option := range stmt.Options.Records {
+			if option.Name.Name == "start_with_counter" {
+				return true
+			}
+		}
+	}
+	return false
+}
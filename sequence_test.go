@@ -0,0 +1,88 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDDLs_Sequences(t *testing.T) {
+	schema, err := ParseDDLs(`-- owner: billing
+CREATE SEQUENCE OrderSeq OPTIONS (sequence_kind = 'bit_reversed_positive', skip_range_min = 1, skip_range_max = 1000);
+CREATE SEQUENCE TicketSeq BIT_REVERSED_POSITIVE SKIP RANGE 1, 1000 OPTIONS (start_with_counter = 10)`)
+	require.NoError(t, err)
+	assert.Empty(t, schema.Unsupported)
+
+	// Options are sorted, and literals formatted by memefish
+	assert.Equal(t, `CREATE SEQUENCE OrderSeq OPTIONS (sequence_kind = "bit_reversed_positive", skip_range_max = 1000, skip_range_min = 1)`,
+		generateCreateSequence(schema.Sequences["OrderSeq"]))
+	assert.Equal(t, "CREATE SEQUENCE TicketSeq BIT_REVERSED_POSITIVE SKIP RANGE 1, 1000 OPTIONS (start_with_counter = 10)", generateCreateSequence(schema.Sequences["TicketSeq"]))
+	assert.Equal(t, "billing", schema.Sequences["OrderSeq"].Owner)
+}
+
+func TestGenerateIdempotentDDLs_Sequences(t *testing.T) {
+	current := `CREATE SEQUENCE OldSeq OPTIONS (sequence_kind = 'bit_reversed_positive');
+CREATE SEQUENCE OrderSeq OPTIONS (sequence_kind = 'bit_reversed_positive', skip_range_min = 1, skip_range_max = 1000);
+CREATE TABLE Logs (Id INT64 NOT NULL DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE OldSeq))) PRIMARY KEY (Id)`
+	desired := `CREATE SEQUENCE OrderSeq OPTIONS (sequence_kind = 'bit_reversed_positive', start_with_counter = 100);
+CREATE SEQUENCE TicketSeq OPTIONS (sequence_kind = 'bit_reversed_positive');
+CREATE TABLE Tickets (Id INT64 NOT NULL DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE TicketSeq))) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DROP TABLE Logs",
+		// Sequences are created before the tables taking values from them,
+		// and dropped after
+		`CREATE SEQUENCE TicketSeq OPTIONS (sequence_kind = "bit_reversed_positive")`,
+		"ALTER SEQUENCE OrderSeq SET OPTIONS (skip_range_max = null, skip_range_min = null, start_with_counter = 100)",
+		"CREATE TABLE Tickets (\n  Id INT64 NOT NULL DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE TicketSeq))\n) PRIMARY KEY (Id)",
+		"DROP SEQUENCE OldSeq",
+	}, ddls)
+	// Replaying the statements brings the sequences in line
+	assert.Empty(t, warnings)
+
+	// Dropping a sequence or setting its counter is destructive
+	assert.True(t, isDestructive("DROP SEQUENCE OldSeq", nil))
+	assert.True(t, isDestructive(ddls[2], nil))
+	assert.False(t, isDestructive("ALTER SEQUENCE OrderSeq SET OPTIONS (skip_range_min = 1)", nil))
+}
+
+func TestGenerateIdempotentDDLs_SequenceParamsChange(t *testing.T) {
+	// memefish only parses the clauses of CREATE SEQUENCE followed by OPTIONS
+	current := `CREATE SEQUENCE TicketSeq BIT_REVERSED_POSITIVE OPTIONS (start_with_counter = 10)`
+	desired := `CREATE SEQUENCE TicketSeq BIT_REVERSED_POSITIVE SKIP RANGE 1, 1000 OPTIONS (start_with_counter = 10)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "unsupported change ignored: sequence TicketSeq differs", warnings[0].String())
+}
+
+func TestGenerateIdempotentDDLs_SequenceDefaultsAsDumped(t *testing.T) {
+	current := `CREATE SEQUENCE TicketSeq OPTIONS (sequence_kind = 'bit_reversed_positive');
+CREATE TABLE Tickets (Id INT64 NOT NULL DEFAULT (get_next_sequence_value(SEQUENCE ` + "`TicketSeq`" + `))) PRIMARY KEY (Id)`
+	desired := `CREATE SEQUENCE TicketSeq OPTIONS (sequence_kind = 'bit_reversed_positive');
+CREATE TABLE Tickets (Id INT64 NOT NULL DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE TicketSeq))) PRIMARY KEY (Id)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	assert.Empty(t, warnings)
+}
+
+func TestGenerateIdempotentDDLs_MissingSequence(t *testing.T) {
+	current := `CREATE SEQUENCE TicketSeq OPTIONS (sequence_kind = 'bit_reversed_positive');
+CREATE TABLE Tickets (Id INT64 NOT NULL DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE TicketSeq))) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE Tickets (Id INT64 NOT NULL DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE TicketSeq))) PRIMARY KEY (Id)`
+
+	_, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	assert.EqualError(t, err, "column Tickets.Id takes its default from sequence TicketSeq, which isn't in the desired schema")
+
+	// Unless the sequence isn't managed
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{TargetTables: []string{"Tickets"}})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
@@ -0,0 +1,114 @@
+package spannerdef
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// RowCounter is implemented by databases that can count the rows of a table
+type RowCounter interface {
+	CountRows(table string) (int64, error)
+}
+
+// SizeRow is the storage of a table or index of the current schema
+type SizeRow struct {
+	Object string `json:"object"` // "table X" or "index X", as in the drift matrix
+	Bytes  int64  `json:"bytes"`
+	// Rows is the row count of a table, nil for indexes and when it
+	// couldn't be counted
+	Rows *int64 `json:"rows,omitempty"`
+	// Dropped is set for objects that aren't in the desired schema, i.e.
+	// whose drop would reclaim Bytes
+	Dropped bool `json:"dropped"`
+}
+
+// BuildSizeReport joins the tables and indexes of the database with their
+// used bytes, as of the latest hourly table size statistics, and their row
+// counts. Rows are sorted by size, largest first. Filters of config apply.
+func BuildSizeReport(db Database, desiredDDLs string, config GeneratorConfig) ([]SizeRow, error) {
+	sizer, ok := db.(TableSizer)
+	if !ok {
+		return nil, fmt.Errorf("the database doesn't report table sizes")
+	}
+
+	desired, err := parseSchema(desiredDDLs, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse desired DDLs: %v", err)
+	}
+	desired = filterSchema(desired, config)
+
+	ddls, err := db.DumpDDLs()
+	if err != nil {
+		return nil, err
+	}
+	current, err := parseSchema(ddls, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse current DDLs: %v", err)
+	}
+	current = filterSchema(current, config)
+
+	sizes, err := sizer.TableSizes()
+	if err != nil {
+		return nil, err
+	}
+	counter, _ := db.(RowCounter)
+
+	var rows []SizeRow
+	for _, name := range sortedKeys(current.Tables) {
+		row := SizeRow{
+			Object:  "table " + name,
+			Bytes:   sizes[name],
+			Dropped: desired.Tables[name] == nil,
+		}
+		if counter != nil {
+			count, err := counter.CountRows(name)
+			if err != nil {
+				return nil, err
+			}
+			row.Rows = &count
+		}
+		rows = append(rows, row)
+	}
+	for _, name := range sortedKeys(current.Indexes) {
+		rows = append(rows, SizeRow{
+			Object:  "index " + name,
+			Bytes:   sizes[name],
+			Dropped: desired.Indexes[name] == nil,
+		})
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Bytes > rows[j].Bytes })
+	return rows, nil
+}
+
+// WriteSizeReport writes rows as an aligned table, with "drop" in the PLAN
+// column of the objects the desired schema drops
+func WriteSizeReport(w io.Writer, rows []SizeRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OBJECT\tBYTES\tROWS\tPLAN")
+	for _, row := range rows {
+		count := "-"
+		if row.Rows != nil {
+			count = strconv.FormatInt(*row.Rows, 10)
+		}
+		plan := "-"
+		if row.Dropped {
+			plan = "drop"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", row.Object, row.Bytes, count, plan)
+	}
+	return tw.Flush()
+}
+
+// WriteSizeReportJSON writes rows as an indented JSON array
+func WriteSizeReportJSON(w io.Writer, rows []SizeRow) error {
+	if rows == nil {
+		rows = []SizeRow{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
@@ -0,0 +1,68 @@
+package spannerdef
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sizedDatabase is a fakeDatabase that reports table sizes and row counts
+type sizedDatabase struct {
+	fakeDatabase
+	sizes map[string]int64
+	rows  map[string]int64
+}
+
+func (d *sizedDatabase) TableSizes() (map[string]int64, error) {
+	return d.sizes, nil
+}
+
+func (d *sizedDatabase) CountRows(table string) (int64, error) {
+	return d.rows[table], nil
+}
+
+func TestBuildSizeReport(t *testing.T) {
+	db := &sizedDatabase{
+		fakeDatabase: fakeDatabase{ddls: `CREATE TABLE Events (
+  Id INT64 NOT NULL,
+  Kind STRING(100),
+) PRIMARY KEY (Id);
+CREATE TABLE LegacyEvents (
+  Id INT64 NOT NULL,
+) PRIMARY KEY (Id);
+CREATE INDEX IdxEventsKind ON Events (Kind)`},
+		sizes: map[string]int64{"Events": 4096, "LegacyEvents": 8192, "IdxEventsKind": 1024},
+		rows:  map[string]int64{"Events": 40, "LegacyEvents": 80},
+	}
+	desired := `CREATE TABLE Events (
+  Id INT64 NOT NULL,
+  Kind STRING(100),
+) PRIMARY KEY (Id)`
+
+	rows, err := BuildSizeReport(db, desired, GeneratorConfig{})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "table LegacyEvents", rows[0].Object)
+	assert.True(t, rows[0].Dropped)
+	assert.Equal(t, int64(80), *rows[0].Rows)
+	assert.Equal(t, "table Events", rows[1].Object)
+	assert.False(t, rows[1].Dropped)
+	assert.Equal(t, "index IdxEventsKind", rows[2].Object)
+	assert.True(t, rows[2].Dropped)
+	assert.Nil(t, rows[2].Rows)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSizeReport(&buf, rows))
+	assert.Equal(t, `OBJECT               BYTES  ROWS  PLAN
+table LegacyEvents   8192   80    drop
+table Events         4096   40    -
+index IdxEventsKind  1024   -     drop
+`, buf.String())
+}
+
+func TestBuildSizeReport_NoSizes(t *testing.T) {
+	_, err := BuildSizeReport(&fakeDatabase{}, "", GeneratorConfig{})
+	assert.Error(t, err)
+}
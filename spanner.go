@@ -1,23 +1,85 @@
 package spannerdef
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	dbadmin "cloud.google.com/go/spanner/admin/database/apiv1"
 	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+const defaultUserAgent = "spannerdef"
+
+// requestTagHeader is the gRPC metadata key request tags are sent under.
+const requestTagHeader = "spannerdef-request-tag"
+
 type SpannerDatabase struct {
+	// client is the data client, created on first use by dataClient since
+	// schema operations only need the admin client.
 	client       *spanner.Client
+	clientOnce   sync.Once
+	clientErr    error
+	clientOpts   []option.ClientOption
+	databaseRole string
+	retry        *RetryPolicy
 	adminClient  *dbadmin.DatabaseAdminClient
 	projectID    string
 	instanceID   string
 	databaseID   string
 	databasePath string
+	requestTags  []string
+	// operationFile persists the in-flight DDL operation name, see Config
+	operationFile string
+	// progress receives DDL operation progress while waiting; nil disables it
+	progress io.Writer
+	// statementProgress is the last progress of the statements applied,
+	// see recordProgress
+	statementProgress map[string]StatementProgress
+	// timeouts bound DDL batches by operation kind, see ParseTimeouts
+	timeouts map[string]time.Duration
+	// protoDescriptors describe the proto types of the proto bundle, see
+	// Config
+	protoDescriptors []byte
+}
+
+// userAgent builds the user agent sent with every call. Request tags are
+// appended because the user agent is recorded as callerSuppliedUserAgent in
+// Cloud Audit Logs, which makes deploys traceable without extra tooling.
+func userAgent(config Config) string {
+	ua := config.UserAgent
+	if ua == "" {
+		ua = defaultUserAgent
+	}
+	if len(config.RequestTags) > 0 {
+		ua += " (" + strings.Join(config.RequestTags, "; ") + ")"
+	}
+	return ua
+}
+
+// clientOptions returns the options shared by the data and admin clients
+func clientOptions(config Config) []option.ClientOption {
+	return []option.ClientOption{option.WithUserAgent(userAgent(config))}
+}
+
+// withRequestTags attaches request tags to the outgoing gRPC metadata
+func withRequestTags(ctx context.Context, tags []string) context.Context {
+	for _, tag := range tags {
+		ctx = metadata.AppendToOutgoingContext(ctx, requestTagHeader, tag)
+	}
+	return ctx
 }
 
 func NewDatabase(config Config) (*SpannerDatabase, error) {
@@ -27,30 +89,182 @@ func NewDatabase(config Config) (*SpannerDatabase, error) {
 	databasePath := fmt.Sprintf("projects/%s/instances/%s/databases/%s",
 		config.ProjectID, config.InstanceID, config.DatabaseID)
 
-	client, err := spanner.NewClient(ctx, databasePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Spanner client: %v", err)
-	}
-
 	// Create admin client for DDL operations
-	adminClient, err := dbadmin.NewDatabaseAdminClient(ctx)
+	adminClient, err := dbadmin.NewDatabaseAdminClient(ctx, clientOptions(config)...)
 	if err != nil {
-		client.Close()
 		return nil, fmt.Errorf("failed to create admin client: %v", err)
 	}
+	applyAdminRetryPolicy(adminClient, config.Retry)
 
 	return &SpannerDatabase{
-		client:       client,
-		adminClient:  adminClient,
-		projectID:    config.ProjectID,
-		instanceID:   config.InstanceID,
-		databaseID:   config.DatabaseID,
-		databasePath: databasePath,
+		clientOpts:       clientOptions(config),
+		databaseRole:     config.DatabaseRole,
+		retry:            config.Retry,
+		adminClient:      adminClient,
+		projectID:        config.ProjectID,
+		instanceID:       config.InstanceID,
+		databaseID:       config.DatabaseID,
+		databasePath:     databasePath,
+		requestTags:      config.RequestTags,
+		timeouts:         config.Timeouts,
+		operationFile:    config.OperationFile,
+		progress:         os.Stderr,
+		protoDescriptors: config.ProtoDescriptors,
 	}, nil
 }
 
+// DatabasePath returns projects/<project>/instances/<instance>/databases/<database>
+func (db *SpannerDatabase) DatabasePath() string {
+	return db.databasePath
+}
+
+// dataClient returns the data client, creating it on first use. Creating a
+// spanner.Client starts a session pool, which costs startup time and session
+// quota that plain schema operations don't need.
+func (db *SpannerDatabase) dataClient() (*spanner.Client, error) {
+	db.clientOnce.Do(func() {
+		clientConfig := spanner.ClientConfig{
+			SessionPoolConfig: spanner.DefaultSessionPoolConfig,
+			CallOptions:       dataCallOptions(db.retry),
+			DatabaseRole:      db.databaseRole,
+		}
+		db.client, db.clientErr = spanner.NewClientWithConfig(context.Background(), db.databasePath, clientConfig, db.clientOpts...)
+		if db.clientErr != nil {
+			db.clientErr = fmt.Errorf("failed to create Spanner client: %v", db.clientErr)
+		}
+	})
+	return db.client, db.clientErr
+}
+
+// CountNulls counts the rows of table whose column is NULL
+func (db *SpannerDatabase) CountNulls(table, column string) (int64, error) {
+	client, err := db.dataClient()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := withRequestTags(context.Background(), db.requestTags)
+	stmt := spanner.Statement{SQL: fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE `%s` IS NULL", table, column)}
+	var count int64
+	err = client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		return row.Columns(&count)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count NULL values of %s.%s: %v", table, column, err)
+	}
+	return count, nil
+}
+
+// ReadFreeze reads the freeze marker of the metadata table, if the table
+// exists
+func (db *SpannerDatabase) ReadFreeze() (string, error) {
+	client, err := db.dataClient()
+	if err != nil {
+		return "", err
+	}
+
+	ctx := withRequestTags(context.Background(), db.requestTags)
+	stmt := spanner.Statement{
+		SQL:    "SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = '' AND TABLE_NAME = @table",
+		Params: map[string]interface{}{"table": metadataTable},
+	}
+	var tables int64
+	err = client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		return row.Columns(&tables)
+	})
+	if err != nil || tables == 0 {
+		return "", err
+	}
+
+	stmt = spanner.Statement{SQL: fmt.Sprintf("SELECT Value FROM `%s` WHERE Key = 'freeze'", metadataTable)}
+	var reason spanner.NullString
+	err = client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		return row.Columns(&reason)
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(reason.StringVal), nil
+}
+
+// CountRows counts the rows of table. It scans the table, so it's only
+// used by the sizes command.
+func (db *SpannerDatabase) CountRows(table string) (int64, error) {
+	client, err := db.dataClient()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := withRequestTags(context.Background(), db.requestTags)
+	stmt := spanner.Statement{SQL: fmt.Sprintf("SELECT COUNT(*) FROM `%s`", table)}
+	var count int64
+	err = client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		return row.Columns(&count)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rows of %s: %v", table, err)
+	}
+	return count, nil
+}
+
+// TableSizes returns the used bytes of each table as of the latest hourly
+// table size statistics
+func (db *SpannerDatabase) TableSizes() (map[string]int64, error) {
+	client, err := db.dataClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := withRequestTags(context.Background(), db.requestTags)
+	stmt := spanner.Statement{SQL: `SELECT TABLE_NAME, USED_BYTES FROM SPANNER_SYS.TABLE_SIZES_STATS_1HOUR
+WHERE INTERVAL_END = (SELECT MAX(INTERVAL_END) FROM SPANNER_SYS.TABLE_SIZES_STATS_1HOUR)`}
+	sizes := make(map[string]int64)
+	err = client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var table string
+		var usedBytes float64
+		if err := row.Columns(&table, &usedBytes); err != nil {
+			return err
+		}
+		sizes[table] = int64(usedBytes)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table sizes: %v", err)
+	}
+	return sizes, nil
+}
+
 func (db *SpannerDatabase) DumpDDLs() (string, error) {
-	ctx := context.Background()
+	statements, err := db.getStatements()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(statements, ";\n\n") + ";", nil
+}
+
+// ExportDDLs writes the schema to w one statement at a time, in the same
+// format as DumpDDLs, and returns the number of statements written.
+func (db *SpannerDatabase) ExportDDLs(w io.Writer) (int, error) {
+	statements, err := db.getStatements()
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, stmt := range statements {
+		if i > 0 {
+			bw.WriteString("\n\n")
+		}
+		bw.WriteString(stmt)
+		bw.WriteString(";")
+	}
+	return len(statements), bw.Flush()
+}
+
+// getStatements fetches the schema statements sorted for consistent output
+func (db *SpannerDatabase) getStatements() ([]string, error) {
+	ctx := withRequestTags(context.Background(), db.requestTags)
 
 	// Get database schema
 	req := &databasepb.GetDatabaseDdlRequest{
@@ -59,15 +273,12 @@ func (db *SpannerDatabase) DumpDDLs() (string, error) {
 
 	resp, err := db.adminClient.GetDatabaseDdl(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("failed to get database DDL: %v", err)
+		return nil, fmt.Errorf("failed to get database DDL: %v", err)
 	}
 
-	// Sort statements for consistent output
-	statements := make([]string, len(resp.Statements))
-	copy(statements, resp.Statements)
-	sort.Strings(statements)
-
-	return strings.Join(statements, ";\n\n") + ";", nil
+	// The response is not used afterwards, so sort in place
+	sort.Strings(resp.Statements)
+	return resp.Statements, nil
 }
 
 func (db *SpannerDatabase) ExecDDL(ddl string) error {
@@ -75,11 +286,18 @@ func (db *SpannerDatabase) ExecDDL(ddl string) error {
 }
 
 func (db *SpannerDatabase) ExecDDLs(ddls []string) error {
-	ctx := context.Background()
+	ctx := withRequestTags(context.Background(), db.requestTags)
+	timeout := batchTimeout(db.timeouts, ddls)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	req := &databasepb.UpdateDatabaseDdlRequest{
-		Database:   db.databasePath,
-		Statements: ddls,
+		Database:         db.databasePath,
+		Statements:       ddls,
+		ProtoDescriptors: db.protoDescriptors,
 	}
 
 	op, err := db.adminClient.UpdateDatabaseDdl(ctx, req)
@@ -87,16 +305,201 @@ func (db *SpannerDatabase) ExecDDLs(ddls []string) error {
 		return fmt.Errorf("failed to execute DDLs: %v", err)
 	}
 
-	// Wait for the operation to complete
-	if err := op.Wait(ctx); err != nil {
-		return fmt.Errorf("DDL operation failed: %v", err)
+	if err := db.saveOperation(op.Name()); err != nil {
+		return err
+	}
+
+	err = db.waitOperation(ctx, op)
+	if errors.Is(err, context.DeadlineExceeded) {
+		// The operation isn't cancelled, it may well be about to finish
+		return fmt.Errorf("DDL operation %s did not finish within %s and may still be running: %w", op.Name(), timeout, err)
+	}
+	return err
+}
+
+// ResumeOperation waits for the DDL operation recorded in the operation file
+// by a previous run, if any, and returns its name. An empty name means there
+// was nothing to resume.
+func (db *SpannerDatabase) ResumeOperation() (string, error) {
+	ctx := withRequestTags(context.Background(), db.requestTags)
+
+	name, err := db.loadOperation()
+	if err != nil || name == "" {
+		return "", err
+	}
+	if !strings.HasPrefix(name, db.databasePath+"/operations/") {
+		return "", fmt.Errorf("operation %s in %s does not belong to %s", name, db.operationFile, db.databasePath)
+	}
+
+	op := db.adminClient.UpdateDatabaseDdlOperation(name)
+	if err := db.waitOperation(ctx, op); err != nil {
+		// Operations are garbage collected some time after completion
+		if status.Code(err) == codes.NotFound {
+			return name, db.clearOperation()
+		}
+		return name, err
+	}
+	return name, nil
+}
+
+// progressPollInterval is how often an in-flight DDL operation is polled
+// for progress while waiting for it.
+const progressPollInterval = 10 * time.Second
+
+// waitOperation waits for op to finish, reporting progress to db.progress
+// whenever it changes. The operation file is cleared once the operation is
+// done, whether it succeeded or not, and kept otherwise so that an
+// interrupted wait can be resumed.
+func (db *SpannerDatabase) waitOperation(ctx context.Context, op *dbadmin.UpdateDatabaseDdlOperation) error {
+	var lastProgress string
+	for {
+		err := op.Poll(ctx)
+		if md, mdErr := op.Metadata(); mdErr == nil && md != nil {
+			db.recordProgress(md)
+			if progress := formatProgress(md); db.progress != nil && progress != lastProgress {
+				fmt.Fprintln(db.progress, progress)
+				lastProgress = progress
+			}
+		}
+
+		if op.Done() {
+			if clearErr := db.clearOperation(); clearErr != nil {
+				return clearErr
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("DDL operation failed: %w", err)
+			if md, mdErr := op.Metadata(); op.Done() && mdErr == nil && md != nil {
+				return attributeError(md, err)
+			}
+			return err
+		}
+		if op.Done() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("DDL operation failed: %w", ctx.Err())
+		case <-time.After(progressPollInterval):
+		}
+	}
+}
+
+// recordProgress records the progress of the statements of md, marking
+// those not done yet as throttled while Spanner throttles the operation
+func (db *SpannerDatabase) recordProgress(md *databasepb.UpdateDatabaseDdlMetadata) {
+	if db.statementProgress == nil {
+		db.statementProgress = make(map[string]StatementProgress)
+	}
+	for i, stmt := range md.GetStatements() {
+		var p *databasepb.OperationProgress
+		if i < len(md.GetProgress()) {
+			p = md.GetProgress()[i]
+		}
+		recorded := db.statementProgress[stmt]
+		recorded.Percent = p.GetProgressPercent()
+		if p.GetEndTime() != nil {
+			recorded.Percent = 100
+		} else if recorded.Percent < 100 && md.GetThrottled() {
+			recorded.Throttled = true
+		}
+		db.statementProgress[stmt] = recorded
+	}
+}
+
+// StatementProgress returns the last progress of the statements applied
+func (db *SpannerDatabase) StatementProgress() map[string]StatementProgress {
+	return db.statementProgress
+}
+
+// attributeError returns err as a StatementError for the statement of md
+// that failed, which is the first one without a commit timestamp, or err
+// as is if every statement was committed
+func attributeError(md *databasepb.UpdateDatabaseDdlMetadata, err error) error {
+	statements := md.GetStatements()
+	failed := len(md.GetCommitTimestamps())
+	if failed >= len(statements) {
+		return err
+	}
+	return &StatementError{Statement: statements[failed], Index: failed, Total: len(statements), Err: err}
+}
+
+// formatProgress summarizes DDL operation metadata in one line, e.g.
+// "-- Progress: 1/2 statements done, CREATE INDEX ... 40% (throttled) --".
+// Spanner sets the throttled flag when it deliberately slows down a schema
+// change, which tells operators a slow apply is not just a large one.
+func formatProgress(md *databasepb.UpdateDatabaseDdlMetadata) string {
+	done := 0
+	current := ""
+	for i, stmt := range md.GetStatements() {
+		var p *databasepb.OperationProgress
+		if i < len(md.GetProgress()) {
+			p = md.GetProgress()[i]
+		}
+		if p.GetEndTime() != nil || p.GetProgressPercent() == 100 {
+			done++
+			continue
+		}
+		if current == "" {
+			current = fmt.Sprintf(", %s %d%%", summarizeStatement(stmt), p.GetProgressPercent())
+		}
+	}
+
+	progress := fmt.Sprintf("-- Progress: %d/%d statements done%s", done, len(md.GetStatements()), current)
+	if md.GetThrottled() {
+		progress += " (throttled)"
+	}
+	return progress + " --"
+}
+
+// summarizeStatement shortens a statement to its first line for display
+func summarizeStatement(stmt string) string {
+	line, _, multiline := strings.Cut(strings.TrimSpace(stmt), "\n")
+	if multiline {
+		line = strings.TrimSuffix(strings.TrimSpace(line), "(") + "..."
+	}
+	return strings.TrimSpace(line)
+}
+
+func (db *SpannerDatabase) saveOperation(name string) error {
+	if db.operationFile == "" {
+		return nil
+	}
+	if err := os.WriteFile(db.operationFile, []byte(name+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to record operation %s: %v", name, err)
 	}
+	return nil
+}
 
+func (db *SpannerDatabase) loadOperation() (string, error) {
+	if db.operationFile == "" {
+		return "", nil
+	}
+	buf, err := os.ReadFile(db.operationFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read operation file: %v", err)
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+func (db *SpannerDatabase) clearOperation() error {
+	if db.operationFile == "" {
+		return nil
+	}
+	if err := os.Remove(db.operationFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove operation file: %v", err)
+	}
 	return nil
 }
 
 func (db *SpannerDatabase) Close() error {
-	db.client.Close()
+	if db.client != nil {
+		db.client.Close()
+	}
 	return db.adminClient.Close()
 }
 
@@ -108,16 +511,26 @@ type SpannerAdminDatabase struct {
 	databaseID   string
 	databasePath string
 	instancePath string
+	requestTags  []string
+	dialect      databasepb.DatabaseDialect
+	kmsKeyName   string
 }
 
 func NewAdminDatabase(config Config) (*SpannerAdminDatabase, error) {
 	ctx := context.Background()
 
 	// Create admin client for database operations
-	adminClient, err := dbadmin.NewDatabaseAdminClient(ctx)
+	adminClient, err := dbadmin.NewDatabaseAdminClient(ctx, clientOptions(config)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create database admin client: %v", err)
 	}
+	applyAdminRetryPolicy(adminClient, config.Retry)
+
+	dialect, err := parseDialect(config.DatabaseDialect)
+	if err != nil {
+		adminClient.Close()
+		return nil, err
+	}
 
 	databasePath := fmt.Sprintf("projects/%s/instances/%s/databases/%s",
 		config.ProjectID, config.InstanceID, config.DatabaseID)
@@ -131,16 +544,44 @@ func NewAdminDatabase(config Config) (*SpannerAdminDatabase, error) {
 		databaseID:   config.DatabaseID,
 		databasePath: databasePath,
 		instancePath: instancePath,
+		requestTags:  config.RequestTags,
+		dialect:      dialect,
+		kmsKeyName:   config.KMSKeyName,
 	}, nil
 }
 
-func (db *SpannerAdminDatabase) CreateDatabase(ctx context.Context) error {
+// parseDialect converts a dialect name of Config to its enum value
+func parseDialect(name string) (databasepb.DatabaseDialect, error) {
+	if name == "" {
+		return databasepb.DatabaseDialect_GOOGLE_STANDARD_SQL, nil
+	}
+	dialect, ok := databasepb.DatabaseDialect_value[strings.ToUpper(name)]
+	if !ok || dialect == int32(databasepb.DatabaseDialect_DATABASE_DIALECT_UNSPECIFIED) {
+		return 0, fmt.Errorf("unknown database dialect %s: expected GOOGLE_STANDARD_SQL or POSTGRESQL", name)
+	}
+	return databasepb.DatabaseDialect(dialect), nil
+}
+
+// CreateDatabaseRequest builds the request CreateDatabase sends: the
+// database of the config, with its dialect and KMS key
+func (db *SpannerAdminDatabase) CreateDatabaseRequest() *databasepb.CreateDatabaseRequest {
 	req := &databasepb.CreateDatabaseRequest{
 		Parent:          db.instancePath,
 		CreateStatement: fmt.Sprintf("CREATE DATABASE `%s`", db.databaseID),
+		DatabaseDialect: db.dialect,
+	}
+	// PostgreSQL quotes identifiers with double quotes
+	if db.dialect == databasepb.DatabaseDialect_POSTGRESQL {
+		req.CreateStatement = fmt.Sprintf(`CREATE DATABASE "%s"`, db.databaseID)
 	}
+	if db.kmsKeyName != "" {
+		req.EncryptionConfig = &databasepb.EncryptionConfig{KmsKeyName: db.kmsKeyName}
+	}
+	return req
+}
 
-	op, err := db.adminClient.CreateDatabase(ctx, req)
+func (db *SpannerAdminDatabase) CreateDatabase(ctx context.Context) error {
+	op, err := db.adminClient.CreateDatabase(withRequestTags(ctx, db.requestTags), db.CreateDatabaseRequest())
 	if err != nil {
 		return fmt.Errorf("failed to create database: %v", err)
 	}
@@ -154,12 +595,41 @@ func (db *SpannerAdminDatabase) CreateDatabase(ctx context.Context) error {
 	return nil
 }
 
+// DatabaseExists reports whether the database exists
+func (db *SpannerAdminDatabase) DatabaseExists(ctx context.Context) (bool, error) {
+	_, err := db.adminClient.GetDatabase(withRequestTags(ctx, db.requestTags), &databasepb.GetDatabaseRequest{
+		Name: db.databasePath,
+	})
+	if err == nil {
+		return true, nil
+	}
+	if status.Code(err) != codes.NotFound {
+		return false, fmt.Errorf("failed to get database: %v", err)
+	}
+	return false, nil
+}
+
+// CreateDatabaseIfNotExists creates the database unless it already exists,
+// and reports whether it did
+func (db *SpannerAdminDatabase) CreateDatabaseIfNotExists(ctx context.Context) (bool, error) {
+	exists, err := db.DatabaseExists(ctx)
+	if err != nil || exists {
+		return false, err
+	}
+	return true, db.CreateDatabase(ctx)
+}
+
+// DatabasePath returns the full path of the database
+func (db *SpannerAdminDatabase) DatabasePath() string {
+	return db.databasePath
+}
+
 func (db *SpannerAdminDatabase) DropDatabase(ctx context.Context) error {
 	req := &databasepb.DropDatabaseRequest{
 		Database: db.databasePath,
 	}
 
-	err := db.adminClient.DropDatabase(ctx, req)
+	err := db.adminClient.DropDatabase(withRequestTags(ctx, db.requestTags), req)
 	if err != nil {
 		return fmt.Errorf("failed to drop database: %v", err)
 	}
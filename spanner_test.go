@@ -2,8 +2,10 @@ package spannerdef
 
 import (
 	"context"
+	"errors"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -13,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // getTestConfig returns the base Config used by every integration test.
@@ -79,6 +82,106 @@ func databaseExists(t *testing.T, ctx context.Context, projectID, instanceID, da
 	return true
 }
 
+func TestUserAgent(t *testing.T) {
+	assert.Equal(t, "spannerdef", userAgent(Config{}))
+	assert.Equal(t, "spannerdef/v1.0.0", userAgent(Config{UserAgent: "spannerdef/v1.0.0"}))
+	assert.Equal(t, "spannerdef/v1.0.0 (deploy=release-42; team=platform)", userAgent(Config{
+		UserAgent:   "spannerdef/v1.0.0",
+		RequestTags: []string{"deploy=release-42", "team=platform"},
+	}))
+}
+
+func TestSpannerDatabase_ResumeOperation(t *testing.T) {
+	operationFile := filepath.Join(t.TempDir(), "spannerdef.operation")
+	db := &SpannerDatabase{
+		databasePath:  "projects/p/instances/i/databases/d",
+		operationFile: operationFile,
+	}
+
+	// Nothing recorded yet
+	name, err := db.ResumeOperation()
+	require.NoError(t, err)
+	assert.Empty(t, name)
+
+	// Operations of other databases are refused
+	require.NoError(t, db.saveOperation("projects/p/instances/i/databases/other/operations/op1"))
+	_, err = db.ResumeOperation()
+	assert.ErrorContains(t, err, "does not belong to")
+
+	require.NoError(t, db.clearOperation())
+	_, err = os.Stat(operationFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFormatProgress(t *testing.T) {
+	md := &databasepb.UpdateDatabaseDdlMetadata{
+		Statements: []string{
+			"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+			"CREATE TABLE Posts (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+			"CREATE INDEX IdxUsersEmail ON Users (Email)",
+		},
+		Progress: []*databasepb.OperationProgress{
+			{ProgressPercent: 100, EndTime: timestamppb.Now()},
+			{ProgressPercent: 40},
+		},
+	}
+	assert.Equal(t, "-- Progress: 1/3 statements done, CREATE TABLE Posts ... 40% --", formatProgress(md))
+
+	md.Throttled = true
+	md.Progress[1] = &databasepb.OperationProgress{ProgressPercent: 100, EndTime: timestamppb.Now()}
+	assert.Equal(t, "-- Progress: 2/3 statements done, CREATE INDEX IdxUsersEmail ON Users (Email) 0% (throttled) --", formatProgress(md))
+}
+
+func TestRecordProgress(t *testing.T) {
+	md := &databasepb.UpdateDatabaseDdlMetadata{
+		Statements: []string{
+			"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+			"CREATE INDEX IdxUsersEmail ON Users (Email)",
+		},
+		Progress: []*databasepb.OperationProgress{
+			{ProgressPercent: 100, EndTime: timestamppb.Now()},
+			{ProgressPercent: 40},
+		},
+		Throttled: true,
+	}
+	db := &SpannerDatabase{}
+	db.recordProgress(md)
+
+	// Throttling is remembered once the statement is done
+	md.Throttled = false
+	md.Progress[1] = &databasepb.OperationProgress{ProgressPercent: 100, EndTime: timestamppb.Now()}
+	db.recordProgress(md)
+	assert.Equal(t, map[string]StatementProgress{
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)": {Percent: 100},
+		"CREATE INDEX IdxUsersEmail ON Users (Email)":    {Percent: 100, Throttled: true},
+	}, db.StatementProgress())
+}
+
+func TestAttributeError(t *testing.T) {
+	md := &databasepb.UpdateDatabaseDdlMetadata{
+		Statements: []string{
+			"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+			"CREATE UNIQUE INDEX IdxUsersName ON Users (Name)",
+			"CREATE INDEX IdxUsersEmail ON Users (Email)",
+		},
+		CommitTimestamps: []*timestamppb.Timestamp{timestamppb.Now()},
+	}
+	cause := errors.New("DDL operation failed: rpc error: code = FailedPrecondition desc = Found uniqueness violation")
+
+	err := attributeError(md, cause)
+	var stmtErr *StatementError
+	require.ErrorAs(t, err, &stmtErr)
+	assert.Equal(t, "CREATE UNIQUE INDEX IdxUsersName ON Users (Name)", stmtErr.Statement)
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, cause.Error()+`
+failed statement 2 of 3 (the 1 statement(s) before it were applied):
+  CREATE UNIQUE INDEX IdxUsersName ON Users (Name)`, err.Error())
+
+	// Failures after every statement committed can't be attributed
+	md.CommitTimestamps = append(md.CommitTimestamps, timestamppb.Now(), timestamppb.Now())
+	assert.Same(t, cause, attributeError(md, cause))
+}
+
 func TestNewDatabase(t *testing.T) {
 	t.Parallel()
 	config := getTestConfig(t)
@@ -87,6 +190,8 @@ func TestNewDatabase(t *testing.T) {
 	assert.NotNil(t, db)
 	assert.Equal(t, config.ProjectID, db.projectID)
 	assert.Equal(t, config.InstanceID, db.instanceID)
+	// The data client is only created when it is needed
+	assert.Nil(t, db.client)
 }
 
 func TestSpannerDatabase_DumpDDLs(t *testing.T) {
@@ -151,6 +256,61 @@ func TestSpannerDatabase_ExecDDLs(t *testing.T) {
 	assert.Contains(t, resultDDLs, "Posts")
 }
 
+func TestDiagnose(t *testing.T) {
+	t.Parallel()
+	config := getTestConfig(t)
+
+	db, _ := newTestDatabase(t, config)
+	dbConfig := config
+	dbConfig.DatabaseID = db.databaseID
+
+	results := Diagnose(dbConfig)
+	checks := make([]string, 0, len(results))
+	for _, d := range results {
+		assert.NoError(t, d.Err, d.Check)
+		checks = append(checks, d.Check)
+	}
+	assert.Equal(t, []string{"emulator", "connectivity", "dialect", "permissions"}, checks)
+
+	// A missing database is reported as a connectivity failure
+	dbConfig.DatabaseID = "missing-db"
+	results = Diagnose(dbConfig)
+	last := results[len(results)-1]
+	assert.Equal(t, "connectivity", last.Check)
+	assert.ErrorContains(t, last.Err, "does not exist")
+}
+
+func TestSpannerAdminDatabase_CreateDatabaseRequest(t *testing.T) {
+	db := &SpannerAdminDatabase{
+		databaseID:   "my-db",
+		instancePath: "projects/p/instances/i",
+		dialect:      databasepb.DatabaseDialect_GOOGLE_STANDARD_SQL,
+	}
+	req := db.CreateDatabaseRequest()
+	assert.Equal(t, "CREATE DATABASE `my-db`", req.CreateStatement)
+	assert.Nil(t, req.EncryptionConfig)
+
+	db.dialect = databasepb.DatabaseDialect_POSTGRESQL
+	db.kmsKeyName = "projects/p/locations/us/keyRings/r/cryptoKeys/k"
+	req = db.CreateDatabaseRequest()
+	assert.Equal(t, `CREATE DATABASE "my-db"`, req.CreateStatement)
+	assert.Equal(t, databasepb.DatabaseDialect_POSTGRESQL, req.DatabaseDialect)
+	assert.Equal(t, "projects/p/locations/us/keyRings/r/cryptoKeys/k", req.EncryptionConfig.KmsKeyName)
+}
+
+func TestParseDialect(t *testing.T) {
+	dialect, err := parseDialect("")
+	require.NoError(t, err)
+	assert.Equal(t, databasepb.DatabaseDialect_GOOGLE_STANDARD_SQL, dialect)
+
+	dialect, err = parseDialect("postgresql")
+	require.NoError(t, err)
+	assert.Equal(t, databasepb.DatabaseDialect_POSTGRESQL, dialect)
+
+	_, err = parseDialect("MYSQL")
+	assert.EqualError(t, err, "unknown database dialect MYSQL: expected GOOGLE_STANDARD_SQL or POSTGRESQL")
+}
+
 func TestNewAdminDatabase(t *testing.T) {
 	t.Parallel()
 	config := getTestConfig(t)
@@ -249,3 +409,41 @@ func TestSpannerDatabase_RowDeletionPolicyWithInterleave(t *testing.T) {
 	assert.Contains(t, dumpedDDLs, "ROW DELETION POLICY")
 	assert.Contains(t, dumpedDDLs, "OLDER_THAN(event_date, INTERVAL 90 DAY)")
 }
+
+func TestSpannerDatabase_DumpTableDDLs(t *testing.T) {
+	t.Parallel()
+	config := getTestConfig(t)
+
+	db := recreateDatabase(t, config)
+
+	ddls := []string{
+		`CREATE TABLE users (
+			id INT64 NOT NULL,
+			email STRING(255),
+			updated_at TIMESTAMP OPTIONS (allow_commit_timestamp = true),
+			CONSTRAINT chk_email CHECK (email IS NOT NULL)
+		) PRIMARY KEY (id)`,
+		`CREATE UNIQUE INDEX idx_users_email ON users (email) STORING (updated_at)`,
+		`CREATE TABLE posts (
+			id INT64 NOT NULL,
+			user_id INT64 NOT NULL,
+			CONSTRAINT fk_posts_users FOREIGN KEY (user_id) REFERENCES users (id)
+		) PRIMARY KEY (id)`,
+		`CREATE TABLE unrelated (
+			id INT64 NOT NULL
+		) PRIMARY KEY (id)`,
+	}
+	require.NoError(t, execDDLsFast(context.Background(), db.adminClient, db.databasePath, ddls))
+
+	partial, err := db.DumpTableDDLs([]string{"users", "posts"})
+	require.NoError(t, err)
+	assert.NotContains(t, partial, "unrelated")
+
+	// The partial dump must be indistinguishable from a filtered full dump
+	full, err := db.DumpDDLs()
+	require.NoError(t, err)
+	filter := GeneratorConfig{TargetTables: []string{"users", "posts"}}
+	generated, _, err := GenerateIdempotentDDLs(full, partial, filter)
+	require.NoError(t, err)
+	assert.Empty(t, generated)
+}
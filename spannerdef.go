@@ -1,84 +1,777 @@
 package spannerdef
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
 )
 
 type Options struct {
 	DesiredDDLs string
-	DryRun      bool
-	Export      bool
-	EnableDrop  bool
-	Config      GeneratorConfig
+	// DesiredSources are the files DesiredDDLs was joined from, if known
+	DesiredSources []Source
+	DryRun         bool
+	Export         bool
+	// VerifyExport checks that the exported schema round-trips through
+	// spannerdef, see verifyExport
+	VerifyExport bool
+	// ExportDir makes Export write one file per table to the directory
+	// instead of stdout, see exportDir
+	ExportDir  string
+	EnableDrop bool
+	Wait       bool // Only wait for the in-flight DDL operation of a previous run
+	Doctor     bool // Only check that spannerdef can work against the database
+	Sizes      bool // Only print the size report, see BuildSizeReport
+	// Drift lists the environments to compare to the desired schema, see
+	// BuildDriftMatrix
+	Drift []string
+	JSON  bool // Print the plan or the drift matrix as JSON
+	// OptionDrift is the severity of drifted database options, see
+	// DriftMatrix.SetOptionSeverity
+	OptionDrift string
+	// CloneFrom is the database the command takes the desired schema from
+	CloneFrom string
+	// SqldefCompat formats output like mysqldef/psqldef, e.g. dry-run
+	// statements are terminated with semicolons
+	SqldefCompat bool
+	// Catalog dumps column metadata of the current schema in the given
+	// format (csv or jsonl) instead of planning changes
+	Catalog string
+	// Annotate prefixes each statement with a comment explaining why it
+	// was generated
+	Annotate bool
+	// FailOnDestructive exits non-zero without applying anything when the
+	// plan contains destructive statements, even with EnableDrop
+	FailOnDestructive bool
+	// Strict fails instead of warning when the plan may not bring the
+	// database in line with the desired schema: on unsupported statements,
+	// statements the schema model approximates, and changes the generator
+	// can't make
+	Strict bool
+	// ReportTemplate is a Go template file rendered with the Report of the
+	// plan instead of printing the statements
+	ReportTemplate string
+	// Stat prints a one-line summary of the changes instead of the
+	// statements, see summarizeDDLs
+	Stat bool
+	// CheckNotNull counts the NULL values of columns made NOT NULL before
+	// applying, and fails with the counts if there are any
+	CheckNotNull bool
+	// TUI lets the operator review the plan on the terminal and only
+	// applies the statements they approve
+	TUI bool
+	// OverrideFreeze applies changes even while Config.Freeze or the freeze
+	// marker of the metadata table is set
+	OverrideFreeze bool
+	// CreateDatabase asks the command to create the database, see
+	// SpannerAdminDatabase.CreateDatabaseIfNotExists, before Run
+	CreateDatabase bool
+	Config         GeneratorConfig
 }
 
 // Main function shared by spannerdef command
 func Run(db Database, options *Options) {
-	currentDDLs, err := db.DumpDDLs()
-	if err != nil {
-		log.Fatalf("Error on DumpDDLs: %s", err)
+	if options.Catalog != "" {
+		if err := exportCatalog(db, options, os.Stdout); err != nil {
+			log.Fatalf("Error on catalog export: %s", err)
+		}
+		return
+	}
+
+	if !options.Export {
+		if resumer, ok := db.(OperationResumer); ok {
+			name, err := resumer.ResumeOperation()
+			if name != "" {
+				fmt.Printf("-- Resumed operation: %s --\n", name)
+			}
+			if err != nil {
+				log.Fatalf("Error on ResumeOperation: %s", err)
+			}
+		}
+	}
+
+	if options.Wait {
+		return
+	}
+
+	if options.Export && options.Config.Schema != "" {
+		if err := exportSchema(db, options, os.Stdout); err != nil {
+			log.Fatalf("Error on DumpDDLs: %s", err)
+		}
+		return
 	}
 
 	if options.Export {
-		if currentDDLs == "" {
-			fmt.Printf("-- No schema exists --\n")
-		} else {
-			fmt.Print(currentDDLs)
+		var exported strings.Builder
+		out := io.Writer(os.Stdout)
+		if options.VerifyExport {
+			out = io.MultiWriter(os.Stdout, &exported)
+		}
+		if options.ExportDir != "" {
+			ddls, err := exportDir(db, options.ExportDir)
+			if err != nil {
+				log.Fatalf("Error on DumpDDLs: %s", err)
+			}
+			exported.WriteString(ddls)
+		} else if err := export(db, out); err != nil {
+			log.Fatalf("Error on DumpDDLs: %s", err)
+		}
+		if options.VerifyExport {
+			if err := verifyExport(exported.String()); err != nil {
+				log.Fatal(err)
+			}
 		}
 		return
 	}
 
-	ddls, err := GenerateIdempotentDDLs(options.DesiredDDLs, currentDDLs, options.Config)
+	// render, if set, prints the plan as a report instead of statements
+	var render func(*Report) ([]byte, error)
+	if options.ReportTemplate != "" {
+		tmpl, err := parseReportTemplate(options.ReportTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		render = func(report *Report) ([]byte, error) { return renderReport(tmpl, report) }
+	} else if options.JSON {
+		render = renderReportJSON
+	}
+
+	currentDDLs, err := dumpCurrentDDLs(db, options)
+	if err != nil {
+		log.Fatalf("Error on DumpDDLs: %s", err)
+	}
+
+	currentSchema, desiredSchema, err := parseSchemas(options.DesiredDDLs, currentDDLs, options.Config)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	// Database options are set on the database applied to, whatever the
+	// desired ALTER DATABASE names
+	if currentSchema.DatabaseName == "" {
+		currentSchema.DatabaseName = databaseID(databasePath(db))
+	}
+	ddls, warnings, err := planDDLsCached(options.DesiredDDLs, currentDDLs, currentSchema, desiredSchema, options.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := reportWarnings(os.Stderr, warnings, options.DesiredSources, options.Strict); err != nil {
+		log.Fatal(err)
+	}
 
 	if len(ddls) == 0 {
-		fmt.Println("-- Nothing is modified --")
+		if render != nil {
+			printReport(render, newReport(databasePath(db), options.DryRun, nil, nil, warnings, currentSchema, false, nil))
+		} else if options.Stat {
+			fmt.Println(summarizeDDLs(nil, currentSchema))
+		} else {
+			fmt.Println("-- Nothing is modified --")
+		}
 		return
 	}
 
+	// A frozen schema refuses to apply before anything is reviewed or
+	// checked
+	if !options.DryRun && !options.OverrideFreeze {
+		reason, err := freezeReason(db, options.Config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if reason != "" {
+			log.Fatalf("Schema changes are frozen (%s). Use --override-freeze to apply anyway.", reason)
+		}
+	}
+
+	// Quotas are advisory: counting failures don't block the apply, and
+	// --strict is about fidelity to the desired schema
+	if counter, ok := db.(ObjectCounter); ok {
+		counts, err := counter.CountObjects()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: failed to check Spanner limits: %s\n", err)
+		} else {
+			quota := quotaWarnings(counts, desiredSchema, ddls)
+			reportWarnings(os.Stderr, quota, nil, false)
+			warnings = append(warnings, quota...)
+		}
+	}
+
+	// Table sizes cost a query, so they are only read if needed
+	sizes := sync.OnceValue(func() map[string]int64 { return tableSizes(db) })
+
+	// Like quotas, the instance configuration is advisory
+	var instance *InstanceInfo
+	if describer, ok := db.(InstanceDescriber); ok {
+		info, err := describer.DescribeInstance()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: failed to describe the instance: %s\n", err)
+		} else {
+			instance = info
+			if info.MultiRegion() {
+				replication := replicationWarnings(info, ddls, currentSchema, sizes())
+				reportWarnings(os.Stderr, replication, nil, false)
+				warnings = append(warnings, replication...)
+			}
+		}
+	}
+
+	if destructive := destructiveDDLs(ddls, currentSchema); options.FailOnDestructive && len(destructive) > 0 {
+		for _, ddl := range destructive {
+			fmt.Printf("-- Destructive: %s;\n", ddl)
+		}
+		log.Fatalf("Refusing to continue with %d destructive statement(s) (--fail-on-destructive)", len(destructive))
+	}
+
+	var reasons []string
+	if options.Annotate || render != nil {
+		reasons = explainDDLs(ddls, currentSchema, desiredSchema, options.DesiredSources)
+	}
+
+	planned := ddls
+	enableDrop := options.EnableDrop
+	if options.TUI {
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			log.Fatalf("--tui requires a terminal: %s", err)
+		}
+		defer tty.Close()
+
+		ddls, reasons, err = review(ddls, reasons, currentSchema, options.EnableDrop, tty, tty)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(ddls) == 0 {
+			fmt.Println("-- Nothing is approved --")
+			return
+		}
+		// Destructive statements left selected were approved one by one
+		enableDrop = true
+	}
+
+	if err := checkSkippedRecreates(ddls, skippedDDLs(ddls, currentSchema, enableDrop)); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkSkippedDependencies(ddls, skippedDDLs(ddls, currentSchema, enableDrop), currentSchema); err != nil {
+		log.Fatal(err)
+	}
+	if options.CheckNotNull {
+		if err := checkNotNull(db, ddls, currentSchema, enableDrop); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var planReport *Report
+	var report []byte
+	if render != nil {
+		// Rendered before applying, so that a broken template doesn't
+		// leave an apply unreported
+		planReport = newReport(databasePath(db), options.DryRun, ddls, reasons, warnings, currentSchema, enableDrop, sizes())
+		planReport.locate(currentSchema, desiredSchema, options.DesiredSources)
+		report, err = render(planReport)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if options.DryRun {
-		showDDLs(ddls, options.EnableDrop)
+		if render != nil {
+			os.Stdout.Write(report)
+		} else if options.Stat {
+			fmt.Println(summarizeDDLs(appliedDDLs(ddls, currentSchema, enableDrop), currentSchema))
+		} else {
+			showDDLs(ddls, reasons, currentSchema, enableDrop, options.SqldefCompat)
+		}
 		return
 	}
 
-	err = RunDDLs(db, ddls, options.EnableDrop, false)
+	quiet := options.Stat || render != nil
+	if instance != nil && !quiet {
+		fmt.Println(instanceHeader(instance))
+	}
+	err = runDDLs(db, ddls, reasons, currentSchema, enableDrop, quiet)
+	if err != nil {
+		if planReport != nil {
+			planReport.record(db)
+			planReport.fail(err)
+			printReport(render, planReport)
+		}
+		log.Fatal(err)
+	}
+
+	// Statements that were planned but deliberately not applied are
+	// expected to still be pending
+	skipped := make(map[string]bool)
+	for _, ddl := range planned {
+		skipped[ddl] = true
+	}
+	notApplied := skippedDDLs(ddls, currentSchema, enableDrop)
+	for i, ddl := range ddls {
+		if !notApplied[i] {
+			delete(skipped, ddl)
+		}
+	}
+	if err := verifyApply(db, options, skipped); err != nil {
+		log.Fatal(err)
+	}
+	if render != nil {
+		planReport.record(db)
+		printReport(render, planReport)
+	} else if options.Stat {
+		fmt.Println(summarizeDDLs(appliedDDLs(ddls, currentSchema, enableDrop), currentSchema))
+	}
+}
+
+// tableSizes returns the table sizes of db, or nil if they are unknown,
+// in which case statements are estimated conservatively
+func tableSizes(db Database) map[string]int64 {
+	sizer, ok := db.(TableSizer)
+	if !ok {
+		return nil
+	}
+	sizes, err := sizer.TableSizes()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to estimate durations: %s\n", err)
+		return nil
+	}
+	return sizes
+}
+
+// printReport renders report with render to stdout
+func printReport(render func(*Report) ([]byte, error), report *Report) {
+	out, err := render(report)
 	if err != nil {
 		log.Fatal(err)
 	}
+	os.Stdout.Write(out)
 }
 
-// GenerateIdempotentDDLs generates DDLs to transform current schema to desired schema
-func GenerateIdempotentDDLs(desiredDDLs, currentDDLs string, config GeneratorConfig) ([]string, error) {
-	currentSchema, err := ParseDDLs(currentDDLs)
+// databasePath returns the path of db, if it has one
+func databasePath(db Database) string {
+	if d, ok := db.(interface{ DatabasePath() string }); ok {
+		return d.DatabasePath()
+	}
+	return ""
+}
+
+// verifyApply re-plans after an apply and fails if anything other than the
+// skipped statements is still pending, which means that what Spanner
+// applied doesn't round-trip through the parser and generator.
+func verifyApply(db Database, options *Options, skipped map[string]bool) error {
+	currentDDLs, err := dumpCurrentDDLs(db, options)
+	if err != nil {
+		return fmt.Errorf("failed to verify apply: %v", err)
+	}
+	currentSchema, desiredSchema, err := parseSchemas(options.DesiredDDLs, currentDDLs, options.Config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse current DDLs: %v", err)
+		return fmt.Errorf("failed to verify apply: %v", err)
 	}
 
-	desiredSchema, err := ParseDDLs(desiredDDLs)
+	var pending []string
+	for _, ddl := range generateDDLs(currentSchema, desiredSchema, options.Config) {
+		if !skipped[ddl] {
+			pending = append(pending, ddl)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	fmt.Println("-- Pending after apply --")
+	for _, ddl := range pending {
+		fmt.Printf("%s;\n", ddl)
+	}
+	return fmt.Errorf("%d statement(s) still pending after apply; this is likely a bug in spannerdef", len(pending))
+}
+
+// export writes the current schema to w. Databases implementing DDLExporter
+// write it one statement at a time rather than building the whole dump
+// first, which halves the memory used on very large schemas; the statements
+// themselves are still read at once.
+func export(db Database, w io.Writer) error {
+	if exporter, ok := db.(DDLExporter); ok {
+		n, err := exporter.ExportDDLs(w)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			_, err = fmt.Fprintf(w, "-- No schema exists --\n")
+		}
+		return err
+	}
+
+	currentDDLs, err := db.DumpDDLs()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse desired DDLs: %v", err)
+		return err
 	}
+	if currentDDLs == "" {
+		_, err = fmt.Fprintf(w, "-- No schema exists --\n")
+	} else {
+		_, err = fmt.Fprint(w, currentDDLs)
+	}
+	return err
+}
 
-	// Apply filters based on config
-	currentSchema = filterSchema(currentSchema, config)
-	desiredSchema = filterSchema(desiredSchema, config)
+// exportDir writes the current schema to dir, which must exist, one file
+// per table, such as Users.sql, with the statements on the table, e.g. its
+// indexes, and _schema.sql with the other statements, e.g. views. Files are
+// written concurrently. It returns the schema as dumped.
+func exportDir(db Database, dir string) (string, error) {
+	currentDDLs, err := db.DumpDDLs()
+	if err != nil {
+		return "", err
+	}
+	files, err := splitByTable(currentDDLs)
+	if err != nil {
+		return "", err
+	}
+
+	names := sortedKeys(files)
+	errs := make([]error, len(names))
+	sem := make(chan struct{}, DefaultDumpConcurrency)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			content := strings.Join(files[name], ";\n\n") + ";\n"
+			errs[i] = os.WriteFile(filepath.Join(dir, name+".sql"), []byte(content), 0o644)
+		}()
+	}
+	wg.Wait()
+
+	return currentDDLs, errors.Join(errs...)
+}
+
+// splitByTable groups the statements of ddls by the table they create or
+// are on, keeping their order, and the statements on no table under
+// "_schema"
+func splitByTable(ddls string) (map[string][]string, error) {
+	raws, err := memefish.SplitRawStatements("", ddls)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]string)
+	for _, raw := range raws {
+		stmt := strings.TrimSpace(raw.Statement)
+		if stmt == "" {
+			continue
+		}
+		name := "_schema"
+		if ddl, err := memefish.ParseDDL("", stmt); err == nil {
+			switch s := ddl.(type) {
+			case *ast.CreateTable:
+				name = getPathName(s.Name)
+			case *ast.AlterTable:
+				name = getPathName(s.Name)
+			case *ast.CreateIndex:
+				name = getPathName(s.TableName)
+			case *ast.CreateVectorIndex:
+				name = s.TableName.Name
+			case *ast.CreateSearchIndex:
+				name = s.TableName.Name
+			}
+		}
+		files[name] = append(files[name], stmt)
+	}
+	return files, nil
+}
+
+// verifyExport checks that ddls, as exported, survive spannerdef's
+// pipeline: that every CREATE TABLE and CREATE INDEX is generated back the
+// same, with its OPTIONS, row deletion policy and interleave clause, and
+// that the whole schema parses back the same once generated. It returns an
+// error listing the statements and objects that would be lost.
+func verifyExport(ddls string) error {
+	schema, err := ParseDDLs(ddls)
+	if err != nil {
+		return fmt.Errorf("failed to parse exported DDLs: %v", err)
+	}
+
+	var errs []error
+	lossy, err := lossyStatements(ddls, schema)
+	if err != nil {
+		return fmt.Errorf("failed to check exported DDLs: %v", err)
+	}
+	for _, stmt := range lossy {
+		errs = append(errs, fmt.Errorf("statement isn't generated back the same: %s", stmt.SQL))
+	}
+
+	regenerated, err := ParseDDLs(schemaDDLs(schema))
+	if err != nil {
+		return fmt.Errorf("failed to parse generated DDLs: %v", err)
+	}
+	drift := diffObjects(schema, regenerated)
+	for _, object := range sortedKeys(drift) {
+		errs = append(errs, fmt.Errorf("%s isn't generated back the same: %s", object, drift[object]))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("exported schema doesn't round-trip through spannerdef:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// exportSchema writes the statements creating the objects of the named
+// schema of options.Config to w. Unlike export, the statements are
+// generated from the parsed schema, so statements spannerdef doesn't
+// manage are left out.
+func exportSchema(db Database, options *Options, w io.Writer) error {
+	currentDDLs, err := db.DumpDDLs()
+	if err != nil {
+		return err
+	}
+	schema, err := parseSchema(currentDDLs, options.Config)
+	if err != nil {
+		return fmt.Errorf("failed to parse current DDLs: %v", err)
+	}
+
+	ddls := schemaDDLs(filterSchema(schema, options.Config))
+	if ddls == "" {
+		_, err = fmt.Fprintf(w, "-- No objects in schema %s --\n", options.Config.Schema)
+	} else {
+		_, err = fmt.Fprint(w, ddls)
+	}
+	return err
+}
+
+// destructiveDDLs returns the destructive statements of ddls
+func destructiveDDLs(ddls []string, current *Schema) []string {
+	var destructive []string
+	for _, ddl := range ddls {
+		if isDestructive(ddl, current) {
+			destructive = append(destructive, ddl)
+		}
+	}
+	return destructive
+}
+
+// exportCatalog writes the column metadata of the current schema to w,
+// honoring the target/skip table filters of the config. Owners are taken
+// from the desired DDLs, if any.
+func exportCatalog(db Database, options *Options, w io.Writer) error {
+	currentDDLs, err := dumpCurrentDDLs(db, options)
+	if err != nil {
+		return err
+	}
+	schema, err := parseSchema(currentDDLs, options.Config)
+	if err != nil {
+		return fmt.Errorf("failed to parse current DDLs: %v", err)
+	}
+	if options.DesiredDDLs != "" {
+		desired, err := parseSchema(options.DesiredDDLs, options.Config)
+		if err != nil {
+			return fmt.Errorf("failed to parse desired DDLs: %v", err)
+		}
+		copyOwners(schema, desired)
+	}
+	return WriteCatalog(w, filterSchema(schema, options.Config), options.Catalog)
+}
+
+// dumpCurrentDDLs fetches the current schema. When the config targets
+// specific tables and the database supports it, only those tables are
+// introspected instead of dumping the entire schema, see
+// introspectedTables. Introspection doesn't reconstruct vector indexes, so
+// the schema is dumped entirely when the desired one has some on the
+// targeted tables.
+func dumpCurrentDDLs(db Database, options *Options) (string, error) {
+	if tables, ok := introspectedTables(options.Config); ok && !hasVectorIndexes(options.DesiredDDLs, options.Config) {
+		if dumper, ok := db.(TableDumper); ok {
+			if len(tables) == 0 {
+				return "", nil
+			}
+			return dumper.DumpTableDDLs(tables)
+		}
+	}
+	return db.DumpDDLs()
+}
+
+// hasVectorIndexes reports whether ddls have vector indexes on the tables
+// config includes. DDLs that don't parse are left for the plan to report.
+func hasVectorIndexes(ddls string, config GeneratorConfig) bool {
+	schema, err := parseSchema(ddls, config)
+	if err != nil {
+		return false
+	}
+	return len(filterSchema(schema, config).VectorIndexes) > 0
+}
+
+// introspectedTables returns the tables of config to introspect instead of
+// dumping the entire schema: the target tables that aren't skipped. It
+// reports false when the config doesn't target tables, or targets them in
+// a named schema, which introspection doesn't cover. Skip lists alone leave
+// everything else in scope, including objects introspection doesn't
+// reconstruct, such as roles, so they need the entire schema.
+func introspectedTables(config GeneratorConfig) ([]string, bool) {
+	qualified := slices.ContainsFunc(config.TargetTables, func(table string) bool {
+		return strings.Contains(table, ".")
+	})
+	if len(config.TargetTables) == 0 || config.Schema != "" || qualified {
+		return nil, false
+	}
+
+	tables := []string{}
+	for _, table := range config.TargetTables {
+		if !slices.Contains(config.SkipTables, table) {
+			tables = append(tables, table)
+		}
+	}
+	return tables, true
+}
+
+// GenerateIdempotentDDLs generates DDLs to transform current schema to
+// desired schema. The warnings list what the DDLs leave out, such as
+// ignored statements, for callers to surface in their own way.
+func GenerateIdempotentDDLs(desiredDDLs, currentDDLs string, config GeneratorConfig) ([]string, []Warning, error) {
+	currentSchema, desiredSchema, err := parseSchemas(desiredDDLs, currentDDLs, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return planDDLsCached(desiredDDLs, currentDDLs, currentSchema, desiredSchema, config)
+}
+
+// planDDLs generates and validates the DDLs from current to desired, which
+// was parsed from desiredDDLs, along with the warnings about them
+func planDDLs(desiredDDLs string, current, desired *Schema, config GeneratorConfig) ([]string, []Warning, error) {
+	// Tables are checked under their new names
+	_, renamed := generateRenameTableDDLs(current, desired)
+	recreated := make(map[string]bool)
+	if config.RecreatePrimaryKeys {
+		recreated = recreatedTables(renamed, desired)
+	} else if err := checkPrimaryKeys(renamed, desired); err != nil {
+		return nil, nil, err
+	}
+	if !config.RecreateGeneratedColumns {
+		if err := checkGeneratedColumns(renamed, desired); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := checkInterleaving(renamed, desired, recreated); err != nil {
+		return nil, nil, err
+	}
+	if err := checkSequenceReferences(desired, config); err != nil {
+		return nil, nil, err
+	}
+	ddls := generateDDLs(current, desired, config)
+	if err := validateDDLs(ddls); err != nil {
+		return nil, nil, err
+	}
+	if err := checkNullability(ddls, renamed); err != nil {
+		return nil, nil, err
+	}
+	if err := checkColumnTypes(ddls, renamed); err != nil {
+		return nil, nil, err
+	}
+	if config.Lenient {
+		// As parsed by parseSchema
+		desiredDDLs, _, _ = maskUnparsed(desiredDDLs)
+	}
+	warnings, err := planWarnings(desiredDDLs, current, desired, ddls, config.EquivalentDefaults)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ddls, warnings, nil
+}
+
+// generateDDLs is GenerateDDLs with the equivalent defaults, recreated
+// tables and generated columns and statement options of config applied
+func generateDDLs(current, desired *Schema, config GeneratorConfig) []string {
+	desired = equateDefaults(current, desired, config.EquivalentDefaults)
+	// Tables are renamed first, so they are recreated under their new names
+	recreated, current := generateRenameTableDDLs(current, desired)
+	if config.RecreatePrimaryKeys {
+		var tables []string
+		tables, current = recreatePrimaryKeys(current, desired)
+		recreated = append(recreated, tables...)
+	}
+	if config.RecreateGeneratedColumns {
+		var columns []string
+		columns, current = recreateGeneratedColumns(current, desired)
+		recreated = append(recreated, columns...)
+	}
+	ddls := append(recreated, GenerateDDLs(current, desired)...)
+	if config.IfNotExists {
+		ddls = addIfNotExists(ddls)
+	}
+	if config.QualifiedNames {
+		ddls = quoteIdentifiers(ddls)
+	}
+	return ddls
+}
+
+// validateDDLs parses every generated statement again, so that a generator
+// bug surfaces as a clear error naming the statement rather than an opaque
+// failure of the Spanner API
+func validateDDLs(ddls []string) error {
+	for _, ddl := range ddls {
+		if _, err := memefish.ParseDDL("", ddl); err != nil {
+			return fmt.Errorf("generated invalid SQL, which is a bug in spannerdef: %v\n%s", err, ddl)
+		}
+	}
+	return nil
+}
+
+// parseSchemas parses the current and desired DDLs and applies the table
+// filters of config
+func parseSchemas(desiredDDLs, currentDDLs string, config GeneratorConfig) (*Schema, *Schema, error) {
+	currentSchema, err := parseSchema(currentDDLs, config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse current DDLs: %v", err)
+	}
 
-	ddls := GenerateDDLs(currentSchema, desiredSchema)
-	return ddls, nil
+	desiredSchema, err := parseSchema(desiredDDLs, config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse desired DDLs: %v", err)
+	}
+
+	// Apply filters based on config
+	current, desired := filterSchema(currentSchema, config), filterSchema(desiredSchema, config)
+	current.Dropped = desired.Dropped
+	return current, desired, nil
 }
 
 // filterSchema applies target/skip table filters
 func filterSchema(s *Schema, config GeneratorConfig) *Schema {
 	filtered := &Schema{
-		Tables:  make(map[string]*Table),
-		Indexes: make(map[string]*Index),
+		Tables:        make(map[string]*Table),
+		Indexes:       make(map[string]*Index),
+		VectorIndexes: make(map[string]*VectorIndex),
+		Views:         make(map[string]*View),
+		ChangeStreams: make(map[string]*ChangeStream),
+		Sequences:     make(map[string]*Sequence),
+		NamedSchemas:  make(map[string]*NamedSchema),
+		Roles:         make(map[string]*Role),
+		Unsupported:   s.Unsupported,
+		Unparsed:      s.Unparsed,
+		Dropped:       s.Dropped,
+
+		Placements:      s.Placements,
+		DatabaseName:    s.DatabaseName,
+		DatabaseOptions: s.DatabaseOptions,
+	}
+
+	// Introspecting tables leaves the database options and placements out,
+	// so they aren't compared then
+	if _, partial := introspectedTables(config); partial {
+		filtered.DatabaseOptions = nil
+		filtered.Placements = nil
 	}
 
 	// Filter tables
@@ -90,16 +783,66 @@ func filterSchema(s *Schema, config GeneratorConfig) *Schema {
 
 	// Filter indexes (only include if their table is included)
 	for name, index := range s.Indexes {
-		if shouldIncludeTable(index.TableName, config) {
+		if shouldIncludeTable(index.TableName, config) && shouldIncludeIndex(name, config) {
 			filtered.Indexes[name] = index
 		}
 	}
+	for name, index := range s.VectorIndexes {
+		if shouldIncludeTable(index.TableName, config) && shouldIncludeIndex(name, config) {
+			filtered.VectorIndexes[name] = index
+		}
+	}
+
+	// Filter views, change streams and sequences by name, like tables
+	for name, view := range s.Views {
+		if shouldIncludeTable(name, config) {
+			filtered.Views[name] = view
+		}
+	}
+	for name, stream := range s.ChangeStreams {
+		if shouldIncludeTable(name, config) {
+			filtered.ChangeStreams[name] = stream
+		}
+	}
+	for name, sequence := range s.Sequences {
+		if shouldIncludeTable(name, config) {
+			filtered.Sequences[name] = sequence
+		}
+	}
+
+	// A named schema is kept with the objects in it
+	for name, namedSchema := range s.NamedSchemas {
+		if config.Schema == name || config.Schema == "" && shouldIncludeTable(name, config) {
+			filtered.NamedSchemas[name] = namedSchema
+		}
+	}
+
+	// Roles and the proto bundle belong to the database rather than to a
+	// named schema. Introspecting tables leaves the proto bundle out, so it
+	// isn't compared then.
+	if config.Schema == "" {
+		for name, role := range s.Roles {
+			if shouldIncludeTable(name, config) {
+				filtered.Roles[name] = role
+			}
+		}
+		if _, partial := introspectedTables(config); !partial {
+			filtered.ProtoBundle = s.ProtoBundle
+		}
+	}
 
 	return filtered
 }
 
 // shouldIncludeTable checks if a table should be included based on config
 func shouldIncludeTable(tableName string, config GeneratorConfig) bool {
+	if tableName == metadataTable {
+		return false
+	}
+	if config.Schema != "" && !strings.HasPrefix(tableName, config.Schema+".") {
+		return false
+	}
+
 	// Check skip tables
 	for _, skip := range config.SkipTables {
 		if tableName == skip {
@@ -120,6 +863,27 @@ func shouldIncludeTable(tableName string, config GeneratorConfig) bool {
 	return true
 }
 
+// shouldIncludeIndex checks if an index should be included based on the
+// index patterns of config
+func shouldIncludeIndex(indexName string, config GeneratorConfig) bool {
+	for _, skip := range config.SkipIndexes {
+		if matched, _ := path.Match(skip, indexName); matched {
+			return false
+		}
+	}
+
+	if len(config.TargetIndexes) > 0 {
+		for _, target := range config.TargetIndexes {
+			if matched, _ := path.Match(target, indexName); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
 func ParseFiles(files []string) []string {
 	if len(files) == 0 {
 		panic("ParseFiles got empty files")
@@ -136,18 +900,134 @@ func ParseFiles(files []string) []string {
 }
 
 func ReadFiles(filepaths []string) (string, error) {
-	var result strings.Builder
+	sources, err := ReadSources(filepaths)
+	if err != nil {
+		return "", err
+	}
+	return JoinSources(sources), nil
+}
+
+// Source is a desired schema file. Run uses the sources to map definitions
+// in the joined DDLs back to file paths and line numbers.
+type Source struct {
+	Path       string
+	DDLs       string
+	LineOffset int // lines of the file preceding DDLs, if it is a fragment
+}
+
+// ReadSources reads the given files, "-" being stdin. Stdin may be mixed
+// with other files and is read only once even if given more than once.
+func ReadSources(filepaths []string) ([]Source, error) {
+	filepaths, err := expandFiles(filepaths)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]Source, 0, len(filepaths))
+	stdinRead := false
 	for _, filepath := range filepaths {
+		if filepath == "-" {
+			if stdinRead {
+				continue
+			}
+			stdinRead = true
+		}
 		f, err := ReadFile(filepath)
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+		path := filepath
+		if path == "-" {
+			path = "stdin"
 		}
-		_, err = result.WriteString(f)
+		sources = append(sources, Source{Path: path, DDLs: f})
+	}
+	return sources, nil
+}
+
+// expandFiles expands glob patterns in filepaths and checks that every file
+// can be read, so that all bad paths are reported at once rather than one
+// run at a time
+func expandFiles(filepaths []string) ([]string, error) {
+	var files, problems []string
+	for _, path := range filepaths {
+		if path == "-" || !strings.ContainsAny(path, "*?[") {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(path)
 		if err != nil {
-			return "", err
+			problems = append(problems, fmt.Sprintf("%s: %v", absPath(path), err))
+		} else if len(matches) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: pattern matches no files", absPath(path)))
+		}
+		files = append(files, matches...)
+	}
+
+	for _, path := range files {
+		if path == "-" {
+			continue
+		}
+		if err := checkReadable(path); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", absPath(path), err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%d invalid file(s):\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	return files, nil
+}
+
+// checkReadable returns why path can't be read as a file, if it can't
+func checkReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return pathErr.Err
+		}
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return fmt.Errorf("is a directory")
+	}
+	return nil
+}
+
+// absPath returns the absolute path of path, or path itself if it can't
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// JoinSources concatenates the DDLs of sources
+func JoinSources(sources []Source) string {
+	var result strings.Builder
+	for _, source := range sources {
+		result.WriteString(source.DDLs)
+	}
+	return result.String()
+}
+
+// locate returns "path:line" of the byte offset pos in the DDLs joined from
+// sources, or "" if it is out of range
+func locate(sources []Source, pos int) string {
+	for _, source := range sources {
+		if pos < len(source.DDLs) {
+			return fmt.Sprintf("%s:%d", source.Path, source.LineOffset+strings.Count(source.DDLs[:pos], "\n")+1)
 		}
+		pos -= len(source.DDLs)
 	}
-	return result.String(), nil
+	return ""
 }
 
 func ReadFile(filepath string) (string, error) {
@@ -155,9 +1035,8 @@ func ReadFile(filepath string) (string, error) {
 	var buf []byte
 
 	if filepath == "-" {
-		stat, _ := os.Stdin.Stat()
-		if (stat.Mode() & os.ModeCharDevice) != 0 {
-			return "", fmt.Errorf("stdin is not piped")
+		if !isPiped(os.Stdin) {
+			return "", fmt.Errorf("stdin is not piped; pipe the desired schema or pass it with --file")
 		}
 
 		buf, err = io.ReadAll(os.Stdin)
@@ -168,16 +1047,72 @@ func ReadFile(filepath string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return string(buf), nil
+	return decompress(buf)
 }
 
-func showDDLs(ddls []string, enableDropTable bool) {
-	fmt.Println("-- dry run --")
-	for _, ddl := range ddls {
-		if !enableDropTable && strings.Contains(ddl, "DROP TABLE") {
-			fmt.Printf("-- Skipped: %s\n", ddl)
-			continue
+// isPiped reports whether f is a pipe, a socket or a redirected file rather
+// than a terminal. A closed stdin, as left by some process supervisors, is
+// not piped either.
+func isPiped(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice == 0
+}
+
+// decompress transparently decompresses gzip files and zip archives, which
+// are recognized by their magic numbers so that compressed stdin works as
+// well. The .sql files of a zip archive are concatenated in name order.
+func decompress(buf []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(buf, []byte{0x1f, 0x8b}):
+		r, err := gzip.NewReader(bytes.NewReader(buf))
+		if err != nil {
+			return "", fmt.Errorf("failed to read gzip: %v", err)
+		}
+		defer r.Close()
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return "", fmt.Errorf("failed to read gzip: %v", err)
+		}
+		return string(out), nil
+	case bytes.HasPrefix(buf, []byte("PK\x03\x04")):
+		r, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
+		if err != nil {
+			return "", fmt.Errorf("failed to read zip: %v", err)
 		}
-		fmt.Printf("%s\n", ddl)
+		files := slices.Clone(r.File)
+		sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+		var result strings.Builder
+		for _, f := range files {
+			if f.FileInfo().IsDir() || !strings.EqualFold(path.Ext(f.Name), ".sql") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return "", fmt.Errorf("failed to read %s in zip: %v", f.Name, err)
+			}
+			_, err = io.Copy(&result, rc)
+			rc.Close()
+			if err != nil {
+				return "", fmt.Errorf("failed to read %s in zip: %v", f.Name, err)
+			}
+			result.WriteString("\n")
+		}
+		return result.String(), nil
+	default:
+		return string(buf), nil
 	}
 }
+
+func showDDLs(ddls []string, reasons []string, current *Schema, enableDrop bool, sqldefCompat bool) {
+	terminator := ""
+	if sqldefCompat {
+		terminator = ";"
+	}
+
+	fmt.Println("-- dry run --")
+	printPlan(ddls, reasons, skippedDDLs(ddls, current, enableDrop), terminator)
+}
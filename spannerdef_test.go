@@ -1,7 +1,12 @@
 package spannerdef
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -36,7 +41,7 @@ func applySchema(t *testing.T, db *SpannerDatabase, schema string, enableDrop bo
 	currentDDLs, err := db.DumpDDLs()
 	require.NoError(t, err)
 
-	ddls, err := GenerateIdempotentDDLs(schema, currentDDLs, GeneratorConfig{})
+	ddls, _, err := GenerateIdempotentDDLs(schema, currentDDLs, GeneratorConfig{})
 	require.NoError(t, err)
 
 	if len(ddls) == 0 {
@@ -839,7 +844,7 @@ func TestEdgeCases(t *testing.T) {
 		currentDDLs, err := db.DumpDDLs()
 		require.NoError(t, err)
 
-		ddls, err := GenerateIdempotentDDLs(updatedSchema, currentDDLs, GeneratorConfig{})
+		ddls, _, err := GenerateIdempotentDDLs(updatedSchema, currentDDLs, GeneratorConfig{})
 		require.NoError(t, err)
 
 		// DDLs should be generated but DROP COLUMN should be skipped when executed
@@ -975,7 +980,382 @@ func TestExport(t *testing.T) {
 		require.NoError(t, err)
 		assert.Contains(t, currentDDLs, "CREATE TABLE Users")
 		assert.Contains(t, currentDDLs, "CREATE INDEX IdxUsersName")
+
+		// Streaming export must produce exactly the same output
+		var buf strings.Builder
+		n, err := db.ExportDDLs(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, currentDDLs, buf.String())
+	})
+}
+
+func TestExportWriter(t *testing.T) {
+	var buf strings.Builder
+	require.NoError(t, export(&fakeDatabase{}, &buf))
+	assert.Equal(t, "-- No schema exists --\n", buf.String())
+
+	buf.Reset()
+	ddls := "CREATE TABLE Users (\n  Id INT64 NOT NULL,\n) PRIMARY KEY(Id);"
+	require.NoError(t, export(&fakeDatabase{ddls: ddls}, &buf))
+	assert.Equal(t, ddls, buf.String())
+}
+
+func TestVerifyExport(t *testing.T) {
+	require.NoError(t, verifyExport("-- No schema exists --\n"))
+	require.NoError(t, verifyExport(`CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  CreatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp = true),
+) PRIMARY KEY (Id), ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 30 DAY));
+CREATE TABLE Posts (
+  Id INT64 NOT NULL,
+  PostId INT64 NOT NULL,
+) PRIMARY KEY (Id, PostId),
+  INTERLEAVE IN PARENT Users ON DELETE CASCADE;
+CREATE INDEX IdxPostsPostId ON Posts (PostId) STORING (Id);
+ALTER TABLE Posts ADD CONSTRAINT FK_PostsUsers FOREIGN KEY (Id) REFERENCES Users (Id);
+CREATE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users;`))
+
+	// The schema model doesn't keep the table an index is interleaved in
+	err := verifyExport(`CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Posts (Id INT64 NOT NULL, PostId INT64 NOT NULL) PRIMARY KEY (Id, PostId DESC),
+  INTERLEAVE IN PARENT Users ON DELETE CASCADE;
+CREATE INDEX IdxPostsPostId ON Posts (Id, PostId DESC), INTERLEAVE IN Users;`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "statement isn't generated back the same: CREATE INDEX IdxPostsPostId ON Posts (Id, PostId DESC), INTERLEAVE IN Users")
+}
+
+func TestReadFileCompressed(t *testing.T) {
+	dir := t.TempDir()
+	users := "CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);"
+	logs := "CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);"
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err := w.Write([]byte(users))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "schema.sql.gz"), gz.Bytes(), 0o644))
+
+	got, err := ReadFile(filepath.Join(dir, "schema.sql.gz"))
+	require.NoError(t, err)
+	assert.Equal(t, users, got)
+
+	// .sql entries of a zip archive are concatenated in name order
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range map[string]string{"2_logs.sql": logs, "1_users.sql": users, "README.md": "# schema"} {
+		f, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "schema.zip"), archive.Bytes(), 0o644))
+
+	got, err = ReadFile(filepath.Join(dir, "schema.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, users+"\n"+logs+"\n", got)
+}
+
+func TestReadSourcesMixedStdin(t *testing.T) {
+	dir := t.TempDir()
+	users := "CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);\n"
+	logs := "CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "users.sql"), []byte(users), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "logs.sql"), []byte(logs), 0o644))
+
+	// Redirected stdin, as in `spannerdef --file=-,logs.sql < users.sql`
+	stdin, err := os.Open(filepath.Join(dir, "users.sql"))
+	require.NoError(t, err)
+	defer stdin.Close()
+	orig := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = orig }()
+
+	sources, err := ReadSources([]string{"-", filepath.Join(dir, "logs.sql"), "-"})
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+	assert.Equal(t, Source{Path: "stdin", DDLs: users}, sources[0])
+	assert.Equal(t, Source{Path: filepath.Join(dir, "logs.sql"), DDLs: logs}, sources[1])
+
+	// A closed stdin is not piped
+	require.NoError(t, stdin.Close())
+	assert.False(t, isPiped(stdin))
+}
+
+func TestReadSourcesInvalidPaths(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.sql"), []byte("CREATE TABLE A (Id INT64) PRIMARY KEY (Id);\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.sql"), []byte("CREATE TABLE B (Id INT64) PRIMARY KEY (Id);\n"), 0o644))
+
+	// Glob patterns are expanded in name order
+	sources, err := ReadSources([]string{filepath.Join(dir, "*.sql")})
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+	assert.Equal(t, filepath.Join(dir, "a.sql"), sources[0].Path)
+	assert.Equal(t, filepath.Join(dir, "b.sql"), sources[1].Path)
+
+	// Every bad path is reported at once
+	_, err = ReadSources([]string{
+		filepath.Join(dir, "a.sql"),
+		filepath.Join(dir, "missing.sql"),
+		filepath.Join(dir, "*.ddl"),
+		dir,
+	})
+	assert.EqualError(t, err, "3 invalid file(s):\n"+
+		"  "+filepath.Join(dir, "*.ddl")+": pattern matches no files\n"+
+		"  "+filepath.Join(dir, "missing.sql")+": no such file or directory\n"+
+		"  "+dir+": is a directory")
+}
+
+func TestShowDDLs_MatchesApply(t *testing.T) {
+	current, err := ParseDDLs("CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id)")
+	require.NoError(t, err)
+	ddls := []string{
+		"DROP INDEX IdxUsersName",
+		"ALTER TABLE Users DROP COLUMN Legacy",
+		"ALTER TABLE Users ALTER COLUMN Name STRING(50)",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+	}
+
+	dryRun := captureStdout(t, func() { showDDLs(ddls, nil, current, false, true) })
+	apply := captureStdout(t, func() {
+		require.NoError(t, runDDLs(&fakeDatabase{}, ddls, nil, current, false, false))
+	})
+
+	plan := `-- Skipped: DROP INDEX IdxUsersName;
+-- Skipped: ALTER TABLE Users DROP COLUMN Legacy;
+-- Skipped: ALTER TABLE Users ALTER COLUMN Name STRING(50);
+ALTER TABLE Users ADD COLUMN Email STRING(255);
+`
+	assert.Equal(t, "-- dry run --\n"+plan, dryRun)
+	assert.Equal(t, "-- Apply --\n"+plan, apply)
+}
+
+func TestValidateDDLs(t *testing.T) {
+	require.NoError(t, validateDDLs([]string{
+		"CREATE TABLE Users (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"ALTER TABLE Users ADD COLUMN Name STRING(100)",
+	}))
+
+	err := validateDDLs([]string{
+		"ALTER TABLE Users ADD COLUMN Name STRING(100)",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "generated invalid SQL, which is a bug in spannerdef")
+	assert.True(t, strings.HasSuffix(err.Error(), "\nALTER TABLE Users ADD COLUMN Email STRING(255"))
+}
+
+func TestGenerateIdempotentDDLs_IfNotExists(t *testing.T) {
+	current := "CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)"
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE UNIQUE INDEX IdxUsersName ON Users (Name)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{IfNotExists: true})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS Logs (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"ALTER TABLE Users ADD COLUMN IF NOT EXISTS Name STRING(100)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS IdxUsersName ON Users (Name)",
+	}, ddls)
+
+	// Statements without IF NOT EXISTS are left as they are
+	ddls, _, err = GenerateIdempotentDDLs(current, desired, GeneratorConfig{IfNotExists: true})
+	require.NoError(t, err)
+	for _, ddl := range ddls {
+		assert.NotContains(t, ddl, "IF NOT EXISTS")
+	}
+}
+
+func TestGenerateIdempotentDDLs_QualifiedNames(t *testing.T) {
+	current := "CREATE TABLE accounting.Invoices (Id INT64 NOT NULL) PRIMARY KEY (Id)"
+	desired := `CREATE TABLE accounting.Invoices (
+  Id INT64 NOT NULL,
+  Total NUMERIC,
+  CreatedAt TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP()) OPTIONS (allow_commit_timestamp = true),
+  CONSTRAINT ChkTotal CHECK (Total >= 0),
+) PRIMARY KEY (Id);
+CREATE TABLE accounting.InvoiceLines (Id INT64 NOT NULL, LineId INT64 NOT NULL) PRIMARY KEY (Id, LineId),
+  INTERLEAVE IN PARENT accounting.Invoices ON DELETE CASCADE;
+CREATE INDEX accounting.IdxInvoicesTotal ON accounting.Invoices (Total) STORING (CreatedAt)`
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{QualifiedNames: true})
+	require.NoError(t, err)
+	// Function and option names aren't quoted
+	assert.Equal(t, []string{
+		"CREATE TABLE `accounting`.`InvoiceLines` (\n  `Id` INT64 NOT NULL,\n  `LineId` INT64 NOT NULL\n) PRIMARY KEY (`Id`, `LineId`),\nINTERLEAVE IN PARENT `accounting`.`Invoices` ON DELETE CASCADE",
+		"ALTER TABLE `accounting`.`Invoices` ADD COLUMN `Total` NUMERIC",
+		"ALTER TABLE `accounting`.`Invoices` ADD COLUMN `CreatedAt` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP()) OPTIONS (allow_commit_timestamp = true)",
+		"ALTER TABLE `accounting`.`Invoices` ADD CONSTRAINT `ChkTotal` CHECK (`Total` >= 0)",
+		"CREATE INDEX `accounting`.`IdxInvoicesTotal` ON `accounting`.`Invoices` (`Total`) STORING (`CreatedAt`)",
+	}, ddls)
+}
+
+func TestVerifyApply(t *testing.T) {
+	options := &Options{
+		DesiredDDLs: "CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(255)) PRIMARY KEY (Id);",
+	}
+
+	// Everything was applied
+	db := &fakeDatabase{ddls: options.DesiredDDLs + "\nCREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);"}
+	require.NoError(t, verifyApply(db, options, map[string]bool{"DROP TABLE Logs": true}))
+
+	// The skipped DROP TABLE is expected, the missing column is not
+	err := verifyApply(db, &Options{
+		DesiredDDLs: "CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(255), Name STRING(100)) PRIMARY KEY (Id);",
+	}, map[string]bool{"DROP TABLE Logs": true})
+	assert.EqualError(t, err, "1 statement(s) still pending after apply; this is likely a bug in spannerdef")
+}
+
+func TestFilterSchema_Indexes(t *testing.T) {
+	schema, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersName ON Users (Name);
+CREATE INDEX IdxPerf_UsersName ON Users (Name, Id);
+CREATE INDEX IdxPerf_Logs ON Logs (Id)`)
+	require.NoError(t, err)
+
+	filtered := filterSchema(schema, GeneratorConfig{SkipIndexes: []string{"IdxPerf_*"}})
+	assert.Len(t, filtered.Tables, 2)
+	assert.Equal(t, []string{"IdxUsersName"}, sortedKeys(filtered.Indexes))
+
+	filtered = filterSchema(schema, GeneratorConfig{TargetIndexes: []string{"IdxPerf_*"}})
+	assert.Len(t, filtered.Tables, 2)
+	assert.Equal(t, []string{"IdxPerf_Logs", "IdxPerf_UsersName"}, sortedKeys(filtered.Indexes))
+
+	// Indexes of skipped tables stay excluded
+	filtered = filterSchema(schema, GeneratorConfig{SkipTables: []string{"Logs"}, TargetIndexes: []string{"IdxPerf_*"}})
+	assert.Equal(t, []string{"IdxPerf_UsersName"}, sortedKeys(filtered.Indexes))
+}
+
+func TestGenerateIdempotentDDLs_Schema(t *testing.T) {
+	current := `CREATE TABLE accounting.Invoices (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE billing.Charges (Id INT64 NOT NULL) PRIMARY KEY (Id)`
+	desired := `CREATE TABLE accounting.Invoices (Id INT64 NOT NULL, Total NUMERIC) PRIMARY KEY (Id);
+CREATE INDEX accounting.IdxInvoicesTotal ON accounting.Invoices (Total)`
+
+	// Objects of other schemas are neither dropped nor reported
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{Schema: "accounting"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE accounting.Invoices ADD COLUMN Total NUMERIC",
+		"CREATE INDEX accounting.IdxInvoicesTotal ON accounting.Invoices (Total)",
+	}, ddls)
+}
+
+func TestExportSchema(t *testing.T) {
+	db := &fakeDatabase{ddls: `CREATE TABLE accounting.Invoices (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)`}
+
+	var buf strings.Builder
+	require.NoError(t, exportSchema(db, &Options{Config: GeneratorConfig{Schema: "accounting"}}, &buf))
+	assert.Equal(t, "CREATE TABLE accounting.Invoices (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id);\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, exportSchema(db, &Options{Config: GeneratorConfig{Schema: "billing"}}, &buf))
+	assert.Equal(t, "-- No objects in schema billing --\n", buf.String())
+}
+
+// tableDumpingDatabase is a fakeDatabase that records the tables it is asked
+// to introspect
+type tableDumpingDatabase struct {
+	fakeDatabase
+	dumped [][]string
+}
+
+func (d *tableDumpingDatabase) DumpTableDDLs(tables []string) (string, error) {
+	d.dumped = append(d.dumped, tables)
+	return "-- partial", nil
+}
+
+func TestDumpCurrentDDLs(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config GeneratorConfig
+		dumped [][]string
+		ddls   string
+	}{
+		{"no filter", GeneratorConfig{}, nil, "-- full"},
+		{"targets", GeneratorConfig{TargetTables: []string{"Users", "Posts"}}, [][]string{{"Users", "Posts"}}, "-- partial"},
+		{"skipped targets", GeneratorConfig{TargetTables: []string{"Users", "Posts"}, SkipTables: []string{"Posts", "Logs"}}, [][]string{{"Users"}}, "-- partial"},
+		{"all targets skipped", GeneratorConfig{TargetTables: []string{"Users"}, SkipTables: []string{"Users"}}, nil, ""},
+		{"skips only", GeneratorConfig{SkipTables: []string{"Logs"}}, nil, "-- full"},
+		{"qualified targets", GeneratorConfig{TargetTables: []string{"accounting.Invoices"}}, nil, "-- full"},
+		{"named schema", GeneratorConfig{Schema: "accounting", TargetTables: []string{"Invoices"}}, nil, "-- full"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &tableDumpingDatabase{fakeDatabase: fakeDatabase{ddls: "-- full"}}
+			ddls, err := dumpCurrentDDLs(db, &Options{Config: tc.config})
+			require.NoError(t, err)
+			assert.Equal(t, tc.ddls, ddls)
+			assert.Equal(t, tc.dumped, db.dumped)
+		})
+	}
+
+	// Vector indexes on the targeted tables aren't introspected
+	db := &tableDumpingDatabase{fakeDatabase: fakeDatabase{ddls: "-- full"}}
+	ddls, err := dumpCurrentDDLs(db, &Options{
+		DesiredDDLs: `CREATE TABLE Docs (Id INT64 NOT NULL, Embedding ARRAY<FLOAT32>(vector_length=>3)) PRIMARY KEY (Id);
+CREATE VECTOR INDEX VI ON Docs(Embedding) OPTIONS (distance_type = 'COSINE')`,
+		Config: GeneratorConfig{TargetTables: []string{"Docs"}},
 	})
+	require.NoError(t, err)
+	assert.Equal(t, "-- full", ddls)
+	assert.Empty(t, db.dumped)
+
+	// Databases that can't introspect tables are dumped entirely
+	ddls, err = dumpCurrentDDLs(&fakeDatabase{ddls: "-- full"}, &Options{Config: GeneratorConfig{TargetTables: []string{"Users"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "-- full", ddls)
+}
+
+func TestExportDir(t *testing.T) {
+	dir := t.TempDir()
+	ddls := `CREATE SEQUENCE OrderSeq OPTIONS (sequence_kind = 'bit_reversed_positive');
+
+CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Name STRING(100),
+) PRIMARY KEY(Id);
+
+CREATE INDEX IdxUsersName ON Users(Name);
+
+CREATE TABLE Posts (
+  Id INT64 NOT NULL,
+) PRIMARY KEY(Id);
+
+CREATE VIEW UserNames SQL SECURITY INVOKER AS SELECT Name FROM Users;`
+
+	dumped, err := exportDir(&fakeDatabase{ddls: ddls}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, ddls, dumped)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"Posts.sql", "Users.sql", "_schema.sql"}, names)
+
+	users, err := os.ReadFile(filepath.Join(dir, "Users.sql"))
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE Users (\n  Id INT64 NOT NULL,\n  Name STRING(100),\n) PRIMARY KEY(Id);\n\nCREATE INDEX IdxUsersName ON Users(Name);\n", string(users))
+	others, err := os.ReadFile(filepath.Join(dir, "_schema.sql"))
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE SEQUENCE OrderSeq OPTIONS (sequence_kind = 'bit_reversed_positive');\n\nCREATE VIEW UserNames SQL SECURITY INVOKER AS SELECT Name FROM Users;\n", string(others))
+
+	// The files read back to the same schema
+	sources, err := ReadSources([]string{filepath.Join(dir, "Posts.sql"), filepath.Join(dir, "Users.sql"), filepath.Join(dir, "_schema.sql")})
+	require.NoError(t, err)
+	generated, _, err := GenerateIdempotentDDLs(JoinSources(sources), ddls, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, generated)
 }
 
 // TestConfigFiltering tests table filtering functionality
@@ -1011,7 +1391,7 @@ func TestConfigFiltering(t *testing.T) {
 		currentDDLs, err := db.DumpDDLs()
 		require.NoError(t, err)
 
-		ddls, err := GenerateIdempotentDDLs(schema, currentDDLs, options.Config)
+		ddls, _, err := GenerateIdempotentDDLs(schema, currentDDLs, options.Config)
 		require.NoError(t, err)
 
 		// Should only create Users table
@@ -1047,7 +1427,7 @@ func TestConfigFiltering(t *testing.T) {
 		currentDDLs, err := db.DumpDDLs()
 		require.NoError(t, err)
 
-		ddls, err := GenerateIdempotentDDLs(schema, currentDDLs, options.Config)
+		ddls, _, err := GenerateIdempotentDDLs(schema, currentDDLs, options.Config)
 		require.NoError(t, err)
 
 		// Should only create Users table
@@ -1055,3 +1435,32 @@ func TestConfigFiltering(t *testing.T) {
 		assertDDLNotContains(t, ddls, "CREATE TABLE Posts")
 	})
 }
+
+func TestGenerateIdempotentDDLs_BackquotedIdentifiers(t *testing.T) {
+	// GetDatabaseDdl may backquote identifiers that don't need it
+	current := "CREATE TABLE `Users` (\n  `Id` INT64 NOT NULL,\n  `Email` STRING(255),\n) PRIMARY KEY (`Id`);\nCREATE INDEX `IdxUsersEmail` ON `Users`(`Email`)"
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Email STRING(255)) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersEmail ON Users (Email)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	assert.Empty(t, warnings)
+}
+
+func TestGenerateIdempotentDDLs_ReservedWordNames(t *testing.T) {
+	current := "CREATE TABLE `Order` (`Id` INT64 NOT NULL) PRIMARY KEY (`Id`)"
+	desired := "CREATE TABLE `Order` (`Id` INT64 NOT NULL, `Limit` INT64, `Group` STRING(10)) PRIMARY KEY (`Id`);\n" +
+		"CREATE INDEX `IdxOrderGroup` ON `Order` (`Group`) STORING (`Limit`);\n" +
+		"CREATE TABLE `Select` (`Id` INT64 NOT NULL) PRIMARY KEY (`Id`), INTERLEAVE IN PARENT `Order`"
+
+	// Reserved words are backquoted again in the generated DDLs
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE `Select` (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id),\nINTERLEAVE IN PARENT `Order`",
+		"ALTER TABLE `Order` ADD COLUMN `Limit` INT64",
+		"ALTER TABLE `Order` ADD COLUMN `Group` STRING(10)",
+		"CREATE INDEX IdxOrderGroup ON `Order` (`Group`) STORING (`Limit`)",
+	}, ddls)
+}
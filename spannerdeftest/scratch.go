@@ -0,0 +1,99 @@
+// Package spannerdeftest provisions throwaway Spanner databases with a
+// given schema for application integration tests.
+package spannerdeftest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/hokaccha/spannerdef"
+)
+
+// pollInterval is how often database creation is polled. The generated
+// client waits a minute between polls, far too long for test setup.
+const pollInterval = 500 * time.Millisecond
+
+// maxDatabaseIDLength is the maximum length of a Spanner database ID
+const maxDatabaseIDLength = 30
+
+// NewScratchDatabase creates a uniquely named database in the project and
+// instance of cfg, which may be the emulator, with desiredDDLs applied. The
+// database ID is cfg.DatabaseID, "scratch" if empty, with a random suffix;
+// cfg.DatabaseDialect and cfg.KMSKeyName apply as with CreateDatabase.
+// The returned cleanup function closes and drops the database.
+func NewScratchDatabase(ctx context.Context, cfg spannerdef.Config, desiredDDLs string) (*spannerdef.SpannerDatabase, func(), error) {
+	ddls, _, err := spannerdef.GenerateIdempotentDDLs(desiredDDLs, "", spannerdef.GeneratorConfig{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	databaseID, err := scratchDatabaseID(cfg.DatabaseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.DatabaseID = databaseID
+
+	adminDB, err := spannerdef.NewAdminDatabase(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Creating the tables along with the database takes a single operation
+	req := adminDB.CreateDatabaseRequest()
+	req.ExtraStatements = ddls
+	op, err := adminDB.DatabaseAdminClient().CreateDatabase(ctx, req)
+	if err != nil {
+		adminDB.Close()
+		return nil, nil, fmt.Errorf("failed to create database: %v", err)
+	}
+	for !op.Done() {
+		if _, err := op.Poll(ctx); err != nil {
+			adminDB.Close()
+			return nil, nil, fmt.Errorf("database creation failed: %v", err)
+		}
+		if op.Done() {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			adminDB.Close()
+			return nil, nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+
+	db, err := spannerdef.NewDatabase(cfg)
+	if err != nil {
+		_ = adminDB.DropDatabase(context.Background())
+		adminDB.Close()
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		db.Close()
+		// ctx may be done by the time tests clean up
+		_ = adminDB.DropDatabase(context.Background())
+		adminDB.Close()
+	}
+	return db, cleanup, nil
+}
+
+// scratchDatabaseID returns prefix followed by a random suffix, within the
+// length limit of database IDs
+func scratchDatabaseID(prefix string) (string, error) {
+	if prefix == "" {
+		prefix = "scratch"
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate database ID: %v", err)
+	}
+	if limit := maxDatabaseIDLength - 1 - 2*len(suffix); len(prefix) > limit {
+		prefix = prefix[:limit]
+	}
+	return prefix + "-" + hex.EncodeToString(suffix), nil
+}
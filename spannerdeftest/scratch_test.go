@@ -0,0 +1,58 @@
+package spannerdeftest
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hokaccha/spannerdef"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScratchDatabaseID(t *testing.T) {
+	id, err := scratchDatabaseID("")
+	require.NoError(t, err)
+	assert.Regexp(t, `^scratch-[0-9a-f]{8}$`, id)
+
+	id, err = scratchDatabaseID(strings.Repeat("a", 40))
+	require.NoError(t, err)
+	assert.Len(t, id, maxDatabaseIDLength)
+}
+
+func TestNewScratchDatabase(t *testing.T) {
+	if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+		t.Skip("SPANNER_EMULATOR_HOST not set; run `make omni-up && make test`")
+	}
+
+	cfg := spannerdef.Config{
+		ProjectID:  "default",
+		InstanceID: "default",
+	}
+	if v := os.Getenv("SPANNER_PROJECT_ID"); v != "" {
+		cfg.ProjectID = v
+	}
+	if v := os.Getenv("SPANNER_INSTANCE_ID"); v != "" {
+		cfg.InstanceID = v
+	}
+
+	desired := `
+		CREATE TABLE Users (
+			Id INT64 NOT NULL,
+			Email STRING(255),
+		) PRIMARY KEY (Id);
+		CREATE INDEX IdxUsersEmail ON Users (Email);
+	`
+	db, cleanup, err := NewScratchDatabase(context.Background(), cfg, desired)
+	require.NoError(t, err)
+	defer cleanup()
+
+	assert.True(t, strings.HasPrefix(db.DatabasePath(), "projects/"+cfg.ProjectID+"/instances/"+cfg.InstanceID+"/databases/scratch-"))
+
+	currentDDLs, err := db.DumpDDLs()
+	require.NoError(t, err)
+	ddls, _, err := spannerdef.GenerateIdempotentDDLs(desired, currentDDLs, spannerdef.GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
@@ -0,0 +1,105 @@
+package spannerdef
+
+import (
+	"fmt"
+	"strings"
+)
+
+// summaryCounts are the parts of a summary, in the order they are printed
+var summaryCounts = []struct {
+	kind     operationKind
+	singular string
+	plural   string
+}{
+	{opAlterDatabase, "database altered", "databases altered"},
+	{opCreateTable, "table created", "tables created"},
+	{opDropTable, "table dropped", "tables dropped"},
+	{opUnknown, "table changed", "tables changed"}, // existing tables, counted apart
+	{opAddColumn, "column added", "columns added"},
+	{opDropColumn, "column dropped", "columns dropped"},
+	{opAlterColumn, "column altered", "columns altered"},
+	{opCreateIndex, "index created", "indexes created"},
+	{opDropIndex, "index dropped", "indexes dropped"},
+	{opCreateVectorIndex, "vector index created", "vector indexes created"},
+	{opDropVectorIndex, "vector index dropped", "vector indexes dropped"},
+	{opAlterVectorIndex, "vector index altered", "vector indexes altered"},
+	{opCreateProtoBundle, "proto bundle created", "proto bundles created"},
+	{opAlterProtoBundle, "proto bundle altered", "proto bundles altered"},
+	{opDropProtoBundle, "proto bundle dropped", "proto bundles dropped"},
+	{opAddConstraint, "constraint added", "constraints added"},
+	{opDropConstraint, "constraint dropped", "constraints dropped"},
+	{opCreateView, "view created", "views created"},
+	{opReplaceView, "view replaced", "views replaced"},
+	{opDropView, "view dropped", "views dropped"},
+	{opCreateChangeStream, "change stream created", "change streams created"},
+	{opDropChangeStream, "change stream dropped", "change streams dropped"},
+	{opAlterChangeStream, "change stream altered", "change streams altered"},
+	{opCreateSequence, "sequence created", "sequences created"},
+	{opAlterSequence, "sequence altered", "sequences altered"},
+	{opDropSequence, "sequence dropped", "sequences dropped"},
+	{opCreatePlacement, "placement created", "placements created"},
+	{opCreateSchema, "schema created", "schemas created"},
+	{opDropSchema, "schema dropped", "schemas dropped"},
+	{opCreateRole, "role created", "roles created"},
+	{opDropRole, "role dropped", "roles dropped"},
+	{opGrant, "privilege granted", "privileges granted"},
+	{opRevoke, "privilege revoked", "privileges revoked"},
+}
+
+// summarizeDDLs returns a one-line summary of ddls for commit messages and
+// release notes, e.g. "3 tables changed, 5 columns added, 1 index dropped".
+// Tables changed are the tables that ddls alter or index without creating
+// or dropping them. Alterations of the same column count once. current
+// tells the table of a dropped index.
+func summarizeDDLs(ddls []string, current *Schema) string {
+	counts := make(map[operationKind]int)
+	createdOrDropped := make(map[string]bool)
+	changed := make(map[string]bool)
+	altered := make(map[string]bool)
+	for _, ddl := range ddls {
+		op := classifyDDL(ddl)
+		table := op.table
+		switch op.kind {
+		case opCreateTable, opDropTable:
+			createdOrDropped[table] = true
+		case opDropIndex:
+			if index, ok := current.Indexes[op.name]; ok {
+				table = index.TableName
+			}
+		case opDropVectorIndex, opAlterVectorIndex:
+			if index, ok := current.VectorIndexes[op.name]; ok {
+				table = index.TableName
+			}
+		case opAlterColumn, opAlterColumnType:
+			if altered[table+"."+op.name] {
+				continue
+			}
+			altered[table+"."+op.name] = true
+			op.kind = opAlterColumn
+		}
+
+		counts[op.kind]++
+		if table != "" {
+			changed[table] = true
+		}
+	}
+	for table := range createdOrDropped {
+		delete(changed, table)
+	}
+	counts[opUnknown] = len(changed)
+
+	var parts []string
+	for _, c := range summaryCounts {
+		switch n := counts[c.kind]; n {
+		case 0:
+		case 1:
+			parts = append(parts, "1 "+c.singular)
+		default:
+			parts = append(parts, fmt.Sprintf("%d %s", n, c.plural))
+		}
+	}
+	if len(parts) == 0 {
+		return "no schema changes"
+	}
+	return strings.Join(parts, ", ")
+}
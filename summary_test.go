@@ -0,0 +1,34 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeDDLs(t *testing.T) {
+	current, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100), Legacy BOOL) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersName ON Users (Name);
+CREATE TABLE Posts (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE INDEX IdxLogs ON Logs (Id)`)
+	require.NoError(t, err)
+
+	ddls := []string{
+		"DROP INDEX IdxUsersName",
+		"DROP INDEX IdxLogs",
+		"DROP TABLE Logs",
+		"ALTER TABLE Users DROP COLUMN Legacy",
+		"CREATE TABLE Comments (\n  Id INT64 NOT NULL\n) PRIMARY KEY (Id)",
+		"ALTER TABLE Users ADD COLUMN Email STRING(255)",
+		"ALTER TABLE Posts ADD COLUMN Title STRING(100)",
+		"ALTER TABLE Users ALTER COLUMN Name STRING(200) NOT NULL",
+		"ALTER TABLE Users ALTER COLUMN Name SET OPTIONS (allow_commit_timestamp = null)",
+		"CREATE INDEX IdxCommentsId ON Comments (Id)",
+	}
+	assert.Equal(t, "1 table created, 1 table dropped, 2 tables changed, 2 columns added, 1 column dropped, "+
+		"1 column altered, 1 index created, 2 indexes dropped", summarizeDDLs(ddls, current))
+
+	assert.Equal(t, "no schema changes", summarizeDDLs(nil, current))
+}
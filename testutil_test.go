@@ -3,6 +3,8 @@ package spannerdef
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -144,3 +146,47 @@ func newTestDatabase(t *testing.T, base Config) (*SpannerDatabase, *SpannerAdmin
 
 	return db, adminDB
 }
+
+// fakeDatabase is an in-memory Database that records the DDL batches it is
+// asked to execute. Used by unit tests that don't need a live Spanner.
+type fakeDatabase struct {
+	ddls    string
+	dumpErr error // returned by DumpDDLs if set
+	batches [][]string
+}
+
+func (d *fakeDatabase) DumpDDLs() (string, error) {
+	return d.ddls, d.dumpErr
+}
+
+func (d *fakeDatabase) ExecDDL(ddl string) error {
+	return d.ExecDDLs([]string{ddl})
+}
+
+func (d *fakeDatabase) ExecDDLs(ddls []string) error {
+	d.batches = append(d.batches, ddls)
+	return nil
+}
+
+func (d *fakeDatabase) Close() error {
+	return nil
+}
+
+// captureStdout returns what f prints to stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	out := make(chan string)
+	go func() {
+		buf, _ := io.ReadAll(r)
+		out <- string(buf)
+	}()
+	f()
+	require.NoError(t, w.Close())
+	return <-out
+}
@@ -0,0 +1,77 @@
+package spannerdef
+
+import "regexp"
+
+// tombstoneRe matches a `-- spannerdef:dropped <object>` line, which
+// authorizes dropping the object without --enable-drop. Objects are tables,
+// indexes, vector indexes, views, change streams, sequences, roles and
+// named schemas by name, and columns and constraints as "Table.Name".
+var tombstoneRe = regexp.MustCompile(`(?m)^[ \t]*--\s*spannerdef:dropped\s+(\S+)\s*$`)
+
+// parseTombstones returns the objects marked as dropped in ddls
+func parseTombstones(ddls string) map[string]bool {
+	var dropped map[string]bool
+	for _, match := range tombstoneRe.FindAllStringSubmatch(ddls, -1) {
+		if dropped == nil {
+			dropped = make(map[string]bool)
+		}
+		dropped[match[1]] = true
+	}
+	return dropped
+}
+
+// tombstoned reports whether the drop ddl is authorized by a tombstone in
+// current.Dropped: one for the object itself or for the table it belongs
+// to. Dropping a table takes its indexes, the tables interleaved in it and
+// the foreign keys referencing it, so its tombstone covers them.
+func tombstoned(ddl string, current *Schema) bool {
+	if current == nil {
+		return false
+	}
+	if current.Dropped[droppedObject(ddl)] {
+		return true
+	}
+
+	// A table is also dropped with a dropped parent
+	var tableDropped func(name string) bool
+	tableDropped = func(name string) bool {
+		table, ok := current.Tables[name]
+		return ok && (current.Dropped[name] || table.ParentTable != "" && tableDropped(table.ParentTable))
+	}
+	switch op := classifyDDL(ddl); op.kind {
+	case opDropTable:
+		return tableDropped(op.table)
+	case opDropIndex:
+		index, ok := current.Indexes[op.name]
+		return ok && tableDropped(index.TableName)
+	case opDropVectorIndex:
+		index, ok := current.VectorIndexes[op.name]
+		return ok && tableDropped(index.TableName)
+	case opDropConstraint:
+		if tableDropped(op.table) {
+			return true
+		}
+		table, ok := current.Tables[op.table]
+		if !ok {
+			return false
+		}
+		constraint, ok := table.Constraints[op.name]
+		return ok && constraint.Type == "FOREIGN KEY" && tableDropped(constraint.ReferenceTable)
+	}
+	return false
+}
+
+// droppedObject returns the object that ddl drops, as named by tombstones,
+// or "" if ddl doesn't drop one
+func droppedObject(ddl string) string {
+	op := classifyDDL(ddl)
+	switch op.kind {
+	case opDropTable:
+		return op.table
+	case opDropIndex, opDropVectorIndex, opDropView, opDropChangeStream, opDropSequence, opDropRole, opDropSchema:
+		return op.name
+	case opDropColumn, opDropConstraint:
+		return op.table + "." + op.name
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTombstones(t *testing.T) {
+	dropped := parseTombstones(`-- spannerdef:dropped Users_old
+CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+  --spannerdef:dropped IdxUsersName
+-- spannerdef:dropped Users.Legacy
+-- Users_old was dropped in #123`)
+	assert.Equal(t, map[string]bool{"Users_old": true, "IdxUsersName": true, "Users.Legacy": true}, dropped)
+
+	assert.Nil(t, parseTombstones("CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id)"))
+}
+
+func TestSkippedDDLs_Tombstones(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, Legacy STRING(10), Name STRING(10)) PRIMARY KEY (Id);
+CREATE TABLE Users_old (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersName ON Users (Name)`
+	desired := `-- spannerdef:dropped Users_old
+-- spannerdef:dropped Users.Legacy
+CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(10)) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id)`
+
+	currentSchema, desiredSchema, err := parseSchemas(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	ddls := GenerateDDLs(currentSchema, desiredSchema)
+
+	// Only the drops without a tombstone still need --enable-drop
+	assert.Equal(t, []string{
+		"DROP INDEX IdxUsersName",
+		"DROP TABLE Users_old",
+		"ALTER TABLE Users DROP COLUMN Legacy",
+	}, ddls)
+	assert.Equal(t, []string{"DROP TABLE Users_old", "ALTER TABLE Users DROP COLUMN Legacy"},
+		appliedDDLs(ddls, currentSchema, false))
+	assert.Equal(t, "table Users_old exists in current but not desired, and is marked as dropped",
+		explainDDLs(ddls, currentSchema, desiredSchema, nil)[1])
+}
+
+func TestSkippedDDLs_TableTombstoneCoversDependents(t *testing.T) {
+	current := `CREATE TABLE Users_old (Id INT64 NOT NULL, A STRING(10)) PRIMARY KEY (Id);
+CREATE TABLE Sessions_old (Id INT64 NOT NULL, SessionId INT64 NOT NULL) PRIMARY KEY (Id, SessionId), INTERLEAVE IN PARENT Users_old;
+CREATE TABLE Orders (Id INT64 NOT NULL, UserId INT64, CONSTRAINT FK_OrdersUsersOld FOREIGN KEY (UserId) REFERENCES Users_old (Id)) PRIMARY KEY (Id);
+CREATE INDEX UsersOldByA ON Users_old (A)`
+	desired := `-- spannerdef:dropped Users_old
+CREATE TABLE Orders (Id INT64 NOT NULL, UserId INT64) PRIMARY KEY (Id)`
+
+	currentSchema, desiredSchema, err := parseSchemas(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	ddls := GenerateDDLs(currentSchema, desiredSchema)
+
+	// The index, the interleaved table and the foreign key go with the table
+	assert.ElementsMatch(t, []string{
+		"DROP INDEX UsersOldByA",
+		"ALTER TABLE Orders DROP CONSTRAINT FK_OrdersUsersOld",
+		"DROP TABLE Sessions_old",
+		"DROP TABLE Users_old",
+	}, ddls)
+	assert.Equal(t, ddls, appliedDDLs(ddls, currentSchema, false))
+}
@@ -0,0 +1,250 @@
+package spannerdef
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// WarningKind classifies a Warning
+type WarningKind string
+
+const (
+	// WarningUnsupportedStatement is a statement of the desired schema that
+	// is ignored, such as a typo of a supported statement or a feature
+	// spannerdef doesn't manage
+	WarningUnsupportedStatement WarningKind = "unsupported statement ignored"
+	// WarningLossyStatement is a statement of the desired schema that the
+	// schema model only approximates, such as an index interleaved with
+	// INTERLEAVE IN
+	WarningLossyStatement WarningKind = "statement not represented faithfully"
+	// WarningUnsupportedChange is a difference between the current and
+	// desired schemas that the generator can't make, such as a changed
+	// generated column expression
+	WarningUnsupportedChange WarningKind = "unsupported change ignored"
+	// WarningUnparsedStatement is a statement of the desired schema that
+	// can't be parsed, skipped with GeneratorConfig.Lenient
+	WarningUnparsedStatement WarningKind = "unparsed statement skipped"
+	// WarningUnparsedCurrentStatement is a statement of the current schema
+	// that can't be parsed, skipped with GeneratorConfig.Lenient. The
+	// object it creates may be dropped or changed by the plan.
+	WarningUnparsedCurrentStatement WarningKind = "unparsed statement of the current schema skipped"
+)
+
+// Warning is a non-fatal problem found while generating DDLs, meaning that
+// they may not bring the database fully in line with the desired schema
+type Warning struct {
+	Kind WarningKind `json:"kind"`
+	// Detail is the statement, or the object of an unsupported change and
+	// how it differs, e.g. "table Users differs"
+	Detail string `json:"detail"`
+	// Pos is the byte offset of the statement in the desired DDLs, or -1
+	// for unsupported changes and statements of the current schema
+	Pos int `json:"-"`
+}
+
+func (w Warning) String() string {
+	return string(w.Kind) + ": " + w.Detail
+}
+
+// planWarnings returns the warnings about generating ddls from current to
+// desired, which was parsed from desiredDDLs, with the equivalent defaults
+// taken to be the same
+func planWarnings(desiredDDLs string, current, desired *Schema, ddls []string, equivalentDefaults [][]string) ([]Warning, error) {
+	var warnings []Warning
+	for _, stmt := range desired.Unsupported {
+		warnings = append(warnings, Warning{Kind: WarningUnsupportedStatement, Detail: stmt.SQL, Pos: stmt.Pos})
+	}
+	for _, stmt := range desired.Unparsed {
+		warnings = append(warnings, Warning{Kind: WarningUnparsedStatement, Detail: stmt.SQL, Pos: stmt.Pos})
+	}
+	for _, stmt := range current.Unparsed {
+		warnings = append(warnings, Warning{Kind: WarningUnparsedCurrentStatement, Detail: stmt.SQL, Pos: -1})
+	}
+
+	lossy, err := lossyStatements(desiredDDLs, desired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the desired schema: %v", err)
+	}
+	for _, stmt := range lossy {
+		warnings = append(warnings, Warning{Kind: WarningLossyStatement, Detail: stmt.SQL, Pos: stmt.Pos})
+	}
+
+	changes, err := unsupportedChanges(current, equateDefaults(current, desired, equivalentDefaults), ddls)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the plan: %v", err)
+	}
+	for _, change := range changes {
+		warnings = append(warnings, Warning{Kind: WarningUnsupportedChange, Detail: change, Pos: -1})
+	}
+	return warnings, nil
+}
+
+// reportWarnings prints warnings on w with the file and line of their
+// statement, if known. With strict, any warning is an error.
+func reportWarnings(w io.Writer, warnings []Warning, sources []Source, strict bool) error {
+	for _, warning := range warnings {
+		location := ""
+		if warning.Pos >= 0 {
+			if loc := locate(sources, warning.Pos); loc != "" {
+				location = " (" + loc + ")"
+			}
+		}
+		fmt.Fprintf(w, "WARNING: %s%s: %s\n", warning.Kind, location, warning.Detail)
+	}
+	if strict && len(warnings) > 0 {
+		return fmt.Errorf("%d warning(s) about the plan (--strict)", len(warnings))
+	}
+	return nil
+}
+
+// unsupportedChanges replays ddls on a copy of current and returns the
+// objects still differing from desired, as "<object> <drift>" sorted by
+// object
+func unsupportedChanges(current, desired *Schema, ddls []string) ([]string, error) {
+	planned, err := ParseDDLs(schemaDDLs(current))
+	if err != nil {
+		return nil, err
+	}
+	for _, ddl := range ddls {
+		stmt, err := memefish.ParseDDL("", ddl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", ddl, err)
+		}
+		if err := replayDDL(planned, stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	drift := diffObjects(planned, desired)
+	objects := make([]string, 0, len(drift))
+	for object := range drift {
+		objects = append(objects, object)
+	}
+	sort.Strings(objects)
+
+	changes := make([]string, 0, len(objects))
+	for _, object := range objects {
+		table, column, _ := strings.Cut(strings.TrimPrefix(object, "column "), ".")
+		if desiredTable, ok := desired.Tables[table]; ok && strings.HasPrefix(object, "column ") && drift[object] == DriftUnexpected {
+			if to := renamedTo(desiredTable, column); to != "" {
+				changes = append(changes, fmt.Sprintf("column %s.%s is replaced by %s, as Spanner can't rename columns; copy its values, then remove the renamed_from annotation to drop it", table, column, to))
+				continue
+			}
+		}
+		changes = append(changes, object+" "+drift[object])
+	}
+	return changes, nil
+}
+
+// lossyStatements returns the CREATE TABLE and CREATE INDEX statements of
+// ddls whose definition in schema, generated back, differs from them. Both
+// sides are compared in memefish's formatting, and constraints added by
+// ALTER TABLE are left out of the generated CREATE TABLE.
+func lossyStatements(ddls string, schema *Schema) ([]Statement, error) {
+	if strings.TrimSpace(ddls) == "" {
+		return nil, nil
+	}
+	parsed, err := memefish.ParseDDLs("", ddls)
+	if err != nil {
+		return nil, err
+	}
+
+	// ALTER INDEX changes the STORING columns written in CREATE INDEX
+	altered := make(map[string]bool)
+	for _, stmt := range parsed {
+		if s, ok := stmt.(*ast.AlterIndex); ok {
+			altered[getPathName(s.Name)] = true
+		}
+	}
+
+	var lossy []Statement
+	for _, stmt := range parsed {
+		var generated string
+		switch s := stmt.(type) {
+		case *ast.CreateTable:
+			table, ok := schema.Tables[getPathName(s.Name)]
+			if !ok {
+				continue
+			}
+			inline := *table
+			// The row deletion policy may be added or changed by ALTER
+			// TABLE later on
+			inline.RowDeletionPolicyColumn, inline.RowDeletionPolicyDays = "", 0
+			if s.RowDeletionPolicy != nil && s.RowDeletionPolicy.RowDeletionPolicy != nil {
+				if err := setRowDeletionPolicy(&inline, s.RowDeletionPolicy.RowDeletionPolicy); err != nil {
+					return nil, err
+				}
+			}
+			// So may columns and constraints
+			inline.Columns = make(map[string]*Column)
+			for name, column := range table.Columns {
+				if column.Pos >= int(s.Pos()) && column.Pos < int(s.End()) {
+					inline.Columns[name] = column
+				}
+			}
+			inline.Constraints = make(map[string]*Constraint)
+			for name, constraint := range table.Constraints {
+				if constraint.Pos >= int(s.Pos()) && constraint.Pos < int(s.End()) {
+					inline.Constraints[name] = constraint
+				}
+			}
+			generated = generateCreateTable(&inline)
+			s.IfNotExists = false
+			normalizeKeys(s.PrimaryKeys)
+			normalizeProtoTypes(s)
+			normalizeColumnOptions(s)
+			// Constraints are generated in name order
+			sort.SliceStable(s.TableConstraints, func(i, j int) bool {
+				return constraintName(s.TableConstraints[i]) < constraintName(s.TableConstraints[j])
+			})
+		case *ast.CreateIndex:
+			index, ok := schema.Indexes[getPathName(s.Name)]
+			if !ok {
+				continue
+			}
+			if altered[index.Name] {
+				created := *index
+				created.Storing = nil
+				if s.Storing != nil {
+					for _, column := range s.Storing.Columns {
+						created.Storing = append(created.Storing, column.Name)
+					}
+				}
+				index = &created
+			}
+			generated = generateCreateIndex(index)
+			s.IfNotExists = false
+			normalizeKeys(s.Keys)
+		default:
+			continue
+		}
+
+		regenerated, err := memefish.ParseDDL("", generated)
+		if err != nil || regenerated.SQL() != stmt.SQL() {
+			lossy = append(lossy, Statement{SQL: ddls[stmt.Pos():statementEnd(ddls, stmt)], Pos: int(stmt.Pos())})
+		}
+	}
+	return lossy, nil
+}
+
+// normalizeKeys leaves out ASC, the default, from keys, as formatKeys does
+func normalizeKeys(keys []*ast.IndexKey) {
+	for _, key := range keys {
+		if key.Dir == ast.DirectionAsc {
+			key.Dir = ""
+		}
+	}
+}
+
+// constraintName returns the name of tc, or "" if it is unnamed
+func constraintName(tc *ast.TableConstraint) string {
+	if tc.Name == nil {
+		return ""
+	}
+	return tc.Name.Name
+}
@@ -0,0 +1,78 @@
+package spannerdef
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdempotentDDLs_Warnings(t *testing.T) {
+	current := "CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100), Upper STRING(100) AS (UPPER(Name)) STORED) PRIMARY KEY (Id)"
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(200), Upper STRING(100), Email STRING(255)) PRIMARY KEY (Id);
+CREATE INDEX IdxUsersName ON Users (Name DESC), INTERLEAVE IN Users;
+CREATE SEARCH INDEX UsersNameSearch ON Users(NameTokens)`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Len(t, ddls, 3)
+
+	// A generated column can't be made a plain one, everything else is
+	// planned
+	assert.Equal(t, []Warning{
+		{Kind: WarningUnsupportedStatement, Detail: "CREATE SEARCH INDEX UsersNameSearch ON Users(NameTokens)", Pos: strings.Index(desired, "CREATE SEARCH INDEX")},
+		{Kind: WarningLossyStatement, Detail: "CREATE INDEX IdxUsersName ON Users (Name DESC), INTERLEAVE IN Users", Pos: strings.Index(desired, "CREATE INDEX")},
+		{Kind: WarningUnsupportedChange, Detail: "column Users.Upper differs", Pos: -1},
+	}, warnings)
+	assert.Equal(t, "unsupported change ignored: column Users.Upper differs", warnings[2].String())
+
+	_, warnings, err = GenerateIdempotentDDLs(current, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func TestReportWarnings(t *testing.T) {
+	sources := []Source{
+		{Path: "schema.sql", DDLs: "CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);\n"},
+		{Path: "search.sql", DDLs: "\nCREATE SEARCH INDEX UsersNameSearch ON Users(NameTokens);\n"},
+	}
+	warnings := []Warning{
+		{Kind: WarningUnsupportedStatement, Detail: "CREATE SEARCH INDEX UsersNameSearch ON Users(NameTokens)", Pos: len(sources[0].DDLs) + 1},
+		{Kind: WarningUnsupportedChange, Detail: "table Users differs", Pos: -1},
+	}
+
+	var buf strings.Builder
+	require.NoError(t, reportWarnings(&buf, warnings, sources, false))
+	assert.Equal(t, "WARNING: unsupported statement ignored (search.sql:2): CREATE SEARCH INDEX UsersNameSearch ON Users(NameTokens)\n"+
+		"WARNING: unsupported change ignored: table Users differs\n", buf.String())
+
+	buf.Reset()
+	err := reportWarnings(&buf, warnings, sources, true)
+	assert.EqualError(t, err, "2 warning(s) about the plan (--strict)")
+	assert.NotEmpty(t, buf.String())
+
+	require.NoError(t, reportWarnings(&buf, nil, sources, true))
+}
+
+func TestLossyStatements(t *testing.T) {
+	ddls := `CREATE TABLE Users (
+  Id INT64 NOT NULL,
+  Age INT64,
+  CONSTRAINT ChkAge CHECK (Age >= 0),
+  CONSTRAINT ChkAdult CHECK (Age >= 18),
+) PRIMARY KEY (Id);
+CREATE TABLE Logs (Id INT64 NOT NULL) PRIMARY KEY (Id DESC);
+CREATE INDEX IdxUsersAge ON Users (Age ASC, Id DESC);
+CREATE INDEX IdxUsersId ON Users (Id) STORING (Age);
+CREATE INDEX IdxLogsId ON Logs (Id), INTERLEAVE IN Users;
+ALTER TABLE Users ADD CONSTRAINT ChkId CHECK (Id > 0)`
+	schema, err := ParseDDLs(ddls)
+	require.NoError(t, err)
+
+	lossy, err := lossyStatements(ddls, schema)
+	require.NoError(t, err)
+	assert.Equal(t, []Statement{
+		{SQL: "CREATE INDEX IdxLogsId ON Logs (Id), INTERLEAVE IN Users", Pos: strings.Index(ddls, "CREATE INDEX IdxLogsId")},
+	}, lossy)
+}
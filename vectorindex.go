@@ -0,0 +1,150 @@
+package spannerdef
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// VectorIndex represents a Spanner vector index, used for approximate
+// nearest neighbor search over an embedding column
+type VectorIndex struct {
+	Name      string
+	TableName string
+	Column    string
+	Storing   []string
+	Where     string // e.g. "WHERE Embedding IS NOT NULL", or empty
+	// Options are the values of the options set on the index by name, e.g.
+	// "\"COSINE\"" for distance_type
+	Options map[string]string
+	Pos     int    // byte offset of the definition in the parsed DDLs
+	Owner   string // team from an "-- owner:" annotation, or the table's owner
+}
+
+// processCreateVectorIndex processes CREATE VECTOR INDEX statement
+func processCreateVectorIndex(schema *Schema, stmt *ast.CreateVectorIndex) {
+	index := &VectorIndex{
+		Name:      stmt.Name.Name,
+		TableName: stmt.TableName.Name,
+		Column:    stmt.ColumnName.Name,
+		Options:   make(map[string]string),
+		Pos:       int(stmt.Pos()),
+	}
+	if stmt.Storing != nil {
+		for _, column := range stmt.Storing.Columns {
+			index.Storing = append(index.Storing, column.Name)
+		}
+	}
+	if stmt.Where != nil {
+		index.Where = stmt.Where.SQL()
+	}
+	if stmt.Options != nil {
+		for _, option := range stmt.Options.Records {
+			index.Options[option.Name.Name] = option.Value.SQL()
+		}
+	}
+	schema.VectorIndexes[index.Name] = index
+}
+
+// processAlterVectorIndex folds ADD/DROP STORED COLUMN of an ALTER VECTOR
+// INDEX into the index, and reports whether it could
+func processAlterVectorIndex(schema *Schema, stmt *ast.AlterVectorIndex) bool {
+	index, ok := schema.VectorIndexes[getPathName(stmt.Name)]
+	if !ok {
+		return false
+	}
+
+	switch a := stmt.Alteration.(type) {
+	case *ast.AddStoredColumn:
+		if !slices.Contains(index.Storing, a.Name.Name) {
+			index.Storing = append(index.Storing, a.Name.Name)
+		}
+		return true
+	case *ast.DropStoredColumn:
+		index.Storing = slices.DeleteFunc(index.Storing, func(column string) bool { return column == a.Name.Name })
+		return true
+	}
+	return false
+}
+
+// generateCreateVectorIndex generates CREATE VECTOR INDEX DDL
+func generateCreateVectorIndex(index *VectorIndex) string {
+	ddl := fmt.Sprintf("CREATE VECTOR INDEX %s ON %s (%s)", quoteName(index.Name), quoteName(index.TableName), quoteName(index.Column))
+	if len(index.Storing) > 0 {
+		ddl += fmt.Sprintf(" STORING (%s)", quoteNames(index.Storing))
+	}
+	if index.Where != "" {
+		ddl += " " + index.Where
+	}
+	options := make([]string, 0, len(index.Options))
+	for _, name := range sortedKeys(index.Options) {
+		options = append(options, name+" = "+index.Options[name])
+	}
+	return ddl + " OPTIONS (" + strings.Join(options, ", ") + ")"
+}
+
+// vectorIndexesEqual compares the definitions of two vector indexes,
+// ignoring the order of their stored columns
+func vectorIndexesEqual(current, desired *VectorIndex) bool {
+	return generateCreateVectorIndex(sortedStoring(current)) == generateCreateVectorIndex(sortedStoring(desired))
+}
+
+// sortedStoring returns a copy of index with its stored columns sorted
+func sortedStoring(index *VectorIndex) *VectorIndex {
+	sorted := *index
+	sorted.Storing = slices.Sorted(slices.Values(index.Storing))
+	return &sorted
+}
+
+// generateDropVectorIndexDDLs generates DDLs to drop the vector indexes
+// that no longer exist or whose tables will be dropped
+func generateDropVectorIndexDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(current.VectorIndexes) {
+		_, exists := desired.VectorIndexes[name]
+		_, tableExists := desired.Tables[current.VectorIndexes[name].TableName]
+		if !exists || !tableExists {
+			ddls = append(ddls, fmt.Sprintf("DROP VECTOR INDEX %s", quoteName(name)))
+		}
+	}
+	return ddls
+}
+
+// generateCreateVectorIndexDDLs generates DDLs to create new vector
+// indexes
+func generateCreateVectorIndexDDLs(current, desired *Schema) []string {
+	var ddls []string
+	for _, name := range sortedKeys(desired.VectorIndexes) {
+		if _, exists := current.VectorIndexes[name]; !exists {
+			ddls = append(ddls, generateCreateVectorIndex(desired.VectorIndexes[name]))
+		}
+	}
+	return ddls
+}
+
+// generateAlterVectorIndexDDLs generates DDLs to change the stored columns
+// of existing vector indexes: drops to run before columns are dropped, and
+// adds to run after they are added. Other changes, which Spanner can't
+// make in place, are left out, see unsupportedChanges.
+func generateAlterVectorIndexDDLs(current, desired *Schema) (drops, adds []string) {
+	for _, name := range sortedKeys(desired.VectorIndexes) {
+		currentIndex, exists := current.VectorIndexes[name]
+		if !exists {
+			continue
+		}
+		desiredIndex := desired.VectorIndexes[name]
+		for _, column := range currentIndex.Storing {
+			if !slices.Contains(desiredIndex.Storing, column) {
+				drops = append(drops, fmt.Sprintf("ALTER VECTOR INDEX %s DROP STORED COLUMN %s", quoteName(name), quoteName(column)))
+			}
+		}
+		for _, column := range desiredIndex.Storing {
+			if !slices.Contains(currentIndex.Storing, column) {
+				adds = append(adds, fmt.Sprintf("ALTER VECTOR INDEX %s ADD STORED COLUMN %s", quoteName(name), quoteName(column)))
+			}
+		}
+	}
+	return drops, adds
+}
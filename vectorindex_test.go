@@ -0,0 +1,81 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDDLs_VectorIndexes(t *testing.T) {
+	schema, err := ParseDDLs(`CREATE TABLE Documents (
+  Id INT64 NOT NULL,
+  Title STRING(MAX),
+  Embedding ARRAY<FLOAT32>(vector_length=>128)
+) PRIMARY KEY (Id);
+-- owner: search
+CREATE VECTOR INDEX DocumentsByEmbedding ON Documents (Embedding) STORING (Title) WHERE Embedding IS NOT NULL
+OPTIONS (distance_type = 'COSINE', tree_depth = 2, num_leaves = 1000)`)
+	require.NoError(t, err)
+	assert.Empty(t, schema.Unsupported)
+
+	index := schema.VectorIndexes["DocumentsByEmbedding"]
+	require.NotNil(t, index)
+	assert.Equal(t, "Documents", index.TableName)
+	assert.Equal(t, "search", index.Owner)
+	// Options are sorted, and literals formatted by memefish
+	assert.Equal(t, `CREATE VECTOR INDEX DocumentsByEmbedding ON Documents (Embedding) STORING (Title) WHERE Embedding IS NOT NULL OPTIONS (distance_type = "COSINE", num_leaves = 1000, tree_depth = 2)`,
+		generateCreateVectorIndex(index))
+	// The vector length is part of the column type
+	assert.Equal(t, "ARRAY<FLOAT32>(vector_length => 128)", schema.Tables["Documents"].Columns["Embedding"].Type)
+}
+
+func TestGenerateIdempotentDDLs_VectorIndexes(t *testing.T) {
+	current := `CREATE TABLE Documents (
+  Id INT64 NOT NULL,
+  Title STRING(MAX),
+  Embedding ARRAY<FLOAT32>(vector_length=>128)
+) PRIMARY KEY (Id);
+CREATE VECTOR INDEX DocumentsByEmbedding ON Documents (Embedding) STORING (Title) WHERE Embedding IS NOT NULL OPTIONS (distance_type = 'COSINE');
+CREATE VECTOR INDEX OldIndex ON Documents (Embedding) WHERE Embedding IS NOT NULL OPTIONS (distance_type = 'COSINE')`
+	desired := `CREATE TABLE Documents (
+  Id INT64 NOT NULL,
+  Summary STRING(MAX),
+  Embedding ARRAY<FLOAT32>(vector_length=>128)
+) PRIMARY KEY (Id);
+CREATE VECTOR INDEX DocumentsByEmbedding ON Documents (Embedding) STORING (Summary) WHERE Embedding IS NOT NULL OPTIONS (distance_type = 'COSINE');
+CREATE VECTOR INDEX NewIndex ON Documents (Embedding) WHERE Embedding IS NOT NULL OPTIONS (distance_type = 'DOT_PRODUCT')`
+
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DROP VECTOR INDEX OldIndex",
+		// Stored columns are dropped before their column, and added after
+		"ALTER VECTOR INDEX DocumentsByEmbedding DROP STORED COLUMN Title",
+		"ALTER TABLE Documents DROP COLUMN Title",
+		"ALTER TABLE Documents ADD COLUMN Summary STRING(MAX)",
+		`CREATE VECTOR INDEX NewIndex ON Documents (Embedding) WHERE Embedding IS NOT NULL OPTIONS (distance_type = "DOT_PRODUCT")`,
+		"ALTER VECTOR INDEX DocumentsByEmbedding ADD STORED COLUMN Summary",
+	}, ddls)
+	assert.Empty(t, warnings)
+
+	assert.True(t, isDestructive("DROP VECTOR INDEX OldIndex", nil))
+	assert.False(t, isDestructive(ddls[len(ddls)-1], nil))
+}
+
+func TestGenerateIdempotentDDLs_VectorIndexOptionsChange(t *testing.T) {
+	table := `CREATE TABLE Documents (
+  Id INT64 NOT NULL,
+  Embedding ARRAY<FLOAT32>(vector_length=>128)
+) PRIMARY KEY (Id);
+`
+	current := table + `CREATE VECTOR INDEX DocumentsByEmbedding ON Documents (Embedding) WHERE Embedding IS NOT NULL OPTIONS (distance_type = 'COSINE')`
+	desired := table + `CREATE VECTOR INDEX DocumentsByEmbedding ON Documents (Embedding) WHERE Embedding IS NOT NULL OPTIONS (distance_type = 'EUCLIDEAN')`
+
+	// Spanner can't change the options of a vector index in place
+	ddls, warnings, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0].String(), "vector index DocumentsByEmbedding differs")
+}
@@ -0,0 +1,179 @@
+package spannerdef
+
+import (
+	"fmt"
+
+	"github.com/cloudspannerecosystem/memefish"
+	"github.com/cloudspannerecosystem/memefish/ast"
+)
+
+// View represents a Spanner view
+type View struct {
+	Name         string
+	SecurityType string // "INVOKER" or "DEFINER"
+	// Query is the query of the view as memefish formats it, so that
+	// views differing only in layout compare equal
+	Query string
+	Pos   int    // byte offset of the definition in the parsed DDLs
+	Owner string // team from an "-- owner:" annotation, if any
+}
+
+// processCreateView processes CREATE VIEW and CREATE OR REPLACE VIEW
+// statements
+func processCreateView(schema *Schema, stmt *ast.CreateView) {
+	name := getPathName(stmt.Name)
+	schema.Views[name] = &View{
+		Name:         name,
+		SecurityType: string(stmt.SecurityType),
+		Query:        stmt.Query.SQL(),
+		Pos:          int(stmt.Pos()),
+	}
+}
+
+// normalizeQuery returns query as memefish formats it, like View.Query, or
+// query as it is if it can't be parsed
+func normalizeQuery(query string) string {
+	parsed, err := memefish.ParseQuery("", query)
+	if err != nil {
+		return query
+	}
+	return parsed.SQL()
+}
+
+// generateCreateView generates the CREATE VIEW DDL of view, or CREATE OR
+// REPLACE VIEW with replace
+func generateCreateView(view *View, replace bool) string {
+	create := "CREATE VIEW"
+	if replace {
+		create = "CREATE OR REPLACE VIEW"
+	}
+	return fmt.Sprintf("%s %s SQL SECURITY %s AS %s", create, view.Name, view.SecurityType, view.Query)
+}
+
+// viewsEqual compares the definitions of two views: their SQL SECURITY and
+// their queries, ignoring layout
+func viewsEqual(current, desired *View) bool {
+	return current.SecurityType == desired.SecurityType && current.Query == desired.Query
+}
+
+// generateDropViewDDLs generates DDLs to drop the views that no longer
+// exist, views referring to them first
+func generateDropViewDDLs(current, desired *Schema) []string {
+	var dropped []*View
+	for _, name := range sortedKeys(current.Views) {
+		if _, exists := desired.Views[name]; !exists {
+			dropped = append(dropped, current.Views[name])
+		}
+	}
+
+	sorted := sortViewsByDependency(dropped)
+	ddls := make([]string, len(sorted))
+	for i, view := range sorted {
+		ddls[len(sorted)-1-i] = fmt.Sprintf("DROP VIEW %s", view.Name)
+	}
+	return ddls
+}
+
+// generateCreateViewDDLs generates DDLs to create new views and replace
+// changed ones, views referred to by others first. Changed views whose new
+// query only reads tables and views that already exist as they are needed
+// are replaced early, before tables and columns are dropped, as the old
+// query may read some of them and Spanner refuses to drop what a view
+// reads. The others are created late, after the tables and columns they
+// read are added.
+func generateCreateViewDDLs(current, desired *Schema) (early, late []string) {
+	var changed []*View
+	for _, name := range sortedKeys(desired.Views) {
+		if currentView, exists := current.Views[name]; !exists || !viewsEqual(currentView, desired.Views[name]) {
+			changed = append(changed, desired.Views[name])
+		}
+	}
+
+	replacedEarly := make(map[string]bool)
+	for _, view := range sortViewsByDependency(changed) {
+		_, exists := current.Views[view.Name]
+		if exists && readsExisting(view, current, desired, replacedEarly) {
+			replacedEarly[view.Name] = true
+			early = append(early, generateCreateView(view, true))
+		} else {
+			late = append(late, generateCreateView(view, exists))
+		}
+	}
+	return early, late
+}
+
+// readsExisting reports whether the query of view only reads tables that
+// exist in current without gaining columns in desired, and views that are
+// unchanged or in replacedEarly
+func readsExisting(view *View, current, desired *Schema, replacedEarly map[string]bool) bool {
+	for _, name := range viewReferences(view) {
+		if desiredView, ok := desired.Views[name]; ok {
+			currentView, exists := current.Views[name]
+			if !replacedEarly[name] && (!exists || !viewsEqual(currentView, desiredView)) {
+				return false
+			}
+			continue
+		}
+		currentTable, desiredTable := current.Tables[name], desired.Tables[name]
+		if currentTable == nil || desiredTable == nil {
+			return false
+		}
+		for colName := range desiredTable.Columns {
+			if _, exists := currentTable.Columns[colName]; !exists {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sortViewsByDependency sorts views so that views come after the views
+// their queries refer to
+func sortViewsByDependency(views []*View) []*View {
+	viewMap := make(map[string]*View)
+	for _, view := range views {
+		viewMap[view.Name] = view
+	}
+
+	var result []*View
+	processed := make(map[string]bool)
+	var processView func(view *View)
+	processView = func(view *View) {
+		if processed[view.Name] {
+			return
+		}
+		processed[view.Name] = true
+		for _, name := range viewReferences(view) {
+			if referenced, exists := viewMap[name]; exists {
+				processView(referenced)
+			}
+		}
+		result = append(result, view)
+	}
+
+	for _, view := range views {
+		processView(view)
+	}
+	return result
+}
+
+// viewReferences returns the names of the tables and views the query of
+// view reads from
+func viewReferences(view *View) []string {
+	query, err := memefish.ParseQuery("", view.Query)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	ast.Inspect(query, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.TableName:
+			names = append(names, n.Table.Name)
+		case *ast.PathTableExpr:
+			names = append(names, getPathName(n.Path))
+		}
+		return true
+	})
+	return names
+}
@@ -0,0 +1,136 @@
+package spannerdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDDLs_Views(t *testing.T) {
+	schema, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE VIEW UserNames SQL SECURITY INVOKER AS
+  select Id,   Name from Users`)
+	require.NoError(t, err)
+	assert.Empty(t, schema.Unsupported)
+	require.Contains(t, schema.Views, "UserNames")
+	assert.Equal(t, "INVOKER", schema.Views["UserNames"].SecurityType)
+	// Layout and keyword case are normalized
+	assert.Equal(t, "SELECT Id, Name FROM Users", schema.Views["UserNames"].Query)
+}
+
+func TestGenerateIdempotentDDLs_Views(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users;
+CREATE VIEW UserNames SQL SECURITY INVOKER AS SELECT Name FROM Users;
+CREATE VIEW Stale SQL SECURITY INVOKER AS SELECT Id FROM Users;
+CREATE VIEW StaleCount SQL SECURITY INVOKER AS SELECT COUNT(*) AS N FROM Stale`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE VIEW ActiveUserIds SQL SECURITY INVOKER AS SELECT Id FROM UserIds;
+CREATE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users;
+CREATE VIEW UserNames SQL SECURITY INVOKER AS SELECT Id, Name FROM Users`
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		// Views reading other views are dropped first
+		"DROP VIEW StaleCount",
+		"DROP VIEW Stale",
+		// Views reading only existing tables are replaced before drops
+		"CREATE OR REPLACE VIEW UserNames SQL SECURITY INVOKER AS SELECT Id, Name FROM Users",
+		"CREATE VIEW ActiveUserIds SQL SECURITY INVOKER AS SELECT Id FROM UserIds",
+	}, ddls)
+
+	// Dropping views is destructive
+	skipped := skippedDDLs(ddls, nil, false)
+	assert.Equal(t, map[int]bool{0: true, 1: true}, skipped)
+
+	ddls, _, err = GenerateIdempotentDDLs(desired, desired, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, ddls)
+}
+
+func TestGenerateIdempotentDDLs_ViewStopsReadingDroppedColumn(t *testing.T) {
+	current := `CREATE TABLE T (Id INT64 NOT NULL, X INT64) PRIMARY KEY (Id);
+CREATE VIEW V SQL SECURITY INVOKER AS SELECT Id, X FROM T`
+	desired := `CREATE TABLE T (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE VIEW V SQL SECURITY INVOKER AS SELECT Id FROM T`
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE OR REPLACE VIEW V SQL SECURITY INVOKER AS SELECT Id FROM T",
+		"ALTER TABLE T DROP COLUMN X",
+	}, ddls)
+}
+
+func TestGenerateIdempotentDDLs_ViewReadsAddedColumn(t *testing.T) {
+	current := `CREATE TABLE T (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE VIEW V SQL SECURITY INVOKER AS SELECT Id FROM T`
+	desired := `CREATE TABLE T (Id INT64 NOT NULL, X INT64) PRIMARY KEY (Id);
+CREATE VIEW V SQL SECURITY INVOKER AS SELECT Id, X FROM T`
+
+	ddls, _, err := GenerateIdempotentDDLs(desired, current, GeneratorConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE T ADD COLUMN X INT64",
+		"CREATE OR REPLACE VIEW V SQL SECURITY INVOKER AS SELECT Id, X FROM T",
+	}, ddls)
+}
+
+func TestGenerateCreateViewDDLs_Dependencies(t *testing.T) {
+	desired, err := ParseDDLs(`CREATE TABLE Users (Id INT64 NOT NULL) PRIMARY KEY (Id);
+CREATE VIEW B SQL SECURITY INVOKER AS SELECT Id FROM C;
+CREATE VIEW A SQL SECURITY INVOKER AS SELECT b.Id FROM B AS b JOIN C ON b.Id = C.Id;
+CREATE VIEW C SQL SECURITY INVOKER AS SELECT Id FROM Users`)
+	require.NoError(t, err)
+
+	early, late := generateCreateViewDDLs(&Schema{}, desired)
+	assert.Empty(t, early)
+	assert.Equal(t, []string{
+		"CREATE VIEW C SQL SECURITY INVOKER AS SELECT Id FROM Users",
+		"CREATE VIEW B SQL SECURITY INVOKER AS SELECT Id FROM C",
+		"CREATE VIEW A SQL SECURITY INVOKER AS SELECT b.Id FROM B AS b INNER JOIN C ON b.Id = C.Id",
+	}, late)
+}
+
+func TestAddIfNotExists_Views(t *testing.T) {
+	assert.Equal(t, []string{
+		"CREATE OR REPLACE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users",
+		"CREATE OR REPLACE VIEW UserNames SQL SECURITY INVOKER AS SELECT Name FROM Users",
+	}, addIfNotExists([]string{
+		"CREATE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users",
+		"CREATE OR REPLACE VIEW UserNames SQL SECURITY INVOKER AS SELECT Name FROM Users",
+	}))
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	schema, err := ParseDDLs("CREATE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users WHERE Name = 'a'")
+	require.NoError(t, err)
+	// As INFORMATION_SCHEMA.VIEWS has it
+	assert.Equal(t, schema.Views["UserIds"].Query, normalizeQuery("select Id\n  from Users\n  where Name = \"a\""))
+	assert.Equal(t, "SELECT Id FROM", normalizeQuery("SELECT Id FROM"))
+}
+
+func TestGenerateIdempotentDDLs_ViewChanges(t *testing.T) {
+	current := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE VIEW UserIds SQL SECURITY INVOKER AS SELECT Id FROM Users;
+CREATE VIEW UserNames SQL SECURITY INVOKER AS SELECT Name FROM Users`
+	desired := `CREATE TABLE Users (Id INT64 NOT NULL, Name STRING(100)) PRIMARY KEY (Id);
+CREATE VIEW UserIds SQL SECURITY DEFINER AS SELECT Id FROM Users;
+CREATE VIEW UserNames SQL SECURITY INVOKER AS select Name from Users where Name is not null`
+
+	currentSchema, err := ParseDDLs(current)
+	require.NoError(t, err)
+	desiredSchema, err := ParseDDLs(desired)
+	require.NoError(t, err)
+	ddls := GenerateDDLs(currentSchema, desiredSchema)
+	assert.Equal(t, []string{
+		"CREATE OR REPLACE VIEW UserIds SQL SECURITY DEFINER AS SELECT Id FROM Users",
+		"CREATE OR REPLACE VIEW UserNames SQL SECURITY INVOKER AS SELECT Name FROM Users WHERE Name IS NOT NULL",
+	}, ddls)
+	assert.Equal(t, []string{
+		"view UserIds SQL SECURITY differs: current INVOKER, desired DEFINER",
+		"view UserNames query differs between current and desired",
+	}, explainDDLs(ddls, currentSchema, desiredSchema, nil))
+}